@@ -17,6 +17,7 @@ import (
 	"config"
 	"xbase"
 
+	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -39,31 +40,39 @@ const (
 	ALL = "A"
 )
 
+// Event tuple, the audit event of a query.
 // easyjson:json
 // NOTE:
 // if the event changes, we must re-generate the audit_easyjson.go file by 'easyjson src/audit/audit.go' command.
-type event struct {
-	Start       time.Time     `json:"start"`        // Time the query was start.
-	End         time.Time     `json:"end"`          // Time the query was end.
-	Cost        time.Duration `json:"cost"`         // Cost.
-	User        string        `json:"user"`         // User.
-	UserHost    string        `json:"user_host"`    // User and host combination.
-	ThreadID    uint32        `json:"thread_id"`    // Thread id.
-	CommandType string        `json:"command_type"` // Type of command.
-	Argument    string        `json:"argument"`     // Full query.
-	Status      uint16        `json:"status"`       // Status of results, if 0 success, else failure.
-	QueryRows   uint64        `json:"query_rows"`   // Query rows.
+type Event struct {
+	Start       time.Time     `json:"start"`              // Time the query was start.
+	End         time.Time     `json:"end"`                // Time the query was end.
+	Cost        time.Duration `json:"cost"`               // Cost.
+	User        string        `json:"user"`               // User.
+	UserHost    string        `json:"user_host"`          // User and host combination.
+	DB          string        `json:"db"`                 // Current database of the session.
+	ThreadID    uint32        `json:"thread_id"`          // Thread id.
+	CommandType string        `json:"command_type"`       // Type of command.
+	Argument    string        `json:"argument"`           // Full query.
+	Status      uint16        `json:"status"`             // Status of results, if 0 success, else failure.
+	QueryRows   uint64        `json:"query_rows"`         // Query rows.
+	Tables      []string      `json:"tables,omitempty"`   // Tables referenced by the query, in db.table.
+	Backends    []string      `json:"backends,omitempty"` // Backends the query executed on.
+	Shards      []string      `json:"shards,omitempty"`   // Sub-tables the query executed on, in backend/db.table.
+	XID         string        `json:"xid,omitempty"`      // XA transaction id.
+	Error       string        `json:"error,omitempty"`    // Error message if failure.
 }
 
 // Audit tuple.
 type Audit struct {
-	log    *xlog.Log
-	conf   *config.AuditConfig
-	ticker *time.Ticker
-	queue  chan *event
-	done   chan bool
-	rfile  xbase.RotateFile
-	wg     sync.WaitGroup
+	log     *xlog.Log
+	conf    *config.AuditConfig
+	ticker  *time.Ticker
+	done    chan bool
+	rfile   xbase.RotateFile
+	filter  *filter
+	workers []*sinkWorker
+	wg      sync.WaitGroup
 }
 
 // NewAudit creates the new audit.
@@ -72,13 +81,13 @@ func NewAudit(log *xlog.Log, conf *config.AuditConfig) *Audit {
 		log:    log,
 		conf:   conf,
 		done:   make(chan bool),
-		queue:  make(chan *event, 1024),
+		filter: &filter{},
 		ticker: time.NewTicker(time.Duration(time.Second * 300)), // 5 minutes
 		rfile:  xbase.NewRotateFile(conf.LogDir, prefix, extension, conf.MaxSize),
 	}
 }
 
-// Init used to create the log dir, if EXISTS we do onthing.
+// Init used to create the log dir and the sinks, if EXISTS we do onthing.
 func (a *Audit) Init() error {
 	log := a.log
 
@@ -87,11 +96,19 @@ func (a *Audit) Init() error {
 		return err
 	}
 
-	a.wg.Add(1)
-	go func(audit *Audit) {
-		defer a.wg.Done()
-		a.eventConsumer()
-	}(a)
+	filter, err := newFilter(a.conf.Filters)
+	if err != nil {
+		return err
+	}
+	workers, err := a.newSinkWorkers()
+	if err != nil {
+		return err
+	}
+	a.filter = filter
+	a.workers = workers
+	for _, w := range a.workers {
+		w.start()
+	}
 
 	a.wg.Add(1)
 	go func(audit *Audit) {
@@ -102,74 +119,115 @@ func (a *Audit) Init() error {
 	return nil
 }
 
-// LogReadEvent used to handle the read-only event.
-func (a *Audit) LogReadEvent(t, user, host string, threadID uint32, query string, status uint16, affected uint64, startTime time.Time) {
-	if a.conf.Mode == ALL || a.conf.Mode == READ {
-		e := &event{
-			Start:       startTime,
-			End:         time.Now(),
-			Cost:        time.Since(startTime),
-			User:        user,
-			UserHost:    host,
-			ThreadID:    threadID,
-			CommandType: t,
-			Argument:    query,
-			Status:      status,
-			QueryRows:   affected,
+// newSinkWorkers creates the workers of the sinks, the file sink is the default.
+func (a *Audit) newSinkWorkers() ([]*sinkWorker, error) {
+	confs := a.conf.Sinks
+	if len(confs) == 0 {
+		confs = []*config.AuditSinkConfig{{Type: SinkFile}}
+	}
+
+	var workers []*sinkWorker
+	cleanup := func() {
+		for _, w := range workers {
+			w.sink.Close()
+		}
+	}
+	files := 0
+	for _, conf := range confs {
+		if conf.Type == SinkFile {
+			if files++; files > 1 {
+				cleanup()
+				return nil, errors.New("audit.file.sink.duplicate")
+			}
+		}
+		filter, err := newFilter(conf.Filters)
+		if err != nil {
+			cleanup()
+			return nil, err
 		}
-		a.queue <- e
+		sink, err := newSink(conf, a.rfile)
+		if err != nil {
+			a.log.Error("audit.new.sink[%+v].error:%v", conf, err)
+			cleanup()
+			return nil, err
+		}
+		workers = append(workers, newSinkWorker(a.log, sinkName(conf), sink, filter, conf.BufferSize))
 	}
+	return workers, nil
 }
 
-// LogWriteEvent used to handle the write event.
-func (a *Audit) LogWriteEvent(t, user, host string, threadID uint32, query string, status uint16, affected uint64, startTime time.Time) {
-	if a.conf.Mode == ALL || a.conf.Mode == WRITE {
-		e := &event{
-			Start:       startTime,
-			End:         time.Now(),
-			Cost:        time.Since(startTime),
-			User:        user,
-			UserHost:    host,
-			ThreadID:    threadID,
-			CommandType: t,
-			Argument:    query,
-			Status:      status,
-			QueryRows:   affected,
+// LogEnabled returns true if the read or write events are audited by the mode.
+func (a *Audit) LogEnabled(write bool) bool {
+	switch a.conf.Mode {
+	case ALL:
+		return true
+	case WRITE:
+		return write
+	case READ:
+		return !write
+	}
+	return false
+}
+
+// LogReadEvent used to handle the read-only event, the e.Start must be set.
+func (a *Audit) LogReadEvent(e *Event) {
+	if a.LogEnabled(false) {
+		a.logEvent(e)
+	}
+}
+
+// LogWriteEvent used to handle the write event, the e.Start must be set.
+func (a *Audit) LogWriteEvent(e *Event) {
+	if a.LogEnabled(true) {
+		a.logEvent(e)
+	}
+}
+
+func (a *Audit) logEvent(e *Event) {
+	e.End = time.Now()
+	e.Cost = e.End.Sub(e.Start)
+	if !a.filter.match(e) {
+		return
+	}
+
+	var b []byte
+	for _, w := range a.workers {
+		if !w.filter.match(e) {
+			continue
 		}
-		a.queue <- e
+		if b == nil {
+			b = encodeEvent(e)
+		}
+		w.push(b)
+	}
+}
+
+// Dropped returns the number of the events dropped by the sinks since the queue is full.
+func (a *Audit) Dropped() map[string]int64 {
+	dropped := make(map[string]int64, len(a.workers))
+	for _, w := range a.workers {
+		dropped[w.name] = w.dropped()
 	}
+	return dropped
 }
 
 // Close used to close the audit log.
 func (a *Audit) Close() {
-	// wait the queue event flush to file.
+	// wait the queue event flush to the sinks.
 	close(a.done)
-	close(a.queue)
 	a.wg.Wait()
-	a.rfile.Sync()
-	a.rfile.Close()
-	a.log.Info("audit.closed")
-}
-
-func (a *Audit) eventConsumer() {
-	for e := range a.queue {
-		a.writeEvent(e)
+	for _, w := range a.workers {
+		w.close()
 	}
+	a.log.Info("audit.closed")
 }
 
-func (a *Audit) writeEvent(e *event) {
-	log := a.log
+func encodeEvent(e *Event) []byte {
 	b, err := e.MarshalJSON()
 	if err != nil {
 		b = []byte(err.Error())
 	}
-	b = append(b, '\n')
-
-	// write
-	_, err = a.rfile.Write(b)
-	if err != nil {
-		log.Error("audit.write.file.error:%v", err)
-	}
+	return append(b, '\n')
 }
 
 func (a *Audit) purge() {
@@ -17,7 +17,7 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjsonF2c44427EncodeAudit(out *jwriter.Writer, in event) {
+func easyjsonF2c44427EncodeAudit(out *jwriter.Writer, in Event) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -55,6 +55,12 @@ func easyjsonF2c44427EncodeAudit(out *jwriter.Writer, in event) {
 		out.RawByte(',')
 	}
 	first = false
+	out.RawString("\"db\":")
+	out.String(string(in.DB))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
 	out.RawString("\"thread_id\":")
 	out.Uint32(uint32(in.ThreadID))
 	if !first {
@@ -81,11 +87,84 @@ func easyjsonF2c44427EncodeAudit(out *jwriter.Writer, in event) {
 	first = false
 	out.RawString("\"query_rows\":")
 	out.Uint64(uint64(in.QueryRows))
+	if len(in.Tables) != 0 {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"tables\":")
+		if in.Tables == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v1, v2 := range in.Tables {
+				if v1 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v2))
+			}
+			out.RawByte(']')
+		}
+	}
+	if len(in.Backends) != 0 {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"backends\":")
+		if in.Backends == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v3, v4 := range in.Backends {
+				if v3 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v4))
+			}
+			out.RawByte(']')
+		}
+	}
+	if len(in.Shards) != 0 {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"shards\":")
+		if in.Shards == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v5, v6 := range in.Shards {
+				if v5 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v6))
+			}
+			out.RawByte(']')
+		}
+	}
+	if in.XID != "" {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"xid\":")
+		out.String(string(in.XID))
+	}
+	if in.Error != "" {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"error\":")
+		out.String(string(in.Error))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v event) MarshalJSON() ([]byte, error) {
+func (v Event) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
 	easyjsonF2c44427EncodeAudit(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
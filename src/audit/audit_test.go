@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -49,13 +50,31 @@ func TestAudit(t *testing.T) {
 		threadID := uint32(i)
 		query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
 		if i%2 == 0 {
-			audit.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogWriteEvent(&Event{CommandType: typ, User: user, UserHost: host, DB: "sbtest", ThreadID: threadID, Argument: query, Start: time.Now()})
 		} else {
-			audit.LogReadEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogReadEvent(&Event{CommandType: typ, User: user, UserHost: host, DB: "sbtest", ThreadID: threadID, Argument: query, Start: time.Now()})
 		}
 	}
 }
 
+func TestAuditEncodeEvent(t *testing.T) {
+	start := time.Date(2019, 10, 21, 3, 11, 1, 0, time.UTC)
+	e := &Event{Start: start, End: start, User: "root", DB: "db1", CommandType: "SELECT", Argument: "select 1"}
+	got := string(encodeEvent(e))
+	assert.False(t, strings.Contains(got, `"tables"`), got)
+	assert.False(t, strings.Contains(got, `"xid"`), got)
+	assert.False(t, strings.Contains(got, `"error"`), got)
+
+	e.Tables = []string{"db1.t1", "db2.t2"}
+	e.Backends = []string{"backend1"}
+	e.Shards = []string{"backend1/db1.t1_0000"}
+	e.XID = "RXID-20191021031101-1"
+	e.Error = "mock.error"
+	got = string(encodeEvent(e))
+	want := `"query_rows":0,"tables":["db1.t1","db2.t2"],"backends":["backend1"],"shards":["backend1/db1.t1_0000"],"xid":"RXID-20191021031101-1","error":"mock.error"}` + "\n"
+	assert.True(t, strings.HasSuffix(got, want), got)
+}
+
 func TestAuditMultiThread(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -85,9 +104,9 @@ func TestAuditMultiThread(t *testing.T) {
 				threadID := uint32(i)
 				query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
 				if i%2 == 0 {
-					a.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+					a.LogWriteEvent(&Event{CommandType: typ, User: user, UserHost: host, DB: "sbtest", ThreadID: threadID, Argument: query, Start: time.Now()})
 				} else {
-					a.LogReadEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+					a.LogReadEvent(&Event{CommandType: typ, User: user, UserHost: host, DB: "sbtest", ThreadID: threadID, Argument: query, Start: time.Now()})
 				}
 			}
 			wait.Done()
@@ -122,9 +141,9 @@ func TestPurge(t *testing.T) {
 		threadID := uint32(i)
 		query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
 		if i%2 == 0 {
-			audit.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogWriteEvent(&Event{CommandType: typ, User: user, UserHost: host, DB: "sbtest", ThreadID: threadID, Argument: query, Start: time.Now()})
 		} else {
-			audit.LogReadEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogReadEvent(&Event{CommandType: typ, User: user, UserHost: host, DB: "sbtest", ThreadID: threadID, Argument: query, Start: time.Now()})
 		}
 	}
 	// first the close the audit to stop the event writing.
@@ -170,7 +189,7 @@ func TestAuditBench(t *testing.T) {
 			host := "127.0.0.1:8899"
 			threadID := uint32(i)
 			query := "select a,b,cd from table1 where a=b and c=d and e=d group by id order\n by desc"
-			audit.LogWriteEvent(typ, user, host, threadID, query, 0, 0, time.Now())
+			audit.LogWriteEvent(&Event{CommandType: typ, User: user, UserHost: host, DB: "sbtest", ThreadID: threadID, Argument: query, Start: time.Now()})
 		}
 		took := time.Since(now)
 		fmt.Printf(" LOOP\t%v COST %v, avg:%v/s\n", N, took, (int64(N)/(took.Nanoseconds()/1e6))*1000)
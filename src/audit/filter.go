@@ -0,0 +1,119 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"strings"
+	"time"
+
+	"config"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// StatusOK enum.
+	StatusOK = "ok"
+
+	// StatusError enum.
+	StatusError = "error"
+)
+
+// rule is the compiled AuditFilterConfig.
+type rule struct {
+	users        map[string]bool
+	databases    map[string]bool
+	commandTypes map[string]bool
+	status       string
+	minLatency   time.Duration
+}
+
+func toSet(values []string, fold bool) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if fold {
+			v = strings.ToUpper(v)
+		}
+		set[v] = true
+	}
+	return set
+}
+
+func newRule(conf *config.AuditFilterConfig) (*rule, error) {
+	switch conf.Status {
+	case "", StatusOK, StatusError:
+	default:
+		return nil, errors.Errorf("audit.filter.unsupported.status[%s]", conf.Status)
+	}
+	if conf.MinLatency < 0 {
+		return nil, errors.Errorf("audit.filter.min.latency[%d].cant.be.negative", conf.MinLatency)
+	}
+	return &rule{
+		users:        toSet(conf.Users, false),
+		databases:    toSet(conf.Databases, false),
+		commandTypes: toSet(conf.CommandTypes, true),
+		status:       conf.Status,
+		minLatency:   time.Duration(conf.MinLatency) * time.Millisecond,
+	}, nil
+}
+
+func (r *rule) match(e *Event) bool {
+	if r.users != nil && !r.users[e.User] {
+		return false
+	}
+	if r.databases != nil && !r.databases[e.DB] {
+		return false
+	}
+	if r.commandTypes != nil && !r.commandTypes[strings.ToUpper(e.CommandType)] {
+		return false
+	}
+	switch r.status {
+	case StatusOK:
+		if e.Status != 0 {
+			return false
+		}
+	case StatusError:
+		if e.Status == 0 {
+			return false
+		}
+	}
+	return e.Cost >= r.minLatency
+}
+
+// filter matches the event if any of the rules matches, the empty filter matches all.
+type filter struct {
+	rules []*rule
+}
+
+func newFilter(confs []*config.AuditFilterConfig) (*filter, error) {
+	f := &filter{}
+	for _, conf := range confs {
+		r, err := newRule(conf)
+		if err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, r)
+	}
+	return f, nil
+}
+
+func (f *filter) match(e *Event) bool {
+	if len(f.rules) == 0 {
+		return true
+	}
+	for _, r := range f.rules {
+		if r.match(e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditFilter(t *testing.T) {
+	confs := []*config.AuditFilterConfig{
+		{Users: []string{"root", "admin"}},
+		{Databases: []string{"db1"}, CommandTypes: []string{"delete", "UPDATE"}},
+		{Status: StatusError, MinLatency: 100},
+	}
+	f, err := newFilter(confs)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		e    *Event
+		want bool
+	}{
+		{&Event{User: "root"}, true},
+		{&Event{User: "u1", DB: "db1", CommandType: "DELETE"}, true},
+		{&Event{User: "u1", DB: "db1", CommandType: "SELECT"}, false},
+		{&Event{User: "u1", DB: "db2", CommandType: "UPDATE"}, false},
+		{&Event{User: "u1", Status: 1, Cost: time.Second}, true},
+		{&Event{User: "u1", Status: 1, Cost: time.Millisecond}, false},
+		{&Event{User: "u1", Status: 0, Cost: time.Second}, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, f.match(test.e), "%+v", test.e)
+	}
+
+	// Status ok.
+	{
+		f, err := newFilter([]*config.AuditFilterConfig{{Status: StatusOK}})
+		assert.Nil(t, err)
+		assert.True(t, f.match(&Event{}))
+		assert.False(t, f.match(&Event{Status: 1}))
+	}
+
+	// Empty filter matches all.
+	{
+		f, err := newFilter(nil)
+		assert.Nil(t, err)
+		assert.True(t, f.match(&Event{}))
+	}
+}
+
+func TestAuditFilterError(t *testing.T) {
+	confs := [][]*config.AuditFilterConfig{
+		{{Status: "xx"}},
+		{{MinLatency: -1}},
+	}
+	for _, conf := range confs {
+		_, err := newFilter(conf)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,235 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/http"
+	"time"
+
+	"config"
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// SinkFile enum.
+	SinkFile = "file"
+
+	// SinkSyslog enum.
+	SinkSyslog = "syslog"
+
+	// SinkTCP enum.
+	SinkTCP = "tcp"
+
+	// SinkHTTP enum.
+	SinkHTTP = "http"
+)
+
+const (
+	defaultSinkBufferSize = 1024
+	defaultSyslogTag      = "radon-audit"
+	sinkTimeout           = 5 * time.Second
+)
+
+// Sink is the destination of the audit events.
+// The data passed to Write is one or more events in json, each ends with '\n'.
+type Sink interface {
+	Write(data []byte) error
+	Close() error
+}
+
+// fileSink writes the events to the rotate files.
+type fileSink struct {
+	rfile xbase.RotateFile
+}
+
+func (s *fileSink) Write(data []byte) error {
+	_, err := s.rfile.Write(data)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.rfile.Sync()
+	s.rfile.Close()
+	return nil
+}
+
+// syslogSink writes the events to the syslog, one event per message.
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink(conf *config.AuditSinkConfig) (*syslogSink, error) {
+	tag := conf.Tag
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+	writer, err := syslog.Dial(conf.Network, conf.Address, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) Write(data []byte) error {
+	for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'}) {
+		if _, err := s.writer.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
+
+// tcpSink streams the events to the tcp address in json lines,
+// the connection is re-established on the next write if broken.
+type tcpSink struct {
+	address string
+	conn    net.Conn
+}
+
+func (s *tcpSink) Write(data []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.address, sinkTimeout)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+	if _, err := s.conn.Write(data); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (s *tcpSink) Close() error {
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// httpSink posts the events to the url in json lines.
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Write(data []byte) error {
+	resp, err := s.client.Post(s.url, "application/x-ndjson", bytes.NewReader(data))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("audit.http.sink[%s].status.code[%d]", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
+
+// newSink creates the sink by the config, the file sink shares the audit rotate file.
+func newSink(conf *config.AuditSinkConfig, rfile xbase.RotateFile) (Sink, error) {
+	switch conf.Type {
+	case SinkFile:
+		return &fileSink{rfile: rfile}, nil
+	case SinkSyslog:
+		return newSyslogSink(conf)
+	case SinkTCP:
+		if conf.Address == "" {
+			return nil, errors.New("audit.tcp.sink.address.cant.be.empty")
+		}
+		return &tcpSink{address: conf.Address}, nil
+	case SinkHTTP:
+		if conf.Address == "" {
+			return nil, errors.New("audit.http.sink.address.cant.be.empty")
+		}
+		return &httpSink{url: conf.Address, client: &http.Client{Timeout: sinkTimeout}}, nil
+	}
+	return nil, errors.Errorf("audit.unsupported.sink.type[%s]", conf.Type)
+}
+
+// sinkWorker writes the events to the sink asynchronously.
+// The events are buffered in the bounded queue, they are dropped if the queue is full,
+// so a slow sink never blocks the queries.
+type sinkWorker struct {
+	log     *xlog.Log
+	name    string
+	sink    Sink
+	filter  *filter
+	batcher *xbase.Batcher
+}
+
+func newSinkWorker(log *xlog.Log, name string, sink Sink, filter *filter, bufferSize int) *sinkWorker {
+	if bufferSize <= 0 {
+		bufferSize = defaultSinkBufferSize
+	}
+	w := &sinkWorker{
+		log:    log,
+		name:   name,
+		sink:   sink,
+		filter: filter,
+	}
+	w.batcher = xbase.NewBatcher(log, fmt.Sprintf("audit.sink[%s]", name), bufferSize, w.write)
+	return w
+}
+
+func (w *sinkWorker) start() {
+	w.batcher.Start()
+}
+
+// push used to buffer the event without blocking.
+func (w *sinkWorker) push(data []byte) {
+	w.batcher.Push(data)
+}
+
+// dropped returns the number of the events dropped since the queue is full.
+func (w *sinkWorker) dropped() int64 {
+	return w.batcher.Dropped()
+}
+
+// write used to write the batch of the events to the sink.
+func (w *sinkWorker) write(events []interface{}) {
+	var buf bytes.Buffer
+	for _, data := range events {
+		buf.Write(data.([]byte))
+	}
+	if err := w.sink.Write(buf.Bytes()); err != nil {
+		w.log.Error("audit.sink[%s].write.error:%v", w.name, err)
+	}
+}
+
+func (w *sinkWorker) close() {
+	w.batcher.Close()
+	if err := w.sink.Close(); err != nil {
+		w.log.Error("audit.sink[%s].close.error:%v", w.name, err)
+	}
+}
+
+func sinkName(conf *config.AuditSinkConfig) string {
+	if conf.Address == "" {
+		return conf.Type
+	}
+	return fmt.Sprintf("%s:%s", conf.Type, conf.Address)
+}
@@ -0,0 +1,241 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package audit
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestAuditSinkTCP(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	lines := make(chan string, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	conf := &config.AuditConfig{
+		Mode:        ALL,
+		MaxSize:     102400,
+		ExpireHours: 1,
+		LogDir:      tmpDir,
+		Sinks: []*config.AuditSinkConfig{
+			{
+				Type:    SinkTCP,
+				Address: ln.Addr().String(),
+				Filters: []*config.AuditFilterConfig{{Users: []string{"root"}}},
+			},
+		},
+	}
+	audit := NewAudit(log, conf)
+	err = audit.Init()
+	assert.Nil(t, err)
+
+	audit.LogReadEvent(&Event{CommandType: "SELECT", User: "u1", UserHost: "127.0.0.1:8899", DB: "db1", ThreadID: 1, Argument: "select 1", Start: time.Now()})
+	audit.LogWriteEvent(&Event{CommandType: "DELETE", User: "root", UserHost: "127.0.0.1:8899", DB: "db1", ThreadID: 2, Argument: "delete from t1", QueryRows: 1, Start: time.Now()})
+	audit.Close()
+
+	select {
+	case line := <-lines:
+		assert.True(t, strings.Contains(line, `"user":"root"`), line)
+		assert.True(t, strings.Contains(line, `"db":"db1"`), line)
+		assert.True(t, strings.Contains(line, `"argument":"delete from t1"`), line)
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "tcp.sink.timeout")
+	}
+	assert.Equal(t, 0, len(lines))
+}
+
+func TestAuditSinkHTTP(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+
+	var mu sync.Mutex
+	var bodys []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodys = append(bodys, string(body))
+		mu.Unlock()
+	}))
+	defer svr.Close()
+
+	conf := &config.AuditConfig{
+		Mode:        WRITE,
+		MaxSize:     102400,
+		ExpireHours: 1,
+		LogDir:      tmpDir,
+		Filters:     []*config.AuditFilterConfig{{Status: StatusError}},
+		Sinks: []*config.AuditSinkConfig{
+			{Type: SinkFile},
+			{Type: SinkHTTP, Address: svr.URL},
+		},
+	}
+	audit := NewAudit(log, conf)
+	err := audit.Init()
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		audit.LogWriteEvent(&Event{CommandType: "INSERT", User: "u1", UserHost: "127.0.0.1:8899", DB: "db1", ThreadID: uint32(i), Argument: "insert into t1 values(1)", Status: uint16(i % 2), Start: time.Now()})
+	}
+	audit.LogReadEvent(&Event{CommandType: "SELECT", User: "u1", UserHost: "127.0.0.1:8899", DB: "db1", ThreadID: 1, Argument: "select 1", Status: 1, Start: time.Now()})
+	audit.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	events := strings.Split(strings.TrimSpace(strings.Join(bodys, "")), "\n")
+	assert.Equal(t, 5, len(events))
+	for _, e := range events {
+		assert.True(t, strings.Contains(e, `"status":1`), e)
+	}
+	assert.Equal(t, map[string]int64{"file": 0, "http:" + svr.URL: 0}, audit.Dropped())
+}
+
+func TestAuditSinkSyslog(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	sink, err := newSink(&config.AuditSinkConfig{Type: SinkSyslog, Network: "udp", Address: conn.LocalAddr().String()}, nil)
+	assert.Nil(t, err)
+	worker := newSinkWorker(log, "syslog", sink, &filter{}, 0)
+	worker.start()
+	worker.push([]byte("{\"a\":1}\n"))
+	worker.push([]byte("{\"b\":2}\n"))
+	worker.close()
+
+	// One message per event.
+	buf := make([]byte, 1024)
+	for _, want := range []string{`{"a":1}`, `{"b":2}`} {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		n, _, err := conn.ReadFrom(buf)
+		assert.Nil(t, err)
+		msg := string(buf[:n])
+		assert.True(t, strings.Contains(msg, defaultSyslogTag), msg)
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(msg), want), msg)
+	}
+}
+
+// blockSink blocks the writes until it's released.
+type blockSink struct {
+	release chan bool
+}
+
+func (s *blockSink) Write(data []byte) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockSink) Close() error {
+	return nil
+}
+
+func TestAuditSinkDropped(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	sink := &blockSink{release: make(chan bool)}
+	worker := newSinkWorker(log, "block", sink, &filter{}, 2)
+	worker.start()
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 10; i++ {
+			worker.push([]byte("x\n"))
+		}
+		close(done)
+	}()
+
+	// The pushes never block on the slow sink.
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "push.blocked")
+	}
+	assert.True(t, worker.dropped() >= 7)
+	close(sink.release)
+	worker.close()
+}
+
+func TestAuditSinkError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_audit_", log)
+	defer os.RemoveAll(tmpDir)
+
+	sinks := [][]*config.AuditSinkConfig{
+		{{Type: "xx"}},
+		{{Type: SinkTCP}},
+		{{Type: SinkHTTP}},
+		{{Type: SinkFile}, {Type: SinkFile}},
+		{{Type: SinkFile, Filters: []*config.AuditFilterConfig{{Status: "xx"}}}},
+	}
+	for _, sink := range sinks {
+		conf := &config.AuditConfig{
+			Mode:    ALL,
+			MaxSize: 102400,
+			LogDir:  tmpDir,
+			Sinks:   sink,
+		}
+		audit := NewAudit(log, conf)
+		err := audit.Init()
+		assert.NotNil(t, err)
+	}
+
+	// Global filter error.
+	{
+		conf := &config.AuditConfig{
+			Mode:    ALL,
+			MaxSize: 102400,
+			LogDir:  tmpDir,
+			Filters: []*config.AuditFilterConfig{{MinLatency: -1}},
+		}
+		audit := NewAudit(log, conf)
+		err := audit.Init()
+		assert.NotNil(t, err)
+	}
+
+	// Unreachable tcp sink, the error is logged.
+	{
+		sink, err := newSink(&config.AuditSinkConfig{Type: SinkTCP, Address: "127.0.0.1:1"}, nil)
+		assert.Nil(t, err)
+		err = sink.Write([]byte("x\n"))
+		assert.NotNil(t, err)
+		sink.Close()
+	}
+}
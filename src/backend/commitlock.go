@@ -0,0 +1,264 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	txnCounterFenceLock    = "#fence.lock"
+	txnCounterFenceExpired = "#fence.expired"
+)
+
+// CommitFence is used to fence the reads of all the radon peers while the XA COMMIT,
+// so that the reads on any peer see the cross-shard commit atomically.
+// Fence must acquire the commit locks of the backends on the peers(including itself), the commit
+// is rolled back if the Fence returns error.
+// Leased must return nil only if the reads on this peer are still fenced by all the peers.
+type CommitFence interface {
+	Fence(xid string, backends []string) error
+	Unfence(xid string, backends []string)
+	Leased() error
+}
+
+// fence tuple, the commit locks held by the xid for the peer.
+type fence struct {
+	locks []*sync.RWMutex
+	timer *time.Timer
+	// unfenced is set if the FenceUnlock comes while the locks are still acquiring.
+	unfenced bool
+}
+
+// commitLocks returns the commit locks of the backends in the order of backend name,
+// the locks must be acquired in this order to avoid deadlock.
+func (mgr *TxnManager) commitLocks(backends []string) []*sync.RWMutex {
+	names := make([]string, 0, len(backends))
+	seen := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		if !seen[backend] {
+			seen[backend] = true
+			names = append(names, backend)
+		}
+	}
+	sort.Strings(names)
+
+	mgr.locksMu.Lock()
+	defer mgr.locksMu.Unlock()
+	locks := make([]*sync.RWMutex, 0, len(names))
+	for _, name := range names {
+		lock, ok := mgr.locks[name]
+		if !ok {
+			lock = &sync.RWMutex{}
+			mgr.locks[name] = lock
+		}
+		locks = append(locks, lock)
+	}
+	return locks
+}
+
+// SetCommitFence used to set the fence of the peers.
+func (mgr *TxnManager) SetCommitFence(fence CommitFence) {
+	mgr.locksMu.Lock()
+	defer mgr.locksMu.Unlock()
+	mgr.fence = fence
+}
+
+func (mgr *TxnManager) commitFence() CommitFence {
+	mgr.locksMu.Lock()
+	defer mgr.locksMu.Unlock()
+	return mgr.fence
+}
+
+// CommitLock used to acquire the commit locks of the backends which the xid commits on.
+// The commits on the different backends can proceed in parallel.
+func (mgr *TxnManager) CommitLock(xid string, backends []string) error {
+	if fence := mgr.commitFence(); fence != nil {
+		return fence.Fence(xid, backends)
+	}
+	for _, lock := range mgr.commitLocks(backends) {
+		lock.Lock()
+	}
+	return nil
+}
+
+// CommitUnlock used to release the commit locks of the backends.
+func (mgr *TxnManager) CommitUnlock(xid string, backends []string) {
+	if fence := mgr.commitFence(); fence != nil {
+		fence.Unfence(xid, backends)
+		return
+	}
+	for _, lock := range mgr.commitLocks(backends) {
+		lock.Unlock()
+	}
+}
+
+// CommitRLock used to acquire the read locks of the backends which the read-txn reads on,
+// it fails if the reads of this peer may be not fenced by the others.
+func (mgr *TxnManager) CommitRLock(backends []string) error {
+	if fence := mgr.commitFence(); fence != nil {
+		if err := fence.Leased(); err != nil {
+			return err
+		}
+	}
+	for _, lock := range mgr.commitLocks(backends) {
+		lock.RLock()
+	}
+	return nil
+}
+
+// CommitRUnlock used to release the read locks of the backends.
+func (mgr *TxnManager) CommitRUnlock(backends []string) {
+	for _, lock := range mgr.commitLocks(backends) {
+		lock.RUnlock()
+	}
+}
+
+// gateLocks returns the gates of the 'db.table's in order, the gates must be acquired in this order to avoid deadlock.
+func (mgr *TxnManager) gateLocks(tables []string) []*sync.RWMutex {
+	names := make([]string, 0, len(tables))
+	seen := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		if !seen[table] {
+			seen[table] = true
+			names = append(names, table)
+		}
+	}
+	sort.Strings(names)
+
+	gates := make([]*sync.RWMutex, 0, len(names))
+	for _, name := range names {
+		mgr.gatesMu.RLock()
+		gate, ok := mgr.gates[name]
+		mgr.gatesMu.RUnlock()
+		if !ok {
+			mgr.gatesMu.Lock()
+			if gate, ok = mgr.gates[name]; !ok {
+				gate = &sync.RWMutex{}
+				mgr.gates[name] = gate
+			}
+			mgr.gatesMu.Unlock()
+		}
+		gates = append(gates, gate)
+	}
+	return gates
+}
+
+// GateRLock used to acquire the read locks of the gates of the 'db.table's which the DML writes or reads,
+// the DML waits while any of the tables is cutting over. The returned func must be called after the DML.
+func (mgr *TxnManager) GateRLock(tables []string) func() {
+	gates := mgr.gateLocks(tables)
+	for _, gate := range gates {
+		gate.RLock()
+	}
+	return func() {
+		for _, gate := range gates {
+			gate.RUnlock()
+		}
+	}
+}
+
+// FenceLock used to acquire the commit locks of the backends for the xid committed by the peer.
+// If the lease > 0, the locks are released after the lease in case the peer is gone.
+func (mgr *TxnManager) FenceLock(xid string, backends []string, lease time.Duration) error {
+	return mgr.fenceLock(xid, mgr.commitLocks(backends), lease)
+}
+
+// GateLock used to acquire the gates of the 'db.table's for the xid of the cutover by the peer, it returns after the
+// DML in progress on the tables finish. The gates are held and released as the commit locks of the fence.
+func (mgr *TxnManager) GateLock(xid string, tables []string, lease time.Duration) error {
+	return mgr.fenceLock(xid, mgr.gateLocks(tables), lease)
+}
+
+func (mgr *TxnManager) fenceLock(xid string, locks []*sync.RWMutex, lease time.Duration) error {
+	log := mgr.log
+	txnCounters.Add(txnCounterFenceLock, 1)
+
+	mgr.locksMu.Lock()
+	if _, ok := mgr.fences[xid]; ok {
+		mgr.locksMu.Unlock()
+		return errors.Errorf("txnmgr.fence.xid[%s].duplicate", xid)
+	}
+	f := &fence{}
+	mgr.fences[xid] = f
+	mgr.locksMu.Unlock()
+
+	for _, lock := range locks {
+		lock.Lock()
+	}
+
+	mgr.locksMu.Lock()
+	defer mgr.locksMu.Unlock()
+	f.locks = locks
+	if f.unfenced {
+		delete(mgr.fences, xid)
+		for _, lock := range locks {
+			lock.Unlock()
+		}
+		return nil
+	}
+	if lease > 0 {
+		f.timer = time.AfterFunc(lease, func() {
+			log.Warning("txnmgr.fence.xid[%s].lease[%v].expired", xid, lease)
+			txnCounters.Add(txnCounterFenceExpired, 1)
+			mgr.FenceUnlock(xid)
+		})
+	}
+	return nil
+}
+
+// FenceRenew used to extend the lease of the commit locks held by the xid, the coordinator
+// renews the lease until the commit completes.
+func (mgr *TxnManager) FenceRenew(xid string, lease time.Duration) error {
+	mgr.locksMu.Lock()
+	defer mgr.locksMu.Unlock()
+
+	f, ok := mgr.fences[xid]
+	if !ok {
+		return errors.Errorf("txnmgr.fence.xid[%s].not.found", xid)
+	}
+	// Still acquiring, the lease starts once acquired.
+	if f.timer == nil {
+		return nil
+	}
+	if !f.timer.Stop() {
+		return errors.Errorf("txnmgr.fence.xid[%s].lease.expired", xid)
+	}
+	f.timer.Reset(lease)
+	return nil
+}
+
+// FenceUnlock used to release the commit locks held by the xid.
+func (mgr *TxnManager) FenceUnlock(xid string) {
+	mgr.locksMu.Lock()
+	f, ok := mgr.fences[xid]
+	if !ok {
+		mgr.locksMu.Unlock()
+		return
+	}
+	if f.locks == nil {
+		// Still acquiring, the locks are released once acquired.
+		f.unfenced = true
+		mgr.locksMu.Unlock()
+		return
+	}
+	delete(mgr.fences, xid)
+	mgr.locksMu.Unlock()
+
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	for _, lock := range f.locks {
+		lock.Unlock()
+	}
+}
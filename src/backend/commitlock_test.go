@@ -0,0 +1,252 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// acquired returns true if the f returns in time.
+func acquired(f func()) bool {
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func TestCommitLock(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	mgr := NewTxnManager(log)
+
+	// Commits on the disjoint backends proceed in parallel.
+	mgr.CommitLock("xid1", []string{"backend1", "backend0"})
+	assert.True(t, acquired(func() { mgr.CommitLock("xid2", []string{"backend2"}) }))
+
+	// Reads on the committing backends are blocked.
+	assert.True(t, acquired(func() { mgr.CommitRLock([]string{"backend3"}) }))
+	mgr.CommitRUnlock([]string{"backend3"})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	blocked := acquired(func() {
+		defer wg.Done()
+		mgr.CommitRLock([]string{"backend0", "backend3"})
+	})
+	assert.False(t, blocked)
+
+	mgr.CommitUnlock("xid1", []string{"backend0", "backend1", "backend0"})
+	wg.Wait()
+	mgr.CommitRUnlock([]string{"backend0", "backend3"})
+	mgr.CommitUnlock("xid2", []string{"backend2"})
+}
+
+func TestCommitFenceLock(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	mgr := NewTxnManager(log)
+
+	// Fence without lease.
+	{
+		err := mgr.FenceLock("xid1", []string{"backend0"}, 0)
+		assert.Nil(t, err)
+
+		err = mgr.FenceLock("xid1", []string{"backend0"}, 0)
+		assert.NotNil(t, err)
+
+		assert.False(t, acquired(func() {
+			mgr.CommitRLock([]string{"backend0"})
+			mgr.CommitRUnlock([]string{"backend0"})
+		}))
+		mgr.FenceUnlock("xid1")
+		// Unlock twice.
+		mgr.FenceUnlock("xid1")
+		assert.True(t, acquired(func() {
+			mgr.CommitRLock([]string{"backend0"})
+			mgr.CommitRUnlock([]string{"backend0"})
+		}))
+	}
+
+	// Fence with lease, released after the lease expired.
+	{
+		err := mgr.FenceLock("xid2", []string{"backend0"}, 50*time.Millisecond)
+		assert.Nil(t, err)
+		time.Sleep(200 * time.Millisecond)
+		assert.True(t, acquired(func() {
+			mgr.CommitRLock([]string{"backend0"})
+			mgr.CommitRUnlock([]string{"backend0"})
+		}))
+		// Unlock after expired.
+		mgr.FenceUnlock("xid2")
+	}
+
+	// Unfence while acquiring.
+	{
+		mgr.CommitRLock([]string{"backend0"})
+		done := make(chan error)
+		go func() {
+			done <- mgr.FenceLock("xid3", []string{"backend0"}, 0)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		mgr.FenceUnlock("xid3")
+		mgr.CommitRUnlock([]string{"backend0"})
+		assert.Nil(t, <-done)
+		assert.True(t, acquired(func() { mgr.CommitLock("xid4", []string{"backend0"}) }))
+		mgr.CommitUnlock("xid4", []string{"backend0"})
+	}
+}
+
+func TestGateLock(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	mgr := NewTxnManager(log)
+
+	// The DML in progress delays the cutover.
+	release := mgr.GateRLock([]string{"db.t1", "db.t2"})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	assert.False(t, acquired(func() {
+		defer wg.Done()
+		assert.Nil(t, mgr.GateLock("xid1", []string{"db.t1"}, 0))
+	}))
+	release()
+	wg.Wait()
+
+	// The DML of the gated table wait, the others not.
+	assert.True(t, acquired(func() { mgr.GateRLock([]string{"db.t2"})() }))
+	assert.False(t, acquired(func() { mgr.GateRLock([]string{"db.t2", "db.t1", "db.t1"})() }))
+	// The commits are not blocked by the gates.
+	assert.True(t, acquired(func() { mgr.CommitRLock([]string{"backend0"}) }))
+	mgr.CommitRUnlock([]string{"backend0"})
+
+	mgr.FenceUnlock("xid1")
+	assert.True(t, acquired(func() { mgr.GateRLock([]string{"db.t1"})() }))
+}
+
+type mockFence struct {
+	mu       sync.Mutex
+	err      error
+	leaseErr error
+	fenced   map[string][]string
+	unfenced map[string][]string
+}
+
+func (f *mockFence) Fence(xid string, backends []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.fenced[xid] = backends
+	return nil
+}
+
+func (f *mockFence) Unfence(xid string, backends []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unfenced[xid] = backends
+}
+
+func (f *mockFence) Leased() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.leaseErr
+}
+
+func TestCommitFence(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+	fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+
+	fence := &mockFence{fenced: make(map[string][]string), unfenced: make(map[string][]string)}
+	txnMgr.SetCommitFence(fence)
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+	err = txn.CommitScatter()
+	assert.Nil(t, err)
+
+	xid := txn.XID()
+	assert.Equal(t, 2, len(fence.fenced[xid]))
+	assert.Equal(t, fence.fenced[xid], fence.unfenced[xid])
+
+	// The local locks are not taken by the fenced commit.
+	for back := range backends {
+		assert.True(t, acquired(func() { txnMgr.CommitRLock([]string{back}) }))
+		txnMgr.CommitRUnlock([]string{back})
+	}
+
+	// The reads fail without the read lease.
+	fence.mu.Lock()
+	fence.leaseErr = errors.New("mock.lease.expired")
+	fence.mu.Unlock()
+	assert.NotNil(t, txnMgr.CommitRLock([]string{"backend0"}))
+}
+
+func TestCommitFenceError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+	fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+
+	fence := &mockFence{err: errors.New("mock.fence.error"), fenced: make(map[string][]string), unfenced: make(map[string][]string)}
+	txnMgr.SetCommitFence(fence)
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+	err = txn.CommitScatter()
+	assert.NotNil(t, err)
+
+	// The txn is rolled back without the commit decision.
+	xid := txn.XID()
+	assert.False(t, txn.xaLogged)
+	assert.Equal(t, 2, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%v'", xid)))
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("XA COMMIT '%v'", xid)))
+	assert.Equal(t, 0, len(fence.unfenced))
+}
+
+func TestFenceRenew(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	mgr := NewTxnManager(log)
+
+	assert.NotNil(t, mgr.FenceRenew("xid1", time.Second))
+	err := mgr.FenceLock("xid1", []string{"backend0"}, 50*time.Millisecond)
+	assert.Nil(t, err)
+	// Still fenced after 90ms.
+	for i := 0; i < 3; i++ {
+		time.Sleep(30 * time.Millisecond)
+		assert.Nil(t, mgr.FenceRenew("xid1", 50*time.Millisecond))
+	}
+
+	// Released by the lease once the renewal stops.
+	time.Sleep(100 * time.Millisecond)
+	assert.NotNil(t, mgr.FenceRenew("xid1", 50*time.Millisecond))
+	assert.True(t, acquired(func() { mgr.CommitRLock([]string{"backend0"}) }))
+	mgr.CommitRUnlock([]string{"backend0"})
+}
@@ -13,6 +13,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,14 +42,20 @@ type Connection interface {
 	Closed() bool
 	LastErr() error
 	UseDB(string) error
+	SetSessionVars(vars map[string]string) error
 	Kill(string) error
 	Recycle()
 	Address() string
 	SetTimestamp(int64)
 	Timestamp() int64
+	Created() int64
 	Execute(string) (*sqltypes.Result, error)
 	ExecuteStreamFetch(string) (driver.Rows, error)
 	ExecuteWithLimits(query string, timeout int, maxmem int) (*sqltypes.Result, error)
+
+	// attach used to mark the connection holds a slot of the pool, the slot is released by Close.
+	attach()
+	attached() bool
 }
 
 type connection struct {
@@ -62,7 +70,10 @@ type connection struct {
 	lastErr      error // If lastErr is not nil, this connection should be closed.
 	killed       sync2.AtomicBool
 	driver       driver.Conn
-	timestamp    int64 // Recycle timestamp, in seconds.
+	timestamp    int64             // Recycle timestamp, in seconds.
+	created      int64             // Dial timestamp, in seconds.
+	vars         map[string]string // The session variables applied to this connection.
+	slot         sync2.AtomicInt32
 	counters     *stats.Counters
 }
 
@@ -92,6 +103,7 @@ func (c *connection) Dial() error {
 		return errors.New("Server maybe lost, please try again")
 	}
 	c.connectionID = c.driver.ConnectionID()
+	c.created = time.Now().Unix()
 	monitor.BackendConnectionInc(c.address)
 	return nil
 }
@@ -118,6 +130,72 @@ func (c *connection) UseDB(db string) error {
 	return nil
 }
 
+// SetSessionVars used to apply the session variables(name->value in sql) to the connection if they are changed.
+// The variables applied before but not in the vars are reset to the default, so the connection can be shared by the sessions.
+func (c *connection) SetSessionVars(vars map[string]string) error {
+	if len(vars) == 0 && len(c.vars) == 0 {
+		return nil
+	}
+
+	var charsets, others []string
+	add := func(name string, value string) {
+		switch name {
+		case "names", "charset":
+			charsets = append(charsets, sessionVarExpr(name, value, c.charset))
+		default:
+			others = append(others, sessionVarExpr(name, value, c.charset))
+		}
+	}
+	for name := range c.vars {
+		if _, ok := vars[name]; !ok {
+			add(name, "")
+		}
+	}
+	for name, value := range vars {
+		if old, ok := c.vars[name]; !ok || old != value {
+			add(name, value)
+		}
+	}
+	if len(charsets) == 0 && len(others) == 0 {
+		return nil
+	}
+
+	// The charset first, the character_set_xx variables may be overwritten by it.
+	sort.Strings(charsets)
+	sort.Strings(others)
+	query := "SET " + strings.Join(append(charsets, others...), ", ")
+	if _, err := c.Execute(query); err != nil {
+		// The SET statement fails as a whole, no variables are changed.
+		return err
+	}
+
+	c.vars = make(map[string]string, len(vars))
+	for name, value := range vars {
+		c.vars[name] = value
+	}
+	return nil
+}
+
+// sessionVarExpr returns the assignment of the SET statement, the empty value means to reset it.
+func sessionVarExpr(name string, value string, charset string) string {
+	switch name {
+	case "names":
+		if value == "" {
+			value = fmt.Sprintf("'%s'", charset)
+		}
+		return "NAMES " + value
+	case "charset":
+		if value == "" {
+			value = fmt.Sprintf("'%s'", charset)
+		}
+		return "CHARACTER SET " + value
+	}
+	if value == "" {
+		value = "DEFAULT"
+	}
+	return fmt.Sprintf("@@SESSION.%s = %s", name, value)
+}
+
 // SetTimestamp used to set the timestamp.
 func (c *connection) SetTimestamp(ts int64) {
 	c.timestamp = ts
@@ -128,6 +206,19 @@ func (c *connection) Timestamp() int64 {
 	return c.timestamp
 }
 
+// Created returns the dial timestamp of connection.
+func (c *connection) Created() int64 {
+	return c.created
+}
+
+func (c *connection) attach() {
+	c.slot.Set(1)
+}
+
+func (c *connection) attached() bool {
+	return c.slot.Get() == 1
+}
+
 // setDeadline used to set deadline for a query.
 func (c *connection) setDeadline(timeout int) (chan bool, *sync.WaitGroup) {
 	var wg sync.WaitGroup
@@ -164,7 +255,11 @@ func (c *connection) ExecuteWithLimits(query string, timeout int, memlimits int)
 	var err error
 	var qr *sqltypes.Result
 	log := c.log
-	defer mysqlStats.Record("Connection.Execute", time.Now())
+	start := time.Now()
+	defer mysqlStats.Record("Connection.Execute", start)
+	defer func() {
+		monitor.BackendRequestObserve(c.address, start, err)
+	}()
 
 	// Query details.
 	qd := NewQueryDetail(c, query)
@@ -217,6 +312,7 @@ func (c *connection) ExecuteWithLimits(query string, timeout int, memlimits int)
 		}
 		return nil, err
 	}
+	monitor.RowsScannedAdd(c.address, len(qr.Rows))
 	return qr, nil
 }
 
@@ -227,15 +323,26 @@ func (c *connection) ExecuteStreamFetch(query string) (driver.Rows, error) {
 // Kill used to kill current connection.
 func (c *connection) Kill(reason string) error {
 	c.counters.Add(poolCounterBackendKilled, 1)
-	kill, err := c.pool.Get()
-	if err != nil {
+	// The KILL is sent by a dedicated connection outside the pool, it holds no slot so it never waits
+	// for the capped pool(which may be exhausted by the connections to kill) nor fails fast when the
+	// backend is marked down.
+	kill := &connection{
+		log:      c.log,
+		pool:     c.pool,
+		user:     c.user,
+		password: c.password,
+		address:  c.address,
+		charset:  c.charset,
+		counters: c.counters,
+	}
+	if err := kill.Dial(); err != nil {
 		return err
 	}
-	defer kill.Recycle()
+	defer kill.Close()
 
 	c.log.Warning("conn[%s, ID:%v].be.killed.by[%v].reason[%s]", c.address, c.ID(), kill.ID(), reason)
 	query := fmt.Sprintf("KILL %d", c.connectionID)
-	if _, err = kill.Execute(query); err != nil {
+	if _, err := kill.Execute(query); err != nil {
 		c.log.Warning("conn[%s, ID:%v].kill.error:%+v", c.address, c.ID(), err)
 		return err
 	}
@@ -247,7 +354,10 @@ func (c *connection) Recycle() {
 	defer mysqlStats.Record("conn.recycle", time.Now())
 	if !c.driver.Closed() {
 		c.pool.Put(c)
+		return
 	}
+	// Release the slot of the pool.
+	c.Close()
 }
 
 // Address returns the backend address of the connection.
@@ -263,6 +373,9 @@ func (c *connection) Close() {
 		c.driver.Close()
 		monitor.BackendConnectionDec(c.address)
 	}
+	if c.slot.CompareAndSwap(1, 0) {
+		c.pool.release()
+	}
 }
 
 func (c *connection) Closed() bool {
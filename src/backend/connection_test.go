@@ -18,6 +18,7 @@ import (
 	"github.com/fortytw2/leaktest"
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -120,6 +121,34 @@ func TestConnectionKill(t *testing.T) {
 	}
 }
 
+func TestConnectionKillPoolFull(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	// MySQL Server starts...
+	fakedb := fakedb.New(log, 1)
+	defer fakedb.Close()
+	addr := fakedb.Addrs()[0]
+
+	conf := MockBackendConfigDefault("", addr)
+	conf.MaxConnections = 1
+	conf.WaitTimeout = 100
+	pool := NewPool(log, conf)
+	defer pool.Close()
+
+	// The only slot is held by the connection to kill.
+	conn, err := pool.Get()
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = pool.Get()
+	assert.NotNil(t, err)
+
+	// kill
+	{
+		err := conn.Kill("kill.you")
+		assert.Nil(t, err)
+	}
+}
+
 func TestConnectionKillError(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -261,3 +290,64 @@ func TestTruncateQueryLog(t *testing.T) {
 		log.Debug("execute[%s].len[%d]", query, len(query))
 	}
 }
+
+func TestConnectionSetSessionVars(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb := fakedb.New(log, 1)
+	defer fakedb.Close()
+	addr := fakedb.Addrs()[0]
+
+	conn, cleanup := MockClient(log, addr)
+	defer cleanup()
+
+	set := "SET NAMES 'utf8mb4', @@SESSION.sql_mode = 'ANSI', @@SESSION.time_zone = '+00:00'"
+	change := "SET @@SESSION.sql_mode = DEFAULT, @@SESSION.time_zone = '+08:00'"
+	reset := "SET NAMES 'utf8', @@SESSION.time_zone = DEFAULT"
+	fakedb.AddQuery(set, &sqltypes.Result{})
+	fakedb.AddQuery(change, &sqltypes.Result{})
+	fakedb.AddQuery(reset, &sqltypes.Result{})
+
+	// Nothing to do.
+	{
+		err := conn.SetSessionVars(nil)
+		assert.Nil(t, err)
+	}
+
+	// Set.
+	vars := map[string]string{"names": "'utf8mb4'", "sql_mode": "'ANSI'", "time_zone": "'+00:00'"}
+	{
+		err := conn.SetSessionVars(vars)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(set))
+
+		// Cached.
+		err = conn.SetSessionVars(vars)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(set))
+	}
+
+	// Change.
+	{
+		err := conn.SetSessionVars(map[string]string{"names": "'utf8mb4'", "time_zone": "'+08:00'"})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(change))
+	}
+
+	// Error, the vars are not changed.
+	{
+		fakedb.AddQueryError("SET @@SESSION.time_zone = 'xx'", errors.New("mock.set.error"))
+		err := conn.SetSessionVars(map[string]string{"names": "'utf8mb4'", "time_zone": "'xx'"})
+		assert.NotNil(t, err)
+	}
+
+	// Reset.
+	{
+		err := conn.SetSessionVars(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(reset))
+
+		err = conn.SetSessionVars(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(reset))
+	}
+}
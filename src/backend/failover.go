@@ -0,0 +1,191 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"config"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// fenceQuery marks the old primary read-only, so the clients still reaching it can't write.
+	fenceQuery = "SET GLOBAL read_only = 1"
+	// gtidQuery reads the transactions executed on the fenced old primary.
+	gtidQuery = "SELECT @@GLOBAL.gtid_executed"
+	// catchupQuery waits the standby to apply the transactions of the old primary, returns 0 if it caught up.
+	catchupQuery = "SELECT WAIT_FOR_EXECUTED_GTID_SET('%s', %d)"
+	// catchupTimeout is the longest time to wait the standby to catch up.
+	catchupTimeout = 30 * time.Second
+)
+
+// promoteQuerys stop the replication of the standby and make it writable.
+var promoteQuerys = []string{
+	"STOP SLAVE",
+	"RESET SLAVE ALL",
+	"SET GLOBAL read_only = 0",
+}
+
+// FailoverQuorum used to get the agreement of the radon peers before the failover,
+// a peer losing the backend alone(such as a network partition) must not promote the standby.
+type FailoverQuorum interface {
+	// Agree returns nil if the quorum of the peers see the backend down at the address,
+	// and this peer is the one elected to fail it over.
+	Agree(backend string, address string) error
+}
+
+// SetFailoverQuorum used to set the quorum of the peers, the failover is refused without it.
+func (scatter *Scatter) SetFailoverQuorum(quorum FailoverQuorum) {
+	scatter.mu.Lock()
+	defer scatter.mu.Unlock()
+	scatter.quorum = quorum
+}
+
+// queryOn used to execute the query on the address with a new connection, it fails if the timeout is exceeded.
+// The connections of the pool can't be used since the address is not the backend of any pool.
+func queryOn(conf *config.BackendConfig, address string, query string, timeout time.Duration) (*sqltypes.Result, error) {
+	type reply struct {
+		qr  *sqltypes.Result
+		err error
+	}
+	replyc := make(chan reply, 1)
+	go func() {
+		conn, err := driver.NewConn(conf.User, conf.Password, address, "", conf.Charset)
+		if err != nil {
+			replyc <- reply{err: err}
+			return
+		}
+		defer conn.Close()
+		qr, err := conn.FetchAll(query, -1)
+		replyc <- reply{qr: qr, err: err}
+	}()
+
+	select {
+	case r := <-replyc:
+		return r.qr, r.err
+	case <-time.After(timeout):
+		return nil, errors.Errorf("exec[%s].on[%s].timeout[%v]", query, address, timeout)
+	}
+}
+
+// execOn used to execute the querys on the address in order, it stops at the first error.
+func execOn(conf *config.BackendConfig, address string, timeout time.Duration, querys ...string) error {
+	for _, query := range querys {
+		if _, err := queryOn(conf, address, query, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// catchup used to wait the standby to apply all the transactions executed on the fenced old primary.
+func catchup(conf *config.BackendConfig, timeout time.Duration) error {
+	qr, err := queryOn(conf, conf.Address, gtidQuery, timeout)
+	if err != nil {
+		return err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 {
+		return errors.Errorf("gtid.executed.of[%s].not.found", conf.Address)
+	}
+	gtids := strings.Replace(qr.Rows[0][0].String(), "\n", "", -1)
+
+	query := fmt.Sprintf(catchupQuery, gtids, int(catchupTimeout/time.Second))
+	if qr, err = queryOn(conf, conf.Standby, query, catchupTimeout+timeout); err != nil {
+		return err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 || qr.Rows[0][0].String() != "0" {
+		return errors.Errorf("standby[%s].not.caught.up.with[%s]", conf.Standby, gtids)
+	}
+	return nil
+}
+
+// failover used to promote the standby of the down backend if the failover is enabled:
+// 1. the quorum of the peers agree that the backend is down and this peer is elected
+// 2. the old primary is fenced read-only, or the failover is aborted since an unreachable primary may still serve others
+// 3. the standby catches up with the gtid_executed of the fenced old primary, or the failover is aborted
+// 4. the replication of the standby is stopped and reset, then it's promoted writable
+// 5. the address and the standby are swapped, then the config is flushed and the peers reload it by the syncer
+// 6. the prepared XA branches replicated to the standby are resolved by the commit decisions
+func (scatter *Scatter) failover(pool *Pool) {
+	log := scatter.log
+	conf := pool.conf
+
+	scatter.mu.RLock()
+	scatterConf, quorum := scatter.conf, scatter.quorum
+	scatter.mu.RUnlock()
+	if scatterConf == nil || !scatterConf.Failover || conf.Standby == "" {
+		return
+	}
+	if quorum == nil {
+		log.Error("scatter.failover.backend[%s].refused:no.quorum", conf.Name)
+		return
+	}
+	if err := quorum.Agree(conf.Name, conf.Address); err != nil {
+		log.Warning("scatter.failover.backend[%s].not.agreed:%v", conf.Name, err)
+		return
+	}
+
+	timeout := time.Duration(scatterConf.HealthCheckTimeout) * time.Millisecond
+	if err := ping(conf, conf.Standby, timeout); err != nil {
+		log.Error("scatter.failover.backend[%s].standby[%s].ping.error:%v", conf.Name, conf.Standby, err)
+		return
+	}
+	if err := execOn(conf, conf.Address, timeout, fenceQuery); err != nil {
+		log.Error("scatter.failover.backend[%s].fence.old.primary[%s].error:%v", conf.Name, conf.Address, err)
+		return
+	}
+	if err := catchup(conf, timeout); err != nil {
+		log.Error("scatter.failover.backend[%s].standby[%s].catchup.error:%v", conf.Name, conf.Standby, err)
+		return
+	}
+	if err := execOn(conf, conf.Standby, timeout, promoteQuerys...); err != nil {
+		log.Error("scatter.failover.backend[%s].promote.standby[%s].error:%v", conf.Name, conf.Standby, err)
+		return
+	}
+
+	scatter.mu.Lock()
+	if scatter.backends[conf.Name] != pool {
+		// The backend has been changed.
+		scatter.mu.Unlock()
+		return
+	}
+	promoted := *conf
+	promoted.Address, promoted.Standby = conf.Standby, conf.Address
+	log.Warning("scatter.failover.backend[%s].from[%s].to[%s]", conf.Name, conf.Address, promoted.Address)
+	newPool := NewPool(log, &promoted)
+	newPool.startHealthCheck(scatterConf, scatter.failover)
+	scatter.backends[conf.Name] = newPool
+	scatter.mu.Unlock()
+
+	// The pool is closed asynchronously, since we are in its health checker.
+	go pool.Close()
+	if err := scatter.FlushConfig(); err != nil {
+		log.Error("scatter.failover.backend[%s].flush.config.error:%v", conf.Name, err)
+		return
+	}
+	scatter.xaRecoverBackends([]string{conf.Name})
+	log.Warning("scatter.failover.backend[%s].to[%s].done", conf.Name, promoted.Address)
+}
+
+// xaRecoverBackends used to resolve the prepared XA branches of the coordinator on the backends
+// whose addresses are changed.
+func (scatter *Scatter) xaRecoverBackends(backends []string) {
+	log := scatter.log
+	for _, backend := range backends {
+		if err := scatter.txnMgr.XaRecoverBackend(scatter, backend); err != nil {
+			log.Error("scatter.xa.recover.backend[%s].error:%v", backend, err)
+		}
+	}
+}
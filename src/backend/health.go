@@ -0,0 +1,152 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"config"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// Health tuple, the health of the backend.
+type Health struct {
+	Up        bool      `json:"up"`
+	Failures  int       `json:"failures"`
+	LastCheck time.Time `json:"last-check"`
+	LastError string    `json:"last-error,omitempty"`
+}
+
+// ping used to check the address with a new connection, it fails if the timeout is exceeded.
+func ping(conf *config.BackendConfig, address string, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		conn, err := driver.NewConn(conf.User, conf.Password, address, "", conf.Charset)
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer conn.Close()
+		errc <- conn.Ping()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(timeout):
+		return errors.Errorf("ping[%s].timeout[%v]", address, timeout)
+	}
+}
+
+// healthChecker checks the backend in the background.
+// The backend is marked down after the consecutive failures, the connections to it fail fast until it's up again.
+type healthChecker struct {
+	log         *xlog.Log
+	pool        *Pool
+	interval    time.Duration
+	timeout     time.Duration
+	maxFailures int
+	// onDown is called by every failed check once the backend is down.
+	onDown func(p *Pool)
+	mu     sync.RWMutex
+	health Health
+	done   chan bool
+}
+
+func newHealthChecker(log *xlog.Log, pool *Pool, conf *config.ScatterConfig, onDown func(p *Pool)) *healthChecker {
+	maxFailures := conf.HealthCheckFailures
+	if maxFailures <= 0 {
+		maxFailures = 1
+	}
+	return &healthChecker{
+		log:         log,
+		pool:        pool,
+		interval:    time.Duration(conf.HealthCheckInterval) * time.Millisecond,
+		timeout:     time.Duration(conf.HealthCheckTimeout) * time.Millisecond,
+		maxFailures: maxFailures,
+		onDown:      onDown,
+		health:      Health{Up: true},
+		done:        make(chan bool),
+	}
+}
+
+func (h *healthChecker) start() {
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				h.check()
+			case <-h.done:
+				return
+			}
+		}
+	}()
+}
+
+func (h *healthChecker) check() {
+	log := h.log
+	pool := h.pool
+	err := ping(pool.conf, pool.conf.Address, h.timeout)
+
+	h.mu.Lock()
+	health := &h.health
+	health.LastCheck = time.Now()
+	if err == nil {
+		if !health.Up {
+			log.Warning("backend[%s].health.check.up.again", pool.name())
+		}
+		health.Up = true
+		health.Failures = 0
+		health.LastError = ""
+		h.mu.Unlock()
+		return
+	}
+	health.Failures++
+	health.LastError = err.Error()
+	if health.Up && health.Failures >= h.maxFailures {
+		log.Error("backend[%s].health.check.failures[%d].marked.down.last.error:%v", pool.name(), health.Failures, err)
+		health.Up = false
+	}
+	down := !health.Up
+	h.mu.Unlock()
+
+	if down && h.onDown != nil {
+		h.onDown(pool)
+	}
+}
+
+// get returns the health snapshot.
+func (h *healthChecker) get() Health {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.health
+}
+
+// err returns the error if the backend is down.
+func (h *healthChecker) err() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.health.Up {
+		return nil
+	}
+	return errors.Errorf("backend[%s].is.down.last.error[%s]", h.pool.conf.Name, h.health.LastError)
+}
+
+// stop used to stop the checker, it doesn't wait for the running check,
+// since the check may be blocked by the scatter lock in failover.
+func (h *healthChecker) stop() {
+	close(h.done)
+}
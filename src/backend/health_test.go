@@ -0,0 +1,327 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func mockHealthScatterConfig(log *xlog.Log) *config.ScatterConfig {
+	conf := MockScatterDefault(log)
+	conf.HealthCheckInterval = 20
+	conf.HealthCheckTimeout = 500
+	conf.HealthCheckFailures = 2
+	return conf
+}
+
+func waitHealth(pool *Pool, up bool) bool {
+	for i := 0; i < 200; i++ {
+		if h := pool.Health(); h != nil && h.Up == up {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestHealthCheckDownAndUp(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	addr := svr.Addr()
+
+	pool := NewPool(log, MockBackendConfigDefault("node1", addr))
+	defer pool.Close()
+	pool.startHealthCheck(mockHealthScatterConfig(log), nil)
+	assert.True(t, waitHealth(pool, true))
+	{
+		_, err := pool.Get()
+		assert.Nil(t, err)
+	}
+
+	// Down.
+	svr.Close()
+	assert.True(t, waitHealth(pool, false))
+	{
+		_, err := pool.Get()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "backend[node1].is.down")
+		h := pool.Health()
+		assert.True(t, h.Failures >= 2)
+		assert.NotEqual(t, "", h.LastError)
+	}
+
+	// Up again.
+	_, port, err := net.SplitHostPort(addr)
+	assert.Nil(t, err)
+	p, err := strconv.Atoi(port)
+	assert.Nil(t, err)
+	svr, err = driver.MockMysqlServerWithPort(log, p, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	assert.True(t, waitHealth(pool, true))
+	{
+		_, err := pool.Get()
+		assert.Nil(t, err)
+		h := pool.Health()
+		assert.Equal(t, 0, h.Failures)
+		assert.Equal(t, "", h.LastError)
+	}
+}
+
+func TestHealthCheckDisabled(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	scatter, _, cleanup := MockScatter(log, 1)
+	defer cleanup()
+
+	err := scatter.Init(MockScatterDefault(log))
+	assert.Nil(t, err)
+	for _, status := range scatter.Backendz() {
+		assert.Nil(t, status.Health)
+	}
+}
+
+// mockQuorum agrees the failover if the err is nil.
+type mockQuorum struct {
+	mu     sync.Mutex
+	err    error
+	agrees []string
+}
+
+func (q *mockQuorum) Agree(backend string, address string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.agrees = append(q.agrees, backend+"@"+address)
+	return q.err
+}
+
+func (q *mockQuorum) setErr(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.err = err
+}
+
+func (q *mockQuorum) agreed() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.agrees)
+}
+
+// mockFailoverResult returns the single value result of the failover querys.
+func mockFailoverResult(value string) *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "value", Type: querypb.Type_VARCHAR}},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(value))},
+		},
+	}
+}
+
+func TestHealthCheckFailover(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs := fakedb.New(log, 2)
+	defer fakedbs.Close()
+	addrs := fakedbs.Addrs()
+
+	metadir := fakedb.GetTmpDir("", "radon_health_", log)
+	defer os.RemoveAll(metadir)
+	scatter := NewScatter(log, metadir)
+	defer scatter.Close()
+
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	primary := svr.Addr()
+	th.AddQueryPattern("set global read_only .*", &sqltypes.Result{})
+	th.AddQuery("select @@global.gtid_executed", mockFailoverResult("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"))
+
+	conf := mockHealthScatterConfig(log)
+	conf.Failover = true
+	err = scatter.Init(conf)
+	assert.Nil(t, err)
+	fakedbs.AddQueryPattern("set global read_only .*", &sqltypes.Result{})
+	fakedbs.AddQuery("stop slave", &sqltypes.Result{})
+	fakedbs.AddQuery("reset slave all", &sqltypes.Result{})
+	fakedbs.AddQuery("xa recover", &sqltypes.Result{})
+	catchup := "select wait_for_executed_gtid_set('3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5', 30)"
+
+	backend := MockBackendConfigDefault("node1", primary)
+	backend.Standby = addrs[0]
+	err = scatter.Add(backend)
+	assert.Nil(t, err)
+	// No standby.
+	err = scatter.Add(MockBackendConfigDefault("node2", addrs[1]))
+	assert.Nil(t, err)
+
+	status := scatter.Backendz()
+	assert.Equal(t, 2, len(status))
+	assert.Equal(t, "node1", status[0].Name)
+	assert.NotNil(t, status[0].Health)
+
+	pool := scatter.PoolClone()["node1"]
+	// The failover is refused without the quorum.
+	scatter.failover(pool)
+	assert.Equal(t, primary, scatter.PoolClone()["node1"].conf.Address)
+
+	// The peers don't agree.
+	quorum := &mockQuorum{err: errors.New("mock.not.quorum")}
+	scatter.SetFailoverQuorum(quorum)
+	scatter.failover(pool)
+	assert.Equal(t, 1, quorum.agreed())
+	assert.Equal(t, primary, scatter.PoolClone()["node1"].conf.Address)
+	assert.Equal(t, 0, fakedbs.GetQueryCalledNum("set global read_only = 0"))
+
+	// The old primary can't be fenced.
+	quorum.setErr(nil)
+	th.AddQueryErrorPattern("set global read_only .*", errors.New("mock.fence.error"))
+	scatter.failover(pool)
+	assert.Equal(t, primary, scatter.PoolClone()["node1"].conf.Address)
+	assert.Equal(t, 0, fakedbs.GetQueryCalledNum(catchup))
+	th.ResetPatternErrors()
+
+	// The standby doesn't catch up.
+	fakedbs.AddQuery(catchup, mockFailoverResult("1"))
+	scatter.failover(pool)
+	assert.Equal(t, primary, scatter.PoolClone()["node1"].conf.Address)
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum(catchup))
+	assert.Equal(t, 0, fakedbs.GetQueryCalledNum("stop slave"))
+	assert.Equal(t, 0, fakedbs.GetQueryCalledNum("set global read_only = 0"))
+
+	// The peers agree, the old primary is fenced and the standby caught up, it's promoted.
+	fakedbs.AddQuery(catchup, mockFailoverResult("0"))
+	fences := th.GetQueryCalledNum("set global read_only = 1")
+	scatter.failover(pool)
+	pool = scatter.PoolClone()["node1"]
+	assert.Equal(t, addrs[0], pool.conf.Address)
+	assert.Equal(t, primary, pool.conf.Standby)
+	assert.Equal(t, "node1@"+primary, quorum.agrees[len(quorum.agrees)-1])
+	assert.Equal(t, fences+1, th.GetQueryCalledNum("set global read_only = 1"))
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("stop slave"))
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("reset slave all"))
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("set global read_only = 0"))
+	assert.True(t, waitHealth(pool, true))
+	{
+		_, err := pool.Get()
+		assert.Nil(t, err)
+	}
+
+	// The config is flushed.
+	data, err := ioutil.ReadFile(path.Join(metadir, backendjson))
+	assert.Nil(t, err)
+	backends, err := config.ReadBackendsConfig(string(data))
+	assert.Nil(t, err)
+	for _, b := range backends.Backends {
+		if b.Name == "node1" {
+			assert.Equal(t, addrs[0], b.Address)
+			assert.Equal(t, primary, b.Standby)
+		}
+	}
+	svr.Close()
+}
+
+func TestHealthCheckFailoverUnfenced(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs := fakedb.New(log, 1)
+	defer fakedbs.Close()
+	addrs := fakedbs.Addrs()
+
+	metadir := fakedb.GetTmpDir("", "radon_health_", log)
+	defer os.RemoveAll(metadir)
+	scatter := NewScatter(log, metadir)
+	defer scatter.Close()
+
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	primary := svr.Addr()
+
+	conf := mockHealthScatterConfig(log)
+	conf.Failover = true
+	err = scatter.Init(conf)
+	assert.Nil(t, err)
+	fakedbs.AddQueryPattern("set global read_only .*", &sqltypes.Result{})
+	quorum := &mockQuorum{}
+	scatter.SetFailoverQuorum(quorum)
+
+	backend := MockBackendConfigDefault("node1", primary)
+	backend.Standby = addrs[0]
+	err = scatter.Add(backend)
+	assert.Nil(t, err)
+
+	// The primary is down and the peers agree, but it can't be fenced, the standby is not promoted.
+	svr.Close()
+	assert.True(t, waitHealth(scatter.PoolClone()["node1"], false))
+	for i := 0; i < 200 && quorum.agreed() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, quorum.agreed() > 0)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, primary, scatter.PoolClone()["node1"].conf.Address)
+	assert.Equal(t, 0, fakedbs.GetQueryCalledNum("set global read_only = 0"))
+}
+
+func TestHealthCheckFailoverReload(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs := fakedb.New(log, 2)
+	defer fakedbs.Close()
+	addrs := fakedbs.Addrs()
+	fakedbs.AddQuery("xa recover", &sqltypes.Result{})
+
+	metadir := fakedb.GetTmpDir("", "radon_health_", log)
+	defer os.RemoveAll(metadir)
+	scatter := NewScatter(log, metadir)
+	defer scatter.Close()
+	err := scatter.Init(MockScatterDefault2(metadir))
+	assert.Nil(t, err)
+
+	backend := MockBackendConfigDefault("node1", addrs[0])
+	backend.Standby = addrs[1]
+	err = scatter.Add(backend)
+	assert.Nil(t, err)
+	err = scatter.FlushConfig()
+	assert.Nil(t, err)
+	recovers := fakedbs.GetQueryCalledNum("xa recover")
+
+	// The address isn't changed.
+	err = scatter.LoadConfig()
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, recovers, fakedbs.GetQueryCalledNum("xa recover"))
+
+	// The peer fails it over, the prepared branches are resolved on the promoted.
+	promoted := *backend
+	promoted.Address, promoted.Standby = backend.Standby, backend.Address
+	err = config.WriteConfig(path.Join(metadir, backendjson), &config.BackendsConfig{Backends: []*config.BackendConfig{&promoted}})
+	assert.Nil(t, err)
+	err = scatter.LoadConfig()
+	assert.Nil(t, err)
+	for i := 0; i < 200 && fakedbs.GetQueryCalledNum("xa recover") == recovers; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, recovers+1, fakedbs.GetQueryCalledNum("xa recover"))
+}
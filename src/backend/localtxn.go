@@ -0,0 +1,111 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"xcontext"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	txnCounterLocalCommit   = "#txn.local.commit"
+	txnCounterLocalRollback = "#txn.local.rollback"
+)
+
+// BeginLocal used to start a local transaction in the multiple-statement transaction, it works without 2PC.
+// The local transaction is started lazily on the first backend which a statement touches alone, and:
+// 1. the reads to the other backends are executed outside of the transaction
+// 2. the writes to the other backends are refused, they require 2PC
+func (txn *Txn) BeginLocal() error {
+	txnCounters.Add(txnCounterTxnBegin, 1)
+	txn.local = true
+	return nil
+}
+
+// localBackendName returns the backend which the local transaction started on.
+func (txn *Txn) localBackendName() string {
+	txn.localMu.Lock()
+	defer txn.localMu.Unlock()
+	return txn.localBackend
+}
+
+// localEnlist used to start the local transaction on the backend if the request touches it only,
+// and to check the request does not write to the other backends.
+func (txn *Txn) localEnlist(req *xcontext.RequestContext) error {
+	if req.Mode == xcontext.ReqSingle {
+		// Execute on the local backend if started, otherwise outside of the transaction.
+		return nil
+	}
+	backs := txn.reqBackends(req)
+
+	txn.localMu.Lock()
+	defer txn.localMu.Unlock()
+	if txn.localBackend == "" && len(backs) == 1 {
+		back := backs[0]
+		conn, err := txn.twopcConnection(back)
+		if err != nil {
+			return err
+		}
+		if err := txn.applyCharacteristics(conn); err != nil {
+			txn.incErrors()
+			return err
+		}
+		if _, err := conn.Execute(txn.chars.startTransactionQuery()); err != nil {
+			txn.incErrors()
+			return err
+		}
+		txn.localBackend = back
+		if err := txn.replaySavepoints(back, conn); err != nil {
+			txn.incErrors()
+			return err
+		}
+	}
+
+	if req.TxnMode == xcontext.TxnWrite {
+		for _, back := range backs {
+			if back != txn.localBackend {
+				return errors.Errorf("txn.local.write.across.backends.unsupported[twopc-disable]")
+			}
+		}
+	}
+	return nil
+}
+
+// endLocal used to send the COMMIT or ROLLBACK to the backend which the local transaction started on.
+func (txn *Txn) endLocal(query string) error {
+	back := txn.localBackendName()
+	if back == "" {
+		return nil
+	}
+
+	conn, err := txn.twopcConnection(back)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Execute(query); err != nil {
+		txn.incErrors()
+		return err
+	}
+	return nil
+}
+
+// commitLocal used to commit the local transaction.
+func (txn *Txn) commitLocal() error {
+	txnCounters.Add(txnCounterLocalCommit, 1)
+	txn.state.Set(int32(txnStateCommitting))
+	return txn.endLocal("COMMIT")
+}
+
+// rollbackLocal used to rollback the local transaction.
+func (txn *Txn) rollbackLocal() error {
+	txnCounters.Add(txnCounterLocalRollback, 1)
+	txn.state.Set(int32(txnStateRollbacking))
+	return txn.endLocal("ROLLBACK")
+}
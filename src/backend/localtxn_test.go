@@ -0,0 +1,140 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"xcontext"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTxnLocal(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQuery("BEGIN", &sqltypes.Result{})
+	fakedb.AddQuery("COMMIT", &sqltypes.Result{})
+	fakedb.AddQuery("ROLLBACK", &sqltypes.Result{})
+	fakedb.AddQueryPattern("select .*", result1)
+	fakedb.AddQueryPattern("insert .*", &sqltypes.Result{})
+	fakedb.AddQueryPattern("SAVEPOINT .*", &sqltypes.Result{})
+
+	read := func(backs ...string) *xcontext.RequestContext {
+		req := &xcontext.RequestContext{TxnMode: xcontext.TxnRead}
+		for _, back := range backs {
+			req.Querys = append(req.Querys, xcontext.QueryTuple{Query: "select * from t1", Backend: back})
+		}
+		return req
+	}
+	write := func(backs ...string) *xcontext.RequestContext {
+		req := &xcontext.RequestContext{TxnMode: xcontext.TxnWrite}
+		for _, back := range backs {
+			req.Querys = append(req.Querys, xcontext.QueryTuple{Query: "insert into t1 values(1)", Backend: back})
+		}
+		return req
+	}
+
+	// Commit.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+		err = txn.BeginLocal()
+		assert.Nil(t, err)
+
+		// Savepoint before the txn starts on the backend.
+		err = txn.Savepoint("sp1")
+		assert.Nil(t, err)
+
+		// The read across the backends does not start the txn.
+		_, err = txn.Execute(read(addrs[0], addrs[1]))
+		assert.Nil(t, err)
+		assert.Equal(t, "", txn.localBackendName())
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum("BEGIN"))
+
+		// The write starts the txn.
+		_, err = txn.Execute(write(addrs[0]))
+		assert.Nil(t, err)
+		assert.Equal(t, addrs[0], txn.localBackendName())
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum("BEGIN"))
+		assert.True(t, txn.savepoints[0].backends[addrs[0]])
+
+		// The read across the backends is ok.
+		_, err = txn.Execute(read(addrs[0], addrs[1]))
+		assert.Nil(t, err)
+
+		// The write across the backends is refused.
+		_, err = txn.Execute(write(addrs[0], addrs[1]))
+		assert.NotNil(t, err)
+		_, err = txn.Execute(write(addrs[1]))
+		assert.NotNil(t, err)
+
+		// The single request executes on the local backend.
+		_, err = txn.ExecuteSingle("select 1")
+		assert.Nil(t, err)
+
+		err = txn.CommitScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum("COMMIT"))
+	}
+
+	// Rollback.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+		err = txn.BeginLocal()
+		assert.Nil(t, err)
+
+		// Rollback nothing.
+		err = txn.RollbackScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum("ROLLBACK"))
+
+		_, err = txn.Execute(read(addrs[1]))
+		assert.Nil(t, err)
+		assert.Equal(t, addrs[1], txn.localBackendName())
+
+		err = txn.RollbackScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum("ROLLBACK"))
+	}
+
+	// Errors.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+		err = txn.BeginLocal()
+		assert.Nil(t, err)
+
+		fakedb.AddQueryError("BEGIN", errors.New("mock.begin.error"))
+		_, err = txn.Execute(write(addrs[0]))
+		assert.NotNil(t, err)
+		assert.Equal(t, "", txn.localBackendName())
+
+		fakedb.AddQuery("BEGIN", &sqltypes.Result{})
+		fakedb.AddQueryError("COMMIT", errors.New("mock.commit.error"))
+		_, err = txn.Execute(write(addrs[0]))
+		assert.Nil(t, err)
+		err = txn.CommitScatter()
+		assert.NotNil(t, err)
+	}
+}
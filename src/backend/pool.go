@@ -10,26 +10,36 @@ package backend
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"config"
+	"monitor"
 	"xbase/stats"
+	"xbase/sync2"
 
+	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
 var (
-	poolCounterPing       = "#pool.ping"
-	poolCounterPingBroken = "#pool.ping.broken"
-	poolCounterHit        = "#pool.hit"
-	poolCounterMiss       = "#pool.miss"
-	poolCounterGet        = "#pool.get"
-	poolCounterPut        = "#pool.put"
-	poolCounterClose      = "#pool.close"
+	poolCounterPing        = "#pool.ping"
+	poolCounterPingBroken  = "#pool.ping.broken"
+	poolCounterHit         = "#pool.hit"
+	poolCounterMiss        = "#pool.miss"
+	poolCounterGet         = "#pool.get"
+	poolCounterPut         = "#pool.put"
+	poolCounterClose       = "#pool.close"
+	poolCounterDown        = "#pool.down"
+	poolCounterWait        = "#pool.wait"
+	poolCounterWaitTimeout = "#pool.wait.timeout"
+	poolCounterWaitFull    = "#pool.wait.full"
+	poolCounterExpired     = "#pool.expired"
+	poolCounterReap        = "#pool.reap"
+	poolCounterWarmup      = "#pool.warmup"
 
 	poolCounterBackendDialError        = "#backend.dial.error"
 	poolCounterBackendExecuteTimeout   = "#backend.execute.timeout"
@@ -39,98 +49,372 @@ var (
 )
 
 var (
-	maxIdleTime = 20 // 20s
-	errClosed   = errors.New("can't get connection from the closed DB")
+	maxIdleTime        = 20   // 20s
+	defaultMaxConns    = 1024 // if the max-connections is not set
+	defaultWaitTimeout = 5000 // 5s
+	defaultMaxWaiters  = 1024
+	maintainInterval   = time.Second
+	errClosed          = errors.New("can't get connection from the closed DB")
 )
 
 // Pool tuple.
+// The open connections are capped by the MaxConnections, the Get waits in the queue if the pool is full.
 type Pool struct {
 	mu          sync.RWMutex
 	log         *xlog.Log
 	conf        *config.BackendConfig
 	counters    *stats.Counters
 	connections chan Connection
+	health      *healthChecker
+	// monitorID is the id of the pool registered to the monitor.
+	monitorID uint64
 
-	// If maxIdleTime reached, the connection will be closed by get.
+	// slots are held by the open connections, its capacity is the max connections.
+	slots   chan struct{}
+	waiters sync2.AtomicInt32
+	done    chan bool
+	wg      sync.WaitGroup
+
+	minIdle     int
+	maxWaiters  int
+	waitTimeout time.Duration
+	// If maxLifetime(in seconds) reached, the connection will be closed, 0 means unlimited.
+	maxLifetime int64
+	// If maxIdleTime(in seconds) reached, the connection will be closed by get or the maintainer.
 	maxIdleTime int64
 }
 
 // NewPool creates the new Pool.
 func NewPool(log *xlog.Log, conf *config.BackendConfig) *Pool {
+	maxConns := conf.MaxConnections
+	if maxConns <= 0 {
+		maxConns = defaultMaxConns
+	}
 	p := &Pool{
 		log:         log,
 		conf:        conf,
-		connections: make(chan Connection, conf.MaxConnections),
+		connections: make(chan Connection, maxConns),
 		counters:    stats.NewCounters(conf.Name + "@" + conf.Address),
+		slots:       make(chan struct{}, maxConns),
+		done:        make(chan bool),
+		minIdle:     conf.MinIdle,
+		maxWaiters:  defaultMaxWaiters,
+		waitTimeout: time.Duration(defaultWaitTimeout) * time.Millisecond,
+		maxLifetime: int64(conf.MaxLifetime / 1000),
 		maxIdleTime: int64(maxIdleTime),
 	}
+	if p.minIdle > maxConns {
+		p.minIdle = maxConns
+	}
+	if conf.MaxWaiters > 0 {
+		p.maxWaiters = conf.MaxWaiters
+	}
+	if conf.WaitTimeout > 0 {
+		p.waitTimeout = time.Duration(conf.WaitTimeout) * time.Millisecond
+	}
+	if conf.MaxIdleTime > 0 {
+		p.maxIdleTime = int64(conf.MaxIdleTime / 1000)
+		if p.maxIdleTime == 0 {
+			p.maxIdleTime = 1
+		}
+	}
+	if conf.MaxLifetime > 0 && p.maxLifetime == 0 {
+		p.maxLifetime = 1
+	}
+	p.monitorID = monitor.RegisterPool(p.name(), p.counts, p.gauges)
+
+	p.wg.Add(1)
+	go p.maintain(p.done)
 	return p
 }
 
-func (p *Pool) reconnect() (Connection, error) {
+func (p *Pool) name() string {
+	return p.conf.Name + "@" + p.conf.Address
+}
+
+// counts returns the counters without the '#' prefix for the monitor.
+func (p *Pool) counts() map[string]int64 {
+	counts := p.counters.Counts()
+	r := make(map[string]int64, len(counts))
+	for k, v := range counts {
+		r[strings.TrimPrefix(k, "#")] = v
+	}
+	return r
+}
+
+// gauges returns the gauges of the pool for the monitor.
+func (p *Pool) gauges() map[string]int64 {
+	return map[string]int64{
+		"open":    int64(len(p.slots)),
+		"idle":    int64(len(p.getConns())),
+		"waiters": int64(p.waiters.Get()),
+		"max":     int64(cap(p.slots)),
+	}
+}
+
+// dial used to create a new connection, the caller must hold a slot which is released if the dial fails.
+func (p *Pool) dial() (Connection, error) {
 	log := p.log
 	c := NewConnection(log, p)
 	if err := c.Dial(); err != nil {
+		p.release()
 		log.Error("pool.reconnect.dial.error:%+v", err)
 		return nil, err
 	}
 	c.SetTimestamp(time.Now().Unix())
+	c.attach()
 	return c, nil
 }
 
+// release used to release the slot held by the closed connection.
+func (p *Pool) release() {
+	select {
+	case <-p.slots:
+	default:
+	}
+}
+
+// maintain used to warm up the min idle connections and reap the expired idle connections in the background.
+func (p *Pool) maintain(done chan bool) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(maintainInterval)
+	defer ticker.Stop()
+
+	p.warmup()
+	for {
+		select {
+		case <-ticker.C:
+			p.reap()
+			p.warmup()
+		case <-done:
+			return
+		}
+	}
+}
+
+// warmup used to fill the idle connections to the minIdle if there are free slots.
+func (p *Pool) warmup() {
+	for {
+		conns := p.getConns()
+		if conns == nil || len(conns) >= p.minIdle {
+			return
+		}
+		if h := p.getHealth(); h != nil && h.err() != nil {
+			return
+		}
+
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			return
+		}
+		conn, err := p.dial()
+		if err != nil {
+			return
+		}
+		p.counters.Add(poolCounterWarmup, 1)
+		p.put(conn, false)
+	}
+}
+
+// reap used to close the expired idle connections, the idle time of the minIdle connections is not limited.
+func (p *Pool) reap() {
+	conns := p.getConns()
+	if conns == nil {
+		return
+	}
+
+	var keep []Connection
+	now := time.Now().Unix()
+	for n := len(conns); n > 0; n-- {
+		var conn Connection
+		var more bool
+		select {
+		case conn, more = <-conns:
+		default:
+		}
+		if !more {
+			break
+		}
+		if p.expired(conn, now, len(keep) >= p.minIdle) {
+			p.counters.Add(poolCounterReap, 1)
+			conn.Close()
+			continue
+		}
+		keep = append(keep, conn)
+	}
+	for _, conn := range keep {
+		p.put(conn, false)
+	}
+}
+
+// expired checks the lifetime of the connection, and the idle time if checkIdle is true.
+func (p *Pool) expired(conn Connection, now int64, checkIdle bool) bool {
+	if maxLifetime := p.maxLifetime; maxLifetime > 0 && now-conn.Created() > maxLifetime {
+		return true
+	}
+	return checkIdle && now-conn.Timestamp() > atomic.LoadInt64(&p.maxIdleTime)
+}
+
+// startHealthCheck used to check the backend in the background, the onDown is called if the backend is down.
+func (p *Pool) startHealthCheck(conf *config.ScatterConfig, onDown func(p *Pool)) {
+	if conf.HealthCheckInterval <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connections == nil || p.health != nil {
+		return
+	}
+	p.health = newHealthChecker(p.log, p, conf, onDown)
+	p.health.start()
+}
+
+func (p *Pool) getHealth() *healthChecker {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.health
+}
+
+// Health returns the health of the backend, nil if the health check is disabled.
+func (p *Pool) Health() *Health {
+	if h := p.getHealth(); h != nil {
+		health := h.get()
+		return &health
+	}
+	return nil
+}
+
 // Get used to get a connection from the pool.
+// The idle connection is preferred, then a new one is created if the pool is not full,
+// or else it waits for the idle connection or the free slot until the wait timeout.
 func (p *Pool) Get() (Connection, error) {
 	counters := p.counters
 	counters.Add(poolCounterGet, 1)
 
+	// Fail fast if the backend is down.
+	if h := p.getHealth(); h != nil {
+		if err := h.err(); err != nil {
+			counters.Add(poolCounterDown, 1)
+			return nil, err
+		}
+	}
+
 	conns := p.getConns()
 	if conns == nil {
 		return nil, errClosed
 	}
 
-	select {
-	case conn, more := <-conns:
-		if !more {
-			return nil, errClosed
-		}
-		// If the idle time more than 1s,
-		// we will do a ping to check the connection is OK or NOT.
-		now := time.Now().Unix()
-		elapsed := (now - conn.Timestamp())
-		if elapsed > 1 {
-			// If elapsed time more than 20s, we create new one.
-			if elapsed > atomic.LoadInt64(&p.maxIdleTime) {
-				conn.Close()
-				return p.reconnect()
+	var timer *time.Timer
+	for {
+		// The idle connection first.
+		select {
+		case conn, more := <-conns:
+			if !more {
+				return nil, errClosed
+			}
+			if conn = p.check(conn); conn != nil {
+				return conn, nil
+			}
+			continue
+		default:
+		}
+
+		select {
+		case p.slots <- struct{}{}:
+			counters.Add(poolCounterMiss, 1)
+			return p.dial()
+		default:
+		}
+
+		// The pool is full, wait in the queue.
+		if timer == nil {
+			if int(p.waiters.Add(1)) > p.maxWaiters {
+				p.waiters.Add(-1)
+				counters.Add(poolCounterWaitFull, 1)
+				return nil, errors.Errorf("pool[%s].wait.queue.is.full[%d]", p.name(), p.maxWaiters)
 			}
+			counters.Add(poolCounterWait, 1)
+			defer p.waiters.Add(-1)
+			defer monitor.PoolWaitObserve(p.name(), time.Now())
+			timer = time.NewTimer(p.waitTimeout)
+			defer timer.Stop()
+		}
 
-			if err := conn.Ping(); err != nil {
-				counters.Add(poolCounterPingBroken, 1)
-				return p.reconnect()
+		select {
+		case conn, more := <-conns:
+			if !more {
+				return nil, errClosed
+			}
+			if conn = p.check(conn); conn != nil {
+				return conn, nil
 			}
-			counters.Add(poolCounterPing, 1)
+		case p.slots <- struct{}{}:
+			counters.Add(poolCounterMiss, 1)
+			return p.dial()
+		case <-timer.C:
+			counters.Add(poolCounterWaitTimeout, 1)
+			return nil, errors.Errorf("pool[%s].get.connection.timeout[%v].max.connections[%d]", p.name(), p.waitTimeout, cap(p.slots))
 		}
-		counters.Add(poolCounterHit, 1)
-		return conn, nil
-	default:
-		counters.Add(poolCounterMiss, 1)
-		return p.reconnect()
 	}
 }
 
+// check returns the idle connection if it's OK, or else closes it and returns nil.
+func (p *Pool) check(conn Connection) Connection {
+	counters := p.counters
+	now := time.Now().Unix()
+	if p.expired(conn, now, false) {
+		counters.Add(poolCounterExpired, 1)
+		conn.Close()
+		return nil
+	}
+
+	// If the idle time more than 1s,
+	// we will do a ping to check the connection is OK or NOT.
+	elapsed := (now - conn.Timestamp())
+	if elapsed > 1 {
+		// If elapsed time more than maxIdleTime, we create new one.
+		if elapsed > atomic.LoadInt64(&p.maxIdleTime) {
+			conn.Close()
+			return nil
+		}
+
+		if err := conn.Ping(); err != nil {
+			counters.Add(poolCounterPingBroken, 1)
+			conn.Close()
+			return nil
+		}
+		counters.Add(poolCounterPing, 1)
+	}
+	counters.Add(poolCounterHit, 1)
+	return conn
+}
+
 // Put used to put a connection to pool.
 func (p *Pool) Put(conn Connection) {
+	p.counters.Add(poolCounterPut, 1)
 	p.put(conn, true)
 }
 
 func (p *Pool) put(conn Connection, updateTs bool) {
-	p.counters.Add(poolCounterPut, 1)
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.connections == nil {
+		conn.Close()
 		return
 	}
 
+	// The connection not created by the pool must hold a slot.
+	if !conn.attached() {
+		select {
+		case p.slots <- struct{}{}:
+			conn.attach()
+		default:
+			conn.Close()
+			return
+		}
+	}
+
 	if updateTs {
 		conn.SetTimestamp(time.Now().Unix())
 	}
@@ -144,6 +428,19 @@ func (p *Pool) put(conn Connection, updateTs bool) {
 // Close used to close the pool.
 func (p *Pool) Close() {
 	p.counters.Add(poolCounterClose, 1)
+	monitor.UnregisterPool(p.monitorID)
+	p.mu.Lock()
+	h, done := p.health, p.done
+	p.health, p.done = nil, nil
+	p.mu.Unlock()
+	if h != nil {
+		h.stop()
+	}
+	if done != nil {
+		close(done)
+		p.wg.Wait()
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.connections == nil {
@@ -163,9 +460,9 @@ func (p *Pool) getConns() chan Connection {
 }
 
 // JSON returns the available string.
-// available is the number of currently unused connections.
+// open is the number of the open connections, idle is the number of currently unused connections.
 func (p *Pool) JSON() string {
 	b := bytes.NewBuffer(make([]byte, 0, 256))
-	fmt.Fprintf(b, `{"name": "%s","capacity": %d, "counters":"%s"}`, p.conf.Name, p.conf.MaxConnections, p.counters.String())
+	fmt.Fprintf(b, `{"name": "%s","capacity": %d, "open": %d, "idle": %d, "waiters": %d, "counters":"%s"}`, p.conf.Name, cap(p.slots), len(p.slots), len(p.getConns()), p.waiters.Get(), p.counters.String())
 	return b.String()
 }
@@ -50,7 +50,7 @@ func TestPool(t *testing.T) {
 			assert.Nil(t, err)
 			pool.Put(conn)
 		}
-		want := "{\"name\": \"node1\",\"capacity\": 64, \"counters\":\"{\"#pool.get\": 1, \"#pool.miss\": 1, \"#pool.put\": 164}\"}"
+		want := "{\"name\": \"node1\",\"capacity\": 64, \"open\": 64, \"idle\": 63, \"waiters\": 0, \"counters\":\"{\"#pool.get\": 1, \"#pool.miss\": 1, \"#pool.put\": 164}\"}"
 		got := pool.JSON()
 		assert.Equal(t, want, got)
 	}
@@ -139,6 +139,8 @@ func TestPoolTimeout(t *testing.T) {
 	// Connection
 	conf := MockBackendConfigDefault(addr, addr)
 	conf.MaxConnections = 64
+	// The gets exceed the max connections.
+	conf.WaitTimeout = 10
 	pool := NewPool(log, conf)
 
 	ch2 := make(chan bool)
@@ -178,3 +180,139 @@ func TestPoolTimeout(t *testing.T) {
 	close(ch2)
 	wg.Wait()
 }
+
+func TestPoolMaxConnections(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// MySQL Server starts...
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	addr := svr.Addr()
+
+	conf := MockBackendConfigDefault("node1", addr)
+	conf.MaxConnections = 2
+	conf.WaitTimeout = 100
+	conf.MaxWaiters = 1
+	pool := NewPool(log, conf)
+	defer pool.Close()
+
+	conn1, err := pool.Get()
+	assert.Nil(t, err)
+	conn2, err := pool.Get()
+	assert.Nil(t, err)
+
+	// Wait timeout.
+	{
+		_, err := pool.Get()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "get.connection.timeout")
+	}
+
+	// Wait queue is full.
+	{
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Get()
+		}()
+		for pool.waiters.Get() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		_, err := pool.Get()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "wait.queue.is.full")
+		wg.Wait()
+	}
+
+	// The waiter gets the recycled one.
+	{
+		var wg sync.WaitGroup
+		var got Connection
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, _ = pool.Get()
+		}()
+		for pool.waiters.Get() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		conn1.Recycle()
+		wg.Wait()
+		assert.Equal(t, conn1, got)
+	}
+
+	// The waiter gets the slot of the closed one.
+	{
+		var wg sync.WaitGroup
+		var got Connection
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, _ = pool.Get()
+		}()
+		for pool.waiters.Get() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		conn2.Close()
+		wg.Wait()
+		assert.NotNil(t, got)
+		assert.NotEqual(t, conn2, got)
+		assert.Equal(t, 2, len(pool.slots))
+		got.Close()
+	}
+	assert.Equal(t, 1, len(pool.slots))
+}
+
+func TestPoolMinIdleAndLifetime(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// MySQL Server starts...
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	addr := svr.Addr()
+
+	conf := MockBackendConfigDefault("node1", addr)
+	conf.MaxConnections = 8
+	conf.MinIdle = 3
+	conf.MaxLifetime = 1000
+	pool := NewPool(log, conf)
+	defer pool.Close()
+
+	// Warmup.
+	for i := 0; i < 100 && len(pool.getConns()) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 3, len(pool.getConns()))
+	assert.Equal(t, 3, len(pool.slots))
+	old, err := pool.Get()
+	assert.Nil(t, err)
+	old.Recycle()
+
+	// The expired connections are reaped and the min idle ones are created again.
+	time.Sleep(2500 * time.Millisecond)
+	assert.True(t, pool.counters.Counts()[poolCounterReap] >= 3)
+
+	// Wait for the warmup right after the next reap, the idle connections are stable until the next tick.
+	reaps := pool.counters.Counts()[poolCounterReap]
+	for i := 0; i < 300; i++ {
+		if pool.counters.Counts()[poolCounterReap] > reaps && len(pool.getConns()) == 3 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, 3, len(pool.getConns()))
+	conn, err := pool.Get()
+	assert.Nil(t, err)
+	assert.NotEqual(t, old, conn)
+	conn.Recycle()
+
+	want := map[string]int64{"open": 3, "idle": 3, "waiters": 0, "max": 8}
+	assert.Equal(t, want, pool.gauges())
+}
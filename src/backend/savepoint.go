@@ -0,0 +1,185 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	txnCounterSavepoint             = "#txn.savepoint"
+	txnCounterSavepointError        = "#txn.savepoint.error"
+	txnCounterRollbackTo            = "#txn.rollback.to.savepoint"
+	txnCounterRollbackToError       = "#txn.rollback.to.savepoint.error"
+	txnCounterReleaseSavepoint      = "#txn.release.savepoint"
+	txnCounterReleaseSavepointError = "#txn.release.savepoint.error"
+)
+
+// savepoint tuple.
+type savepoint struct {
+	name string
+	// backends which the savepoint has been set on.
+	backends map[string]bool
+}
+
+func savepointQuery(format string, name string) string {
+	return fmt.Sprintf(format, "`"+strings.Replace(name, "`", "``", -1)+"`")
+}
+
+// findSavepoint returns the index of the savepoint, -1 if not found.
+// The savepoint name is case-insensitive as MySQL.
+func (txn *Txn) findSavepoint(name string) int {
+	for i := len(txn.savepoints) - 1; i >= 0; i-- {
+		if strings.EqualFold(txn.savepoints[i].name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// executeOnEnlisted used to execute the query on the enlisted backends concurrently,
+// returns the backends which executed successfully.
+func (txn *Txn) executeOnEnlisted(query string, filter func(back string) bool) (map[string]bool, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	log := txn.log
+	done := make(map[string]bool)
+	allErrors := make([]error, 0, 8)
+
+	txn.twopcConnMu.RLock()
+	conns := make(map[string]Connection, len(txn.twopcConnections))
+	for back, conn := range txn.twopcConnections {
+		if filter == nil || filter(back) {
+			conns[back] = conn
+		}
+	}
+	txn.twopcConnMu.RUnlock()
+
+	for back, conn := range conns {
+		wg.Add(1)
+		go func(back string, c Connection) {
+			defer wg.Done()
+			log.Debug("conn[%v].txn.sessid[%v].execute[%v]", c.ID(), txn.sessionID, query)
+			if _, err := c.Execute(query); err != nil {
+				log.Error("txn.execute[%v].on[%v].error:%+v", query, c.Address(), err)
+				mu.Lock()
+				allErrors = append(allErrors, err)
+				mu.Unlock()
+				return
+			}
+			mu.Lock()
+			done[back] = true
+			mu.Unlock()
+		}(back, conn)
+	}
+	wg.Wait()
+
+	if len(allErrors) > 0 {
+		txn.incErrors()
+		return done, allErrors[0]
+	}
+	return done, nil
+}
+
+// replaySavepoints used to set the savepoints on the backend which enlisted after the savepoints were taken,
+// so that the work on it can be rolled back to the savepoints too.
+// It must be called after the XA START.
+func (txn *Txn) replaySavepoints(back string, c Connection) error {
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+
+	for _, sp := range txn.savepoints {
+		if sp.backends[back] {
+			continue
+		}
+		if _, err := c.Execute(savepointQuery("SAVEPOINT %s", sp.name)); err != nil {
+			return err
+		}
+		sp.backends[back] = true
+	}
+	return nil
+}
+
+// Savepoint used to set a named savepoint on all the enlisted backends of the multiple-statement transaction.
+// If the savepoint with the same name exists, the old one will be replaced.
+func (txn *Txn) Savepoint(name string) error {
+	txnCounters.Add(txnCounterSavepoint, 1)
+	if !txn.isMultiStmtTxn {
+		return errors.New("txn.savepoint.only.supported.in.multistmt.txn")
+	}
+
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+
+	backends, err := txn.executeOnEnlisted(savepointQuery("SAVEPOINT %s", name), nil)
+	if err != nil {
+		txnCounters.Add(txnCounterSavepointError, 1)
+		return err
+	}
+	if i := txn.findSavepoint(name); i >= 0 {
+		txn.savepoints = append(txn.savepoints[:i], txn.savepoints[i+1:]...)
+	}
+	txn.savepoints = append(txn.savepoints, &savepoint{name: name, backends: backends})
+	return nil
+}
+
+// RollbackToSavepoint used to rollback the enlisted backends to the named savepoint,
+// the savepoints set after it are removed, the savepoint itself is retained.
+func (txn *Txn) RollbackToSavepoint(name string) error {
+	txnCounters.Add(txnCounterRollbackTo, 1)
+	if !txn.isMultiStmtTxn {
+		return errors.New("txn.savepoint.only.supported.in.multistmt.txn")
+	}
+
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+
+	i := txn.findSavepoint(name)
+	if i < 0 {
+		return errors.Errorf("SAVEPOINT %s does not exist", name)
+	}
+	sp := txn.savepoints[i]
+	if _, err := txn.executeOnEnlisted(savepointQuery("ROLLBACK TO SAVEPOINT %s", sp.name), func(back string) bool {
+		return sp.backends[back]
+	}); err != nil {
+		txnCounters.Add(txnCounterRollbackToError, 1)
+		return err
+	}
+	txn.savepoints = txn.savepoints[:i+1]
+	return nil
+}
+
+// ReleaseSavepoint used to remove the named savepoint and the savepoints set after it.
+func (txn *Txn) ReleaseSavepoint(name string) error {
+	txnCounters.Add(txnCounterReleaseSavepoint, 1)
+	if !txn.isMultiStmtTxn {
+		return errors.New("txn.savepoint.only.supported.in.multistmt.txn")
+	}
+
+	txn.savepointMu.Lock()
+	defer txn.savepointMu.Unlock()
+
+	i := txn.findSavepoint(name)
+	if i < 0 {
+		return errors.Errorf("SAVEPOINT %s does not exist", name)
+	}
+	sp := txn.savepoints[i]
+	if _, err := txn.executeOnEnlisted(savepointQuery("RELEASE SAVEPOINT %s", sp.name), func(back string) bool {
+		return sp.backends[back]
+	}); err != nil {
+		txnCounters.Add(txnCounterReleaseSavepointError, 1)
+		return err
+	}
+	txn.savepoints = txn.savepoints[:i]
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTxnSavepoint(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQueryPattern("XA .*", result1)
+	fakedb.AddQueryPattern("SAVEPOINT .*", &sqltypes.Result{})
+	fakedb.AddQueryPattern("ROLLBACK TO SAVEPOINT .*", &sqltypes.Result{})
+	fakedb.AddQueryPattern("RELEASE SAVEPOINT .*", &sqltypes.Result{})
+
+	// Not in multiple-statement txn.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		err = txn.Savepoint("sp1")
+		assert.NotNil(t, err)
+		err = txn.RollbackToSavepoint("sp1")
+		assert.NotNil(t, err)
+		err = txn.ReleaseSavepoint("sp1")
+		assert.NotNil(t, err)
+	}
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+
+	// Savepoints.
+	{
+		for _, name := range []string{"sp1", "sp2", "sp3", "SP2"} {
+			err = txn.Savepoint(name)
+			assert.Nil(t, err)
+		}
+		// The sp2 is replaced by SP2.
+		assert.Equal(t, 3, len(txn.savepoints))
+		assert.Equal(t, "SP2", txn.savepoints[2].name)
+		assert.Equal(t, 2, len(txn.savepoints[2].backends))
+	}
+
+	// Rollback to.
+	{
+		err = txn.RollbackToSavepoint("sp3")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(txn.savepoints))
+
+		err = txn.RollbackToSavepoint("sp2")
+		assert.NotNil(t, err)
+		assert.Equal(t, "SAVEPOINT sp2 does not exist", err.Error())
+	}
+
+	// Replay on the backend which enlisted later.
+	{
+		for back, conn := range txn.twopcConnections {
+			delete(txn.savepoints[0].backends, back)
+			err = txn.replaySavepoints(back, conn)
+			assert.Nil(t, err)
+			assert.True(t, txn.savepoints[0].backends[back])
+			break
+		}
+	}
+
+	// Release.
+	{
+		err = txn.ReleaseSavepoint("sp1")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(txn.savepoints))
+
+		err = txn.ReleaseSavepoint("sp1")
+		assert.NotNil(t, err)
+	}
+
+	// Errors.
+	{
+		fakedb.AddQueryErrorPattern("SAVEPOINT .*", errors.New("mock.savepoint.error"))
+		err = txn.Savepoint("sp4")
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, len(txn.savepoints))
+	}
+}
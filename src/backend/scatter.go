@@ -14,6 +14,7 @@ import (
 	"path"
 	"sort"
 	"sync"
+	"time"
 
 	"config"
 	"monitor"
@@ -30,6 +31,8 @@ const (
 type Scatter struct {
 	log      *xlog.Log
 	mu       sync.RWMutex
+	conf     *config.ScatterConfig
+	quorum   FailoverQuorum
 	txnMgr   *TxnManager
 	metadir  string
 	backends map[string]*Pool
@@ -45,9 +48,19 @@ func NewScatter(log *xlog.Log, metadir string) *Scatter {
 	}
 }
 
-// Init is used to init the xaCheck and start the xaCheck thread.
+// Init is used to init the xaCheck and start the xaCheck thread, and start the health check of the backends.
 func (scatter *Scatter) Init(scatterConf *config.ScatterConfig) error {
-	return scatter.txnMgr.Init(scatter, scatterConf)
+	if err := scatter.txnMgr.Init(scatter, scatterConf); err != nil {
+		return err
+	}
+
+	scatter.mu.Lock()
+	defer scatter.mu.Unlock()
+	scatter.conf = scatterConf
+	for _, pool := range scatter.backends {
+		pool.startHealthCheck(scatterConf, scatter.failover)
+	}
+	return nil
 }
 
 // Add backend node.
@@ -66,6 +79,9 @@ func (scatter *Scatter) add(config *config.BackendConfig) error {
 	}
 
 	pool := NewPool(scatter.log, config)
+	if scatter.conf != nil {
+		pool.startHealthCheck(scatter.conf, scatter.failover)
+	}
 	scatter.backends[config.Name] = pool
 	monitor.BackendInc("backend")
 	return nil
@@ -146,9 +162,29 @@ func (scatter *Scatter) FlushConfig() error {
 
 // LoadConfig used to load all backends from metadir/backend.json file.
 func (scatter *Scatter) LoadConfig() error {
+	changed, err := scatter.loadConfig()
+	if err != nil {
+		return err
+	}
+	// The backends failed over by the peer, resolve the prepared XA branches of this coordinator.
+	scatter.mu.RLock()
+	inited := scatter.conf != nil
+	scatter.mu.RUnlock()
+	if len(changed) > 0 && inited {
+		go scatter.xaRecoverBackends(changed)
+	}
+	return nil
+}
+
+// loadConfig returns the backends whose addresses are changed.
+func (scatter *Scatter) loadConfig() ([]string, error) {
 	scatter.mu.Lock()
 	defer scatter.mu.Unlock()
 
+	olds := make(map[string]string, len(scatter.backends))
+	for name, pool := range scatter.backends {
+		olds[name] = pool.conf.Address
+	}
 	// Do clear first.
 	scatter.clear()
 
@@ -161,28 +197,32 @@ func (scatter *Scatter) LoadConfig() error {
 		backends := config.BackendsConfig{}
 		if err := config.WriteConfig(file, backends); err != nil {
 			log.Error("scatter.flush.backends.to.file[%v].error:%v", file, err)
-			return err
+			return nil, err
 		}
 	}
 
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
 		log.Error("scatter.load.from.file[%v].error:%v", file, err)
-		return err
+		return nil, err
 	}
 	conf, err := config.ReadBackendsConfig(string(data))
 	if err != nil {
 		log.Error("scatter.parse.json.file[%v].error:%v", file, err)
-		return err
+		return nil, err
 	}
+	var changed []string
 	for _, backend := range conf.Backends {
 		if err := scatter.add(backend); err != nil {
 			log.Error("scatter.add.backend[%+v].error:%v", backend.Name, err)
-			return err
+			return nil, err
+		}
+		if old, ok := olds[backend.Name]; ok && old != backend.Address {
+			changed = append(changed, backend.Name)
 		}
 		log.Info("scatter.load.backend:%+v", backend.Name)
 	}
-	return nil
+	return changed, nil
 }
 
 // AllBackends returns all backends.
@@ -228,6 +268,26 @@ func (scatter *Scatter) CheckBackend(backenName string) bool {
 	return false
 }
 
+// CheckSessionVars used to check the session variables(name->value in sql) by applying them on a backend connection,
+// the invalid value such as an unknown time zone fails here rather than the later queries.
+// The connection keeps the variables applied, the session using them hits the cache.
+func (scatter *Scatter) CheckSessionVars(vars map[string]string) error {
+	pools := scatter.PoolClone()
+	var lastErr error
+	for _, name := range scatter.Backends() {
+		conn, err := pools[name].Get()
+		if err != nil {
+			// Try the next backend, the backend may be down.
+			lastErr = err
+			continue
+		}
+		err = conn.SetSessionVars(vars)
+		conn.Recycle()
+		return err
+	}
+	return lastErr
+}
+
 // PoolClone used to copy backends to new map.
 func (scatter *Scatter) PoolClone() map[string]*Pool {
 	poolMap := make(map[string]*Pool)
@@ -250,6 +310,66 @@ func (scatter *Scatter) BackendConfigsClone() []*config.BackendConfig {
 	return beConfigs
 }
 
+// BackendStatus tuple, the backend config with its health.
+type BackendStatus struct {
+	*config.BackendConfig
+	Health *Health `json:"health,omitempty"`
+}
+
+// Backendz returns the backends with their health order by the name.
+func (scatter *Scatter) Backendz() []*BackendStatus {
+	scatter.mu.RLock()
+	defer scatter.mu.RUnlock()
+	status := make([]*BackendStatus, 0, len(scatter.backends))
+	for _, pool := range scatter.backends {
+		status = append(status, &BackendStatus{BackendConfig: pool.conf, Health: pool.Health()})
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+	return status
+}
+
+// SetCommitFence used to set the commit fence of the peers.
+func (scatter *Scatter) SetCommitFence(fence CommitFence) {
+	scatter.txnMgr.SetCommitFence(fence)
+}
+
+// FenceLock used to acquire the commit locks of the backends for the xid.
+func (scatter *Scatter) FenceLock(xid string, backends []string, lease time.Duration) error {
+	return scatter.txnMgr.FenceLock(xid, backends, lease)
+}
+
+// GateLock used to acquire the gates of the 'db.table's for the xid of the cutover.
+func (scatter *Scatter) GateLock(xid string, tables []string, lease time.Duration) error {
+	return scatter.txnMgr.GateLock(xid, tables, lease)
+}
+
+// GateRLock used to acquire the read locks of the gates of the 'db.table's for the DML.
+func (scatter *Scatter) GateRLock(tables []string) func() {
+	return scatter.txnMgr.GateRLock(tables)
+}
+
+// FenceRenew used to extend the lease of the commit locks held by the xid.
+func (scatter *Scatter) FenceRenew(xid string, lease time.Duration) error {
+	return scatter.txnMgr.FenceRenew(xid, lease)
+}
+
+// FenceUnlock used to release the commit locks held by the xid.
+func (scatter *Scatter) FenceUnlock(xid string) {
+	scatter.txnMgr.FenceUnlock(xid)
+}
+
+// Xaz returns the in-doubt XA transactions.
+func (scatter *Scatter) Xaz() (*Xaz, error) {
+	return scatter.txnMgr.Xaz(scatter)
+}
+
+// XaResolve used to commit or rollback the in-doubt xid manually.
+func (scatter *Scatter) XaResolve(xid string, commit bool) (int, error) {
+	return scatter.txnMgr.XaResolve(scatter, xid, commit)
+}
+
 // CreateTransaction used to create a transaction.
 func (scatter *Scatter) CreateTransaction() (*Txn, error) {
 	return scatter.txnMgr.CreateTxn(scatter.PoolClone())
@@ -9,12 +9,14 @@
 package backend
 
 import (
+	"errors"
 	"os"
 	"testing"
 
 	"fakedb"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -220,3 +222,36 @@ func TestScatterNormalBackends(t *testing.T) {
 		assert.Equal(t, "node1", backends[0])
 	}
 }
+
+func TestScatterCheckSessionVars(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_backend_", log)
+	defer os.RemoveAll(tmpDir)
+
+	scatter := NewScatter(log, tmpDir)
+	defer scatter.Close()
+	fakedb := fakedb.New(log, 1)
+	defer fakedb.Close()
+
+	// No backends.
+	{
+		err := scatter.CheckSessionVars(map[string]string{"time_zone": "'+00:00'"})
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, scatter.Add(MockBackendConfigDefault("node1", fakedb.Addrs()[0])))
+	fakedb.AddQuery("SET @@SESSION.time_zone = '+00:00'", &sqltypes.Result{})
+	fakedb.AddQueryError("SET @@SESSION.time_zone = 'bogus'", errors.New("mock.unknown.time.zone"))
+
+	// ok
+	{
+		err := scatter.CheckSessionVars(map[string]string{"time_zone": "'+00:00'"})
+		assert.Nil(t, err)
+	}
+
+	// invalid
+	{
+		err := scatter.CheckSessionVars(map[string]string{"time_zone": "'bogus'"})
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"config"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	txnCounterSnapshotBegin = "#txn.snapshot.begin"
+)
+
+// SnapshotPosition tuple, the binlog position of the backend where the snapshot is taken.
+type SnapshotPosition struct {
+	Backend  string `json:"backend"`
+	Address  string `json:"address"`
+	File     string `json:"binlog-file"`
+	Position uint64 `json:"binlog-position"`
+	GTID     string `json:"gtid-executed"`
+	// Approximate is true if the position is not read atomically with the snapshot.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// Snapshot tuple.
+type Snapshot struct {
+	Time      time.Time          `json:"time"`
+	Positions []SnapshotPosition `json:"positions"`
+}
+
+// BeginSnapshot used to start the consistent snapshot on all the normal backends.
+// The commit locks are held while the snapshots are opening, so no XA COMMIT lands on a part of the backends,
+// the reads of the txn see a point-in-time image across the shards.
+// The snapshot txn is read only, it ends when the txn finishes.
+func (txn *Txn) BeginSnapshot() (*Snapshot, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	log := txn.log
+	txnCounters.Add(txnCounterSnapshotBegin, 1)
+	txn.snapshot = true
+	txn.chars.ReadOnly = true
+	txn.chars.ConsistentSnapshot = true
+
+	backs := make([]string, 0, len(txn.backends))
+	for back, pool := range txn.backends {
+		if pool.conf.Role != config.NormalBackend {
+			continue
+		}
+		backs = append(backs, back)
+	}
+	sort.Strings(backs)
+
+	// Get the connections before locking, the commits shouldn't wait for the pool.
+	conns := make([]Connection, len(backs))
+	for i, back := range backs {
+		conn, err := txn.fetchOneConnection(back)
+		if err != nil {
+			return nil, err
+		}
+		if err := txn.applyCharacteristics(conn); err != nil {
+			return nil, err
+		}
+		conns[i] = conn
+	}
+
+	allErrors := make([]error, 0, 8)
+	snapshot := &Snapshot{Positions: make([]SnapshotPosition, len(backs))}
+	if err := txn.mgr.CommitRLock(backs); err != nil {
+		return nil, err
+	}
+	snapshot.Time = time.Now()
+	for i := range backs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn := conns[i]
+			query := txn.chars.startTransactionQuery()
+			if _, err := conn.Execute(query); err != nil {
+				log.Error("txn.snapshot.start.on[%s].error:%+v", backs[i], err)
+				mu.Lock()
+				allErrors = append(allErrors, err)
+				mu.Unlock()
+				return
+			}
+			snapshot.Positions[i] = txn.snapshotPosition(backs[i], conn)
+		}(i)
+	}
+	wg.Wait()
+	txn.mgr.CommitRUnlock(backs)
+
+	if len(allErrors) > 0 {
+		return nil, allErrors[0]
+	}
+	return snapshot, nil
+}
+
+// snapshotPosition returns the binlog position of the consistent snapshot of the connection, the position is empty if the binlog is disabled.
+// The Binlog_snapshot_file/Binlog_snapshot_position status(Percona Server and MariaDB) is the position where the snapshot is
+// taken atomically. Without them, the position is read by the SHOW MASTER STATUS after the snapshot and is marked approximate:
+// the XA COMMITs of radon are locked out, but the writes not by radon may land between the snapshot and the position.
+func (txn *Txn) snapshotPosition(back string, conn Connection) SnapshotPosition {
+	log := txn.log
+	pos := SnapshotPosition{Backend: back, Address: conn.Address()}
+	qr, err := conn.Execute("SHOW STATUS LIKE 'binlog_snapshot_%'")
+	if err == nil {
+		for _, row := range qr.Rows {
+			if len(row) < 2 {
+				continue
+			}
+			switch row[0].String() {
+			case "Binlog_snapshot_file":
+				pos.File = row[1].String()
+			case "Binlog_snapshot_position":
+				pos.Position, _ = strconv.ParseUint(row[1].String(), 10, 64)
+			case "Binlog_snapshot_gtid_executed":
+				pos.GTID = row[1].String()
+			}
+		}
+		if pos.File != "" {
+			return pos
+		}
+	}
+
+	if qr, err = conn.Execute("SHOW MASTER STATUS"); err != nil {
+		log.Error("txn.snapshot.show.master.status.on[%s].error:%+v", back, err)
+		return pos
+	}
+	if len(qr.Rows) == 0 {
+		log.Warning("txn.snapshot.on[%s].binlog.disabled", back)
+		return pos
+	}
+
+	row := qr.Rows[0]
+	for i, field := range qr.Fields {
+		if i >= len(row) {
+			break
+		}
+		switch field.Name {
+		case "File":
+			pos.File = row[i].String()
+		case "Position":
+			pos.Position, _ = strconv.ParseUint(row[i].String(), 10, 64)
+		case "Executed_Gtid_Set":
+			pos.GTID = row[i].String()
+		}
+	}
+	pos.Approximate = true
+	log.Warning("txn.snapshot.on[%s].position[%s:%d].is.approximate", back, pos.File, pos.Position)
+	return pos
+}
+
+// endSnapshot used to end the snapshot on the connections, the connection is closed if it fails.
+func (txn *Txn) endSnapshot() {
+	log := txn.log
+	for back, conn := range txn.twopcConnections {
+		if _, err := conn.Execute("ROLLBACK"); err != nil {
+			log.Error("txn.snapshot.end.on[%s].error:%+v", back, err)
+			txn.incErrors()
+		}
+	}
+}
+
+// snapshotStreamFetch used to stream fetch the querys on the snapshot connections one by one,
+// since a connection can't hold more than one cursor at a time.
+func (txn *Txn) snapshotStreamFetch(req *xcontext.RequestContext, callback func(*sqltypes.Result) error, streamBufferSize int) error {
+	var allRowCount, allByteCount, allBatchCount uint64
+
+	log := txn.log
+	byteCount := 0
+	qr := &sqltypes.Result{Rows: make([][]sqltypes.Value, 0, 256), State: sqltypes.RStateRows}
+	for i, qt := range req.Querys {
+		conn, err := txn.fetchOneConnection(qt.Backend)
+		if err != nil {
+			return err
+		}
+		cursor, err := conn.ExecuteStreamFetch(qt.Query)
+		if err != nil {
+			return err
+		}
+
+		// Send Fields.
+		if i == 0 {
+			qr.Fields = cursor.Fields()
+			fieldsQr := &sqltypes.Result{Fields: qr.Fields, State: sqltypes.RStateFields}
+			if err := callback(fieldsQr); err != nil {
+				cursor.Close()
+				return err
+			}
+		}
+
+		// Send rows.
+		for cursor.Next() {
+			row, err := cursor.RowValues()
+			if err != nil {
+				log.Error("txn.snapshot.stream.cursor[%s].RowValues.error:%+v", qt.Backend, err)
+				cursor.Close()
+				return err
+			}
+			rowLen := sqltypes.Values(row).Len()
+			allRowCount++
+			byteCount += rowLen
+			allByteCount += uint64(rowLen)
+			qr.Rows = append(qr.Rows, row)
+
+			if byteCount >= streamBufferSize {
+				if err := callback(qr); err != nil {
+					log.Error("txn.snapshot.stream.cursor.send.error:%+v", err)
+					cursor.Close()
+					return err
+				}
+				qr.Rows = qr.Rows[:0]
+				allBatchCount++
+				byteCount = 0
+			}
+		}
+		if err := cursor.Close(); err != nil {
+			log.Error("txn.snapshot.stream.cursor[%s].error:%+v", qt.Backend, err)
+			return err
+		}
+	}
+	if len(qr.Rows) > 0 {
+		if err := callback(qr); err != nil {
+			log.Error("txn.snapshot.stream.cursor.send.error:%+v", err)
+			return err
+		}
+	}
+	log.Warning("txn.snapshot.stream.send.done[allRows:%v, allBytes:%v, allBatches:%v]", allRowCount, allByteCount, allBatchCount)
+
+	// Send finished.
+	finishQr := &sqltypes.Result{Fields: qr.Fields, RowsAffected: allRowCount, State: sqltypes.RStateFinished}
+	return callback(finishQr)
+}
@@ -0,0 +1,192 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"xcontext"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var masterStatusResult = &sqltypes.Result{
+	Fields: []*querypb.Field{
+		{Name: "File", Type: querypb.Type_VARCHAR},
+		{Name: "Position", Type: querypb.Type_UINT64},
+		{Name: "Binlog_Do_DB", Type: querypb.Type_VARCHAR},
+		{Name: "Binlog_Ignore_DB", Type: querypb.Type_VARCHAR},
+		{Name: "Executed_Gtid_Set", Type: querypb.Type_VARCHAR},
+	},
+	Rows: [][]sqltypes.Value{
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("mysql-bin.000003")),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte("1234")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")),
+		},
+	},
+}
+
+var snapshotStatusResult = &sqltypes.Result{
+	Fields: []*querypb.Field{
+		{Name: "Variable_name", Type: querypb.Type_VARCHAR},
+		{Name: "Value", Type: querypb.Type_VARCHAR},
+	},
+	Rows: [][]sqltypes.Value{
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("Binlog_snapshot_file")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("mysql-bin.000004")),
+		},
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("Binlog_snapshot_position")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("5678")),
+		},
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("Binlog_snapshot_gtid_executed")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6")),
+		},
+	},
+}
+
+func TestTxnSnapshot(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	startQuery := "START TRANSACTION WITH CONSISTENT SNAPSHOT"
+	fakedb.AddQuery("SET TRANSACTION READ ONLY", &sqltypes.Result{})
+	fakedb.AddQuery(startQuery, &sqltypes.Result{})
+	fakedb.AddQuery("SHOW MASTER STATUS", masterStatusResult)
+	fakedb.AddQuery("ROLLBACK", &sqltypes.Result{})
+
+	querys := []xcontext.QueryTuple{
+		{Query: "select * from node1", Backend: addrs[0]},
+		{Query: "select * from node2", Backend: addrs[1]},
+		{Query: "select * from node3", Backend: addrs[1]},
+	}
+	for _, qt := range querys {
+		fakedb.AddQueryStream(qt.Query, result1)
+	}
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+
+	// The snapshot waits for the XA COMMIT in progress.
+	txnMgr.CommitLock("xid", addrs[:2])
+	done := make(chan struct{})
+	var snapshot *Snapshot
+	go func() {
+		snapshot, err = txn.BeginSnapshot()
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(startQuery))
+	txnMgr.CommitUnlock("xid", addrs[:2])
+	<-done
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fakedb.GetQueryCalledNum(startQuery))
+	assert.Equal(t, 2, len(snapshot.Positions))
+	for _, pos := range snapshot.Positions {
+		assert.Equal(t, "mysql-bin.000003", pos.File)
+		assert.Equal(t, uint64(1234), pos.Position)
+		assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", pos.GTID)
+		// Without the Binlog_snapshot_xx status.
+		assert.True(t, pos.Approximate)
+	}
+
+	// The querys are fetched on the snapshot connections.
+	rctx := &xcontext.RequestContext{Querys: querys}
+	callbackQr := &sqltypes.Result{}
+	err = txn.ExecuteStreamFetch(rctx, func(qr *sqltypes.Result) error {
+		callbackQr.AppendResult(qr)
+		return nil
+	}, 8)
+	assert.Nil(t, err)
+	assert.Equal(t, 3*len(result1.Rows), len(callbackQr.Rows))
+	assert.Equal(t, uint64(3*len(result1.Rows)), callbackQr.RowsAffected)
+
+	// The writes are refused.
+	req := &xcontext.RequestContext{Mode: xcontext.ReqNormal, TxnMode: xcontext.TxnWrite}
+	req.Querys = append(req.Querys, xcontext.QueryTuple{Query: "insert into t1 values(1)", Backend: addrs[0]})
+	_, err = txn.Execute(req)
+	sqlErr, ok := err.(*sqldb.SQLError)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(1792), sqlErr.Num)
+
+	// The snapshot ends when the txn finishes.
+	err = txn.Finish()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fakedb.GetQueryCalledNum("ROLLBACK"))
+}
+
+func TestTxnSnapshotError(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	startQuery := "START TRANSACTION WITH CONSISTENT SNAPSHOT"
+	fakedb.AddQuery("SET TRANSACTION READ ONLY", &sqltypes.Result{})
+	fakedb.AddQuery(startQuery, &sqltypes.Result{})
+	fakedb.AddQuery("ROLLBACK", &sqltypes.Result{})
+
+	// The binlog is disabled.
+	{
+		fakedb.AddQuery("SHOW MASTER STATUS", &sqltypes.Result{Fields: masterStatusResult.Fields})
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		snapshot, err := txn.BeginSnapshot()
+		assert.Nil(t, err)
+		for _, pos := range snapshot.Positions {
+			assert.NotEqual(t, "", pos.Backend)
+			assert.Equal(t, "", pos.File)
+			assert.Equal(t, uint64(0), pos.Position)
+		}
+	}
+
+	// The position of the snapshot is read atomically.
+	{
+		fakedb.AddQuery("SHOW STATUS LIKE 'binlog_snapshot_%'", snapshotStatusResult)
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		snapshot, err := txn.BeginSnapshot()
+		assert.Nil(t, err)
+		for _, pos := range snapshot.Positions {
+			assert.Equal(t, "mysql-bin.000004", pos.File)
+			assert.Equal(t, uint64(5678), pos.Position)
+			assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6", pos.GTID)
+			assert.False(t, pos.Approximate)
+		}
+	}
+
+	// The snapshot fails to start.
+	{
+		fakedb.AddQueryError(startQuery, errors.New("mock.start.snapshot.error"))
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		_, err = txn.BeginSnapshot()
+		assert.NotNil(t, err)
+	}
+}
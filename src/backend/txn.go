@@ -32,6 +32,11 @@ var (
 	txnCounterTxnAbort              = "#txn.abort"
 )
 
+const (
+	// maxRecordQuerys is the max number of the query tuples recorded for a statement.
+	maxRecordQuerys = 4096
+)
+
 type txnState int32
 
 const (
@@ -60,18 +65,28 @@ type Transaction interface {
 	Finish() error
 
 	BeginScatter() error
+	BeginLocal() error
 	CommitScatter() error
 	RollbackScatter() error
 	SetMultiStmtTxn()
 	SetSessionID(id uint32)
 
+	Savepoint(name string) error
+	RollbackToSavepoint(name string) error
+	ReleaseSavepoint(name string) error
+
 	SetTimeout(timeout int)
 	SetMaxResult(max int)
 	SetMaxJoinRows(max int)
 	MaxJoinRows() int
+	SetProfile(profile *xcontext.Profile)
+	SetSessionVars(vars map[string]string)
+	SetCharacteristics(chars TxnCharacteristics)
 
 	Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	ExecuteRaw(database string, query string) (*sqltypes.Result, error)
+	SetRecordQuerys(record bool)
+	PopQueryTuples() []xcontext.QueryTuple
 }
 
 // Txn tuple.
@@ -96,8 +111,22 @@ type Txn struct {
 	errors            int
 	twopcConnections  map[string]Connection
 	normalConnections []Connection
+	reusedConnections map[string]Connection
 	twopcConnMu       sync.RWMutex
 	normalConnMu      sync.RWMutex
+	savepoints        []*savepoint
+	savepointMu       sync.Mutex
+	xaLogged          bool
+	local             bool
+	localBackend      string
+	localMu           sync.Mutex
+	record            bool
+	querys            []xcontext.QueryTuple
+	querysMu          sync.Mutex
+	profile           *xcontext.Profile
+	sessionVars       map[string]string
+	chars             TxnCharacteristics
+	snapshot          bool
 }
 
 // NewTxn creates the new Txn.
@@ -129,11 +158,36 @@ func (txn *Txn) SetMaxResult(max int) {
 	txn.maxResult = max
 }
 
+// SetReuseConnections used to make the normal querys on one backend share a connection,
+// the caller must execute the querys of one backend one by one.
+func (txn *Txn) SetReuseConnections() {
+	txn.normalConnMu.Lock()
+	defer txn.normalConnMu.Unlock()
+	txn.reusedConnections = make(map[string]Connection)
+}
+
 // SetMaxJoinRows used to set the txn max join rows.
 func (txn *Txn) SetMaxJoinRows(max int) {
 	txn.maxJoinRows = max
 }
 
+// SetRecordQuerys used to enable the recording of the query tuples popped by the PopQueryTuples.
+func (txn *Txn) SetRecordQuerys(record bool) {
+	txn.querysMu.Lock()
+	defer txn.querysMu.Unlock()
+	txn.record = record
+}
+
+// SetProfile used to set the profile of the current statement, the XA phases are traced by its span.
+func (txn *Txn) SetProfile(profile *xcontext.Profile) {
+	txn.profile = profile
+}
+
+// SetSessionVars used to set the session variables, they're applied to the connections before executing.
+func (txn *Txn) SetSessionVars(vars map[string]string) {
+	txn.sessionVars = vars
+}
+
 // MaxJoinRows returns txn maxJoinRows.
 func (txn *Txn) MaxJoinRows() int {
 	return txn.maxJoinRows
@@ -202,6 +256,13 @@ func (txn *Txn) reFetchTwopcConnection(backend string) (Connection, error) {
 // normalConnection used to get a connection via backend name from pool.
 // The Connection is stored in normalConnections for recycling.
 func (txn *Txn) normalConnection(backend string) (Connection, error) {
+	txn.normalConnMu.RLock()
+	conn, ok := txn.reusedConnections[backend]
+	txn.normalConnMu.RUnlock()
+	if ok {
+		return conn, nil
+	}
+
 	pool, ok := txn.backends[backend]
 	if !ok {
 		txnCounters.Add(txnCounterNormalConnectionError, 1)
@@ -213,6 +274,9 @@ func (txn *Txn) normalConnection(backend string) (Connection, error) {
 	}
 	txn.normalConnMu.Lock()
 	txn.normalConnections = append(txn.normalConnections, conn)
+	if txn.reusedConnections != nil {
+		txn.reusedConnections[backend] = conn
+	}
 	txn.normalConnMu.Unlock()
 	return conn, nil
 }
@@ -220,7 +284,7 @@ func (txn *Txn) normalConnection(backend string) (Connection, error) {
 func (txn *Txn) fetchOneConnection(back string) (Connection, error) {
 	var err error
 	var conn Connection
-	if txn.twopc {
+	if txn.twopc || txn.snapshot || (txn.local && txn.localBackendName() == back) {
 		if conn, err = txn.twopcConnection(back); err != nil {
 			return nil, err
 		}
@@ -229,6 +293,11 @@ func (txn *Txn) fetchOneConnection(back string) (Connection, error) {
 			return nil, err
 		}
 	}
+	if err = conn.SetSessionVars(txn.sessionVars); err != nil {
+		log := txn.log
+		log.Error("txn.set.session.vars[%v].on[%s].error:%+v", txn.sessionVars, back, err)
+		return nil, err
+	}
 	return conn, nil
 }
 
@@ -244,7 +313,8 @@ func (txn *Txn) Begin() error {
 // Commit does:
 // 1. XA END
 // 2. XA PREPARE
-// 3. XA COMMIT
+// 3. log the commit decision to the xalog
+// 4. XA COMMIT
 func (txn *Txn) Commit() error {
 	txn.state.Set(int32(txnStateCommitting))
 
@@ -262,7 +332,21 @@ func (txn *Txn) Commit() error {
 			return err
 		}
 
-		// 3. XA COMMIT
+		// 3. Fence the reads of the backends, rollback if failed.
+		backs, err := txn.xaFence()
+		if err != nil {
+			txn.xaRollback()
+			return err
+		}
+		defer txn.xaUnfence(backs)
+
+		// 4. Log the commit decision, rollback if failed.
+		if err := txn.xaLogCommit(); err != nil {
+			txn.xaRollback()
+			return err
+		}
+
+		// 5. XA COMMIT
 		txn.xaCommit()
 	}
 	return nil
@@ -274,6 +358,11 @@ func (txn *Txn) Rollback() error {
 	log := txn.log
 	txn.state.Set(int32(txnStateRollbacking))
 
+	// Rollback nothing if the txn is refused before executing.
+	if txn.req == nil {
+		return nil
+	}
+
 	// Here, we only handle the write-txn.
 	// Rollback nothing for read-txn.
 	switch txn.req.TxnMode {
@@ -305,8 +394,12 @@ func (txn *Txn) BeginScatter() error {
 	return txn.xaStart()
 }
 
-// CommitScatter is used in the multiple-statement transaction
+// CommitScatter is used in the multiple-statement transaction.
+// If the txn is a local transaction, it only commits on the backend which the txn started on.
 func (txn *Txn) CommitScatter() error {
+	if txn.local {
+		return txn.commitLocal()
+	}
 	txn.state.Set(int32(txnStateCommitting))
 	txn.twopc = true
 	txn.req = xcontext.NewRequestContext()
@@ -322,13 +415,31 @@ func (txn *Txn) CommitScatter() error {
 		return err
 	}
 
-	// 3. XA COMMIT
+	// 3. Fence the reads of the backends, rollback if failed.
+	backs, err := txn.xaFence()
+	if err != nil {
+		txn.xaRollback()
+		return err
+	}
+	defer txn.xaUnfence(backs)
+
+	// 4. Log the commit decision, rollback if failed.
+	if err := txn.xaLogCommit(); err != nil {
+		txn.xaRollback()
+		return err
+	}
+
+	// 5. XA COMMIT
 	txn.xaCommit()
 	return nil
 }
 
-// RollbackScatter is used in the multiple-statement transaction
+// RollbackScatter is used in the multiple-statement transaction.
+// If the txn is a local transaction, it only rollbacks on the backend which the txn started on.
 func (txn *Txn) RollbackScatter() error {
+	if txn.local {
+		return txn.rollbackLocal()
+	}
 	log := txn.log
 	txn.state.Set(int32(txnStateRollbacking))
 	txn.twopc = true
@@ -366,9 +477,74 @@ func (txn *Txn) ExecuteRaw(database string, query string) (*sqltypes.Result, err
 	return nil, fmt.Errorf("txn.ExecuteRaw.not.implemented")
 }
 
+// reqBackends returns the backends which the request touches, nil for the ReqSingle.
+func (txn *Txn) reqBackends(req *xcontext.RequestContext) []string {
+	var backs []string
+
+	switch req.Mode {
+	case xcontext.ReqScatter:
+		for back, pool := range txn.backends {
+			if pool.conf.Role == config.NormalBackend {
+				backs = append(backs, back)
+			}
+		}
+	case xcontext.ReqNormal:
+		seen := make(map[string]bool)
+		for _, query := range req.Querys {
+			if !seen[query.Backend] {
+				seen[query.Backend] = true
+				backs = append(backs, query.Backend)
+			}
+		}
+	}
+	return backs
+}
+
+// recordQuerys used to record the query tuples the request executes on if the recording is enabled,
+// the ReqSingle is not recorded. They are popped for every statement, at most maxRecordQuerys are kept.
+func (txn *Txn) recordQuerys(req *xcontext.RequestContext) {
+	txn.querysMu.Lock()
+	defer txn.querysMu.Unlock()
+	if !txn.record {
+		return
+	}
+
+	switch req.Mode {
+	case xcontext.ReqScatter:
+		for _, back := range txn.reqBackends(req) {
+			if len(txn.querys) >= maxRecordQuerys {
+				return
+			}
+			txn.querys = append(txn.querys, xcontext.QueryTuple{Query: req.RawQuery, Backend: back})
+		}
+	case xcontext.ReqNormal:
+		n := maxRecordQuerys - len(txn.querys)
+		if n > len(req.Querys) {
+			n = len(req.Querys)
+		}
+		if n > 0 {
+			txn.querys = append(txn.querys, req.Querys[:n]...)
+		}
+	}
+}
+
+// PopQueryTuples returns the query tuples executed since the last pop.
+func (txn *Txn) PopQueryTuples() []xcontext.QueryTuple {
+	txn.querysMu.Lock()
+	defer txn.querysMu.Unlock()
+	querys := txn.querys
+	txn.querys = nil
+	return querys
+}
+
 // Execute used to execute the query.
 // If the txn is in twopc mode, we do the xaStart before the real query execute.
 func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error) {
+	if req.TxnMode == xcontext.TxnWrite {
+		if err := txn.checkReadOnly(); err != nil {
+			return nil, err
+		}
+	}
 	if txn.twopc {
 		// DATA RACE in the same txn e.g, UNION etc.
 		txn.mu.Lock()
@@ -377,9 +553,17 @@ func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 
 		switch req.TxnMode {
 		case xcontext.TxnRead:
-			// read-txn acquires the commit read-lock.
-			txn.mgr.CommitRLock()
-			defer txn.mgr.CommitRUnlock()
+			// read-txn acquires the commit read-locks of the backends it reads on.
+			backs := txn.reqBackends(req)
+			if len(backs) == 0 {
+				for back := range txn.backends {
+					backs = append(backs, back)
+				}
+			}
+			if err := txn.mgr.CommitRLock(backs); err != nil {
+				return nil, err
+			}
+			defer txn.mgr.CommitRUnlock(backs)
 		case xcontext.TxnWrite:
 			// write-txn xa starts to the single statement.
 			if !txn.isMultiStmtTxn {
@@ -388,7 +572,12 @@ func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 				}
 			}
 		}
+	} else if txn.local {
+		if err := txn.localEnlist(req); err != nil {
+			return nil, err
+		}
 	}
+	txn.recordQuerys(req)
 	qr, err := txn.execute(req)
 	if err != nil {
 		txn.incErrors()
@@ -429,7 +618,12 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 				var innerqr *sqltypes.Result
 
 				// Execute to backends.
-				if innerqr, x = c.ExecuteWithLimits(query, txn.timeout, txn.maxResult); x != nil {
+				start := time.Now()
+				innerqr, x = c.ExecuteWithLimits(query, txn.timeout, txn.maxResult)
+				if req.Profile != nil {
+					req.Profile.AddQuery(queryProfile(back, query, start, innerqr, x, req.Profile.Analyze()))
+				}
+				if x != nil {
 					log.Error("txn.execute.on[%v].query[%v].error:%+v", c.Address(), query, x)
 					break
 				}
@@ -451,6 +645,12 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	// it is random sometimes, be careful.
 	case xcontext.ReqSingle:
 		qs := []string{req.RawQuery}
+		// The local transaction executes on the backend which it started on.
+		if back := txn.localBackendName(); back != "" {
+			wg.Add(1)
+			oneShard(back, txn, qs)
+			break
+		}
 		for back, pool := range txn.backends {
 			if pool.conf.Role != config.NormalBackend {
 				continue
@@ -507,6 +707,29 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	return qr, err
 }
 
+// queryProfile returns the execution stats of the query, the bytes of the result are only counted if bytes is true.
+func queryProfile(backend string, query string, start time.Time, qr *sqltypes.Result, err error, bytes bool) xcontext.QueryProfile {
+	qp := xcontext.QueryProfile{
+		Query:    query,
+		Backend:  backend,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		qp.Error = err.Error()
+		return qp
+	}
+	qp.Rows = len(qr.Rows)
+	if !bytes {
+		return qp
+	}
+	for _, row := range qr.Rows {
+		for _, v := range row {
+			qp.Bytes += len(v.Raw())
+		}
+	}
+	return qp
+}
+
 // ExecuteStreamFetch used to execute stream fetch query.
 func (txn *Txn) ExecuteStreamFetch(req *xcontext.RequestContext, callback func(*sqltypes.Result) error, streamBufferSize int) error {
 	var err error
@@ -522,6 +745,11 @@ func (txn *Txn) ExecuteStreamFetch(req *xcontext.RequestContext, callback func(*
 			cursor.Close()
 		}
 	}()
+	txn.recordQuerys(req)
+
+	if txn.snapshot {
+		return txn.snapshotStreamFetch(req, callback, streamBufferSize)
+	}
 
 	oneShard := func(c Connection, query string) {
 		defer wg.Done()
@@ -699,6 +927,10 @@ func (txn *Txn) Finish() error {
 	defer func() {
 		txn.twopc = false
 		txn.isMultiStmtTxn = false
+		txn.local = false
+		txn.localBackend = ""
+		txn.xaLogged = false
+		txn.snapshot = false
 	}()
 
 	// If the txn has aborted, we won't do finish.
@@ -709,6 +941,11 @@ func (txn *Txn) Finish() error {
 	txn.xaState.Set(int32(txnXAStateNone))
 	txn.state.Set(int32(txnStateFinshing))
 
+	// End the snapshot before the connections recycled.
+	if txn.snapshot {
+		txn.endSnapshot()
+	}
+
 	// 2pc connections.
 	for id, conn := range txn.twopcConnections {
 		if txn.errors > 0 {
@@ -742,6 +979,9 @@ func (txn *Txn) Abort() error {
 	defer func() {
 		txn.twopc = false
 		txn.isMultiStmtTxn = false
+		txn.local = false
+		txn.localBackend = ""
+		txn.snapshot = false
 	}()
 
 	// If the txn has finished, we won't do abort.
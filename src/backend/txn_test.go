@@ -95,6 +95,30 @@ func TestTxnNormalExecute(t *testing.T) {
 	}
 }
 
+func TestTxnReuseConnections(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQuery("select * from node1", result1)
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetReuseConnections()
+
+	for i := 0; i < 3; i++ {
+		for back := range backends {
+			_, err := txn.ExecuteOnThisBackend(back, "select * from node1")
+			assert.Nil(t, err)
+		}
+	}
+	// One connection for each backend.
+	assert.Equal(t, len(backends), len(txn.normalConnections))
+}
+
 func TestTxnNormalExecuteWithAttach(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -372,6 +396,69 @@ func TestTxnExecuteOnThisBackend(t *testing.T) {
 	}
 }
 
+func TestTxnPopQueryTuples(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "select * from node1", Backend: addrs[0]},
+		xcontext.QueryTuple{Query: "select * from node2", Backend: addrs[1]},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+	fakedb.AddQuery(querys[1].Query, result1)
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+
+	// The recording is disabled by default.
+	{
+		_, err := txn.Execute(&xcontext.RequestContext{Querys: querys})
+		assert.Nil(t, err)
+		assert.Nil(t, txn.PopQueryTuples())
+	}
+	txn.SetRecordQuerys(true)
+
+	// normal execute.
+	{
+		_, err := txn.Execute(&xcontext.RequestContext{Querys: querys})
+		assert.Nil(t, err)
+		assert.Equal(t, querys, txn.PopQueryTuples())
+		assert.Nil(t, txn.PopQueryTuples())
+	}
+
+	// single execute isn't recorded.
+	{
+		_, err := txn.ExecuteSingle(querys[0].Query)
+		assert.Nil(t, err)
+		assert.Nil(t, txn.PopQueryTuples())
+	}
+
+	// scatter execute.
+	{
+		_, err := txn.ExecuteScatter(querys[0].Query)
+		assert.Nil(t, err)
+		got := txn.PopQueryTuples()
+		assert.Equal(t, 2, len(got))
+		for _, tuple := range got {
+			assert.Equal(t, querys[0].Query, tuple.Query)
+		}
+	}
+
+	// At most maxRecordQuerys are kept.
+	{
+		many := make([]xcontext.QueryTuple, maxRecordQuerys+1)
+		for i := range many {
+			many[i] = querys[i%2]
+		}
+		_, err := txn.Execute(&xcontext.RequestContext{Querys: many})
+		assert.Nil(t, err)
+		assert.Equal(t, maxRecordQuerys, len(txn.PopQueryTuples()))
+	}
+}
+
 func TestTxnSetting(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
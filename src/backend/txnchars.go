@@ -0,0 +1,74 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"strings"
+
+	"github.com/xelabs/go-mysqlstack/sqldb"
+)
+
+// TxnCharacteristics tuple, the characteristics are applied to every branch of the transaction when it starts.
+type TxnCharacteristics struct {
+	// Isolation is the isolation level, such as 'read committed', empty means the backend default.
+	Isolation string
+	// ReadOnly refuses the writes in the transaction.
+	ReadOnly bool
+	// ConsistentSnapshot takes the snapshot when the branch starts, only works for the local transaction.
+	ConsistentSnapshot bool
+}
+
+// setTransactionQuery returns the SET TRANSACTION which is sent before the branch starts, empty if nothing to set.
+func (chars TxnCharacteristics) setTransactionQuery() string {
+	var items []string
+	if chars.Isolation != "" {
+		items = append(items, "ISOLATION LEVEL "+strings.ToUpper(chars.Isolation))
+	}
+	if chars.ReadOnly {
+		items = append(items, "READ ONLY")
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	return "SET TRANSACTION " + strings.Join(items, ", ")
+}
+
+// startTransactionQuery returns the query to start the local transaction on the branch.
+func (chars TxnCharacteristics) startTransactionQuery() string {
+	if chars.ConsistentSnapshot {
+		return "START TRANSACTION WITH CONSISTENT SNAPSHOT"
+	}
+	return "BEGIN"
+}
+
+// SetCharacteristics used to set the characteristics of the transaction, it must be called before the txn begins.
+func (txn *Txn) SetCharacteristics(chars TxnCharacteristics) {
+	txn.chars = chars
+}
+
+// applyCharacteristics used to send the SET TRANSACTION to the connection before the branch starts.
+func (txn *Txn) applyCharacteristics(conn Connection) error {
+	query := txn.chars.setTransactionQuery()
+	if query == "" {
+		return nil
+	}
+	if _, err := conn.Execute(query); err != nil {
+		txn.log.Error("txn.set.characteristics[%s].on[%v].error:%+v", query, conn.Address(), err)
+		return err
+	}
+	return nil
+}
+
+// checkReadOnly returns the mysql error if the write is executed in the READ ONLY transaction.
+func (txn *Txn) checkReadOnly() error {
+	if txn.chars.ReadOnly {
+		return sqldb.NewSQLError1(1792, "25006", "Cannot execute statement in a READ ONLY transaction.")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"xcontext"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTxnCharacteristicsQuery(t *testing.T) {
+	tests := []struct {
+		chars TxnCharacteristics
+		set   string
+		start string
+	}{
+		{
+			chars: TxnCharacteristics{},
+			set:   "",
+			start: "BEGIN",
+		},
+		{
+			chars: TxnCharacteristics{Isolation: "read committed"},
+			set:   "SET TRANSACTION ISOLATION LEVEL READ COMMITTED",
+			start: "BEGIN",
+		},
+		{
+			chars: TxnCharacteristics{Isolation: "serializable", ReadOnly: true},
+			set:   "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE, READ ONLY",
+			start: "BEGIN",
+		},
+		{
+			chars: TxnCharacteristics{ReadOnly: true, ConsistentSnapshot: true},
+			set:   "SET TRANSACTION READ ONLY",
+			start: "START TRANSACTION WITH CONSISTENT SNAPSHOT",
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.set, test.chars.setTransactionQuery())
+		assert.Equal(t, test.start, test.chars.startTransactionQuery())
+	}
+}
+
+func TestTxnCharacteristicsXA(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	setQuery := "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"
+	fakedb.AddQuery(setQuery, &sqltypes.Result{})
+	fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+	fakedb.AddQueryPattern("insert .*", &sqltypes.Result{})
+
+	write := func(backs ...string) *xcontext.RequestContext {
+		req := &xcontext.RequestContext{Mode: xcontext.ReqNormal, TxnMode: xcontext.TxnWrite}
+		for _, back := range backs {
+			req.Querys = append(req.Querys, xcontext.QueryTuple{Query: "insert into t1 values(1)", Backend: back})
+		}
+		return req
+	}
+
+	// The isolation level is set on every branch before XA START.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetCharacteristics(TxnCharacteristics{Isolation: "repeatable read"})
+
+		err = txn.Begin()
+		assert.Nil(t, err)
+		_, err = txn.Execute(write(addrs[0], addrs[1]))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(setQuery))
+		err = txn.Commit()
+		assert.Nil(t, err)
+	}
+
+	// The multiple-statement transaction starts the branches on all the backends.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+		txn.SetCharacteristics(TxnCharacteristics{Isolation: "repeatable read"})
+
+		err = txn.BeginScatter()
+		assert.Nil(t, err)
+		assert.Equal(t, 4, fakedb.GetQueryCalledNum(setQuery))
+		err = txn.CommitScatter()
+		assert.Nil(t, err)
+	}
+
+	// The writes are refused in the READ ONLY transaction.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetCharacteristics(TxnCharacteristics{ReadOnly: true})
+
+		err = txn.Begin()
+		assert.Nil(t, err)
+		_, err = txn.Execute(write(addrs[0], addrs[1]))
+		assert.NotNil(t, err)
+		sqlErr, ok := err.(*sqldb.SQLError)
+		assert.True(t, ok)
+		assert.Equal(t, uint16(1792), sqlErr.Num)
+	}
+
+	// The branch fails to start if the SET TRANSACTION errors.
+	{
+		fakedb.ResetAll()
+		fakedb.AddQueryError(setQuery, errors.New("mock.set.transaction.error"))
+		fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetCharacteristics(TxnCharacteristics{Isolation: "repeatable read"})
+
+		err = txn.Begin()
+		assert.Nil(t, err)
+		_, err = txn.Execute(write(addrs[0], addrs[1]))
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum("XA START"))
+		txn.Rollback()
+	}
+}
+
+func TestTxnCharacteristicsLocal(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	setQuery := "SET TRANSACTION ISOLATION LEVEL READ COMMITTED, READ ONLY"
+	startQuery := "START TRANSACTION WITH CONSISTENT SNAPSHOT"
+	fakedb.AddQuery(setQuery, &sqltypes.Result{})
+	fakedb.AddQuery(startQuery, &sqltypes.Result{})
+	fakedb.AddQuery("COMMIT", &sqltypes.Result{})
+	fakedb.AddQueryPattern("select .*", result1)
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMultiStmtTxn()
+	txn.SetCharacteristics(TxnCharacteristics{Isolation: "read committed", ReadOnly: true, ConsistentSnapshot: true})
+	err = txn.BeginLocal()
+	assert.Nil(t, err)
+
+	req := &xcontext.RequestContext{TxnMode: xcontext.TxnRead}
+	req.Querys = append(req.Querys, xcontext.QueryTuple{Query: "select * from t1", Backend: addrs[0]})
+	_, err = txn.Execute(req)
+	assert.Nil(t, err)
+	assert.Equal(t, addrs[0], txn.localBackendName())
+	assert.Equal(t, 1, fakedb.GetQueryCalledNum(setQuery))
+	assert.Equal(t, 1, fakedb.GetQueryCalledNum(startQuery))
+
+	err = txn.CommitScatter()
+	assert.Nil(t, err)
+}
@@ -20,37 +20,70 @@ import (
 
 // TxnManager tuple.
 type TxnManager struct {
-	log        *xlog.Log
-	xaCheck    *XaCheck
-	txnid      uint64
-	txnNums    int64
-	commitLock sync.RWMutex
+	log     *xlog.Log
+	xaCheck *XaCheck
+	xaLog   *XaLog
+	txnid   uint64
+	txnNums int64
+	locksMu sync.Mutex
+	locks   map[string]*sync.RWMutex
+	fences  map[string]*fence
+	fence   CommitFence
+	// gates of the tables, the DML hold the read lock and the cutover of the online ddl holds the write lock.
+	gatesMu sync.RWMutex
+	gates   map[string]*sync.RWMutex
 }
 
 // NewTxnManager creates new TxnManager.
 func NewTxnManager(log *xlog.Log) *TxnManager {
 	return &TxnManager{
-		log:   log,
-		txnid: 0,
+		log:    log,
+		txnid:  0,
+		locks:  make(map[string]*sync.RWMutex),
+		fences: make(map[string]*fence),
+		gates:  make(map[string]*sync.RWMutex),
 	}
 }
 
-// Init is used to init the async worker xaCheck.
+// Init is used to init the async worker xaCheck, and the xaLog to recover the orphaned prepared XA branches.
 func (mgr *TxnManager) Init(scatter *Scatter, ScatterConf *config.ScatterConfig) error {
+	log := mgr.log
 	xaChecker := NewXaCheck(scatter, ScatterConf)
 	if err := xaChecker.Init(); err != nil {
 		return err
 	}
 	mgr.xaCheck = xaChecker
+
+	xaLog := NewXaLog(log, ScatterConf.XaCheckDir)
+	if err := xaLog.Init(); err != nil {
+		return err
+	}
+	mgr.xaLog = xaLog
+
+	// The backends may be unavailable now, the decisions are kept for the next recovery.
+	if err := xaLog.XaRecover(scatter); err != nil {
+		log.Error("txnmgr.xa.recover.error:%v", err)
+	}
 	return nil
 }
 
+// XaRecover used to resolve the orphaned prepared XA branches by the coordinator log.
+func (mgr *TxnManager) XaRecover(scatter *Scatter) error {
+	return mgr.xaLog.XaRecover(scatter)
+}
+
+// XaRecoverBackend used to resolve the prepared XA branches of the backend failed over by the coordinator log.
+func (mgr *TxnManager) XaRecoverBackend(scatter *Scatter, backend string) error {
+	return mgr.xaLog.XaRecoverBackend(scatter, backend)
+}
+
 // Close is used to close the async worker xaCheck.
 func (mgr *TxnManager) Close() {
 	if mgr.xaCheck != nil {
 		mgr.xaCheck.Close()
 		mgr.xaCheck = nil
 	}
+	mgr.xaLog.Close()
 }
 
 // GetID returns a new txnid.
@@ -84,23 +117,3 @@ func (mgr *TxnManager) CreateTxn(backends map[string]*Pool) (*Txn, error) {
 	mgr.Add()
 	return txn, nil
 }
-
-// CommitLock used to acquire the commit.
-func (mgr *TxnManager) CommitLock() {
-	mgr.commitLock.Lock()
-}
-
-// CommitUnlock used to release the commit.
-func (mgr *TxnManager) CommitUnlock() {
-	mgr.commitLock.Unlock()
-}
-
-// CommitRLock used to acquire the read lock of commit.
-func (mgr *TxnManager) CommitRLock() {
-	mgr.commitLock.RLock()
-}
-
-// CommitRUnlock used to release the read lock of commit.
-func (mgr *TxnManager) CommitRUnlock() {
-	mgr.commitLock.RUnlock()
-}
@@ -26,6 +26,7 @@ func TestTxnManager(t *testing.T) {
 	for _, addr := range addrs {
 		conf := MockBackendConfigDefault(addr, addr)
 		pool := NewPool(log, conf)
+		defer pool.Close()
 		backends[addr] = pool
 	}
 	txnmgr := NewTxnManager(log)
@@ -12,7 +12,10 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"monitor"
 	"xcontext"
+	"xtrace"
 
 	"github.com/xelabs/go-mysqlstack/sqldb"
 )
@@ -83,9 +86,20 @@ func (txn *Txn) executeXA(req *xcontext.RequestContext, state txnXAState) error
 			if c, x = txn.twopcConnection(back); x != nil {
 				log.Error("txn.xa.fetch.connection.state[%v].on[%s].query[%v].error:%+v", state, back, query, x)
 			} else {
-				log.Debug("conn[%v].txn.sessid[%v].xa.execute[%v]", c.ID(), txn.sessionID, query)
-				if _, x = c.Execute(query); x != nil {
-					log.Error("txn.xa.execute[%v].on[%v].error:%+v", query, c.Address(), x)
+				if state == txnXAStateStart {
+					// The characteristics must be set before the branch starts.
+					x = txn.applyCharacteristics(c)
+				}
+				if x == nil {
+					log.Debug("conn[%v].txn.sessid[%v].xa.execute[%v]", c.ID(), txn.sessionID, query)
+					if _, x = c.Execute(query); x != nil {
+						log.Error("txn.xa.execute[%v].on[%v].error:%+v", query, c.Address(), x)
+					} else if state == txnXAStateStart {
+						// The backend enlisted after the savepoints were taken.
+						if x = txn.replaySavepoints(back, c); x != nil {
+							log.Error("txn.xa.replay.savepoints.on[%v].error:%+v", c.Address(), x)
+						}
+					}
 				}
 			}
 		case txnXAStateCommit, txnXAStateRollback:
@@ -145,13 +159,6 @@ func (txn *Txn) executeXA(req *xcontext.RequestContext, state txnXAState) error
 		// Only do XA when Querys's backends numbers larger than one.
 		beLen := len(backends)
 		if beLen > 1 {
-			switch state {
-			case txnXAStateCommit, txnXAStateRollback:
-				// Acquire the commit lock if the txn is write.
-				txn.mgr.CommitLock()
-				defer txn.mgr.CommitUnlock()
-			}
-
 			for back := range backends {
 				wg.Add(1)
 				go oneShard(state, back, txn, req.RawQuery)
@@ -159,13 +166,6 @@ func (txn *Txn) executeXA(req *xcontext.RequestContext, state txnXAState) error
 		}
 	case xcontext.ReqScatter:
 		backends := txn.backends
-		switch state {
-		case txnXAStateCommit, txnXAStateRollback:
-			// Acquire the commit lock when the txn commit/rollback
-			txn.mgr.CommitLock()
-			defer txn.mgr.CommitUnlock()
-		}
-
 		for back := range backends {
 			wg.Add(1)
 			go oneShard(state, back, txn, req.RawQuery)
@@ -179,8 +179,17 @@ func (txn *Txn) executeXA(req *xcontext.RequestContext, state txnXAState) error
 	return err
 }
 
+// phase used to observe the latency of the 2pc phase, it's traced if the statement is traced.
+func (txn *Txn) phase(name string, start time.Time) {
+	monitor.TwoPCPhaseObserve(name, start)
+	span := txn.profile.Span().StartChildAt("xa."+name, xtrace.SpanKindInternal, start)
+	span.SetAttribute("radon.xid", txn.xid)
+	span.End()
+}
+
 func (txn *Txn) xaStart() error {
 	log := txn.log
+	defer txn.phase("start", time.Now())
 	txnCounters.Add(txnCounterXaStart, 1)
 	txn.xaState.Set(int32(txnXAStateStart))
 	defer func() { txn.xaState.Set(int32(txnXAStateStartFinished)) }()
@@ -190,6 +199,10 @@ func (txn *Txn) xaStart() error {
 	} else {
 		txn.xid = fmt.Sprintf("RXID-%v-%v", time.Now().Format("20060102150405"), txn.id)
 	}
+	// The owner suffix makes the xid recoverable by the coordinator log.
+	if owner := txn.mgr.xaLog.Owner(); owner != "" {
+		txn.xid = fmt.Sprintf("%v-%v", txn.xid, owner)
+	}
 	start := fmt.Sprintf("XA START '%v'", txn.xid)
 	if err := txn.executeXACommand(start, txnXAStateStart); err != nil {
 		log.Error("xa.start[%v].error:%v", start, err)
@@ -202,6 +215,7 @@ func (txn *Txn) xaStart() error {
 
 func (txn *Txn) xaEnd() error {
 	log := txn.log
+	defer txn.phase("end", time.Now())
 	txnCounters.Add(txnCounterXaEnd, 1)
 	txn.xaState.Set(int32(txnXAStateEnd))
 	defer func() { txn.xaState.Set(int32(txnXAStateEndFinished)) }()
@@ -218,6 +232,7 @@ func (txn *Txn) xaEnd() error {
 
 func (txn *Txn) xaPrepare() error {
 	log := txn.log
+	defer txn.phase("prepare", time.Now())
 	txnCounters.Add(txnCounterXaPrepare, 1)
 	txn.xaState.Set(int32(txnXAStatePrepare))
 	defer func() { txn.xaState.Set(int32(txnXAStatePrepareFinished)) }()
@@ -232,8 +247,74 @@ func (txn *Txn) xaPrepare() error {
 	return nil
 }
 
+// xaLogCommit used to log the commit decision before the XA COMMIT.
+// The decision is only needed if there are more than one branches.
+func (txn *Txn) xaLogCommit() error {
+	log := txn.log
+	txn.twopcConnMu.RLock()
+	branches := len(txn.twopcConnections)
+	txn.twopcConnMu.RUnlock()
+	if branches < 2 {
+		return nil
+	}
+
+	defer txn.phase("log", time.Now())
+	if err := txn.mgr.xaLog.LogCommit(txn.xid); err != nil {
+		log.Error("xa.log.commit[%v].error:%v", txn.xid, err)
+		txn.incErrors()
+		return err
+	}
+	txn.xaLogged = true
+	return nil
+}
+
+// xaFence used to acquire the commit locks(fenced on all the peers if the commit fence is set)
+// of the backends before the commit decision is logged, so the txn can still be rolled back
+// if the fence fails. It returns the backends which must be unfenced after the XA COMMIT.
+func (txn *Txn) xaFence() ([]string, error) {
+	log := txn.log
+	backends := make(map[string]bool)
+	switch txn.req.Mode {
+	case xcontext.ReqNormal:
+		for _, query := range txn.req.Querys {
+			backends[query.Backend] = true
+		}
+		// The single backend commit is not a XA.
+		if len(backends) < 2 {
+			return nil, nil
+		}
+	case xcontext.ReqScatter:
+		for back := range txn.backends {
+			backends[back] = true
+		}
+	}
+	if len(backends) == 0 {
+		return nil, nil
+	}
+
+	backs := make([]string, 0, len(backends))
+	for back := range backends {
+		backs = append(backs, back)
+	}
+	defer txn.phase("fence", time.Now())
+	if err := txn.mgr.CommitLock(txn.xid, backs); err != nil {
+		log.Error("xa.fence[%v].error:%v", txn.xid, err)
+		txn.incErrors()
+		return nil, err
+	}
+	return backs, nil
+}
+
+// xaUnfence used to release the commit locks acquired by the xaFence.
+func (txn *Txn) xaUnfence(backs []string) {
+	if len(backs) > 0 {
+		txn.mgr.CommitUnlock(txn.xid, backs)
+	}
+}
+
 func (txn *Txn) xaCommit() {
 	log := txn.log
+	defer txn.phase("commit", time.Now())
 	txnCounters.Add(txnCounterXaCommit, 1)
 	txn.xaState.Set(int32(txnXAStateCommit))
 	// if the commit is failed, the status is set txnXAStateCommitFinished which is not used.
@@ -249,11 +330,20 @@ func (txn *Txn) xaCommit() {
 		if err := txn.WriteXaCommitErrLog(txnXACommitErrStateCommit); err != nil {
 			log.Error("txn.xa.WriteXaCommitErrLog.query[%v].error[%T]:%+v", commit, err, err)
 		}
+		return
+	}
+
+	// All the branches are committed, the decision is done.
+	if txn.xaLogged {
+		if err := txn.mgr.xaLog.LogDone(txn.xid); err != nil {
+			log.Error("xa.log.done[%v].error:%v", txn.xid, err)
+		}
 	}
 }
 
 func (txn *Txn) xaRollback() {
 	log := txn.log
+	defer txn.phase("rollback", time.Now())
 	txnCounters.Add(txnCounterXaRollback, 1)
 	txn.xaState.Set(int32(txnXAStateRollback))
 	defer func() { txn.xaState.Set(int32(txnXAStateRollbackFinished)) }()
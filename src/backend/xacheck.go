@@ -55,22 +55,25 @@ type XaCheck struct {
 	times   int
 	scatter *Scatter
 	retrys  map[string]*XaCommitErr
-	done    chan bool
-	ticker  *time.Ticker
-	wg      sync.WaitGroup
-	mu      sync.RWMutex
+	// resolving is the xids being resolved manually, they're taken out of the retrys meanwhile.
+	resolving map[string]*XaCommitErr
+	done      chan bool
+	ticker    *time.Ticker
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
 }
 
 // NewXaCheck creates the XaCheck tuple.
 func NewXaCheck(scatter *Scatter, conf *config.ScatterConfig) *XaCheck {
 	return &XaCheck{
-		log:     scatter.log,
-		dir:     conf.XaCheckDir,
-		times:   conf.XaCheckRetrys,
-		scatter: scatter,
-		retrys:  make(map[string]*XaCommitErr),
-		done:    make(chan bool),
-		ticker:  time.NewTicker(time.Duration(time.Second * time.Duration(conf.XaCheckInterval))),
+		log:       scatter.log,
+		dir:       conf.XaCheckDir,
+		times:     conf.XaCheckRetrys,
+		scatter:   scatter,
+		retrys:    make(map[string]*XaCommitErr),
+		resolving: make(map[string]*XaCommitErr),
+		done:      make(chan bool),
+		ticker:    time.NewTicker(time.Duration(time.Second * time.Duration(conf.XaCheckInterval))),
 	}
 }
 
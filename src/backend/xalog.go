@@ -0,0 +1,461 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	xalogFile      = "xalog"
+	xalogOwnerFile = "xalog.owner"
+)
+
+const (
+	xalogStateCommit = "commit"
+	xalogStateDone   = "done"
+)
+
+var (
+	// xalogCompactDones is the number of the done records appended before the log compaction.
+	xalogCompactDones = 4096
+)
+
+var (
+	txnCounterXaLogCommit      = "#xalog.commit"
+	txnCounterXaLogCommitError = "#xalog.commit.error"
+	txnCounterXaRecover        = "#xa.recover"
+	txnCounterXaRecoverCommit  = "#xa.recover.commit"
+	txnCounterXaRecoverAbort   = "#xa.recover.rollback"
+	txnCounterXaRecoverError   = "#xa.recover.error"
+)
+
+// XaLogEntry tuple, one entry per line in the xalog file.
+type XaLogEntry struct {
+	Time  string `json:"time"`
+	Xid   string `json:"xid"`
+	State string `json:"state"`
+}
+
+// XaLog is the write-ahead log of the 2PC coordinator.
+// The commit decision is flushed to the log before the XA COMMIT,
+// so the prepared branches left by a crash can be resolved by the decision:
+// the branches with the commit decision are committed, the others are rolled back(presumed abort).
+type XaLog struct {
+	log     *xlog.Log
+	dir     string
+	owner   string
+	mu      sync.Mutex
+	file    *os.File
+	dones   int
+	pending map[string]*XaLogEntry
+}
+
+// NewXaLog creates the XaLog tuple.
+func NewXaLog(log *xlog.Log, dir string) *XaLog {
+	return &XaLog{
+		log:     log,
+		dir:     dir,
+		pending: make(map[string]*XaLogEntry),
+	}
+}
+
+// Init used to load the owner and the pending commit decisions from the dir.
+func (xl *XaLog) Init() error {
+	if err := os.MkdirAll(xl.dir, 0744); err != nil {
+		return err
+	}
+	if err := xl.loadOwner(); err != nil {
+		return err
+	}
+	if err := xl.load(); err != nil {
+		return err
+	}
+	return xl.compact()
+}
+
+// loadOwner used to load the owner id of the coordinator, it's created at the first time.
+// The owner is the suffix of the xids, so the coordinator only recovers the xids owned by itself.
+func (xl *XaLog) loadOwner() error {
+	file := path.Join(xl.dir, xalogOwnerFile)
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		xl.owner = strings.TrimSpace(string(data))
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return errors.WithStack(err)
+	}
+	owner := hex.EncodeToString(buf)
+	if err := ioutil.WriteFile(file, []byte(owner), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	xl.owner = owner
+	return nil
+}
+
+// load used to replay the xalog file to get the pending commit decisions.
+func (xl *XaLog) load() error {
+	log := xl.log
+	file := path.Join(xl.dir, xalogFile)
+
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := &XaLogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			// The last line may be torn by the crash, the decision isn't made.
+			log.Warning("xalog.load.entry[%s].error:%v", scanner.Text(), err)
+			continue
+		}
+		switch entry.State {
+		case xalogStateCommit:
+			xl.pending[entry.Xid] = entry
+		case xalogStateDone:
+			delete(xl.pending, entry.Xid)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	log.Info("xalog.load.pending.commits:%d", len(xl.pending))
+	return nil
+}
+
+// compact used to rewrite the xalog file with the pending commit decisions only,
+// and reopen it for appending.
+func (xl *XaLog) compact() error {
+	file := path.Join(xl.dir, xalogFile)
+	tmp := file + ".tmp"
+
+	if xl.file != nil {
+		xl.file.Close()
+		xl.file = nil
+	}
+
+	var buf []byte
+	for _, xid := range xl.pendingXids() {
+		data, err := json.Marshal(xl.pending[xid])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+	if err := writeSyncFile(tmp, buf); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	xl.file = f
+	xl.dones = 0
+	return nil
+}
+
+func writeSyncFile(file string, data []byte) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	return f.Sync()
+}
+
+func (xl *XaLog) append(entry *XaLogEntry, sync bool) error {
+	if xl.file == nil {
+		return errors.New("xalog.not.inited")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	data = append(data, '\n')
+	if _, err := xl.file.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	if sync {
+		return xl.file.Sync()
+	}
+	return nil
+}
+
+func (xl *XaLog) pendingXids() []string {
+	xids := make([]string, 0, len(xl.pending))
+	for xid := range xl.pending {
+		xids = append(xids, xid)
+	}
+	sort.Strings(xids)
+	return xids
+}
+
+// Owner returns the owner id of the coordinator, empty if the xalog is nil.
+func (xl *XaLog) Owner() string {
+	if xl == nil {
+		return ""
+	}
+	return xl.owner
+}
+
+// IsOwned returns true if the xid is generated by this coordinator.
+func (xl *XaLog) IsOwned(xid string) bool {
+	if xl == nil || xl.owner == "" {
+		return false
+	}
+	if !strings.HasPrefix(xid, "RXID-") && !strings.HasPrefix(xid, "MULTRXID-") {
+		return false
+	}
+	return strings.HasSuffix(xid, "-"+xl.owner)
+}
+
+// LogCommit used to flush the commit decision of the xid to the disk before the XA COMMIT.
+func (xl *XaLog) LogCommit(xid string) error {
+	if xl == nil {
+		return nil
+	}
+	txnCounters.Add(txnCounterXaLogCommit, 1)
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	entry := &XaLogEntry{
+		Time:  time.Now().Format("20060102150405"),
+		Xid:   xid,
+		State: xalogStateCommit,
+	}
+	if err := xl.append(entry, true); err != nil {
+		txnCounters.Add(txnCounterXaLogCommitError, 1)
+		return err
+	}
+	xl.pending[xid] = entry
+	return nil
+}
+
+// LogDone used to mark the xid is committed on all the branches, the decision is no longer needed.
+// The record isn't synced, a lost done record only causes a needless recovery check.
+func (xl *XaLog) LogDone(xid string) error {
+	if xl == nil {
+		return nil
+	}
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	return xl.logDone(xid)
+}
+
+func (xl *XaLog) logDone(xid string) error {
+	if _, ok := xl.pending[xid]; !ok {
+		return nil
+	}
+	entry := &XaLogEntry{
+		Time:  time.Now().Format("20060102150405"),
+		Xid:   xid,
+		State: xalogStateDone,
+	}
+	if err := xl.append(entry, false); err != nil {
+		return err
+	}
+	delete(xl.pending, xid)
+	xl.dones++
+	if xl.dones >= xalogCompactDones {
+		return xl.compact()
+	}
+	return nil
+}
+
+// IsCommitted returns true if the commit decision of the xid is logged and not done.
+func (xl *XaLog) IsCommitted(xid string) bool {
+	if xl == nil {
+		return false
+	}
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	_, ok := xl.pending[xid]
+	return ok
+}
+
+// Pending returns the xids which the commit decision is logged and not done.
+func (xl *XaLog) Pending() []string {
+	if xl == nil {
+		return nil
+	}
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	return xl.pendingXids()
+}
+
+// Close used to close the xalog file.
+func (xl *XaLog) Close() {
+	if xl == nil {
+		return
+	}
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	if xl.file != nil {
+		xl.file.Close()
+		xl.file = nil
+	}
+}
+
+// XaRecover used to resolve the prepared XA branches owned by the coordinator on all the backends,
+// it must be called before the coordinator starts new transactions:
+// 1. XA RECOVER on every backend to find the prepared branches
+// 2. XA COMMIT the branch if the commit decision is logged, otherwise XA ROLLBACK
+// 3. mark the decisions done if all the backends are resolved
+// The xids owned by the xa check are skipped, they're retried or resolved by it.
+func (xl *XaLog) XaRecover(scatter *Scatter) error {
+	if xl == nil {
+		return nil
+	}
+	log := xl.log
+	txnCounters.Add(txnCounterXaRecover, 1)
+
+	skips, err := scatter.txnMgr.xaCheck.owned()
+	if err != nil {
+		log.Error("xalog.recover.xacheck.owned.error:%v", err)
+		return err
+	}
+
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Finish()
+
+	var allErrors []error
+	for _, backend := range scatter.AllBackends() {
+		allErrors = append(allErrors, xl.recoverOn(txn, backend, skips)...)
+	}
+
+	// Keep the decisions until all the backends are resolved.
+	if len(allErrors) > 0 {
+		return allErrors[0]
+	}
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	for _, xid := range xl.pendingXids() {
+		if !skips[xid] {
+			delete(xl.pending, xid)
+		}
+	}
+	log.Info("xalog.recover.done")
+	return xl.compact()
+}
+
+// recoverOn used to resolve the prepared XA branches owned by the coordinator on the backend,
+// the xids in the skips are in flight and left to their transactions.
+func (xl *XaLog) recoverOn(txn *Txn, backend string, skips map[string]bool) []error {
+	log := xl.log
+	qr, err := txn.ExecuteOnThisBackend(backend, "XA RECOVER")
+	if err != nil {
+		log.Error("xalog.recover.xa.recover.on[%s].error:%v", backend, err)
+		return []error{err}
+	}
+	if len(qr.Fields) != 4 {
+		return nil
+	}
+
+	var allErrors []error
+	for _, row := range qr.Rows {
+		xid := string(row[3].Raw())
+		if !xl.IsOwned(xid) || skips[xid] {
+			continue
+		}
+
+		counter := txnCounterXaRecoverAbort
+		query := fmt.Sprintf("XA ROLLBACK '%s'", xid)
+		if xl.IsCommitted(xid) {
+			counter = txnCounterXaRecoverCommit
+			query = fmt.Sprintf("XA COMMIT '%s'", xid)
+		}
+		log.Warning("xalog.recover.orphaned.xid[%s].on[%s].query[%s]", xid, backend, query)
+		if _, err := txn.ExecuteOnThisBackend(backend, query); err != nil {
+			log.Error("xalog.recover.query[%s].on[%s].error:%v", query, backend, err)
+			txnCounters.Add(txnCounterXaRecoverError, 1)
+			allErrors = append(allErrors, err)
+			continue
+		}
+		txnCounters.Add(counter, 1)
+	}
+	return allErrors
+}
+
+// XaRecoverBackend used to resolve the prepared XA branches owned by the coordinator on the backend
+// whose address is changed by the failover, the prepared branches replicated from the old primary
+// are resolved on the promoted one. The in-flight transactions are skipped, their decisions are
+// not made yet and they resolve the branches themselves, so are the xids owned by the xa check.
+func (xl *XaLog) XaRecoverBackend(scatter *Scatter, backend string) error {
+	if xl == nil {
+		return nil
+	}
+	txnCounters.Add(txnCounterXaRecover, 1)
+
+	skips, err := scatter.txnMgr.xaCheck.owned()
+	if err != nil {
+		return err
+	}
+	if skips == nil {
+		skips = make(map[string]bool)
+	}
+	for _, row := range scatter.Txnz().GetTxnzRows() {
+		if row.XAID != "" {
+			skips[row.XAID] = true
+		}
+	}
+
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Finish()
+	if errs := xl.recoverOn(txn, backend, skips); len(errs) > 0 {
+		return errs[0]
+	}
+	xl.log.Info("xalog.recover.backend[%s].done", backend)
+	return nil
+}
@@ -0,0 +1,352 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"fakedb"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func mockXaRecoverResult(xids ...string) *sqltypes.Result {
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "formatID", Type: querypb.Type_INT64},
+			{Name: "gtrid_length", Type: querypb.Type_INT64},
+			{Name: "bqual_length", Type: querypb.Type_INT64},
+			{Name: "data", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, xid := range xids {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(xid)))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(xid)),
+		})
+	}
+	qr.RowsAffected = uint64(len(xids))
+	return qr
+}
+
+func TestXaLog(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	xl := NewXaLog(log, dir)
+	err := xl.Init()
+	assert.Nil(t, err)
+	owner := xl.Owner()
+	assert.Equal(t, 8, len(owner))
+
+	// Owned.
+	{
+		assert.True(t, xl.IsOwned("RXID-20190101000000-1-"+owner))
+		assert.True(t, xl.IsOwned("MULTRXID-20190101000000-1-"+owner))
+		assert.False(t, xl.IsOwned("RXID-20190101000000-1"))
+		assert.False(t, xl.IsOwned("XID-20190101000000-1-"+owner))
+	}
+
+	// Commit and done.
+	{
+		for i := 0; i < 3; i++ {
+			err = xl.LogCommit(fmt.Sprintf("RXID-%d", i))
+			assert.Nil(t, err)
+		}
+		err = xl.LogDone("RXID-1")
+		assert.Nil(t, err)
+		// Done the unknown xid does nothing.
+		err = xl.LogDone("RXID-9")
+		assert.Nil(t, err)
+		assert.True(t, xl.IsCommitted("RXID-0"))
+		assert.False(t, xl.IsCommitted("RXID-1"))
+		assert.Equal(t, []string{"RXID-0", "RXID-2"}, xl.Pending())
+		xl.Close()
+	}
+
+	// Reload with the torn record, the owner is kept.
+	{
+		f, err := os.OpenFile(path.Join(dir, xalogFile), os.O_WRONLY|os.O_APPEND, 0644)
+		assert.Nil(t, err)
+		f.WriteString(`{"time":"20190101000000","xid":"RXID-3","sta`)
+		f.Close()
+
+		xl = NewXaLog(log, dir)
+		err = xl.Init()
+		assert.Nil(t, err)
+		assert.Equal(t, owner, xl.Owner())
+		assert.Equal(t, []string{"RXID-0", "RXID-2"}, xl.Pending())
+
+		// The log is compacted by the init.
+		data, err := ioutil.ReadFile(path.Join(dir, xalogFile))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "\n"))
+	}
+
+	// Compact by the dones.
+	{
+		old := xalogCompactDones
+		xalogCompactDones = 2
+		defer func() { xalogCompactDones = old }()
+
+		err = xl.LogDone("RXID-0")
+		assert.Nil(t, err)
+		err = xl.LogDone("RXID-2")
+		assert.Nil(t, err)
+		data, err := ioutil.ReadFile(path.Join(dir, xalogFile))
+		assert.Nil(t, err)
+		assert.Equal(t, "", string(data))
+		xl.Close()
+	}
+
+	// Not inited.
+	{
+		xl := NewXaLog(log, dir)
+		err := xl.LogCommit("RXID-0")
+		assert.NotNil(t, err)
+	}
+
+	// Nil xalog.
+	{
+		var xl *XaLog
+		assert.Equal(t, "", xl.Owner())
+		assert.False(t, xl.IsOwned("RXID-0"))
+		assert.Nil(t, xl.LogCommit("RXID-0"))
+		assert.Nil(t, xl.LogDone("RXID-0"))
+		assert.False(t, xl.IsCommitted("RXID-0"))
+		assert.Nil(t, xl.Pending())
+		assert.Nil(t, xl.XaRecover(nil))
+		xl.Close()
+	}
+}
+
+func TestXaLogRecover(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	// The decisions before the crash.
+	xl := NewXaLog(log, dir)
+	err := xl.Init()
+	assert.Nil(t, err)
+	owner := xl.Owner()
+	committed := "RXID-20190101000000-1-" + owner
+	prepared := "MULTRXID-20190101000000-2-" + owner
+	others := "RXID-20190101000000-3-ffffffff"
+	err = xl.LogCommit(committed)
+	assert.Nil(t, err)
+	xl.Close()
+
+	scatter, fakedb, cleanup := MockScatter(log, 2)
+	defer cleanup()
+
+	fakedb.AddQuery("XA RECOVER", mockXaRecoverResult(committed, prepared, others))
+	fakedb.AddQuery(fmt.Sprintf("XA COMMIT '%s'", committed), &sqltypes.Result{})
+	fakedb.AddQuery(fmt.Sprintf("XA ROLLBACK '%s'", prepared), &sqltypes.Result{})
+
+	// Recover error, the decision is kept.
+	{
+		fakedb.AddQueryError(fmt.Sprintf("XA ROLLBACK '%s'", prepared), errors.New("mock.xa.rollback.error"))
+		err = scatter.Init(MockScatterDefault2(dir))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{committed}, scatter.txnMgr.xaLog.Pending())
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(fmt.Sprintf("XA COMMIT '%s'", committed)))
+	}
+
+	// Recover again.
+	{
+		fakedb.AddQuery(fmt.Sprintf("XA ROLLBACK '%s'", prepared), &sqltypes.Result{})
+		err = scatter.txnMgr.XaRecover(scatter)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(scatter.txnMgr.xaLog.Pending()))
+		assert.Equal(t, 4, fakedb.GetQueryCalledNum(fmt.Sprintf("XA COMMIT '%s'", committed)))
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", prepared)))
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", others)))
+	}
+
+	// The xid owned by the coordinator.
+	{
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+		err = txn.BeginScatter()
+		assert.Nil(t, err)
+		assert.True(t, scatter.txnMgr.xaLog.IsOwned(txn.XID()))
+	}
+}
+
+func TestXaLogRecoverXaCheck(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	xl := NewXaLog(log, dir)
+	err := xl.Init()
+	assert.Nil(t, err)
+	owner := xl.Owner()
+	xl.Close()
+
+	// The single branch commits failed before the crash, they have no decision in the xalog.
+	retry := "RXID-20190101000000-1-" + owner
+	timesout := "RXID-20190101000000-2-" + owner
+	prepared := "RXID-20190101000000-3-" + owner
+	err = ioutil.WriteFile(path.Join(dir, xacheckJSONFile), []byte(fmt.Sprintf(`{"xacommit-errs":[{"time":"20190101000000","xaid":"%s","state":"commit","times":1}]}`, retry)), 0644)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(path.Join(dir, xacheckTimesOutJSONFile), []byte(fmt.Sprintf(`{"time":"20190101000000","xaid":"%s","state":"commit","times":10}`, timesout)), 0644)
+	assert.Nil(t, err)
+
+	scatter, fakedb, cleanup := MockScatter(log, 2)
+	defer cleanup()
+	fakedb.AddQuery("XA RECOVER", mockXaRecoverResult(retry, timesout, prepared))
+	fakedb.AddQueryPattern("XA ROLLBACK .*", &sqltypes.Result{})
+	fakedb.AddQueryErrorPattern("XA COMMIT .*", errors.New("mock.xa.commit.error"))
+
+	err = scatter.Init(MockScatterDefault2(dir))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", retry)))
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", timesout)))
+	assert.Equal(t, 2, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", prepared)))
+
+	err = scatter.txnMgr.XaRecoverBackend(scatter, "backend0")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", retry)))
+	assert.Equal(t, 3, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", prepared)))
+}
+
+func TestXaLogRecoverBackend(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	scatter, fakedb, cleanup := MockScatter(log, 2)
+	defer cleanup()
+	fakedb.AddQuery("XA RECOVER", mockXaRecoverResult())
+	err := scatter.Init(MockScatterDefault2(dir))
+	assert.Nil(t, err)
+	xl := scatter.txnMgr.xaLog
+	owner := xl.Owner()
+	committed := "RXID-20190101000000-1-" + owner
+	prepared := "RXID-20190101000000-2-" + owner
+	err = xl.LogCommit(committed)
+	assert.Nil(t, err)
+
+	// The in-flight transaction.
+	fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	err = txn.BeginScatter()
+	assert.Nil(t, err)
+	inflight := txn.XID()
+
+	fakedb.AddQuery("XA RECOVER", mockXaRecoverResult(committed, prepared, inflight))
+	fakedb.AddQuery(fmt.Sprintf("XA COMMIT '%s'", committed), &sqltypes.Result{})
+	fakedb.AddQuery(fmt.Sprintf("XA ROLLBACK '%s'", prepared), &sqltypes.Result{})
+	fakedb.AddQuery(fmt.Sprintf("XA ROLLBACK '%s'", inflight), &sqltypes.Result{})
+
+	backend := scatter.Backends()[0]
+	err = scatter.txnMgr.XaRecoverBackend(scatter, backend)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, fakedb.GetQueryCalledNum(fmt.Sprintf("XA COMMIT '%s'", committed)))
+	assert.Equal(t, 1, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", prepared)))
+	assert.Equal(t, 0, fakedb.GetQueryCalledNum(fmt.Sprintf("XA ROLLBACK '%s'", inflight)))
+	// The decision is kept for the other backends.
+	assert.Equal(t, []string{committed}, xl.Pending())
+
+	// Error.
+	fakedb.AddQueryError("XA RECOVER", errors.New("mock.xa.recover.error"))
+	err = scatter.txnMgr.XaRecoverBackend(scatter, backend)
+	assert.NotNil(t, err)
+}
+
+func TestTxnXaLogCommit(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xalog", log)
+	defer os.RemoveAll(dir)
+
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+	fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+
+	txnMgr.xaLog = NewXaLog(log, dir)
+	err := txnMgr.xaLog.Init()
+	assert.Nil(t, err)
+	defer txnMgr.xaLog.Close()
+
+	// Commit, the decision is logged and done.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+		err = txn.BeginScatter()
+		assert.Nil(t, err)
+		err = txn.CommitScatter()
+		assert.Nil(t, err)
+		assert.False(t, txnMgr.xaLog.IsCommitted(txn.XID()))
+
+		data, err := ioutil.ReadFile(path.Join(dir, xalogFile))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, strings.Count(string(data), `"state":"commit"`))
+		assert.Equal(t, 1, strings.Count(string(data), `"state":"done"`))
+	}
+
+	// Commit error, the decision is kept for the recovery.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+		err = txn.BeginScatter()
+		assert.Nil(t, err)
+
+		old := xaMaxRetryNum
+		xaMaxRetryNum = 1
+		defer func() { xaMaxRetryNum = old }()
+		txnMgr.xaCheck = NewXaCheck(NewScatter(log, ""), MockScatterDefault2(dir))
+		fakedb.AddQueryErrorPattern("XA COMMIT .*", errors.New("mock.xa.commit.error"))
+		err = txn.CommitScatter()
+		assert.Nil(t, err)
+		assert.True(t, txnMgr.xaLog.IsCommitted(txn.XID()))
+		fakedb.ResetPatternErrors()
+	}
+
+	// Log error, the txn is rolled back.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMultiStmtTxn()
+		err = txn.BeginScatter()
+		assert.Nil(t, err)
+
+		txnMgr.xaLog.Close()
+		err = txn.CommitScatter()
+		assert.NotNil(t, err)
+		assert.False(t, txnMgr.xaLog.IsCommitted(txn.XID()))
+	}
+}
@@ -0,0 +1,274 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	txnCounterXaResolve      = "#xa.resolve"
+	txnCounterXaResolveError = "#xa.resolve.error"
+)
+
+// XaRecoverRow tuple, the prepared XA branch returned by the XA RECOVER of the backend.
+type XaRecoverRow struct {
+	Backend string `json:"backend"`
+	Xid     string `json:"xid"`
+	// Committed is true if the commit decision of the xid is logged by this coordinator.
+	Committed bool   `json:"committed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Xaz tuple, the in-doubt XA transactions.
+type Xaz struct {
+	Retrys   []*XaCommitErr  `json:"retrys"`
+	TimesOut []*XaCommitErr  `json:"timesout"`
+	Recovers []*XaRecoverRow `json:"recovers"`
+}
+
+// Retrys returns the xa commit errors which are pending to retry, sorted by the xid.
+func (xc *XaCheck) Retrys() []*XaCommitErr {
+	xc.mu.RLock()
+	defer xc.mu.RUnlock()
+
+	retrys := make([]*XaCommitErr, 0, len(xc.retrys))
+	for _, retry := range xc.retrys {
+		clone := *retry
+		retrys = append(retrys, &clone)
+	}
+	sort.Slice(retrys, func(i, j int) bool { return retrys[i].Xaid < retrys[j].Xaid })
+	return retrys
+}
+
+// TimesOut returns the xa commit errors which have retried for the number of times unsuccessfully.
+func (xc *XaCheck) TimesOut() ([]*XaCommitErr, error) {
+	file := path.Join(xc.dir, xacheckTimesOutJSONFile)
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	// The entries are appended one by one.
+	var timesout []*XaCommitErr
+	decoder := json.NewDecoder(f)
+	for {
+		entry := &XaCommitErr{}
+		if err := decoder.Decode(entry); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return timesout, errors.WithStack(err)
+		}
+		timesout = append(timesout, entry)
+	}
+	return timesout, nil
+}
+
+// claimRetry used to take the xid out of the retrys while it's resolved manually,
+// so the retry worker doesn't resolve it meanwhile.
+func (xc *XaCheck) claimRetry(xid string) error {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if _, ok := xc.resolving[xid]; ok {
+		return errors.Errorf("txnmgr.xa.resolve.xid[%s].is.resolving", xid)
+	}
+	xc.resolving[xid] = xc.retrys[xid]
+	delete(xc.retrys, xid)
+	return nil
+}
+
+// releaseRetry used to release the claimed xid: the resolved is removed from the log,
+// otherwise it's put back to the retrys.
+func (xc *XaCheck) releaseRetry(xid string, resolved bool) error {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	retry := xc.resolving[xid]
+	delete(xc.resolving, xid)
+	if retry == nil {
+		return nil
+	}
+	if !resolved {
+		xc.retrys[xid] = retry
+		return nil
+	}
+	return xc.flushXaCommitErrLog()
+}
+
+// owned returns the xids resolved by the xa check: the retrys, the resolving and the timed out ones.
+// The single branch commit has no decision in the xalog, so they must not be presumed abort by the recovery.
+func (xc *XaCheck) owned() (map[string]bool, error) {
+	if xc == nil {
+		return nil, nil
+	}
+	timesout, err := xc.TimesOut()
+	if err != nil {
+		return nil, err
+	}
+
+	xc.mu.RLock()
+	defer xc.mu.RUnlock()
+	owned := make(map[string]bool, len(xc.retrys)+len(xc.resolving)+len(timesout))
+	for xid := range xc.retrys {
+		owned[xid] = true
+	}
+	for xid := range xc.resolving {
+		owned[xid] = true
+	}
+	for _, entry := range timesout {
+		owned[entry.Xaid] = true
+	}
+	return owned, nil
+}
+
+// xaRecoverOn returns the xids which are prepared on the backend.
+func xaRecoverOn(txn *Txn, backend string) ([]string, error) {
+	qr, err := txn.ExecuteOnThisBackend(backend, "XA RECOVER")
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Fields) != 4 {
+		return nil, nil
+	}
+
+	xids := make([]string, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		xids = append(xids, string(row[3].Raw()))
+	}
+	return xids, nil
+}
+
+// Xaz returns the in-doubt XA transactions: the pending retrys, the timed-out retrys
+// and the prepared branches on all the backends.
+// The unreachable backend is returned with the error, so the others can be inspected.
+func (mgr *TxnManager) Xaz(scatter *Scatter) (*Xaz, error) {
+	log := mgr.log
+	xaz := &Xaz{}
+
+	if xc := mgr.xaCheck; xc != nil {
+		xaz.Retrys = xc.Retrys()
+		timesout, err := xc.TimesOut()
+		if err != nil {
+			log.Error("txnmgr.xaz.timesout.error:%v", err)
+			return nil, err
+		}
+		xaz.TimesOut = timesout
+	}
+
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+
+	for _, backend := range scatter.AllBackends() {
+		xids, err := xaRecoverOn(txn, backend)
+		if err != nil {
+			log.Error("txnmgr.xaz.xa.recover.on[%s].error:%v", backend, err)
+			xaz.Recovers = append(xaz.Recovers, &XaRecoverRow{Backend: backend, Error: err.Error()})
+			continue
+		}
+		for _, xid := range xids {
+			xaz.Recovers = append(xaz.Recovers, &XaRecoverRow{
+				Backend:   backend,
+				Xid:       xid,
+				Committed: mgr.xaLog.IsCommitted(xid),
+			})
+		}
+	}
+	return xaz, nil
+}
+
+// XaResolve used to resolve the in-doubt xid manually:
+// XA COMMIT or XA ROLLBACK the xid on the backends which it's prepared on,
+// then remove it from the retrys and the coordinator log.
+// The xid of the querys is the one recovered from the backends, the input is only matched against them.
+// Returns the number of the backends resolved, the error lists the backends failed to resolve.
+func (mgr *TxnManager) XaResolve(scatter *Scatter, xid string, commit bool) (int, error) {
+	log := mgr.log
+	txnCounters.Add(txnCounterXaResolve, 1)
+
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer txn.Finish()
+
+	// All the backends must be ready, otherwise the branch on the unreachable backend is left.
+	var recovered string
+	var backends []string
+	for _, backend := range scatter.AllBackends() {
+		xids, err := xaRecoverOn(txn, backend)
+		if err != nil {
+			log.Error("txnmgr.xa.resolve.xa.recover.on[%s].error:%v", backend, err)
+			txnCounters.Add(txnCounterXaResolveError, 1)
+			return 0, err
+		}
+		for _, x := range xids {
+			if strings.EqualFold(x, xid) {
+				recovered = x
+				backends = append(backends, backend)
+				break
+			}
+		}
+	}
+	if len(backends) == 0 {
+		txnCounters.Add(txnCounterXaResolveError, 1)
+		return 0, errors.Errorf("txnmgr.xa.resolve.xid[%s].not.found", xid)
+	}
+
+	// Stop the retry worker resolving the same xid.
+	xc := mgr.xaCheck
+	if xc != nil {
+		if err := xc.claimRetry(recovered); err != nil {
+			txnCounters.Add(txnCounterXaResolveError, 1)
+			return 0, err
+		}
+	}
+
+	query := fmt.Sprintf("XA ROLLBACK '%s'", recovered)
+	if commit {
+		query = fmt.Sprintf("XA COMMIT '%s'", recovered)
+	}
+	var failed []string
+	for _, backend := range backends {
+		log.Warning("txnmgr.xa.resolve.query[%s].on[%s]", query, backend)
+		if _, err := txn.ExecuteOnThisBackend(backend, query); err != nil {
+			log.Error("txnmgr.xa.resolve.query[%s].on[%s].error:%v", query, backend, err)
+			failed = append(failed, backend)
+		}
+	}
+	resolved := len(backends) - len(failed)
+
+	if xc != nil {
+		if err := xc.releaseRetry(recovered, len(failed) == 0); err != nil {
+			return resolved, err
+		}
+	}
+	if len(failed) > 0 {
+		txnCounters.Add(txnCounterXaResolveError, 1)
+		return resolved, errors.Errorf("txnmgr.xa.resolve.xid[%s].failed.on.backends%v", recovered, failed)
+	}
+	if err := mgr.xaLog.LogDone(recovered); err != nil {
+		return resolved, err
+	}
+	return resolved, nil
+}
@@ -0,0 +1,164 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"config"
+	"fakedb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func mockXazScatter(t *testing.T, log *xlog.Log, dir string) (*Scatter, *fakedb.DB, func()) {
+	scatter, fakedb, cleanup := MockScatter(log, 2)
+	fakedb.AddQuery("XA RECOVER", mockXaRecoverResult())
+	// The retry worker is out of the test.
+	conf := &config.ScatterConfig{
+		XaCheckInterval: 3600,
+		XaCheckDir:      dir,
+		XaCheckRetrys:   10,
+	}
+	err := scatter.Init(conf)
+	assert.Nil(t, err)
+	return scatter, fakedb, cleanup
+}
+
+func TestXaz(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xaz", log)
+	defer os.RemoveAll(dir)
+
+	scatter, fakedb, cleanup := mockXazScatter(t, log, dir)
+	defer cleanup()
+	xc := scatter.txnMgr.xaCheck
+	xl := scatter.txnMgr.xaLog
+
+	retry := "RXID-20190101000000-1"
+	committed := "RXID-20190101000000-2-" + xl.Owner()
+	timeout := "RXID-20190101000000-3"
+	xc.mu.Lock()
+	xc.addXaCommitErrLog(&XaCommitErr{Time: "20190101000000", Xaid: retry, State: txnXACommitErrStateCommit, Times: 10})
+	xc.mu.Unlock()
+	err := xl.LogCommit(committed)
+	assert.Nil(t, err)
+	data := fmt.Sprintf(`{"time":"20190101000000","xaid":"%s","state":"rollback","times":0}{"time":"20190101000001","xaid":"%s","state":"commit","times":0}`, timeout, timeout)
+	err = ioutil.WriteFile(path.Join(dir, xacheckTimesOutJSONFile), []byte(data), 0644)
+	assert.Nil(t, err)
+
+	{
+		fakedb.AddQuery("XA RECOVER", mockXaRecoverResult(retry, committed))
+		xaz, err := scatter.Xaz()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(xaz.Retrys))
+		assert.Equal(t, retry, xaz.Retrys[0].Xaid)
+		assert.Equal(t, 2, len(xaz.TimesOut))
+		assert.Equal(t, txnXACommitErrStateRollback, xaz.TimesOut[0].State)
+		assert.Equal(t, 4, len(xaz.Recovers))
+		for _, row := range xaz.Recovers {
+			assert.Equal(t, row.Xid == committed, row.Committed)
+		}
+	}
+
+	// Recover error, returned with the backend.
+	{
+		fakedb.AddQueryError("XA RECOVER", errors.New("mock.xa.recover.error"))
+		xaz, err := scatter.Xaz()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(xaz.Recovers))
+		assert.Equal(t, "mock.xa.recover.error (errno 1105) (sqlstate HY000)", xaz.Recovers[0].Error)
+	}
+
+	// Timeouts file error.
+	{
+		err = ioutil.WriteFile(path.Join(dir, xacheckTimesOutJSONFile), []byte("{"), 0644)
+		assert.Nil(t, err)
+		_, err := scatter.Xaz()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestXaResolve(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir := fakedb.GetTmpDir("/tmp", "xaz", log)
+	defer os.RemoveAll(dir)
+
+	scatter, fakedb, cleanup := mockXazScatter(t, log, dir)
+	defer cleanup()
+	xc := scatter.txnMgr.xaCheck
+	xl := scatter.txnMgr.xaLog
+
+	retry := "RXID-20190101000000-1"
+	committed := "RXID-20190101000000-2-" + xl.Owner()
+	xc.mu.Lock()
+	xc.addXaCommitErrLog(&XaCommitErr{Time: "20190101000000", Xaid: retry, State: txnXACommitErrStateCommit, Times: 10})
+	xc.mu.Unlock()
+	err := xl.LogCommit(committed)
+	assert.Nil(t, err)
+	fakedb.AddQuery("XA RECOVER", mockXaRecoverResult(retry, committed))
+
+	// Commit the retry.
+	{
+		query := fmt.Sprintf("XA COMMIT '%s'", retry)
+		fakedb.AddQuery(query, &sqltypes.Result{})
+		n, err := scatter.XaResolve(retry, true)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(query))
+		assert.Equal(t, 0, xc.GetRetrysLen())
+	}
+
+	// Rollback the committed, the decision is done.
+	{
+		query := fmt.Sprintf("XA ROLLBACK '%s'", committed)
+		fakedb.AddQuery(query, &sqltypes.Result{})
+		n, err := scatter.XaResolve(committed, false)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+		assert.False(t, xl.IsCommitted(committed))
+	}
+
+	// Xid not found.
+	{
+		fakedb.AddQuery("XA RECOVER", mockXaRecoverResult())
+		_, err := scatter.XaResolve(retry, true)
+		assert.NotNil(t, err)
+	}
+
+	// XA error, the failed backends are returned and the retry is kept.
+	{
+		xc.mu.Lock()
+		xc.addXaCommitErrLog(&XaCommitErr{Time: "20190101000000", Xaid: retry, State: txnXACommitErrStateCommit, Times: 10})
+		xc.mu.Unlock()
+		fakedb.AddQuery("XA RECOVER", mockXaRecoverResult(retry))
+		fakedb.AddQueryError(fmt.Sprintf("XA COMMIT '%s'", retry), errors.New("mock.xa.commit.error"))
+		n, err := scatter.XaResolve(strings.ToLower(retry), true)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, n)
+		assert.True(t, strings.Contains(err.Error(), "xid["+retry+"].failed.on.backends["))
+		for _, backend := range scatter.AllBackends() {
+			assert.True(t, strings.Contains(err.Error(), backend))
+		}
+		assert.Equal(t, 1, xc.GetRetrysLen())
+		assert.Equal(t, 0, len(xc.resolving))
+
+		fakedb.AddQueryError("XA RECOVER", errors.New("mock.xa.recover.error"))
+		_, err = scatter.XaResolve(retry, true)
+		assert.NotNil(t, err)
+	}
+}
@@ -38,10 +38,26 @@ type ProxyConfig struct {
 	LongQueryTime    int    `json:"long-query-time"`
 	StreamBufferSize int    `json:"stream-buffer-size"`
 	IdleTxnTimeout   uint32 `json:"kill-idle-transaction"` //is consistent with the official 8.0 kill_idle_transaction
+	PlanCacheSize    int    `json:"plan-cache-size"`       // 0 means the plan cache is disabled.
+	StmtCacheSize    int    `json:"stmt-cache-size"`       // 0 means the statement cache of the bind variables is disabled.
+	QueryDigestSize  int    `json:"query-digest-size"`     // 0 means the query digest is disabled.
 
-	//If autocommit-false-is-txn=true (false by default), a client connection with cmd: set autocommit=0
-	//is treated as start a transaction, e.g. begin, start transaction.
+	//A client connection with cmd: set autocommit=0 starts a transaction implicitly by the next statement.
+	//If autocommit-false-is-txn=true (false by default), the cmd itself is treated as start a transaction,
+	//e.g. begin, start transaction.
 	AutocommitFalseIsTxn bool `json:"autocommit-false-is-txn"`
+
+	//If commit-fence-enable=true (false by default), the XA COMMIT fences the reads on all the peers,
+	//the fence on the peer is released after commit-fence-lease(ms) if the coordinator is gone.
+	CommitFenceEnable bool `json:"commit-fence-enable"`
+	CommitFenceLease  int  `json:"commit-fence-lease"`
+
+	//The online schema change(RADON ALTER) copies online-ddl-chunk-size rows per chunk, the copy is paused
+	//while the replica lag of the backend standby is more than online-ddl-max-lag(seconds), the job fails
+	//if the lag stays over it or unknown for online-ddl-lag-timeout(seconds).
+	OnlineDDLChunkSize  int `json:"online-ddl-chunk-size"`
+	OnlineDDLMaxLag     int `json:"online-ddl-max-lag"`
+	OnlineDDLLagTimeout int `json:"online-ddl-lag-timeout"`
 }
 
 // DefaultProxyConfig returns default proxy config.
@@ -58,6 +74,14 @@ func DefaultProxyConfig() *ProxyConfig {
 		LongQueryTime:    5,                // 5 seconds
 		StreamBufferSize: 1024 * 1024 * 32, // 32MB
 		IdleTxnTimeout:   60,               // 60 seconds
+		PlanCacheSize:    4096,
+		StmtCacheSize:    4096,
+		QueryDigestSize:  4096,
+		CommitFenceLease: 30 * 1000, // 30 seconds
+
+		OnlineDDLChunkSize:  1000,
+		OnlineDDLMaxLag:     1,   // 1 second
+		OnlineDDLLagTimeout: 600, // 10 minutes
 	}
 }
 
@@ -78,6 +102,38 @@ type AuditConfig struct {
 	LogDir      string `json:"audit-dir"`
 	MaxSize     int    `json:"max-size"`
 	ExpireHours int    `json:"expire-hours"`
+	// Sinks where the events are written to, if empty the events are written to the audit-dir files.
+	Sinks []*AuditSinkConfig `json:"sinks,omitempty"`
+	// Filters of the events for all the sinks, an event is audited if any of the filters matches.
+	Filters []*AuditFilterConfig `json:"filters,omitempty"`
+}
+
+// AuditSinkConfig tuple.
+type AuditSinkConfig struct {
+	// Type is one of: file, syslog, tcp, http.
+	Type string `json:"type"`
+	// Network of the syslog: "", "tcp" or "udp", "" means the local syslog.
+	Network string `json:"network,omitempty"`
+	// Address of the syslog or the tcp sink, the url of the http sink.
+	Address string `json:"address,omitempty"`
+	// Tag of the syslog.
+	Tag string `json:"tag,omitempty"`
+	// BufferSize is the max number of the events buffered for the sink, the events are dropped if it's full.
+	BufferSize int `json:"buffer-size,omitempty"`
+	// Filters of the events for this sink.
+	Filters []*AuditFilterConfig `json:"filters,omitempty"`
+}
+
+// AuditFilterConfig tuple.
+// The conditions are ANDed, the empty condition matches all.
+type AuditFilterConfig struct {
+	Users        []string `json:"users,omitempty"`
+	Databases    []string `json:"databases,omitempty"`
+	CommandTypes []string `json:"command-types,omitempty"`
+	// Status is one of: "", "ok", "error".
+	Status string `json:"status,omitempty"`
+	// MinLatency in millisecond.
+	MinLatency int `json:"min-latency,omitempty"`
 }
 
 // DefaultAuditConfig returns default audit config.
@@ -90,6 +146,73 @@ func DefaultAuditConfig() *AuditConfig {
 	}
 }
 
+// SlowLogConfig tuple.
+// The queries exceed the long-query-time of the proxy are logged.
+type SlowLogConfig struct {
+	Enable      bool   `json:"enable"`
+	LogDir      string `json:"slowlog-dir"`
+	MaxSize     int    `json:"max-size"`
+	ExpireHours int    `json:"expire-hours"`
+}
+
+// DefaultSlowLogConfig returns default slow log config.
+func DefaultSlowLogConfig() *SlowLogConfig {
+	return &SlowLogConfig{
+		Enable:      false,
+		LogDir:      "/tmp/slowlog",
+		MaxSize:     1024 * 1024 * 256, // 256MB
+		ExpireHours: 24,                // 1days
+	}
+}
+
+// UnmarshalJSON interface on SlowLogConfig.
+func (c *SlowLogConfig) UnmarshalJSON(b []byte) error {
+	type confAlias *SlowLogConfig
+	conf := confAlias(DefaultSlowLogConfig())
+	if err := json.Unmarshal(b, conf); err != nil {
+		return err
+	}
+	*c = SlowLogConfig(*conf)
+	return nil
+}
+
+// TraceConfig tuple.
+type TraceConfig struct {
+	Enable bool `json:"enable"`
+	// Exporter is the otlp or file.
+	Exporter string `json:"exporter"`
+	// Endpoint is the OTLP/HTTP traces url for the otlp exporter, or the file path for the file exporter.
+	Endpoint    string `json:"endpoint"`
+	ServiceName string `json:"service-name"`
+	// SampleRatio is the ratio of the querys without the trace context to be traced,
+	// 0 means only the querys with the sampled traceparent are traced.
+	SampleRatio float64 `json:"sample-ratio"`
+	BufferSize  int     `json:"buffer-size"`
+}
+
+// DefaultTraceConfig returns default trace config.
+func DefaultTraceConfig() *TraceConfig {
+	return &TraceConfig{
+		Enable:      false,
+		Exporter:    "otlp",
+		Endpoint:    "http://127.0.0.1:4318/v1/traces",
+		ServiceName: "radon",
+		SampleRatio: 0,
+		BufferSize:  4096,
+	}
+}
+
+// UnmarshalJSON interface on TraceConfig.
+func (c *TraceConfig) UnmarshalJSON(b []byte) error {
+	type confAlias *TraceConfig
+	conf := confAlias(DefaultTraceConfig())
+	if err := json.Unmarshal(b, conf); err != nil {
+		return err
+	}
+	*c = TraceConfig(*conf)
+	return nil
+}
+
 // UnmarshalJSON interface on AuditConfig.
 func (c *AuditConfig) UnmarshalJSON(b []byte) error {
 	type confAlias *AuditConfig
@@ -157,6 +280,20 @@ type BackendConfig struct {
 	Charset        string `json:"charset"`
 	MaxConnections int    `json:"max-connections"`
 	Role           int    `json:"role"`
+	// Standby is the address promoted if the backend is down and the failover is enabled.
+	Standby string `json:"standby,omitempty"`
+
+	// The pool settings, 0 means the default.
+	// MinIdle is the number of the idle connections kept warm.
+	MinIdle int `json:"min-idle,omitempty"`
+	// MaxIdleTime(ms) is the max idle time of the connection, default is 20s.
+	MaxIdleTime int `json:"max-idle-time,omitempty"`
+	// MaxLifetime(ms) is the max lifetime of the connection, 0 means unlimited.
+	MaxLifetime int `json:"max-lifetime,omitempty"`
+	// WaitTimeout(ms) is the max time waiting for a connection if the pool is full, default is 5s.
+	WaitTimeout int `json:"wait-timeout,omitempty"`
+	// MaxWaiters is the max length of the wait queue, default is 1024.
+	MaxWaiters int `json:"max-waiters,omitempty"`
 }
 
 // BackendsConfig tuple.
@@ -164,6 +301,21 @@ type BackendsConfig struct {
 	Backends []*BackendConfig `json:"backends"`
 }
 
+// QuotaConfig tuple, the limits of a user or a database, 0 means no limits.
+type QuotaConfig struct {
+	MaxConcurrentQueries int `json:"max-concurrent-queries"`
+	MaxQPS               int `json:"max-qps"`
+	MaxConnections       int `json:"max-connections"`
+	MaxResultSize        int `json:"max-result-size"`
+	QueryTimeout         int `json:"query-timeout"`
+}
+
+// QuotasConfig tuple.
+type QuotasConfig struct {
+	Users     map[string]*QuotaConfig `json:"users"`
+	Databases map[string]*QuotaConfig `json:"databases"`
+}
+
 // PartitionConfig tuple.
 type PartitionConfig struct {
 	Table     string `json:"table"`
@@ -186,6 +338,7 @@ type TableConfig struct {
 	ShardKey      string             `json:"shardkey"`
 	Partitions    []*PartitionConfig `json:"partitions"`
 	AutoIncrement *AutoIncrement     `json:"auto-increment,omitempty"`
+	TableGroup    string             `json:"tablegroup,omitempty"`
 }
 
 // SchemaConfig tuple.
@@ -224,14 +377,29 @@ type ScatterConfig struct {
 	XaCheckInterval int    `json:"xa-check-interval"`
 	XaCheckDir      string `json:"xa-check-dir"`
 	XaCheckRetrys   int    `json:"xa-check-retrys`
+
+	// The backends are checked every health-check-interval milliseconds, 0 means disabled(the default),
+	// every check dials a new connection to the backend.
+	// A backend is marked down after health-check-failures consecutive failures,
+	// each check times out after health-check-timeout milliseconds.
+	HealthCheckInterval int `json:"health-check-interval"`
+	HealthCheckTimeout  int `json:"health-check-timeout"`
+	HealthCheckFailures int `json:"health-check-failures"`
+	// Failover enables promoting the standby of the down backend, the health check must be enabled
+	// on all the peers and the quorum of the peers must agree.
+	Failover bool `json:"failover"`
 }
 
 // DefaultScatterConfig returns default ScatterConfig config.
 func DefaultScatterConfig() *ScatterConfig {
 	return &ScatterConfig{
-		XaCheckInterval: 10,
-		XaCheckDir:      "./xacheck", //In the production environment, don't set the tmp dir
-		XaCheckRetrys:   10,
+		XaCheckInterval:     10,
+		XaCheckDir:          "./xacheck", //In the production environment, don't set the tmp dir
+		XaCheckRetrys:       10,
+		HealthCheckInterval: 0,
+		HealthCheckTimeout:  1000, // 1s
+		HealthCheckFailures: 3,
+		Failover:            false,
 	}
 }
 
@@ -250,6 +418,8 @@ func (c *ScatterConfig) UnmarshalJSON(b []byte) error {
 type Config struct {
 	Proxy   *ProxyConfig   `json:"proxy"`
 	Audit   *AuditConfig   `json:"audit"`
+	SlowLog *SlowLogConfig `json:"slowlog"`
+	Trace   *TraceConfig   `json:"trace"`
 	Router  *RouterConfig  `json:"router"`
 	Log     *LogConfig     `json:"log"`
 	Monitor *MonitorConfig `json:"monitor"`
@@ -265,6 +435,14 @@ func checkConfig(conf *Config) {
 		conf.Audit = DefaultAuditConfig()
 	}
 
+	if conf.SlowLog == nil {
+		conf.SlowLog = DefaultSlowLogConfig()
+	}
+
+	if conf.Trace == nil {
+		conf.Trace = DefaultTraceConfig()
+	}
+
 	if conf.Router == nil {
 		conf.Router = DefaultRouterConfig()
 	}
@@ -314,6 +492,15 @@ func ReadBackendsConfig(data string) (*BackendsConfig, error) {
 	return conf, nil
 }
 
+// ReadQuotasConfig used to read the quotas config from the data.
+func ReadQuotasConfig(data string) (*QuotasConfig, error) {
+	conf := &QuotasConfig{}
+	if err := json.Unmarshal([]byte(data), conf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return conf, nil
+}
+
 // WriteConfig used to write the conf to file.
 func WriteConfig(path string, conf interface{}) error {
 	b, err := json.MarshalIndent(conf, "", "\t")
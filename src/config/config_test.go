@@ -32,6 +32,8 @@ func TestWriteConfig(t *testing.T) {
 		Proxy:   MockProxyConfig,
 		Log:     MockLogConfig,
 		Audit:   DefaultAuditConfig(),
+		SlowLog: DefaultSlowLogConfig(),
+		Trace:   DefaultTraceConfig(),
 		Router:  DefaultRouterConfig(),
 		Monitor: DefaultMonitorConfig(),
 		Scatter: DefaultScatterConfig(),
@@ -68,6 +70,8 @@ func TestLoadConfig(t *testing.T) {
 		conf := &Config{
 			Proxy:   mockProxyConfig,
 			Audit:   DefaultAuditConfig(),
+			SlowLog: DefaultSlowLogConfig(),
+			Trace:   DefaultTraceConfig(),
 			Router:  DefaultRouterConfig(),
 			Monitor: DefaultMonitorConfig(),
 			Log:     MockLogConfig,
@@ -100,6 +104,8 @@ func TestLoadConfig(t *testing.T) {
 				Proxy:   MockProxyConfig,
 				Log:     MockLogConfig,
 				Audit:   DefaultAuditConfig(),
+				SlowLog: DefaultSlowLogConfig(),
+				Trace:   DefaultTraceConfig(),
 				Router:  DefaultRouterConfig(),
 				Monitor: DefaultMonitorConfig(),
 				Scatter: DefaultScatterConfig(),
@@ -115,6 +121,8 @@ func TestLoadConfig(t *testing.T) {
 			Proxy:   MockProxyConfig,
 			Log:     MockLogConfig,
 			Audit:   DefaultAuditConfig(),
+			SlowLog: DefaultSlowLogConfig(),
+			Trace:   DefaultTraceConfig(),
 			Router:  DefaultRouterConfig(),
 			Monitor: DefaultMonitorConfig(),
 			Scatter: DefaultScatterConfig(),
@@ -150,6 +158,8 @@ func TestWriteLoadConfig(t *testing.T) {
 			Proxy:   MockProxyConfig,
 			Log:     MockLogConfig,
 			Audit:   DefaultAuditConfig(),
+			SlowLog: DefaultSlowLogConfig(),
+			Trace:   DefaultTraceConfig(),
 			Router:  DefaultRouterConfig(),
 			Monitor: DefaultMonitorConfig(),
 			Scatter: DefaultScatterConfig(),
@@ -260,6 +270,8 @@ func TestRouterConfigUnmarshalJSON(t *testing.T) {
 			Proxy:   DefaultProxyConfig(),
 			Router:  DefaultRouterConfig(),
 			Audit:   DefaultAuditConfig(),
+			SlowLog: DefaultSlowLogConfig(),
+			Trace:   DefaultTraceConfig(),
 			Log:     DefaultLogConfig(),
 			Monitor: DefaultMonitorConfig(),
 			Scatter: DefaultScatterConfig(),
@@ -302,6 +314,8 @@ func TestRouterConfigUnmarshalJSON(t *testing.T) {
 			Proxy:   proxy,
 			Router:  DefaultRouterConfig(),
 			Audit:   DefaultAuditConfig(),
+			SlowLog: DefaultSlowLogConfig(),
+			Trace:   DefaultTraceConfig(),
 			Log:     DefaultLogConfig(),
 			Monitor: DefaultMonitorConfig(),
 			Scatter: DefaultScatterConfig(),
@@ -56,12 +56,27 @@ func (admin *Admin) NewRouter() (rest.App, error) {
 		rest.Post("/v1/peer/add", v1.AddPeerHandler(log, proxy)),
 		rest.Post("/v1/peer/remove", v1.RemovePeerHandler(log, proxy)),
 
+		// quota
+		rest.Get("/v1/quota/quotaz", v1.QuotazHandler(log, proxy)),
+		rest.Post("/v1/quota/set", v1.SetQuotaHandler(log, proxy)),
+		rest.Post("/v1/quota/remove", v1.RemoveQuotaHandler(log, proxy)),
+
+		// txn
+		rest.Post("/v1/txn/fence", v1.FenceHandler(log, proxy)),
+		rest.Post("/v1/txn/unfence", v1.UnfenceHandler(log, proxy)),
+
 		// debug
 		rest.Get("/v1/debug/processlist", v1.ProcesslistHandler(log, proxy)),
 		rest.Get("/v1/debug/queryz/:limit", v1.QueryzHandler(log, proxy)),
 		rest.Get("/v1/debug/txnz/:limit", v1.TxnzHandler(log, proxy)),
+		rest.Get("/v1/debug/xaz", v1.XazHandler(log, proxy)),
+		rest.Get("/v1/debug/slowlog", v1.SlowLogHandler(log, proxy)),
+		rest.Get("/v1/debug/slowlog/:limit", v1.SlowLogHandler(log, proxy)),
+		rest.Get("/v1/debug/digestz", v1.DigestzHandler(log, proxy)),
+		rest.Post("/v1/debug/digestz/reset", v1.DigestzResetHandler(log, proxy)),
 		rest.Get("/v1/debug/configz", v1.ConfigzHandler(log, proxy)),
 		rest.Get("/v1/debug/backendz", v1.BackendzHandler(log, proxy)),
 		rest.Get("/v1/debug/schemaz", v1.SchemazHandler(log, proxy)),
+		rest.Get("/v1/debug/schemacheck", v1.SchemaCheckHandler(log, proxy)),
 	)
 }
@@ -25,6 +25,11 @@ type backendParams struct {
 	User           string `json:"user"`
 	Password       string `json:"password"`
 	MaxConnections int    `json:"max-connections"`
+	MinIdle        int    `json:"min-idle"`
+	MaxIdleTime    int    `json:"max-idle-time"`
+	MaxLifetime    int    `json:"max-lifetime"`
+	WaitTimeout    int    `json:"wait-timeout"`
+	MaxWaiters     int    `json:"max-waiters"`
 }
 
 // AddBackendHandler impl.
@@ -69,6 +74,11 @@ func addBackendHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter,
 		Password:       p.Password,
 		Charset:        "utf8",
 		MaxConnections: p.MaxConnections,
+		MinIdle:        p.MinIdle,
+		MaxIdleTime:    p.MaxIdleTime,
+		MaxLifetime:    p.MaxLifetime,
+		WaitTimeout:    p.WaitTimeout,
+		MaxWaiters:     p.MaxWaiters,
 	}
 	log.Warning("api.v1.add[from:%v].backend[%+v]", r.RemoteAddr, conf)
 
@@ -43,9 +43,18 @@ func TestCtlV1BackendAdd(t *testing.T) {
 			User:           "mock",
 			Password:       "pwd",
 			MaxConnections: 1024,
+			WaitTimeout:    1000,
+			MaxWaiters:     16,
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/radon/backend", p))
 		recorded.CodeIs(200)
+
+		for _, conf := range proxy.Scatter().BackendConfigsClone() {
+			if conf.Name == "backend6" {
+				assert.Equal(t, 1000, conf.WaitTimeout)
+				assert.Equal(t, 16, conf.MaxWaiters)
+			}
+		}
 	}
 
 	// duplicate address.
@@ -25,5 +25,5 @@ func BackendzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
 
 func backendzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
 	scatter := proxy.Scatter()
-	w.WriteJson(scatter.BackendConfigsClone())
+	w.WriteJson(scatter.Backendz())
 }
@@ -22,7 +22,10 @@ import (
 
 func TestCtlV1Backendz(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	_, proxy, cleanup := proxy.MockProxy(log)
+	// The health check is opt-in.
+	conf := proxy.MockDefaultConfig()
+	conf.Scatter.HealthCheckInterval = 1000
+	_, proxy, cleanup := proxy.MockProxy1(log, conf)
 	defer cleanup()
 
 	// server
@@ -45,5 +48,6 @@ func TestCtlV1Backendz(t *testing.T) {
 		got := recorded.Recorder.Body.String()
 		log.Debug(got)
 		assert.True(t, strings.Contains(got, "backend4"))
+		assert.True(t, strings.Contains(got, "\"health\""))
 	}
 }
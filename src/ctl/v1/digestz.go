@@ -0,0 +1,46 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// DigestzHandler impl.
+func DigestzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		digestzHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+// digestzHandler used to list the query digests order by the sum latency desc.
+func digestzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(proxy.Spanner().Digests().Rows())
+}
+
+// DigestzResetHandler impl.
+func DigestzResetHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		digestzResetHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+// digestzResetHandler used to clear all the query digests.
+func digestzResetHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	log.Warning("api.v1.digestz.reset")
+	proxy.Spanner().Digests().Reset()
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,97 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type fenceParams struct {
+	Xid      string   `json:"xid"`
+	Backends []string `json:"backends"`
+	Tables   []string `json:"tables,omitempty"`
+	Lease    int      `json:"lease"`
+	Renew    bool     `json:"renew,omitempty"`
+	Peer     string   `json:"peer,omitempty"`
+}
+
+// FenceHandler impl.
+func FenceHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		fenceHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+// fenceHandler used to acquire the commit locks of the backends for the xid committed by the peer, or the gates
+// of the tables for the cutover of the online ddl by the peer, it returns after the locks are acquired.
+// The renew request extends the lease of the locks, the request with the peer grants the read lease to the peer.
+func fenceHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	scatter := proxy.Scatter()
+	p := fenceParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.txn.fence.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lease := time.Duration(p.Lease) * time.Millisecond
+	if p.Peer != "" {
+		proxy.PeerFence().Grant(p.Peer, lease)
+		return
+	}
+	if p.Xid == "" {
+		rest.Error(w, "api.v1.txn.fence.xid.cant.be.empty", http.StatusInternalServerError)
+		return
+	}
+
+	if p.Renew {
+		if err := scatter.FenceRenew(p.Xid, lease); err != nil {
+			log.Error("api.v1.txn.fence.renew[%+v].error:%+v", p, err)
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if len(p.Tables) > 0 {
+		err = scatter.GateLock(p.Xid, p.Tables, lease)
+	} else {
+		err = scatter.FenceLock(p.Xid, p.Backends, lease)
+	}
+	if err != nil {
+		log.Error("api.v1.txn.fence[%+v].error:%+v", p, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// UnfenceHandler impl.
+func UnfenceHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		unfenceHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func unfenceHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	scatter := proxy.Scatter()
+	p := fenceParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.txn.unfence.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	scatter.FenceUnlock(p.Xid)
+}
@@ -0,0 +1,130 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"testing"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1TxnFence(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+	scatter := proxy.Scatter()
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Post("/v1/txn/fence", FenceHandler(log, proxy)),
+		rest.Post("/v1/txn/unfence", UnfenceHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	p := &fenceParams{
+		Xid:      "RXID-20190101000000-1",
+		Backends: scatter.Backends(),
+		Lease:    10000,
+	}
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", p))
+		recorded.CodeIs(200)
+		// Fenced.
+		assert.NotNil(t, scatter.FenceLock(p.Xid, p.Backends, 0))
+	}
+
+	// Duplicate xid.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", p))
+		recorded.CodeIs(500)
+	}
+
+	// Renew.
+	{
+		renew := &fenceParams{Xid: p.Xid, Lease: 10000, Renew: true}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", renew))
+		recorded.CodeIs(200)
+	}
+
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/unfence", p))
+		recorded.CodeIs(200)
+		// Unfenced.
+		assert.Nil(t, scatter.FenceLock(p.Xid, p.Backends, 0))
+		scatter.FenceUnlock(p.Xid)
+	}
+
+	// Renew the unfenced xid.
+	{
+		renew := &fenceParams{Xid: p.Xid, Lease: 10000, Renew: true}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", renew))
+		recorded.CodeIs(500)
+	}
+
+	// Gate the tables.
+	{
+		gate := &fenceParams{Xid: "radon_osc_1_1", Tables: []string{"test.t1"}, Lease: 10000}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", gate))
+		recorded.CodeIs(200)
+		assert.NotNil(t, scatter.GateLock(gate.Xid, gate.Tables, 0))
+		// The commits are not fenced.
+		assert.Nil(t, scatter.FenceLock(p.Xid, p.Backends, 0))
+		scatter.FenceUnlock(p.Xid)
+
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/unfence", gate))
+		recorded.CodeIs(200)
+		assert.Nil(t, scatter.GateLock(gate.Xid, gate.Tables, 0))
+		scatter.FenceUnlock(gate.Xid)
+	}
+
+	// Grant the read lease to the peer, no xid is needed.
+	{
+		grant := &fenceParams{Peer: "127.0.0.1:8081", Lease: 10000}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", grant))
+		recorded.CodeIs(200)
+	}
+}
+
+func TestCtlV1TxnFenceError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Post("/v1/txn/fence", FenceHandler(log, proxy)),
+		rest.Post("/v1/txn/unfence", UnfenceHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", nil))
+		recorded.CodeIs(500)
+	}
+
+	{
+		p := &fenceParams{}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/fence", p))
+		recorded.CodeIs(500)
+	}
+
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/txn/unfence", nil))
+		recorded.CodeIs(500)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"config"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// quotaParams is the quota of the user or the database, one of them must be set.
+type quotaParams struct {
+	User     string `json:"user"`
+	Database string `json:"database"`
+	config.QuotaConfig
+}
+
+func (p *quotaParams) check() string {
+	if (p.User == "") == (p.Database == "") {
+		return "one.of.the.user.and.database.must.be.set"
+	}
+	return ""
+}
+
+// QuotazHandler impl.
+func QuotazHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		quotazHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func quotazHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(proxy.Quota().Config())
+}
+
+// SetQuotaHandler impl.
+func SetQuotaHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		setQuotaHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func setQuotaHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	quota := proxy.Quota()
+	p := quotaParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.set.quota.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if msg := p.check(); msg != "" {
+		log.Error("api.v1.set.quota[%+v].error:%s", p, msg)
+		rest.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.set.quota[from:%v].[%+v]", r.RemoteAddr, p)
+	conf := p.QuotaConfig
+	if p.User != "" {
+		err = quota.SetUser(p.User, &conf)
+	} else {
+		err = quota.SetDatabase(p.Database, &conf)
+	}
+	if err != nil {
+		log.Error("api.v1.set.quota[%+v].error:%+v", p, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// RemoveQuotaHandler impl.
+func RemoveQuotaHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		removeQuotaHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+func removeQuotaHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	quota := proxy.Quota()
+	p := quotaParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.remove.quota.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if msg := p.check(); msg != "" {
+		log.Error("api.v1.remove.quota[%+v].error:%s", p, msg)
+		rest.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.remove.quota[from:%v].[%+v]", r.RemoteAddr, p)
+	if p.User != "" {
+		err = quota.RemoveUser(p.User)
+	} else {
+		err = quota.RemoveDatabase(p.Database)
+	}
+	if err != nil {
+		log.Error("api.v1.remove.quota[%+v].error:%+v", p, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"testing"
+
+	"config"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1Quota(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Get("/v1/quota/quotaz", QuotazHandler(log, proxy)),
+		rest.Post("/v1/quota/set", SetQuotaHandler(log, proxy)),
+		rest.Post("/v1/quota/remove", RemoveQuotaHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// Set.
+	{
+		p := &quotaParams{User: "u1", QuotaConfig: config.QuotaConfig{MaxQPS: 100, MaxConnections: 10}}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/set", p))
+		recorded.CodeIs(200)
+
+		p = &quotaParams{Database: "db1", QuotaConfig: config.QuotaConfig{QueryTimeout: 1000}}
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/set", p))
+		recorded.CodeIs(200)
+	}
+
+	// Quotaz.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/quota/quotaz", nil))
+		recorded.CodeIs(200)
+		want := `{"users":{"u1":{"max-concurrent-queries":0,"max-qps":100,"max-connections":10,"max-result-size":0,"query-timeout":0}},"databases":{"db1":{"max-concurrent-queries":0,"max-qps":0,"max-connections":0,"max-result-size":0,"query-timeout":1000}}}`
+		assert.Equal(t, want, recorded.Recorder.Body.String())
+	}
+
+	// Remove.
+	{
+		p := &quotaParams{User: "u1"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/remove", p))
+		recorded.CodeIs(200)
+
+		p = &quotaParams{Database: "db1"}
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/remove", p))
+		recorded.CodeIs(200)
+
+		conf := proxy.Quota().Config()
+		assert.Equal(t, 0, len(conf.Users))
+		assert.Equal(t, 0, len(conf.Databases))
+	}
+}
+
+func TestCtlV1QuotaError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Post("/v1/quota/set", SetQuotaHandler(log, proxy)),
+		rest.Post("/v1/quota/remove", RemoveQuotaHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// Empty payload.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/set", nil))
+		recorded.CodeIs(500)
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/remove", nil))
+		recorded.CodeIs(500)
+	}
+
+	// Both user and database.
+	{
+		p := &quotaParams{User: "u1", Database: "db1"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/set", p))
+		recorded.CodeIs(500)
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/remove", p))
+		recorded.CodeIs(500)
+	}
+
+	// Negative.
+	{
+		p := &quotaParams{User: "u1", QuotaConfig: config.QuotaConfig{MaxQPS: -1}}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/set", p))
+		recorded.CodeIs(500)
+	}
+
+	// Not found.
+	{
+		p := &quotaParams{Database: "db1"}
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/quota/remove", p))
+		recorded.CodeIs(500)
+	}
+}
@@ -28,6 +28,7 @@ type radonParams struct {
 	AuditMode        *string  `json:"audit-mode"`
 	StreamBufferSize *int     `json:"stream-buffer-size"`
 	Blocks           *int     `json:"blocks-readonly"`
+	StmtCacheSize    *int     `json:"stmt-cache-size"`
 }
 
 // RadonConfigHandler impl.
@@ -76,6 +77,9 @@ func radonConfigHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter
 	if p.Blocks != nil {
 		proxy.SetBlocks(*p.Blocks)
 	}
+	if p.StmtCacheSize != nil {
+		proxy.SetStmtCacheSize(*p.StmtCacheSize)
+	}
 
 	// reset the allow ip table list.
 	proxy.IPTable().Refresh()
@@ -46,6 +46,7 @@ func TestCtlV1RadonConfig(t *testing.T) {
 			AuditMode        string   `json:"audit-mode"`
 			StreamBufferSize int      `json:"stream-buffer-size"`
 			Blocks           int      `json:"blocks-readonly"`
+			StmtCacheSize    int      `json:"stmt-cache-size"`
 		}
 
 		// 200.
@@ -61,6 +62,7 @@ func TestCtlV1RadonConfig(t *testing.T) {
 				AuditMode:        "A",
 				StreamBufferSize: 16777216,
 				Blocks:           128,
+				StmtCacheSize:    128,
 			}
 			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("PUT", "http://localhost/v1/radon/config", p))
 			recorded.CodeIs(200)
@@ -76,6 +78,7 @@ func TestCtlV1RadonConfig(t *testing.T) {
 			assert.Equal(t, "A", radonConf.Audit.Mode)
 			assert.Equal(t, 16777216, radonConf.Proxy.StreamBufferSize)
 			assert.Equal(t, 128, radonConf.Router.Blocks)
+			assert.Equal(t, 128, radonConf.Proxy.StmtCacheSize)
 		}
 
 		// Unset AllowIP.
@@ -0,0 +1,52 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// SchemaCheckHandler impl.
+func SchemaCheckHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		schemaCheckHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+// schemaCheckHandler used to check the sub-tables on the backends are consistent with the router,
+// the query parameters database, table and repair are optional.
+func schemaCheckHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	params := r.URL.Query()
+	database := params.Get("database")
+	table := params.Get("table")
+	if database == "" && table != "" {
+		rest.Error(w, "api.v1.schemacheck.table.requires.database", http.StatusBadRequest)
+		return
+	}
+	repair, _ := strconv.ParseBool(params.Get("repair"))
+
+	issues, err := proxy.Spanner().CheckSchema(database, table, repair)
+	if err != nil {
+		log.Error("api.v1.schemacheck.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(issues) == 0 {
+		w.WriteJson([]interface{}{})
+		return
+	}
+	w.WriteJson(issues)
+}
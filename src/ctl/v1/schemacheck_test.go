@@ -0,0 +1,98 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1SchemaCheck(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQuery("show tables from `test`", &sqltypes.Result{})
+		fakedbs.AddQuery("show tables from `test1`", &sqltypes.Result{})
+		fakedbs.AddQueryError("show tables from `test2`", sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, "test2"))
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		querys := []string{
+			"create database test",
+			"create database test1",
+			"create database test2",
+			"create table test.t1(id int, b int) partition by hash(id)",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+	}
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Get("/v1/debug/schemacheck", SchemaCheckHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// The sub-tables are missing.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/schemacheck?database=test&table=t1", nil))
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(got, `"sub-table":"t1_0000","type":"missing","detail":"sub-table.missing"`))
+		assert.False(t, strings.Contains(got, `"repair"`))
+	}
+
+	// All the databases with repair.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/schemacheck?repair=true", nil))
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(got, `"database":"test","table":"t1"`))
+		assert.True(t, strings.Contains(got, `"repair":"CREATE DATABASE IF NOT EXISTS `+"`test2`"+`"`))
+	}
+
+	// Consistent.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/schemacheck?database=test1", nil))
+		recorded.CodeIs(200)
+		assert.Equal(t, "[]", recorded.Recorder.Body.String())
+	}
+
+	// Errors.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/schemacheck?database=xx", nil))
+		recorded.CodeIs(500)
+
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/schemacheck?table=t1", nil))
+		recorded.CodeIs(400)
+	}
+}
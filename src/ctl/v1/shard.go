@@ -9,6 +9,7 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -445,20 +446,49 @@ func shardMigrateHandler(proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Requ
 		Checksum:               p.Checksum,
 		WaitTimeBeforeChecksum: p.WaitTimeBeforeChecksum,
 	}
+	// The tables in the same table group must be moved together, or the group will lose the colocation.
+	siblings, err := proxy.Router().TableGroupSiblings(p.FromDatabase, p.FromTable)
+	if err != nil {
+		log.Error("api.v1.shard.migrate.get.tablegroup.siblings.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := runShift(log, cfg); err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	shifted := []string{p.FromTable}
+	for i, sibling := range siblings {
+		sibCfg := *cfg
+		sibCfg.FromTable = sibling
+		sibCfg.ToTable = sibling
+		log.Warning("api.v1.shard.migrate.tablegroup.sibling[%s.%s]...", p.FromDatabase, sibling)
+		if err := runShift(log, &sibCfg); err != nil {
+			// The shifted tables can't be moved back safely, the operator must finish or revert the unshifted ones.
+			msg := fmt.Sprintf("api.v1.shard.migrate.tablegroup.diverged.shifted%v.unshifted%v:%v", shifted, siblings[i:], err)
+			log.Error("%s", msg)
+			rest.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		shifted = append(shifted, sibling)
+	}
+	log.Warning("api.v1.shard.migrate.done...")
+}
+
+func runShift(log *shiftlog.Log, cfg *shift.Config) error {
 	log.Info("shift.cfg:%+v", cfg)
 
 	shift := shift.NewShift(log, cfg)
 	if err := shift.Start(); err != nil {
 		log.Error("shift.start.error:%+v", err)
-		rest.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
 	}
 
-	err = shift.WaitFinish()
-	if err != nil {
+	if err := shift.WaitFinish(); err != nil {
 		log.Error("shift.wait.finish.error:%+v", err)
-		rest.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
 	}
-	log.Warning("api.v1.shard.migrate.done...")
+	return nil
 }
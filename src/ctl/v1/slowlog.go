@@ -0,0 +1,52 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// SlowLogHandler impl.
+func SlowLogHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		slowLogHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+// slowLogHandler writes the recent slow querys in the mysql slow log format,
+// so the output can be piped to the pt-query-digest directly.
+// The limit is the max number of the latest querys written, 0 or absent means all.
+func slowLogHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	limit := 0
+	param := r.PathParam("limit")
+	if param == "" {
+		param = r.URL.Query().Get("limit")
+	}
+	if param != "" {
+		v, err := strconv.Atoi(param)
+		if err != nil || v < 0 {
+			rest.Error(w, "api.v1.slowlog.invalid.limit:"+param, http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.(http.ResponseWriter).Write(proxy.SlowLog().Recent(limit)); err != nil {
+		log.Error("api.v1.slowlog.write.error:%+v", err)
+	}
+}
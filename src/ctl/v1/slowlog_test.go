@@ -0,0 +1,89 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"config"
+	"fakedb"
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1SlowLog(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+
+	conf := proxy.MockDefaultConfig()
+	conf.Proxy.LongQueryTime = 0
+	conf.SlowLog = &config.SlowLogConfig{Enable: true, LogDir: tmpDir, MaxSize: 1024 * 1024}
+	fakedbs, proxy, cleanup := proxy.MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test1", -1)
+		assert.Nil(t, err)
+	}
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Get("/v1/debug/slowlog", SlowLogHandler(log, proxy)),
+		rest.Get("/v1/debug/slowlog/:limit", SlowLogHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/slowlog", nil))
+		recorded.CodeIs(200)
+		recorded.HeaderIs("Content-Type", "text/plain; charset=utf-8")
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.HasPrefix(got, "# Time: "), got)
+		assert.Equal(t, 2, strings.Count(got, "# Time: "), got)
+		assert.True(t, strings.HasSuffix(got, "create database test1;\n"), got)
+	}
+
+	// Limit.
+	for _, url := range []string{"http://localhost/v1/debug/slowlog/1", "http://localhost/v1/debug/slowlog?limit=1"} {
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.Equal(t, 1, strings.Count(got, "# Time: "), got)
+		assert.True(t, strings.HasSuffix(got, "create database test1;\n"), got)
+	}
+
+	// Invalid limit.
+	for _, url := range []string{"http://localhost/v1/debug/slowlog/x", "http://localhost/v1/debug/slowlog?limit=-1"} {
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", url, nil))
+		recorded.CodeIs(400)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"proxy"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestCtlV1Xaz(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+
+	// fakedbs.
+	{
+		fakedbs.AddQuery("XA RECOVER", &sqltypes.Result{
+			RowsAffected: 1,
+			Fields: []*querypb.Field{
+				{Name: "formatID", Type: querypb.Type_INT64},
+				{Name: "gtrid_length", Type: querypb.Type_INT64},
+				{Name: "bqual_length", Type: querypb.Type_INT64},
+				{Name: "data", Type: querypb.Type_VARCHAR},
+			},
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("21")),
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("RXID-20190101000000-1")),
+				},
+			},
+		})
+	}
+
+	// server
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Get("/v1/debug/xaz", XazHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/xaz", nil))
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(got, `"xid":"RXID-20190101000000-1"`))
+		assert.True(t, strings.Contains(got, `"retrys":[]`))
+	}
+
+	// Recover error is returned with the backend.
+	{
+		fakedbs.AddQueryError("XA RECOVER", errors.New("mock.xa.recover.error"))
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/xaz", nil))
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(got, "mock.xa.recover.error"))
+	}
+}
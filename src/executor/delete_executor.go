@@ -39,11 +39,16 @@ func NewDeleteExecutor(log *xlog.Log, plan planner.Plan, txn backend.Transaction
 // Execute used to execute the executor.
 func (executor *DeleteExecutor) Execute(ctx *xcontext.ResultContext) error {
 	plan := executor.plan.(*planner.DeletePlan)
+	querys, err := bindQuerys(plan.Querys, plan.ParsedQuerys, ctx.BindVars)
+	if err != nil {
+		return err
+	}
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = plan.ReqMode
 	reqCtx.TxnMode = xcontext.TxnWrite
-	reqCtx.Querys = plan.Querys
+	reqCtx.Querys = querys
 	reqCtx.RawQuery = plan.RawQuery
+	reqCtx.Profile = ctx.Profile
 
 	rs, err := executor.txn.Execute(reqCtx)
 	if err != nil {
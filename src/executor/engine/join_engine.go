@@ -10,6 +10,7 @@ package engine
 
 import (
 	"sync"
+	"time"
 
 	"backend"
 	"executor/engine/operator"
@@ -57,6 +58,8 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 		}
 	}
 
+	// The time of the nest loop join includes the right node's executions.
+	start := time.Now()
 	maxrow := j.txn.MaxJoinRows()
 	if j.node.Strategy == builder.NestLoop {
 		joinVars := make(map[string]*querypb.BindVariable)
@@ -64,8 +67,8 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 			return err
 		}
 	} else {
-		lctx := xcontext.NewResultContext()
-		rctx := xcontext.NewResultContext()
+		lctx := ctx.Child()
+		rctx := ctx.Child()
 		wg.Add(1)
 		go oneExec(j.left, lctx)
 		wg.Add(1)
@@ -75,9 +78,11 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 			return allErrors[0]
 		}
 
+		start = time.Now()
 		ctx.Results = &sqltypes.Result{}
 		ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
 		if len(lctx.Results.Rows) == 0 {
+			ctx.Profile.AddOperator(j.node, start, 0)
 			return nil
 		}
 
@@ -98,14 +103,15 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 		}
 	}
 
+	ctx.Profile.AddOperator(j.node, start, len(ctx.Results.Rows))
 	return operator.ExecSubPlan(j.log, j.node, ctx)
 }
 
 // execBindVars used to execute querys with bindvars.
 func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
 	var err error
-	lctx := xcontext.NewResultContext()
-	rctx := xcontext.NewResultContext()
+	lctx := ctx.Child()
+	rctx := ctx.Child()
 	maxrow := j.txn.MaxJoinRows()
 	ctx.Results = &sqltypes.Result{}
 
@@ -175,8 +181,8 @@ func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[stri
 // getFields fetches the field info.
 func (j *JoinEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
 	var err error
-	lctx := xcontext.NewResultContext()
-	rctx := xcontext.NewResultContext()
+	lctx := ctx.Child()
+	rctx := ctx.Child()
 
 	joinVars := make(map[string]*querypb.BindVariable)
 	if err = j.left.getFields(lctx, bindVars); err != nil {
@@ -9,6 +9,8 @@
 package engine
 
 import (
+	"time"
+
 	"backend"
 	"executor/engine/operator"
 	"planner/builder"
@@ -43,20 +45,30 @@ func NewMergeEngine(log *xlog.Log, node *builder.MergeNode, txn backend.Transact
 func (m *MergeEngine) Execute(ctx *xcontext.ResultContext) error {
 	var err error
 
+	// The literals of the parameterized plan are bound by the ctx.
+	if ctx.BindVars != nil && m.node.ReqMode == xcontext.ReqNormal {
+		return m.execBindVars(ctx, nil, false)
+	}
+
+	start := time.Now()
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = m.node.ReqMode
 	reqCtx.TxnMode = xcontext.TxnRead
+	reqCtx.Profile = ctx.Profile
 	if reqCtx.Mode == xcontext.ReqNormal {
 		reqCtx.Querys = m.node.Querys
 	} else {
 		buf := sqlparser.NewTrackedBuffer(nil)
 		m.node.Sel.Format(buf)
-		reqCtx.RawQuery = buf.String()
+		if reqCtx.RawQuery, err = buf.ParsedQuery().GenerateQuery(ctx.BindVars, nil); err != nil {
+			return err
+		}
 	}
 
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
+	ctx.Profile.AddOperator(m.node, start, len(ctx.Results.Rows))
 	return operator.ExecSubPlan(m.log, m.node, ctx)
 }
 
@@ -65,7 +77,13 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 	var query string
 	var err error
 
-	querys := m.node.Querys
+	start := time.Now()
+	if ctx.BindVars != nil {
+		bindVars = combineVars(ctx.BindVars, bindVars)
+	}
+	// Copy the querys, the node maybe shared by the cached plans.
+	querys := make([]xcontext.QueryTuple, len(m.node.Querys))
+	copy(querys, m.node.Querys)
 	for i, p := range m.node.ParsedQuerys {
 		query, err = p.GenerateQuery(bindVars, nil)
 		if err != nil {
@@ -78,10 +96,12 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 	reqCtx.Mode = xcontext.ReqNormal
 	reqCtx.TxnMode = xcontext.TxnRead
 	reqCtx.Querys = querys
+	reqCtx.Profile = ctx.Profile
 
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
+	ctx.Profile.AddOperator(m.node, start, len(ctx.Results.Rows))
 	return operator.ExecSubPlan(m.log, m.node, ctx)
 }
 
@@ -89,6 +109,9 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 func (m *MergeEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
 	var err error
 
+	if ctx.BindVars != nil {
+		bindVars = combineVars(ctx.BindVars, bindVars)
+	}
 	query := m.node.Querys[len(m.node.Querys)-1]
 	query.Query, err = m.node.GenerateFieldQuery().GenerateQuery(bindVars, nil)
 	if err != nil {
@@ -99,6 +122,7 @@ func (m *MergeEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string
 	reqCtx.Mode = xcontext.ReqNormal
 	reqCtx.TxnMode = xcontext.TxnRead
 	reqCtx.Querys = []xcontext.QueryTuple{query}
+	reqCtx.Profile = ctx.Profile
 
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
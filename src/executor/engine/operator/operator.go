@@ -9,6 +9,8 @@
 package operator
 
 import (
+	"time"
+
 	"planner/builder"
 	"xcontext"
 
@@ -25,6 +27,7 @@ func ExecSubPlan(log *xlog.Log, node builder.PlanNode, ctx *xcontext.ResultConte
 	subPlanTree := node.Children()
 	if subPlanTree != nil {
 		for _, subPlan := range subPlanTree {
+			start := time.Now()
 			switch subPlan.Type() {
 			case builder.ChildTypeAggregate:
 				aggrOperator := NewAggregateOperator(log, subPlan)
@@ -42,6 +45,7 @@ func ExecSubPlan(log *xlog.Log, node builder.PlanNode, ctx *xcontext.ResultConte
 					return err
 				}
 			}
+			ctx.Profile.AddOperator(subPlan, start, len(ctx.Results.Rows))
 		}
 	}
 	return nil
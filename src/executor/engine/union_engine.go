@@ -11,6 +11,7 @@ package engine
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"backend"
 	"executor/engine/operator"
@@ -57,8 +58,8 @@ func (u *UnionEngine) Execute(ctx *xcontext.ResultContext) error {
 			mu.Unlock()
 		}
 	}
-	lctx := xcontext.NewResultContext()
-	rctx := xcontext.NewResultContext()
+	lctx := ctx.Child()
+	rctx := ctx.Child()
 	wg.Add(1)
 	go oneExec(u.left, lctx)
 	wg.Add(1)
@@ -71,10 +72,12 @@ func (u *UnionEngine) Execute(ctx *xcontext.ResultContext) error {
 	if len(lctx.Results.Fields) != len(rctx.Results.Fields) {
 		return errors.New("unsupported: the.used.'select'.statements.have.a.different.number.of.columns")
 	}
+	start := time.Now()
 	ctx.Results = &sqltypes.Result{}
 	ctx.Results.Fields = lctx.Results.Fields
 	lctx.Results.AppendResult(rctx.Results)
 	if len(lctx.Results.Rows) == 0 {
+		ctx.Profile.AddOperator(u.node, start, 0)
 		return nil
 	}
 	if u.node.Typ == "union distinct" || u.node.Typ == "union" {
@@ -96,6 +99,7 @@ func (u *UnionEngine) Execute(ctx *xcontext.ResultContext) error {
 		ctx.Results.Rows = lctx.Results.Rows
 		ctx.Results.RowsAffected = lctx.Results.RowsAffected
 	}
+	ctx.Profile.AddOperator(u.node, start, len(ctx.Results.Rows))
 	return operator.ExecSubPlan(u.log, u.node, ctx)
 }
 
@@ -14,6 +14,8 @@ import (
 	"xcontext"
 
 	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -29,6 +31,8 @@ type Tree struct {
 	children []Executor
 	txn      backend.Transaction
 	planTree *planner.PlanTree
+	profile  *xcontext.Profile
+	bindVars map[string]*querypb.BindVariable
 }
 
 // NewTree creates the new execute tree.
@@ -47,6 +51,16 @@ func (et *Tree) Add(executor Executor) error {
 	return nil
 }
 
+// SetProfile used to collect the execution stats to the profile.
+func (et *Tree) SetProfile(profile *xcontext.Profile) {
+	et.profile = profile
+}
+
+// SetBindVars used to bind the literals of the parameterized plan tree.
+func (et *Tree) SetBindVars(bindVars map[string]*querypb.BindVariable) {
+	et.bindVars = bindVars
+}
+
 // Execute executes all Executor.Execute
 func (et *Tree) Execute() (*sqltypes.Result, error) {
 	// build tree
@@ -94,6 +108,8 @@ func (et *Tree) Execute() (*sqltypes.Result, error) {
 
 	// execute all
 	rsCtx := xcontext.NewResultContext()
+	rsCtx.Profile = et.profile
+	rsCtx.BindVars = et.bindVars
 	for _, executor := range et.children {
 		if err := executor.Execute(rsCtx); err != nil {
 			return nil, err
@@ -101,3 +117,22 @@ func (et *Tree) Execute() (*sqltypes.Result, error) {
 	}
 	return rsCtx.Results, nil
 }
+
+// bindQuerys returns the querys generated with the bind variables, the querys
+// are shared by the cached plans so they are copied.
+func bindQuerys(querys []xcontext.QueryTuple, parsedQuerys []*sqlparser.ParsedQuery, bindVars map[string]*querypb.BindVariable) ([]xcontext.QueryTuple, error) {
+	if bindVars == nil {
+		return querys, nil
+	}
+
+	bound := make([]xcontext.QueryTuple, len(querys))
+	copy(bound, querys)
+	for i, p := range parsedQuerys {
+		query, err := p.GenerateQuery(bindVars, nil)
+		if err != nil {
+			return nil, err
+		}
+		bound[i].Query = query
+	}
+	return bound, nil
+}
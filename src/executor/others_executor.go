@@ -44,6 +44,7 @@ func (executor *OthersExecutor) Execute(ctx *xcontext.ResultContext) error {
 	reqCtx.TxnMode = xcontext.TxnRead
 	reqCtx.Querys = plan.Querys
 	reqCtx.RawQuery = plan.RawQuery
+	reqCtx.Profile = ctx.Profile
 
 	rs, err := executor.txn.Execute(reqCtx)
 	if err != nil {
@@ -11,6 +11,8 @@ package monitor
 import (
 	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"config"
 
@@ -75,6 +77,68 @@ var (
 			Name: "peer_number",
 			Help: "radon peer Number",
 		})
+
+	queryLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "query_latency_seconds",
+			Help:    "Latency of queries by command and the first table known by the router.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+		},
+		[]string{"command", "table"},
+	)
+
+	rowsReturnedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rows_returned_total",
+			Help: "Counter of rows returned to the clients.",
+		},
+		[]string{"command"},
+	)
+
+	backendLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "backend_request_latency_seconds",
+			Help:    "Latency of the requests to the backends.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+		},
+		[]string{"address"},
+	)
+
+	backendErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backend_request_error_total",
+			Help: "Counter of the failed requests to the backends.",
+		},
+		[]string{"address"},
+	)
+
+	rowsScannedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rows_scanned_total",
+			Help: "Counter of rows fetched from the backends.",
+		},
+		[]string{"address"},
+	)
+
+	twopcPhaseLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "twopc_phase_latency_seconds",
+			Help:    "Latency of the 2PC phases.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+		},
+		[]string{"phase"},
+	)
+
+	poolWaitLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pool_wait_seconds",
+			Help:    "Time spent waiting for a connection of the backend pools.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+		},
+		[]string{"pool"},
+	)
+
+	pools = newPoolCollector()
 )
 
 func init() {
@@ -85,6 +149,14 @@ func init() {
 	prometheus.MustRegister(diskUsage)
 	prometheus.MustRegister(slowQueryTotalCounter)
 	prometheus.MustRegister(peerNum)
+	prometheus.MustRegister(queryLatency)
+	prometheus.MustRegister(rowsReturnedCounter)
+	prometheus.MustRegister(backendLatency)
+	prometheus.MustRegister(backendErrorCounter)
+	prometheus.MustRegister(rowsScannedCounter)
+	prometheus.MustRegister(twopcPhaseLatency)
+	prometheus.MustRegister(poolWaitLatency)
+	prometheus.MustRegister(pools)
 }
 
 // Start monitor
@@ -162,3 +234,138 @@ func PeerNumDec() {
 func PeerNumSet(v float64) {
 	peerNum.Set(v)
 }
+
+// QueryLatencyObserve observes the latency of the query on the table.
+func QueryLatencyObserve(command string, table string, latency time.Duration) {
+	queryLatency.WithLabelValues(command, table).Observe(latency.Seconds())
+}
+
+// RowsReturnedAdd add the rows returned to the client.
+func RowsReturnedAdd(command string, rows int) {
+	rowsReturnedCounter.WithLabelValues(command).Add(float64(rows))
+}
+
+// BackendRequestObserve observes the request to the backend.
+func BackendRequestObserve(address string, start time.Time, err error) {
+	backendLatency.WithLabelValues(address).Observe(time.Since(start).Seconds())
+	if err != nil {
+		backendErrorCounter.WithLabelValues(address).Inc()
+	}
+}
+
+// RowsScannedAdd add the rows fetched from the backend.
+func RowsScannedAdd(address string, rows int) {
+	rowsScannedCounter.WithLabelValues(address).Add(float64(rows))
+}
+
+// TwoPCPhaseObserve observes the latency of the 2PC phase.
+func TwoPCPhaseObserve(phase string, start time.Time) {
+	twopcPhaseLatency.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// RegisterPool registers the counters and the gauges of the pool, they're exported when scraped.
+// It returns the id of the registration which is used to unregister, the pool replaced by the one
+// with the same name keeps its own id so that closing it never unregisters the replacement.
+func RegisterPool(name string, counts func() map[string]int64, gauges func() map[string]int64) uint64 {
+	return pools.register(name, counts, gauges)
+}
+
+// UnregisterPool unregisters the counters and the gauges of the pool registered with the id.
+func UnregisterPool(id uint64) {
+	if name, last := pools.unregister(id); last {
+		poolWaitLatency.DeleteLabelValues(name)
+	}
+}
+
+// PoolWaitObserve observes the time waiting for a connection of the pool.
+func PoolWaitObserve(name string, start time.Time) {
+	poolWaitLatency.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
+
+// poolEntry tuple, the registration of a pool.
+type poolEntry struct {
+	name   string
+	counts func() map[string]int64
+	gauges func() map[string]int64
+}
+
+// poolCollector collects the counters and the gauges of the backend pools.
+type poolCollector struct {
+	mu        sync.RWMutex
+	seq       uint64
+	desc      *prometheus.Desc
+	gaugeDesc *prometheus.Desc
+	entries   map[uint64]*poolEntry
+}
+
+func newPoolCollector() *poolCollector {
+	return &poolCollector{
+		desc:      prometheus.NewDesc("pool_counter", "Counters of the backend pools.", []string{"pool", "counter"}, nil),
+		gaugeDesc: prometheus.NewDesc("pool_gauge", "Gauges of the backend pools.", []string{"pool", "gauge"}, nil),
+		entries:   make(map[uint64]*poolEntry),
+	}
+}
+
+func (c *poolCollector) register(name string, counts func() map[string]int64, gauges func() map[string]int64) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	c.entries[c.seq] = &poolEntry{name: name, counts: counts, gauges: gauges}
+	return c.seq
+}
+
+// unregister returns the name of the pool and true if no other pool is registered with the name.
+func (c *poolCollector) unregister(id uint64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	if !ok {
+		return "", false
+	}
+	delete(c.entries, id)
+	for _, e := range c.entries {
+		if e.name == entry.name {
+			return entry.name, false
+		}
+	}
+	return entry.name, true
+}
+
+// current returns the latest registered entry of every pool name, the metrics of
+// a name must be collected once.
+func (c *poolCollector) current() []*poolEntry {
+	ids := make(map[string]uint64)
+	for id, e := range c.entries {
+		if id > ids[e.name] {
+			ids[e.name] = id
+		}
+	}
+	entries := make([]*poolEntry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, c.entries[id])
+	}
+	return entries
+}
+
+// Describe impl.
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+	ch <- c.gaugeDesc
+}
+
+// Collect impl.
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, e := range c.current() {
+		for counter, v := range e.counts() {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(v), e.name, counter)
+		}
+		if e.gauges == nil {
+			continue
+		}
+		for gauge, v := range e.gauges() {
+			ch <- prometheus.MustNewConstMetric(c.gaugeDesc, prometheus.GaugeValue, float64(v), e.name, gauge)
+		}
+	}
+}
@@ -9,10 +9,13 @@
 package monitor
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"config"
 
+	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -184,11 +187,122 @@ func TestPeerNum(t *testing.T) {
 	assert.EqualValues(t, 1, v)
 }
 
+func TestQueryLatencyObserve(t *testing.T) {
+	QueryLatencyObserve("Select", "db1.t1", time.Millisecond)
+	QueryLatencyObserve("Select", "db1.t1", time.Second)
+
+	var m dto.Metric
+	h, _ := queryLatency.GetMetricWithLabelValues("Select", "db1.t1")
+	err := h.(prometheus.Metric).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetHistogram().GetSampleCount())
+
+	RowsReturnedAdd("Select", 10)
+	c, _ := rowsReturnedCounter.GetMetricWithLabelValues("Select")
+	err = c.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 10, m.GetCounter().GetValue())
+}
+
+func TestBackendRequestObserve(t *testing.T) {
+	address := "192.168.0.3:3306"
+	BackendRequestObserve(address, time.Now(), nil)
+	BackendRequestObserve(address, time.Now(), errors.New("mock.error"))
+
+	var m dto.Metric
+	h, _ := backendLatency.GetMetricWithLabelValues(address)
+	err := h.(prometheus.Metric).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, m.GetHistogram().GetSampleCount())
+
+	c, _ := backendErrorCounter.GetMetricWithLabelValues(address)
+	err = c.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetCounter().GetValue())
+
+	RowsScannedAdd(address, 5)
+	c, _ = rowsScannedCounter.GetMetricWithLabelValues(address)
+	err = c.Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 5, m.GetCounter().GetValue())
+}
+
+func TestTwoPCPhaseObserve(t *testing.T) {
+	TwoPCPhaseObserve("prepare", time.Now())
+
+	var m dto.Metric
+	h, _ := twopcPhaseLatency.GetMetricWithLabelValues("prepare")
+	err := h.(prometheus.Metric).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+}
+
+func TestPoolWaitObserve(t *testing.T) {
+	PoolWaitObserve("node1@192.168.0.4:3306", time.Now())
+
+	var m dto.Metric
+	h, _ := poolWaitLatency.GetMetricWithLabelValues("node1@192.168.0.4:3306")
+	err := h.(prometheus.Metric).Write(&m)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, m.GetHistogram().GetSampleCount())
+	UnregisterPool(RegisterPool("node1@192.168.0.4:3306", func() map[string]int64 { return nil }, nil))
+}
+
+func TestPoolCollector(t *testing.T) {
+	id := RegisterPool("node1@192.168.0.4:3306", func() map[string]int64 {
+		return map[string]int64{"pool.hit": 3, "pool.miss": 1}
+	}, func() map[string]int64 {
+		return map[string]int64{"idle": 2}
+	})
+
+	collect := func() map[string]float64 {
+		ch := make(chan prometheus.Metric, 16)
+		pools.Collect(ch)
+		close(ch)
+		got := make(map[string]float64)
+		for metric := range ch {
+			var m dto.Metric
+			assert.Nil(t, metric.Write(&m))
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if m.GetGauge() != nil {
+				got[labels["pool"]+"/"+labels["gauge"]] = m.GetGauge().GetValue()
+				continue
+			}
+			got[labels["pool"]+"/"+labels["counter"]] = m.GetCounter().GetValue()
+		}
+		return got
+	}
+	want := map[string]float64{
+		"node1@192.168.0.4:3306/pool.hit":  3,
+		"node1@192.168.0.4:3306/pool.miss": 1,
+		"node1@192.168.0.4:3306/idle":      2,
+	}
+	assert.Equal(t, want, collect())
+
+	// The replacement with the same name wins, closing the old one keeps it.
+	id2 := RegisterPool("node1@192.168.0.4:3306", func() map[string]int64 {
+		return map[string]int64{"pool.hit": 5}
+	}, nil)
+	assert.Equal(t, map[string]float64{"node1@192.168.0.4:3306/pool.hit": 5}, collect())
+	UnregisterPool(id)
+	assert.Equal(t, map[string]float64{"node1@192.168.0.4:3306/pool.hit": 5}, collect())
+	UnregisterPool(id)
+	assert.Equal(t, map[string]float64{"node1@192.168.0.4:3306/pool.hit": 5}, collect())
+
+	UnregisterPool(id2)
+	assert.Equal(t, map[string]float64{}, collect())
+}
+
 func TestMonitorStart(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
 	var conf config.Config
 	conf.Proxy = config.DefaultProxyConfig()
 	conf.Audit = config.DefaultAuditConfig()
+	conf.SlowLog = config.DefaultSlowLogConfig()
+	conf.Trace = config.DefaultTraceConfig()
 	conf.Router = config.DefaultRouterConfig()
 	conf.Log = config.DefaultLogConfig()
 	conf.Monitor = config.DefaultMonitorConfig()
@@ -33,31 +33,37 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A1 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A2 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend2",
 					Range:   "[32-64)",
+					Tables:  []string{"sbtest.A2"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A3 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend3",
 					Range:   "[64-96)",
+					Tables:  []string{"sbtest.A3"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A4 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend4",
 					Range:   "[96-256)",
+					Tables:  []string{"sbtest.A4"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A5 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend5",
 					Range:   "[256-512)",
+					Tables:  []string{"sbtest.A5"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A6 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 		{
@@ -68,31 +74,37 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select id, sum(a) as A from sbtest.A1 as A group by id having id > 1000 order by id asc",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A2 as A group by id having id > 1000 order by id asc",
 					Backend: "backend2",
 					Range:   "[32-64)",
+					Tables:  []string{"sbtest.A2"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A3 as A group by id having id > 1000 order by id asc",
 					Backend: "backend3",
 					Range:   "[64-96)",
+					Tables:  []string{"sbtest.A3"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A4 as A group by id having id > 1000 order by id asc",
 					Backend: "backend4",
 					Range:   "[96-256)",
+					Tables:  []string{"sbtest.A4"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A5 as A group by id having id > 1000 order by id asc",
 					Backend: "backend5",
 					Range:   "[256-512)",
+					Tables:  []string{"sbtest.A5"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A6 as A group by id having id > 1000 order by id asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 		{
@@ -103,6 +115,7 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select id, a from sbtest.A6 as A where a > 1 and id = 1",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 		{
@@ -113,11 +126,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select B.id from sbtest.B1 as B where B.id = 1 order by B.id asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -128,16 +143,19 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.id from sbtest.A6 as A where A.id = 1",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select 1 from sbtest.B0 as B",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0"},
 				},
 				{
 					Query:   "select 1 from sbtest.B1 as B",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -148,11 +166,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.id, A.a = 1 as tmpc_0 from sbtest.A6 as A where A.id = 1 order by A.id asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select B.id from sbtest.B1 as B where B.id = 1 and 1 = 1 and B.b = 2 order by B.id asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -163,11 +183,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.id, A.str from sbtest.A6 as A where A.id = 1",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select 1 from sbtest.B1 as B where B.id = 1 and concat(:A_str, B.str) = 'golang' and :A_id = B.id",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -178,16 +200,19 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.id, A.a + 1 as tmpc_0 from sbtest.A6 as A where A.id = 1 order by tmpc_0 asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select B.a from sbtest.B0 as B order by B.a asc",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0"},
 				},
 				{
 					Query:   "select B.a from sbtest.B1 as B order by B.a asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -198,11 +223,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select B.id as a from sbtest.B0 as B group by a order by a asc",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0"},
 				},
 				{
 					Query:   "select B.id as a from sbtest.B1 as B group by a order by a asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -213,11 +240,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select id as tmp, b, id as `sum(id)`, id as `count(id)` from sbtest.B0 as B group by b order by b asc",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0"},
 				},
 				{
 					Query:   "select id as tmp, b, id as `sum(id)`, id as `count(id)` from sbtest.B1 as B group by b order by b asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -228,11 +257,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.a as `sum(A.a)`, A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select B.b, B.id from sbtest.B1 as B where B.id = 1 order by B.id asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -243,6 +274,7 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select 1, sum(a), avg(a), a, b from sbtest.S where id > 1 group by a, b order by a desc limit 100, 10",
 					Backend: "backend1",
 					Range:   "",
+					Tables:  []string{"sbtest.S"},
 				}},
 		},
 		{
@@ -253,6 +285,7 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select sum(G.a), S.b from sbtest.G join sbtest.S on G.id = S.id where G.id > 1 group by S.b",
 					Backend: "backend1",
 					Range:   "",
+					Tables:  []string{"sbtest.G", "sbtest.S"},
 				}},
 		},
 		{
@@ -263,6 +296,7 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select sum(A.a), S.b from sbtest.A1 as A join sbtest.S on A.id = S.id where A.id = 0 and S.id = 0 group by S.b",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1", "sbtest.S"},
 				}},
 		},
 
@@ -274,11 +308,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.a as `sum(A.a)`, A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select S.b, S.id from sbtest.S where S.id = 1 order by S.id asc",
 					Backend: "backend1",
 					Range:   "",
+					Tables:  []string{"sbtest.S"},
 				}},
 		},
 
@@ -290,6 +326,7 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select * from sbtest.A6 as A where id in (1, 2)",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 		{
@@ -300,11 +337,13 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select * from sbtest.B0 as B where (B.id = 0 and B.name = 'a' or B.id in (1, 2))",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0"},
 				},
 				{
 					Query:   "select * from sbtest.B1 as B where (B.id = 0 and B.name = 'a' or B.id in (1, 2))",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -315,21 +354,25 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.id from sbtest.A1 as A where A.id in (0, 1, 2) order by A.id asc",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1"},
 				},
 				{
 					Query:   "select A.id from sbtest.A6 as A where A.id in (0, 1, 2) order by A.id asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select B.id from sbtest.B0 as B where B.id in (0, 1, 2) order by B.id asc",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0"},
 				},
 				{
 					Query:   "select B.id from sbtest.B1 as B where B.id in (0, 1, 2) order by B.id asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 		{
@@ -340,16 +383,19 @@ func TestProcessSelect(t *testing.T) {
 					Query:   "select A.a, A.id from sbtest.A6 as A where A.id = 1",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				},
 				{
 					Query:   "select B.a from sbtest.B1 as B where B.id = 1 and :A_id = B.id",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				},
 				{
 					Query:   "select S.a from sbtest.S where :A_a + :B_a > S.a",
 					Backend: "backend1",
 					Range:   "",
+					Tables:  []string{"sbtest.S"},
 				}},
 		},
 	}
@@ -394,31 +440,37 @@ func TestSelectDatabaseIsNull(t *testing.T) {
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A1 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A2 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend2",
 					Range:   "[32-64)",
+					Tables:  []string{"sbtest.A2"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A3 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend3",
 					Range:   "[64-96)",
+					Tables:  []string{"sbtest.A3"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A4 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend4",
 					Range:   "[96-256)",
+					Tables:  []string{"sbtest.A4"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A5 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend5",
 					Range:   "[256-512)",
+					Tables:  []string{"sbtest.A5"},
 				},
 				{
 					Query:   "select 1, sum(a), sum(a) as `avg(a)`, count(a), a, b from sbtest.A6 as A where id > 1 group by a, b order by a desc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 		{
@@ -428,31 +480,37 @@ func TestSelectDatabaseIsNull(t *testing.T) {
 					Query:   "select id, sum(a) as A from sbtest.A1 as A group by id having id > 1000 order by id asc",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A2 as A group by id having id > 1000 order by id asc",
 					Backend: "backend2",
 					Range:   "[32-64)",
+					Tables:  []string{"sbtest.A2"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A3 as A group by id having id > 1000 order by id asc",
 					Backend: "backend3",
 					Range:   "[64-96)",
+					Tables:  []string{"sbtest.A3"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A4 as A group by id having id > 1000 order by id asc",
 					Backend: "backend4",
 					Range:   "[96-256)",
+					Tables:  []string{"sbtest.A4"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A5 as A group by id having id > 1000 order by id asc",
 					Backend: "backend5",
 					Range:   "[256-512)",
+					Tables:  []string{"sbtest.A5"},
 				},
 				{
 					Query:   "select id, sum(a) as A from sbtest.A6 as A group by id having id > 1000 order by id asc",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 	}
@@ -652,31 +710,37 @@ func TestSelectPlanAs(t *testing.T) {
 					Query:   "select a1.id from sbtest.A1 as a1 where a1.id > 1000",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1"},
 				},
 				{
 					Query:   "select a1.id from sbtest.A2 as a1 where a1.id > 1000",
 					Backend: "backend2",
 					Range:   "[32-64)",
+					Tables:  []string{"sbtest.A2"},
 				},
 				{
 					Query:   "select a1.id from sbtest.A3 as a1 where a1.id > 1000",
 					Backend: "backend3",
 					Range:   "[64-96)",
+					Tables:  []string{"sbtest.A3"},
 				},
 				{
 					Query:   "select a1.id from sbtest.A4 as a1 where a1.id > 1000",
 					Backend: "backend4",
 					Range:   "[96-256)",
+					Tables:  []string{"sbtest.A4"},
 				},
 				{
 					Query:   "select a1.id from sbtest.A5 as a1 where a1.id > 1000",
 					Backend: "backend5",
 					Range:   "[256-512)",
+					Tables:  []string{"sbtest.A5"},
 				},
 				{
 					Query:   "select a1.id from sbtest.A6 as a1 where a1.id > 1000",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 		{
@@ -686,31 +750,37 @@ func TestSelectPlanAs(t *testing.T) {
 					Query:   "select A.id from sbtest.A1 as A where A.id > 1000",
 					Backend: "backend1",
 					Range:   "[0-32)",
+					Tables:  []string{"sbtest.A1"},
 				},
 				{
 					Query:   "select A.id from sbtest.A2 as A where A.id > 1000",
 					Backend: "backend2",
 					Range:   "[32-64)",
+					Tables:  []string{"sbtest.A2"},
 				},
 				{
 					Query:   "select A.id from sbtest.A3 as A where A.id > 1000",
 					Backend: "backend3",
 					Range:   "[64-96)",
+					Tables:  []string{"sbtest.A3"},
 				},
 				{
 					Query:   "select A.id from sbtest.A4 as A where A.id > 1000",
 					Backend: "backend4",
 					Range:   "[96-256)",
+					Tables:  []string{"sbtest.A4"},
 				},
 				{
 					Query:   "select A.id from sbtest.A5 as A where A.id > 1000",
 					Backend: "backend5",
 					Range:   "[256-512)",
+					Tables:  []string{"sbtest.A5"},
 				},
 				{
 					Query:   "select A.id from sbtest.A6 as A where A.id > 1000",
 					Backend: "backend6",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.A6"},
 				}},
 		},
 	}
@@ -827,6 +897,7 @@ func TestSelectPlanJoin(t *testing.T) {
 					Query:   "select G.a, G.b from sbtest.G join sbtest.B1 as B on G.a = B.a where B.id = 1",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1", "sbtest.G"},
 				}},
 		},
 		{
@@ -836,6 +907,7 @@ func TestSelectPlanJoin(t *testing.T) {
 					Query:   "select G.a, G.b from sbtest.G join sbtest.B1 as B on G.a = B.a join sbtest.G1 on G1.a = B.a where B.id = 1",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1", "sbtest.G", "sbtest.G1"},
 				}},
 		},
 		{
@@ -845,6 +917,7 @@ func TestSelectPlanJoin(t *testing.T) {
 					Query:   "select G.a, G.b from sbtest.G, sbtest.B1 as B where B.id = 1",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1", "sbtest.G"},
 				}},
 		},
 		{
@@ -854,11 +927,13 @@ func TestSelectPlanJoin(t *testing.T) {
 					Query:   "select G.a, B.a from sbtest.G join sbtest.B0 as B on G.a = B.a order by B.a asc",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0", "sbtest.G"},
 				},
 				{
 					Query:   "select G.a, B.a from sbtest.G join sbtest.B1 as B on G.a = B.a order by B.a asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1", "sbtest.G"},
 				}},
 		},
 		{
@@ -868,11 +943,13 @@ func TestSelectPlanJoin(t *testing.T) {
 					Query:   "select * from sbtest.B0 as B, sbtest.B0 as A where A.id = B.id and A.a = B.a",
 					Backend: "backend1",
 					Range:   "[0-512)",
+					Tables:  []string{"sbtest.B0"},
 				},
 				{
 					Query:   "select * from sbtest.B1 as B, sbtest.B1 as A where A.id = B.id and A.a = B.a",
 					Backend: "backend2",
 					Range:   "[512-4096)",
+					Tables:  []string{"sbtest.B1"},
 				}},
 		},
 	}
@@ -953,6 +1030,7 @@ func TestProcessUnion(t *testing.T) {
 				Query:   "select a, b from sbtest.G union select a, b from sbtest.A6 as A where id = 1 order by a asc limit 10",
 				Backend: "backend6",
 				Range:   "[512-4096)",
+				Tables:  []string{"sbtest.A6", "sbtest.G"},
 			}},
 		},
 		{
@@ -961,10 +1039,12 @@ func TestProcessUnion(t *testing.T) {
 				Query:   "select a, b from sbtest.A6 as A where id = 1",
 				Backend: "backend6",
 				Range:   "[512-4096)",
+				Tables:  []string{"sbtest.A6"},
 			}, {
 				Query:   "select a, b from sbtest.B0 as B where id = 0",
 				Backend: "backend1",
 				Range:   "[0-512)",
+				Tables:  []string{"sbtest.B0"},
 			}},
 		},
 		{
@@ -973,6 +1053,7 @@ func TestProcessUnion(t *testing.T) {
 				Query:   "select a, b from sbtest.S union (select a, b from sbtest.G order by a asc) limit 10",
 				Backend: "backend1",
 				Range:   "",
+				Tables:  []string{"sbtest.G", "sbtest.S"},
 			}},
 		},
 		{
@@ -981,14 +1062,17 @@ func TestProcessUnion(t *testing.T) {
 				Query:   "select a, b from sbtest.S",
 				Backend: "backend1",
 				Range:   "",
+				Tables:  []string{"sbtest.S"},
 			}, {
 				Query:   "select a, b from sbtest.A6 as A where id = 1",
 				Backend: "backend6",
 				Range:   "[512-4096)",
+				Tables:  []string{"sbtest.A6"},
 			}, {
 				Query:   "select a, b from sbtest.B0 as B where id = 0",
 				Backend: "backend1",
 				Range:   "[0-512)",
+				Tables:  []string{"sbtest.B0"},
 			}},
 		},
 		{
@@ -997,6 +1081,7 @@ func TestProcessUnion(t *testing.T) {
 				Query:   "select 1 from dual union select a from sbtest.A6 as A where id = 1 order by 1 asc limit 10",
 				Backend: "backend6",
 				Range:   "[512-4096)",
+				Tables:  []string{"sbtest.A6"},
 			}},
 		},
 		{
@@ -1005,14 +1090,17 @@ func TestProcessUnion(t *testing.T) {
 				Query:   "select a as tmp, b from sbtest.B0 as B",
 				Backend: "backend1",
 				Range:   "[0-512)",
+				Tables:  []string{"sbtest.B0"},
 			}, {
 				Query:   "select a as tmp, b from sbtest.B1 as B",
 				Backend: "backend2",
 				Range:   "[512-4096)",
+				Tables:  []string{"sbtest.B1"},
 			}, {
 				Query:   "select a, b from sbtest.S union select 1, 'a' from dual",
 				Backend: "backend1",
 				Range:   "",
+				Tables:  []string{"sbtest.S"},
 			}},
 		},
 	}
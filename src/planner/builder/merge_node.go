@@ -11,6 +11,7 @@ package builder
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"time"
 
 	"router"
@@ -309,7 +310,17 @@ func (m *MergeNode) buildQuery(root PlanNode) {
 	for i := 0; i < m.routeLen; i++ {
 		// Rewrite the shard table's name.
 		backend := m.backend
+		seen := make(map[string]bool)
+		var tables []string
 		for _, tbInfo := range m.referTables {
+			table := tbInfo.database + "." + tbInfo.tableName
+			if tbInfo.shardKey != "" {
+				table = tbInfo.database + "." + tbInfo.Segments[i].Table
+			}
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
 			if tbInfo.shardKey == "" {
 				continue
 			}
@@ -327,10 +338,12 @@ func (m *MergeNode) buildQuery(root PlanNode) {
 		pq := buf.ParsedQuery()
 		m.ParsedQuerys = append(m.ParsedQuerys, pq)
 
+		sort.Strings(tables)
 		tuple := xcontext.QueryTuple{
 			Query:   pq.Query,
 			Backend: backend,
 			Range:   Range,
+			Tables:  tables,
 		}
 		m.Querys = append(m.Querys, tuple)
 	}
@@ -36,6 +36,20 @@ type PlanNode interface {
 	Order() int
 }
 
+// IsCacheable returns false if the plan node picks a random backend for the global tables,
+// such node can't be reused by the other querys.
+func IsCacheable(node PlanNode) bool {
+	switch node := node.(type) {
+	case *MergeNode:
+		return node.nonGlobalCnt > 0
+	case *JoinNode:
+		return IsCacheable(node.Left) && IsCacheable(node.Right)
+	case *UnionNode:
+		return IsCacheable(node.Left) && IsCacheable(node.Right)
+	}
+	return false
+}
+
 // findLCA get the two plannode's lowest common ancestors node.
 func findLCA(h, p1, p2 PlanNode) PlanNode {
 	if p1 == h || p2 == h {
@@ -73,38 +73,77 @@ func (p *DDLPlan) checkUnsupportedOperations(database, table string) error {
 	if err != nil {
 		return err
 	}
+	if node.Action != sqlparser.AlterMultiStr {
+		return checkAlterSpec(node, shardKey)
+	}
+	for _, spec := range node.AlterSpecs {
+		if err := checkAlterSpec(spec, shardKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAlterSpec used to check the alter specification on the table with the shard key.
+func checkAlterSpec(node *sqlparser.DDL, shardKey string) error {
+	// The foreign key can't be checked across the backends.
+	if node.Action == sqlparser.AlterAddForeignKeyStr {
+		return errors.New("unsupported: foreign.key")
+	}
+
 	// Unsupported operations check when shardtype is HASH/LIST.
 	if shardKey != "" {
+		constraintErr := fmt.Sprintf("The unique/primary constraint should be only defined on the sharding key column[%s]", shardKey)
 		switch node.Action {
 		case sqlparser.AlterDropColumnStr:
-			if shardKey == node.DropColumnName {
+			if strings.EqualFold(shardKey, node.DropColumnName) {
 				return errors.New("unsupported: cannot.drop.the.column.on.shard.key")
 			}
 		case sqlparser.AlterModifyColumnStr:
-			if shardKey == node.ModifyColumnDef.Name.String() {
+			if strings.EqualFold(shardKey, node.ModifyColumnDef.Name.String()) {
 				return errors.New("unsupported: cannot.modify.the.column.on.shard.key")
 			}
 			// constraint check in column definition
 			if node.ModifyColumnDef.Type.PrimaryKeyOpt == sqlparser.ColKeyPrimary ||
 				node.ModifyColumnDef.Type.UniqueKeyOpt == sqlparser.ColKeyUniqueKey {
-				err := fmt.Sprintf("The unique/primary constraint should be only defined on the sharding key column[%s]", shardKey)
-				return errors.New(err)
+				return errors.New(constraintErr)
 			}
-		case sqlparser.AlterAddColumnStr:
+		case sqlparser.AlterChangeColumnStr:
+			if strings.EqualFold(shardKey, node.OldColumnName) {
+				return errors.New("unsupported: cannot.change.the.column.on.shard.key")
+			}
+			// constraint check in column definition
+			if node.ModifyColumnDef.Type.PrimaryKeyOpt == sqlparser.ColKeyPrimary ||
+				node.ModifyColumnDef.Type.UniqueKeyOpt == sqlparser.ColKeyUniqueKey {
+				return errors.New(constraintErr)
+			}
+		case sqlparser.AlterRenameColumnStr:
+			if strings.EqualFold(shardKey, node.OldColumnName) {
+				return errors.New("unsupported: cannot.rename.the.column.on.shard.key")
+			}
+		case sqlparser.AlterAddColumnStr, sqlparser.AlterAddIndexStr:
 			// constraint check in column definition
 			for _, col := range node.TableSpec.Columns {
 				if col.Type.PrimaryKeyOpt == sqlparser.ColKeyPrimary ||
 					col.Type.UniqueKeyOpt == sqlparser.ColKeyUniqueKey {
-					err := fmt.Sprintf("The unique/primary constraint should be only defined on the sharding key column[%s]", shardKey)
-					return errors.New(err)
+					return errors.New(constraintErr)
 				}
 			}
-			// constraint check in index definition
+			// constraint check in index definition, the unique/primary index must include the shard key.
 			for _, index := range node.TableSpec.Indexes {
 				info := index.Info
-				if info.Unique || info.Primary {
-					err := fmt.Sprintf("The unique/primary constraint should be only defined on the sharding key column[%s]", shardKey)
-					return errors.New(err)
+				if !info.Unique && !info.Primary {
+					continue
+				}
+				hasShardKey := false
+				for _, col := range index.Columns {
+					if strings.EqualFold(col.Column.String(), shardKey) {
+						hasShardKey = true
+						break
+					}
+				}
+				if !hasShardKey {
+					return errors.New(constraintErr)
 				}
 			}
 		}
@@ -145,6 +184,7 @@ func (p *DDLPlan) commonImpl() error {
 			Query:   newQuery,
 			Backend: segment.Backend,
 			Range:   segment.Range.String(),
+			Tables:  []string{database + "." + newTable},
 		}
 		p.Querys = append(p.Querys, tuple)
 	}
@@ -183,6 +223,7 @@ func (p *DDLPlan) dropTblImpl() error {
 			Query:   newQuery,
 			Backend: segment.Backend,
 			Range:   segment.Range.String(),
+			Tables:  []string{database + "." + newTable},
 		}
 		p.Querys = append(p.Querys, tuple)
 	}
@@ -245,6 +286,7 @@ func (p *DDLPlan) renameImpl() error {
 			Query:   newQuery,
 			Backend: segment.Backend,
 			Range:   segment.Range.String(),
+			Tables:  []string{fromDatabase + "." + newFromTable, fromDatabase + "." + newToTable},
 		}
 		p.Querys = append(p.Querys, tuple)
 	}
@@ -155,6 +155,18 @@ func TestDDLAlterError(t *testing.T) {
 		"The unique/primary constraint should be only defined on the sharding key column[id]",
 		"The unique/primary constraint should be only defined on the sharding key column[id]",
 		"The unique/primary constraint should be only defined on the sharding key column[id]",
+		"unsupported: cannot.change.the.column.on.shard.key",
+		"unsupported: cannot.rename.the.column.on.shard.key",
+		"The unique/primary constraint should be only defined on the sharding key column[id]",
+		"The unique/primary constraint should be only defined on the sharding key column[id]",
+		"The unique/primary constraint should be only defined on the sharding key column[id]",
+		"unsupported: foreign.key",
+		"unsupported: foreign.key",
+		"unsupported: cannot.drop.the.column.on.shard.key",
+		"unsupported: cannot.drop.the.column.on.shard.key",
+		"unsupported: cannot.modify.the.column.on.shard.key",
+		"unsupported: cannot.change.the.column.on.shard.key",
+		"unsupported: cannot.rename.the.column.on.shard.key",
 	}
 
 	// For now we doesn`t support unique in add column, e.g.:
@@ -172,6 +184,18 @@ func TestDDLAlterError(t *testing.T) {
 		"alter table A add column(c6 int unique key, c7 varchar(100))",
 		"alter table A add column(c8 int, c9 varchar(100), primary key(c8, c9))",
 		"alter table A add column(c10 int, c11 varchar(100), primary key(c14))",
+		"alter table A change column id id2 int",
+		"alter table A rename column id to id2",
+		"alter table A change b c int unique",
+		"alter table A add unique key uk_b(b)",
+		"alter table A add constraint pk primary key(b)",
+		"alter table A add foreign key(b) references B(id)",
+		"alter table A add constraint fk_b foreign key(b) references B(id)",
+		"alter table A add column c int, drop column id",
+		"alter table A drop column ID",
+		"alter table A modify column Id bigint",
+		"alter table A change column ID id2 int",
+		"alter table A rename column ID to id2",
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -202,6 +226,48 @@ func TestDDLAlterError(t *testing.T) {
 	}
 }
 
+func TestDDLPlanAlterSpecs(t *testing.T) {
+	results := []string{
+		"alter table sbtest.A0 change column `b` `c` bigint",
+		"alter table sbtest.A0 rename column `b` to `c`",
+		"alter table sbtest.A0 add unique key `uk_id_b` (`id`, `b`)",
+		"alter table sbtest.A0 add primary key (`id`)",
+		"alter table sbtest.A0 add index `idx_b` (`b`)",
+		"drop index idx_b on sbtest.A0",
+		"alter table sbtest.A0 add column (\n\t`c` int\n), add index `idx_c` (`c`), drop column `b`",
+		"alter table sbtest.A0 add unique key `uk_id_b` (`ID`, `b`)",
+	}
+
+	querys := []string{
+		"alter table A change b c bigint",
+		"alter table A rename column b to c",
+		"alter table A add unique key uk_id_b(id, b)",
+		"alter table A add constraint pk primary key(id)",
+		"alter table A add index idx_b(b)",
+		"alter table A drop index idx_b",
+		"alter table A add column c int, add index idx_c(c), drop column b",
+		"alter table A add unique key uk_id_b(ID, b)",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDDLPlan(log, database, query, node.(*sqlparser.DDL), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		want := results[i]
+		got := plan.Querys[0].Query
+		assert.Equal(t, want, got)
+	}
+}
+
 func TestDDLPlanCreateIndexWithTableNameIssue10(t *testing.T) {
 	results := []string{
 		"{\n\t\"RawQuery\": \"create index idx_A_id on A(a)\",\n\t\"Partitions\": [\n\t\t{\n\t\t\t\"Query\": \"create index idx_A_id on sbtest.A0\",\n\t\t\t\"Backend\": \"backend0\",\n\t\t\t\"Range\": \"[0-2)\"\n\t\t},\n\t\t{\n\t\t\t\"Query\": \"create index idx_A_id on sbtest.A2\",\n\t\t\t\"Backend\": \"backend2\",\n\t\t\t\"Range\": \"[2-4)\"\n\t\t},\n\t\t{\n\t\t\t\"Query\": \"create index idx_A_id on sbtest.A4\",\n\t\t\t\"Backend\": \"backend4\",\n\t\t\t\"Range\": \"[4-8)\"\n\t\t},\n\t\t{\n\t\t\t\"Query\": \"create index idx_A_id on sbtest.A8\",\n\t\t\t\"Backend\": \"backend8\",\n\t\t\t\"Range\": \"[8-4096)\"\n\t\t}\n\t]\n}",
@@ -49,6 +49,9 @@ type DeletePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// ParsedQuerys are the querys with the bind locations of the parameterized literals.
+	ParsedQuerys []*sqlparser.ParsedQuery
 }
 
 // NewDeletePlan used to create DeletePlan
@@ -107,12 +110,15 @@ func (p *DeletePlan) Build() error {
 	for _, segment := range segments {
 		buf := sqlparser.NewTrackedBuffer(nil)
 		buf.Myprintf("delete %vfrom %s.%s%v%v%v", node.Comments, database, segment.Table, node.Where, node.OrderBy, node.Limit)
+		parsed := buf.ParsedQuery()
 		tuple := xcontext.QueryTuple{
-			Query:   buf.String(),
+			Query:   parsed.Query,
 			Backend: segment.Backend,
 			Range:   segment.Range.String(),
+			Tables:  []string{database + "." + segment.Table},
 		}
 		p.Querys = append(p.Querys, tuple)
+		p.ParsedQuerys = append(p.ParsedQuerys, parsed)
 	}
 	return nil
 }
@@ -99,6 +99,7 @@ func (p *InsertPlan) Build() error {
 				Query:   buf.String(),
 				Backend: segment.Backend,
 				Range:   segment.Range.String(),
+				Tables:  []string{database + "." + segment.Table},
 			}
 			p.Querys = append(p.Querys, tuple)
 		}
@@ -171,6 +172,7 @@ func (p *InsertPlan) Build() error {
 			Query:   buf.String(),
 			Backend: v.backend,
 			Range:   v.rangi,
+			Tables:  []string{database + "." + rewritten},
 		}
 		p.Querys = append(p.Querys, tuple)
 	}
@@ -88,6 +88,7 @@ func (p *OthersPlan) Build() error {
 				Query:   p.RawQuery,
 				Backend: segment.Backend,
 				Range:   segment.Segment,
+				Tables:  []string{database + "." + segment.Table},
 			}
 			p.Querys = append(p.Querys, tuple)
 		} else {
@@ -99,6 +100,7 @@ func (p *OthersPlan) Build() error {
 					Query:   buf.String(),
 					Backend: segment.Backend,
 					Range:   segment.Segment,
+					Tables:  []string{database + "." + segment.Table},
 				}
 				p.Querys = append(p.Querys, tuple)
 			}
@@ -8,6 +8,10 @@
 
 package planner
 
+import (
+	"planner/builder"
+)
+
 // Plan interface.
 type Plan interface {
 	Build() error
@@ -51,6 +55,30 @@ func (pt *PlanTree) Plans() []Plan {
 	return pt.children
 }
 
+// Cacheable returns true if the plans can be reused by the same query.
+// The insert plan is excluded since the auto-increment values are filled in the query.
+func (pt *PlanTree) Cacheable() bool {
+	if len(pt.children) == 0 {
+		return false
+	}
+	for _, plan := range pt.children {
+		switch plan := plan.(type) {
+		case *SelectPlan:
+			if !builder.IsCacheable(plan.Root) {
+				return false
+			}
+		case *UnionPlan:
+			if !builder.IsCacheable(plan.Root) {
+				return false
+			}
+		case *UpdatePlan, *DeletePlan:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // Size used to measure the memory usage for this plantree.
 func (pt *PlanTree) Size() int {
 	return pt.size
@@ -76,3 +76,52 @@ func TestPlannerError(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestPlanTreeCacheable(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableMConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+
+	tcases := []struct {
+		query     string
+		cacheable bool
+	}{
+		{"select * from A where id=1", true},
+		{"select * from A join G on A.id=G.id", true},
+		{"select * from G where id=1", false},
+		{"select * from G union select * from A", false},
+		{"update A set b=1 where id=1", true},
+		{"delete from A where id=1", true},
+		{"insert into A(id, b) values(1, 1)", false},
+	}
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+
+		var plan Plan
+		switch node := node.(type) {
+		case *sqlparser.Select:
+			plan = NewSelectPlan(log, database, tcase.query, node, route)
+		case *sqlparser.Union:
+			plan = NewUnionPlan(log, database, tcase.query, node, route)
+		case *sqlparser.Update:
+			plan = NewUpdatePlan(log, database, tcase.query, node, route)
+		case *sqlparser.Delete:
+			plan = NewDeletePlan(log, database, tcase.query, node, route)
+		case *sqlparser.Insert:
+			plan = NewInsertPlan(log, database, tcase.query, node, route)
+		}
+		planTree := NewPlanTree()
+		err = planTree.Add(plan)
+		assert.Nil(t, err)
+		err = planTree.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.cacheable, planTree.Cacheable(), tcase.query)
+	}
+	assert.False(t, NewPlanTree().Cacheable())
+}
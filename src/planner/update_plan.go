@@ -49,6 +49,9 @@ type UpdatePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// ParsedQuerys are the querys with the bind locations of the parameterized literals.
+	ParsedQuerys []*sqlparser.ParsedQuery
 }
 
 // NewUpdatePlan used to create UpdatePlan
@@ -112,12 +115,15 @@ func (p *UpdatePlan) Build() error {
 	for _, segment := range segments {
 		buf := sqlparser.NewTrackedBuffer(nil)
 		buf.Myprintf("update %v%s.%s set %v%v%v%v", node.Comments, database, segment.Table, node.Exprs, node.Where, node.OrderBy, node.Limit)
+		parsed := buf.ParsedQuery()
 		tuple := xcontext.QueryTuple{
-			Query:   buf.String(),
+			Query:   parsed.Query,
 			Backend: segment.Backend,
 			Range:   segment.Range.String(),
+			Tables:  []string{database + "." + segment.Table},
 		}
 		p.Querys = append(p.Querys, tuple)
+		p.ParsedQuerys = append(p.ParsedQuerys, parsed)
 	}
 	return nil
 }
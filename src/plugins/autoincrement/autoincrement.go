@@ -73,6 +73,16 @@ func (autoinc *AutoIncrement) Process(database string, ins *sqlparser.Insert) er
 	return nil
 }
 
+// Reserve -- reserves n auto-increment values(thread-safe).
+// Returns the base seq, the values are base+1...base+n.
+func (autoinc *AutoIncrement) Reserve(n uint64) uint64 {
+	autoinc.mu.Lock()
+	defer autoinc.mu.Unlock()
+	seq := autoinc.seq
+	autoinc.seq += n
+	return seq
+}
+
 // Close -- close the plugin.
 func (autoinc *AutoIncrement) Close() error {
 	return nil
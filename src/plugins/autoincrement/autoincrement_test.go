@@ -178,3 +178,22 @@ func TestPluginAutoIncrement(t *testing.T) {
 		log.Debug("%v", buf.String())
 	}
 }
+
+func TestPluginAutoIncrementReserve(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+
+	// Router.
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	// Plugin.
+	autoplug := NewAutoIncrement(log, route)
+	err := autoplug.Init()
+	assert.Nil(t, err)
+	defer autoplug.Close()
+
+	base := autoplug.Reserve(10)
+	assert.Equal(t, base+10, autoplug.Reserve(5))
+	assert.Equal(t, base+15, autoplug.Reserve(0))
+	assert.Equal(t, base+15, autoplug.Reserve(1))
+}
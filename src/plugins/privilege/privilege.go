@@ -127,6 +127,13 @@ func (p *Privilege) CheckPrivilege(db string, user string, node sqlparser.Statem
 			return (userpriv.priv.superPriv || userpriv.priv.updatePriv || dbpriv.priv.updatePriv)
 		case *sqlparser.Delete:
 			return (userpriv.priv.superPriv || userpriv.priv.deletePriv || dbpriv.priv.deletePriv)
+		case *sqlparser.Load:
+			// LOAD DATA REPLACE deletes the duplicate rows.
+			insert := userpriv.priv.insertPriv || dbpriv.priv.insertPriv
+			if node.(*sqlparser.Load).Dup == sqlparser.LoadReplaceStr {
+				insert = insert && (userpriv.priv.deletePriv || dbpriv.priv.deletePriv)
+			}
+			return (userpriv.priv.superPriv || insert)
 		case *sqlparser.Show:
 			return (userpriv.priv.superPriv || userpriv.priv.showDBPriv || dbpriv.priv.showDBPriv)
 		case *sqlparser.DDL:
@@ -79,6 +79,14 @@ func TestLoadUserPrivilege(t *testing.T) {
 			err:  "",
 		},
 
+		{
+			name: "load.ok",
+			db:   "test",
+			user: "mock",
+			sql:  "load data local infile 't1.txt' replace into table t1",
+			err:  "",
+		},
+
 		{
 			name: "show.ok",
 			db:   "test",
@@ -175,6 +183,14 @@ func TestLoadUserPrivilegeDenied(t *testing.T) {
 			err:  "Access denied for user 'mock'@'%' to database 'test1' (errno 1045) (sqlstate 28000)",
 		},
 
+		{
+			name: "load.denied",
+			db:   "test",
+			user: "mock",
+			sql:  "load data local infile 't1.txt' into table test1.t1",
+			err:  "Access denied for user 'mock'@'%' to database 'test1' (errno 1045) (sqlstate 28000)",
+		},
+
 		{
 			name: "show.denied",
 			db:   "test",
@@ -9,9 +9,13 @@
 package proxy
 
 import (
-	"time"
+	"fmt"
+
+	"audit"
+	"xcontext"
 
 	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
@@ -24,21 +28,108 @@ const (
 	W
 )
 
-func (spanner *Spanner) auditLog(session *driver.Session, m mode, typ string, query string, qr *sqltypes.Result, status uint16) error {
+func (spanner *Spanner) auditLog(session *driver.Session, m mode, typ string, query string, node sqlparser.Statement, qr *sqltypes.Result, err error, status uint16) error {
 	adit := spanner.audit
-	user := session.User()
-	host := session.Addr()
-	connID := session.ID()
+	if !adit.LogEnabled(m == W) {
+		return nil
+	}
+	xid, tuples := spanner.sessions.QueryInfo(session)
+
+	db := session.Schema()
 	affected := uint64(0)
 	if qr != nil {
 		affected = qr.RowsAffected
 	}
-	now := time.Now().UTC()
+	event := &audit.Event{
+		// The query starts at the last query time of the session.
+		Start:       session.LastQueryTime().UTC(),
+		User:        session.User(),
+		UserHost:    session.Addr(),
+		DB:          db,
+		ThreadID:    session.ID(),
+		CommandType: typ,
+		Argument:    query,
+		Status:      status,
+		QueryRows:   affected,
+		XID:         xid,
+	}
+	if node != nil {
+		event.Tables = queryTables(node, db)
+	}
+	event.Backends, event.Shards = auditShards(tuples)
+	if err != nil {
+		event.Error = err.Error()
+	}
+
 	switch m {
 	case R:
-		adit.LogReadEvent(typ, user, host, connID, query, status, affected, now)
+		adit.LogReadEvent(event)
 	case W:
-		adit.LogWriteEvent(typ, user, host, connID, query, status, affected, now)
+		adit.LogWriteEvent(event)
 	}
 	return nil
 }
+
+// queryTables returns the distinct 'db.table' referenced by the node, the db is default to the session schema.
+func queryTables(node sqlparser.SQLNode, db string) []string {
+	var tables []string
+	seen := make(map[string]bool)
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case *sqlparser.ColName:
+			// The qualifier of the column is not a table reference.
+			return false, nil
+		case sqlparser.TableName:
+			// The 'dual' is the dummy table of the 'select 1'.
+			if node.IsEmpty() || (node.Qualifier.IsEmpty() && node.Name.String() == "dual") {
+				return false, nil
+			}
+			schema := db
+			if !node.Qualifier.IsEmpty() {
+				schema = node.Qualifier.String()
+			}
+			table := node.Name.String()
+			if schema != "" {
+				table = fmt.Sprintf("%s.%s", schema, table)
+			}
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+			return false, nil
+		}
+		return true, nil
+	}, node)
+	return tables
+}
+
+// recordQuerys returns true if the query tuples executed by the statement are consumed by
+// the audit or the query digests, the txn records them only if so.
+func (spanner *Spanner) recordQuerys() bool {
+	adit := spanner.audit
+	return adit.LogEnabled(true) || adit.LogEnabled(false) || spanner.digests.Enabled()
+}
+
+// auditShards returns the distinct backends and 'backend/db.subtable' the query tuples executed on.
+func auditShards(tuples []xcontext.QueryTuple) ([]string, []string) {
+	var backends, shards []string
+	seenBackends := make(map[string]bool)
+	seenShards := make(map[string]bool)
+	for _, tuple := range tuples {
+		if tuple.Backend == "" {
+			continue
+		}
+		if !seenBackends[tuple.Backend] {
+			seenBackends[tuple.Backend] = true
+			backends = append(backends, tuple.Backend)
+		}
+		for _, table := range tuple.Tables {
+			shard := fmt.Sprintf("%s/%s", tuple.Backend, table)
+			if !seenShards[shard] {
+				seenShards[shard] = true
+				shards = append(shards, shard)
+			}
+		}
+	}
+	return backends, shards
+}
@@ -0,0 +1,146 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyQueryTables(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"select a.id from t1 a join db2.t2 b on a.id=b.id where a.id in (select id from t1)", []string{"db1.t1", "db2.t2"}},
+		{"insert into t1(id) values(1)", []string{"db1.t1"}},
+		{"alter table t1 rename to db2.t2", []string{"db1.t1", "db2.t2"}},
+		{"select 1", nil},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, queryTables(node, "db1"), test.query)
+	}
+}
+
+func TestProxyAuditShards(t *testing.T) {
+	tuples := []xcontext.QueryTuple{
+		{Query: "insert into db1.t1_0000(id) values(1)", Backend: "backend1", Tables: []string{"db1.t1_0000"}},
+		{Query: "insert into db1.t1_0000(id) values(1)", Backend: "backend1", Tables: []string{"db1.t1_0000"}},
+		{Query: "select * from db1.t1_0001 join db1.t2", Backend: "backend2", Tables: []string{"db1.t1_0001", "db1.t2"}},
+		{Query: "xx", Backend: "backend2"},
+		{Query: "select 1", Backend: ""},
+	}
+	backends, shards := auditShards(tuples)
+	assert.Equal(t, []string{"backend1", "backend2"}, backends)
+	assert.Equal(t, []string{"backend1/db1.t1_0000", "backend2/db1.t1_0001", "backend2/db1.t2"}, shards)
+}
+
+func TestProxyAuditEvent(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	lines := make(chan string, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	conf := MockDefaultConfig()
+	conf.Audit.Mode = "W"
+	conf.Audit.Sinks = []*config.AuditSinkConfig{{Type: "tcp", Address: ln.Addr().String()}}
+	conf.Audit.Filters = []*config.AuditFilterConfig{{CommandTypes: []string{"INSERT", "DELETE"}}}
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryErrorPattern("delete .*", errors.New("mock.delete.error"))
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"use test",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	readEvent := func() map[string]interface{} {
+		select {
+		case line := <-lines:
+			event := make(map[string]interface{})
+			assert.Nil(t, json.Unmarshal([]byte(line), &event))
+			return event
+		case <-time.After(time.Second * 5):
+			assert.Fail(t, "audit.event.timeout")
+		}
+		return nil
+	}
+
+	// Insert.
+	{
+		_, err = client.FetchAll("insert into t1(id, b) values(1,2),(3,4)", -1)
+		assert.Nil(t, err)
+		event := readEvent()
+		assert.Equal(t, "INSERT", event["command_type"])
+		assert.Equal(t, []interface{}{"test.t1"}, event["tables"])
+		assert.NotEmpty(t, event["backends"])
+		shards := event["shards"].([]interface{})
+		assert.NotEmpty(t, shards)
+		for _, shard := range shards {
+			assert.True(t, strings.Contains(shard.(string), "/test.t1_"), shard)
+		}
+		assert.Nil(t, event["error"])
+	}
+
+	// Delete with error.
+	{
+		_, err = client.FetchAll("delete from t1 where id=1", -1)
+		assert.NotNil(t, err)
+		event := readEvent()
+		assert.Equal(t, "DELETE", event["command_type"])
+		assert.Equal(t, []interface{}{"test.t1"}, event["tables"])
+		assert.Equal(t, float64(1), event["status"])
+		assert.True(t, strings.Contains(event["error"].(string), "mock.delete.error"), event["error"])
+	}
+}
@@ -138,5 +138,7 @@ func (spanner *Spanner) AuthCheck(s *driver.Session) error {
 		log.Error("proxy: auth.user[%s].failed(password.invalid):want[%+v]!=got[%+v]", user, want, got)
 		return sqldb.NewSQLErrorf(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%v'", user)
 	}
-	return nil
+
+	// Max connections quota check.
+	return spanner.userConnectionsCheck(s)
 }
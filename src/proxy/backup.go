@@ -0,0 +1,253 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"backend"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// backupInsertSize is the max bytes of the INSERT statement in the dump.
+	backupInsertSize = 1024 * 1024
+
+	// backupManifestFile is the manifest file name in the backup dir.
+	backupManifestFile = "manifest.json"
+)
+
+// backupTable tuple, the table dumped in the backup.
+type backupTable struct {
+	Database   string `json:"database"`
+	Table      string `json:"table"`
+	ShardType  string `json:"shardtype"`
+	Rows       uint64 `json:"rows"`
+	SchemaFile string `json:"schema-file"`
+	DataFile   string `json:"data-file"`
+}
+
+// backupManifest tuple, the manifest of the backup.
+// The positions are the binlog positions of the backends where the snapshot is taken, the incremental
+// changes can be replayed from them.
+type backupManifest struct {
+	Time      time.Time                  `json:"time"`
+	Duration  string                     `json:"duration"`
+	Positions []backend.SnapshotPosition `json:"positions"`
+	Tables    []backupTable              `json:"tables"`
+}
+
+// handleRadonBackup used to handle the command: radon backup to 'dir'.
+// It takes the consistent snapshot across all the backends, and writes the logical dump to the dir:
+// <db>-schema-create.sql, <db>.<table>-schema.sql, <db>.<table>.sql and the manifest.json.
+func (spanner *Spanner) handleRadonBackup(session *driver.Session, query string, node *sqlparser.Radon) (*sqltypes.Result, error) {
+	log := spanner.log
+	router := spanner.router
+	scatter := spanner.scatter
+	sessions := spanner.sessions
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	dir := node.Dir
+	if dir == "" {
+		return nil, errors.New("backup.dir.can.not.be.empty")
+	}
+	if files, err := ioutil.ReadDir(dir); err == nil && len(files) > 0 {
+		return nil, errors.Errorf("backup.dir[%s].is.not.empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return nil, err
+	}
+
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		log.Error("spanner.txn.create.error:[%v]", err)
+		return nil, err
+	}
+	defer txn.Finish()
+
+	// binding.
+	sessions.TxnBinding(session, txn, node, query)
+	defer sessions.TxnUnBinding(session)
+
+	snapshot, err := txn.BeginSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	log.Warning("proxy.backup.to[%s].snapshot.at[%v].positions:%+v", dir, snapshot.Time, snapshot.Positions)
+
+	manifest := &backupManifest{
+		Time:      snapshot.Time,
+		Positions: snapshot.Positions,
+		Tables:    make([]backupTable, 0, 16),
+	}
+	var allRows uint64
+	tables := router.Tables()
+	databases := make([]string, 0, len(tables))
+	for db := range tables {
+		if router.IsSystemDB(db) {
+			continue
+		}
+		databases = append(databases, db)
+	}
+	sort.Strings(databases)
+	for _, db := range databases {
+		schemaFile := fmt.Sprintf("%s-schema-create.sql", db)
+		if err := ioutil.WriteFile(path.Join(dir, schemaFile), []byte(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`;\n", db)), 0644); err != nil {
+			return nil, err
+		}
+
+		sort.Strings(tables[db])
+		for _, table := range tables[db] {
+			bt, err := spanner.dumpTable(session, txn, dir, db, table)
+			if err != nil {
+				log.Error("proxy.backup.table[%s.%s].error:%+v", db, table, err)
+				return nil, err
+			}
+			allRows += bt.Rows
+			manifest.Tables = append(manifest.Tables, *bt)
+		}
+	}
+
+	manifest.Duration = time.Since(snapshot.Time).String()
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path.Join(dir, backupManifestFile), data, 0644); err != nil {
+		return nil, err
+	}
+	log.Warning("proxy.backup.to[%s].done[tables:%v, rows:%v, duration:%v]", dir, len(manifest.Tables), allRows, manifest.Duration)
+
+	qr := &sqltypes.Result{RowsAffected: allRows}
+	qr.Fields = []*querypb.Field{
+		{Name: "Dir", Type: querypb.Type_VARCHAR},
+		{Name: "Tables", Type: querypb.Type_INT64},
+		{Name: "Rows", Type: querypb.Type_UINT64},
+	}
+	qr.Rows = append(qr.Rows, []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(dir)),
+		sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(manifest.Tables)))),
+		sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", allRows))),
+	})
+	return qr, nil
+}
+
+// dumpTable used to dump the schema and the rows of the table in the snapshot to the dir.
+func (spanner *Spanner) dumpTable(session *driver.Session, txn *backend.Txn, dir string, database string, table string) (*backupTable, error) {
+	router := spanner.router
+	tableConfig, err := router.TableConfig(database, table)
+	if err != nil {
+		return nil, err
+	}
+	bt := &backupTable{
+		Database:   database,
+		Table:      table,
+		ShardType:  tableConfig.ShardType,
+		SchemaFile: fmt.Sprintf("%s.%s-schema.sql", database, table),
+		DataFile:   fmt.Sprintf("%s.%s.sql", database, table),
+	}
+
+	// Schema.
+	show := &sqlparser.Show{Type: sqlparser.ShowCreateTableStr, Table: sqlparser.TableName{Name: sqlparser.NewTableIdent(table), Qualifier: sqlparser.NewTableIdent(database)}}
+	qr, err := spanner.handleShowCreateTable(session, "", show)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path.Join(dir, bt.SchemaFile), append(qr.Rows[0][1].Raw(), []byte(";\n")...), 0644); err != nil {
+		return nil, err
+	}
+
+	// Rows.
+	segments, err := router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The global table has the same rows on all the backends.
+	if tableConfig.ShardType == "GLOBAL" {
+		segments = segments[:1]
+	}
+	req := xcontext.NewRequestContext()
+	for _, segment := range segments {
+		req.Querys = append(req.Querys, xcontext.QueryTuple{
+			Query:   fmt.Sprintf("SELECT * FROM `%s`.`%s`", database, segment.Table),
+			Backend: segment.Backend,
+		})
+	}
+
+	file, err := os.Create(path.Join(dir, bt.DataFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+
+	stmt := bytes.NewBuffer(make([]byte, 0, backupInsertSize))
+	flush := func() error {
+		if stmt.Len() == 0 {
+			return nil
+		}
+		stmt.WriteString(";\n")
+		_, err := w.Write(stmt.Bytes())
+		stmt.Reset()
+		return err
+	}
+	err = txn.ExecuteStreamFetch(req, func(qr *sqltypes.Result) error {
+		for _, row := range qr.Rows {
+			if stmt.Len() == 0 {
+				fmt.Fprintf(stmt, "INSERT INTO `%s` VALUES ", table)
+			} else {
+				stmt.WriteByte(',')
+			}
+			stmt.WriteByte('(')
+			for i, v := range row {
+				if i > 0 {
+					stmt.WriteByte(',')
+				}
+				v.EncodeSQL(stmt)
+			}
+			stmt.WriteByte(')')
+			bt.Rows++
+
+			if stmt.Len() >= backupInsertSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, spanner.conf.Proxy.StreamBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return bt, nil
+}
@@ -0,0 +1,224 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"fakedb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	backupStartQuery = "start transaction with consistent snapshot"
+
+	backupMasterStatusResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "File", Type: querypb.Type_VARCHAR},
+			{Name: "Position", Type: querypb.Type_UINT64},
+			{Name: "Executed_Gtid_Set", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("mysql-bin.000003")),
+				sqltypes.MakeTrusted(querypb.Type_UINT64, []byte("1234")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+			},
+		},
+	}
+
+	backupCreateTableResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Create Table", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1_0000")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("CREATE TABLE `t1_0000` (\n  `id` int(11) DEFAULT NULL\n) ENGINE=InnoDB")),
+			},
+		},
+	}
+
+	backupRowsResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("it's")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+				sqltypes.NULL,
+			},
+		},
+	}
+)
+
+func mockBackupQuerys(fakedbs *fakedb.DB) {
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	fakedbs.AddQuery("set transaction read only", &sqltypes.Result{})
+	fakedbs.AddQuery(backupStartQuery, &sqltypes.Result{})
+	fakedbs.AddQuery("show master status", backupMasterStatusResult)
+	fakedbs.AddQuery("rollback", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("show create table .*", backupCreateTableResult)
+	fakedbs.AddQueryPattern("select .*", backupRowsResult)
+}
+
+func TestProxyRadonBackup(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	backends := proxy.Scatter().AllBackends()
+	mockBackupQuerys(fakedbs)
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, name varchar(32)) partition by hash(id)",
+		"create table test.g1(id int, name varchar(32)) global",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	tmpDir := fakedb.GetTmpDir("", "radon_backup_", log)
+	defer os.RemoveAll(tmpDir)
+	dir := path.Join(tmpDir, "backup")
+
+	{
+		qr, err := client.FetchAll("radon backup to '"+dir+"'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, dir, qr.Rows[0][0].String())
+		assert.Equal(t, "2", qr.Rows[0][1].String())
+		assert.Equal(t, len(backends), fakedbs.GetQueryCalledNum(backupStartQuery))
+		assert.Equal(t, len(backends), fakedbs.GetQueryCalledNum("rollback"))
+
+		data, err := ioutil.ReadFile(path.Join(dir, backupManifestFile))
+		assert.Nil(t, err)
+		manifest := &backupManifest{}
+		err = json.Unmarshal(data, manifest)
+		assert.Nil(t, err)
+		assert.Equal(t, len(backends), len(manifest.Positions))
+		for _, pos := range manifest.Positions {
+			assert.Equal(t, "mysql-bin.000003", pos.File)
+			assert.Equal(t, uint64(1234), pos.Position)
+		}
+
+		// The tables are sorted by name, the global table is dumped from one backend.
+		assert.Equal(t, 2, len(manifest.Tables))
+		assert.Equal(t, "g1", manifest.Tables[0].Table)
+		assert.Equal(t, uint64(2), manifest.Tables[0].Rows)
+		assert.Equal(t, "t1", manifest.Tables[1].Table)
+		assert.Equal(t, fmt.Sprintf("%d", manifest.Tables[0].Rows+manifest.Tables[1].Rows), qr.Rows[0][2].String())
+
+		schema, err := ioutil.ReadFile(path.Join(dir, "test.t1-schema.sql"))
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(schema), "CREATE TABLE `t1` ("))
+		rows, err := ioutil.ReadFile(path.Join(dir, "test.g1.sql"))
+		assert.Nil(t, err)
+		assert.Equal(t, "INSERT INTO `g1` VALUES (1,'it\\'s'),(2,null);\n", string(rows))
+		_, err = os.Stat(path.Join(dir, "test-schema-create.sql"))
+		assert.Nil(t, err)
+	}
+
+	// The dir is not empty.
+	{
+		_, err := client.FetchAll("radon backup to '"+dir+"'", -1)
+		assert.NotNil(t, err)
+	}
+
+	// The snapshot fails to start.
+	{
+		fakedbs.AddQueryError(backupStartQuery, errors.New("mock.start.snapshot.error"))
+		_, err := client.FetchAll("radon backup to '"+path.Join(tmpDir, "backup2")+"'", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyStreamingSnapshot(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	backends := proxy.Scatter().AllBackends()
+	mockBackupQuerys(fakedbs)
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, name varchar(32)) partition by hash(id)",
+		"set @@session.radon_streaming_fetch='ON'",
+		"set radon_streaming_snapshot=1",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	qr, err := client.FetchAll("select * from test.t1", -1)
+	assert.Nil(t, err)
+	assert.True(t, len(qr.Rows) > 0)
+	assert.Equal(t, len(backends), fakedbs.GetQueryCalledNum(backupStartQuery))
+
+	// Off.
+	{
+		_, err := client.FetchAll("set radon_streaming_snapshot=OFF", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("select * from test.t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, len(backends), fakedbs.GetQueryCalledNum(backupStartQuery))
+	}
+
+	// Invalid value.
+	{
+		_, err := client.FetchAll("set radon_streaming_snapshot='xx'", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyRadonBackupPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("radon backup to 'backup'", -1)
+	want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
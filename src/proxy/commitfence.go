@@ -0,0 +1,331 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"backend"
+	"syncer"
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	fenceRestURL   = "/v1/txn/fence"
+	unfenceRestURL = "/v1/txn/unfence"
+)
+
+// fenceParams is the payload of the fence request to the peers.
+type fenceParams struct {
+	Xid      string   `json:"xid"`
+	Backends []string `json:"backends"`
+	// Tables are the 'db.table's to gate for the cutover of the online ddl, instead of the backends to fence.
+	Tables []string `json:"tables,omitempty"`
+	Lease  int      `json:"lease"`
+	// Renew extends the lease of the fence held by the xid.
+	Renew bool `json:"renew,omitempty"`
+	// Peer asks for the read lease, without it the peer can't serve the fenced reads.
+	Peer string `json:"peer,omitempty"`
+}
+
+// peerFenced tuple, the peers fenced by the xid.
+type peerFenced struct {
+	peers []string
+	// done stops the lease renewal.
+	done     chan struct{}
+	renewing sync.WaitGroup
+}
+
+// PeerFence is the CommitFence across all the radon peers.
+// The XA COMMIT acquires the commit locks of the backends on every peer(including itself) one by one
+// in the order of the peer address before the commit decision, so the reads on any peer never see the
+// half-committed cross-shard transaction. The backends are locked in order on each peer, so the concurrent
+// fences acquire all the locks in the same global order and never wait for each other.
+// The commit is rolled back if any peer can't be fenced, the fenced peers are unfenced.
+// The remote locks are held with a lease which is renewed until the commit completes,
+// the lease expires only if the coordinator is gone.
+//
+// A peer serves the fenced reads only while it holds the read lease granted by all the other peers,
+// so an unreachable peer is skipped only if the lease granted to it has expired: it can't serve the reads
+// since then, even if it's partitioned from this peer only.
+// It's a commit fence, not a global timestamp: MySQL has no snapshot which can be read at a given timestamp.
+type PeerFence struct {
+	log     *xlog.Log
+	self    string
+	lease   time.Duration
+	syncer  *syncer.Syncer
+	scatter *backend.Scatter
+	mu      sync.Mutex
+	fenced  map[string]*peerFenced
+	// grants is the expiry of the read lease granted to the peer, by the clock of this peer.
+	grants map[string]time.Time
+	// leases is the expiry of the read lease granted by the peer, it expires before the grant of the peer.
+	leases map[string]time.Time
+	// renewMu serializes the renewals of the read leases.
+	renewMu sync.Mutex
+}
+
+// NewPeerFence creates the PeerFence tuple.
+func NewPeerFence(log *xlog.Log, self string, lease time.Duration, syncer *syncer.Syncer, scatter *backend.Scatter) *PeerFence {
+	return &PeerFence{
+		log:     log,
+		self:    self,
+		lease:   lease,
+		syncer:  syncer,
+		scatter: scatter,
+		fenced:  make(map[string]*peerFenced),
+		grants:  make(map[string]time.Time),
+		leases:  make(map[string]time.Time),
+	}
+}
+
+func peerPost(peer string, url string, params interface{}) error {
+	resp, cleanup, err := xbase.HTTPPost("http://"+path.Join(peer, url), params)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("peer[%s].post[%s].status.code[%d].body[%s]", peer, url, resp.StatusCode, xbase.HTTPReadBody(resp))
+	}
+	return nil
+}
+
+// peers returns the peers to fence in order, including itself.
+func (f *PeerFence) peers() []string {
+	peers := append([]string{}, f.syncer.Peers()...)
+	self := false
+	for _, peer := range peers {
+		if peer == f.self {
+			self = true
+		}
+	}
+	if !self {
+		peers = append(peers, f.self)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// Grant used to grant the read lease to the peer, it's asked by the peer before serving the fenced reads.
+func (f *PeerFence) Grant(peer string, lease time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.grants[peer] = time.Now().Add(lease)
+}
+
+// expired returns true if the read lease granted to the peer has expired(or never granted),
+// the peer doesn't serve the fenced reads since then. Without the lease, no peer can be skipped.
+func (f *PeerFence) expired(peer string) bool {
+	if f.lease <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !time.Now().Before(f.grants[peer])
+}
+
+// expiring returns the other peers whose read lease expires within the third of the lease.
+func (f *PeerFence) expiring() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var peers []string
+	deadline := time.Now().Add(f.lease / 3)
+	for _, peer := range f.peers() {
+		if peer != f.self && f.leases[peer].Before(deadline) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// Leased used to check that this peer holds the read lease of all the other peers before the fenced reads,
+// the leases expiring are renewed. It fails if the lease of any unreachable peer has expired,
+// since the peer may commit without fencing this one.
+func (f *PeerFence) Leased() error {
+	if f.lease <= 0 || len(f.expiring()) == 0 {
+		return nil
+	}
+	f.renewMu.Lock()
+	defer f.renewMu.Unlock()
+
+	// Renewed by others meanwhile.
+	peers := f.expiring()
+	var wg sync.WaitGroup
+	params := &fenceParams{Peer: f.self, Lease: int(f.lease / time.Millisecond)}
+	errs := make([]error, len(peers))
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			start := time.Now()
+			if errs[i] = peerPost(peer, fenceRestURL, params); errs[i] == nil {
+				f.mu.Lock()
+				f.leases[peer] = start.Add(f.lease)
+				f.mu.Unlock()
+			}
+		}(i, peer)
+	}
+	wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for i, peer := range peers {
+		if errs[i] != nil && !now.Before(f.leases[peer]) {
+			f.log.Error("proxy.fence.peer[%s].read.lease.expired.error:%v", peer, errs[i])
+			return errors.Errorf("proxy.fence.peer[%s].read.lease.expired:%v", peer, errs[i])
+		}
+	}
+	return nil
+}
+
+// Fence used to acquire the commit locks of the backends on all the peers in order.
+// The failed peer is skipped only if its read lease has expired.
+// If any other peer fails, all the peers are unfenced and the error is returned.
+func (f *PeerFence) Fence(xid string, backends []string) error {
+	params := &fenceParams{
+		Xid:      xid,
+		Backends: backends,
+		Lease:    int(f.lease / time.Millisecond),
+	}
+	return f.fence(params, func() error {
+		return f.scatter.FenceLock(xid, backends, 0)
+	})
+}
+
+// FenceTables used to acquire the gates of the 'db.table's on all the peers in order, so the DML of the tables
+// on every peer wait for the cutover of the online ddl. It's released by the Unfence as the Fence.
+func (f *PeerFence) FenceTables(xid string, tables []string) error {
+	params := &fenceParams{
+		Xid:    xid,
+		Tables: tables,
+		Lease:  int(f.lease / time.Millisecond),
+	}
+	return f.fence(params, func() error {
+		return f.scatter.GateLock(xid, tables, 0)
+	})
+}
+
+func (f *PeerFence) fence(params *fenceParams, lockSelf func() error) error {
+	log := f.log
+	xid := params.Xid
+
+	// The peers which may hold the locks, including the failed ones which may be still acquiring.
+	pf := &peerFenced{done: make(chan struct{})}
+	for _, peer := range f.peers() {
+		if peer == f.self {
+			if err := lockSelf(); err != nil {
+				log.Error("proxy.fence.xid[%s].self[%s].error:%v", xid, peer, err)
+				f.unfence(xid, pf)
+				return errors.Errorf("proxy.fence.xid[%s].peer[%s].error:%v", xid, peer, err)
+			}
+			pf.peers = append(pf.peers, peer)
+			continue
+		}
+
+		err := peerPost(peer, fenceRestURL, params)
+		pf.peers = append(pf.peers, peer)
+		if err == nil {
+			continue
+		}
+		if f.expired(peer) {
+			log.Warning("proxy.fence.xid[%s].peer[%s].read.lease.expired.skipped.error:%v", xid, peer, err)
+			continue
+		}
+		log.Error("proxy.fence.xid[%s].peer[%s].error:%v", xid, peer, err)
+		f.unfence(xid, pf)
+		return errors.Errorf("proxy.fence.xid[%s].peer[%s].error:%v", xid, peer, err)
+	}
+
+	f.mu.Lock()
+	f.fenced[xid] = pf
+	f.mu.Unlock()
+	if f.lease > 0 {
+		pf.renewing.Add(1)
+		go f.renew(xid, pf)
+	}
+	return nil
+}
+
+// renew used to renew the lease of the remote locks until the xid is unfenced.
+func (f *PeerFence) renew(xid string, pf *peerFenced) {
+	log := f.log
+	params := &fenceParams{
+		Xid:   xid,
+		Lease: int(f.lease / time.Millisecond),
+		Renew: true,
+	}
+	ticker := time.NewTicker(f.lease / 3)
+	defer ticker.Stop()
+	defer pf.renewing.Done()
+	for {
+		select {
+		case <-pf.done:
+			return
+		case <-ticker.C:
+			var wg sync.WaitGroup
+			for _, peer := range pf.peers {
+				if peer == f.self {
+					continue
+				}
+				wg.Add(1)
+				go func(peer string) {
+					defer wg.Done()
+					if err := peerPost(peer, fenceRestURL, params); err != nil {
+						log.Error("proxy.fence.xid[%s].peer[%s].renew.error:%v", xid, peer, err)
+					}
+				}(peer)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+// Unfence used to release the commit locks on the peers fenced by the xid.
+func (f *PeerFence) Unfence(xid string, backends []string) {
+	f.mu.Lock()
+	pf, ok := f.fenced[xid]
+	delete(f.fenced, xid)
+	f.mu.Unlock()
+	if ok {
+		f.unfence(xid, pf)
+	}
+}
+
+func (f *PeerFence) unfence(xid string, pf *peerFenced) {
+	log := f.log
+	close(pf.done)
+	pf.renewing.Wait()
+
+	var wg sync.WaitGroup
+	params := &fenceParams{Xid: xid}
+	for _, peer := range pf.peers {
+		if peer == f.self {
+			f.scatter.FenceUnlock(xid)
+			continue
+		}
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			if err := peerPost(peer, unfenceRestURL, params); err != nil {
+				// The locks will be released by the lease.
+				log.Warning("proxy.unfence.xid[%s].peer[%s].error:%v", xid, peer, err)
+			}
+		}(peer)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,236 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyCommitFence(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+
+	// Mock peer.
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &fenceParams{}
+		json.NewDecoder(r.Body).Decode(p)
+		mu.Lock()
+		requests = append(requests, r.URL.Path+":"+p.Xid)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	peer := strings.TrimPrefix(server.URL, "http://")
+
+	self := proxy.PeerAddress()
+	syncer := proxy.Syncer()
+	assert.Nil(t, syncer.AddPeer(peer))
+	// Unreachable peer is skipped, it has never been granted the read lease.
+	assert.Nil(t, syncer.AddPeer("127.0.0.1:1"))
+
+	scatter := proxy.Scatter()
+	fence := NewPeerFence(log, self, time.Minute, syncer, scatter)
+	backends := scatter.Backends()
+
+	err := fence.Fence("xid1", backends)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{fenceRestURL + ":xid1"}, requests)
+	// Self is fenced.
+	assert.NotNil(t, scatter.FenceLock("xid1", backends, 0))
+	// The peers are fenced in order, the skipped one may be still acquiring.
+	fenced := fence.fenced["xid1"].peers
+	assert.Equal(t, 3, len(fenced))
+	assert.True(t, sort.StringsAreSorted(fenced))
+
+	fence.Unfence("xid1", backends)
+	assert.Equal(t, []string{fenceRestURL + ":xid1", unfenceRestURL + ":xid1"}, requests)
+	assert.Equal(t, 0, len(fence.fenced))
+	// Self is unfenced.
+	assert.Nil(t, scatter.FenceLock("xid1", backends, 0))
+	scatter.FenceUnlock("xid1")
+
+	// The unreachable peer holding the read lease can't be skipped.
+	fence.Grant("127.0.0.1:1", time.Minute)
+	err = fence.Fence("xid2", backends)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(fence.fenced))
+	assert.Nil(t, scatter.FenceLock("xid2", backends, 0))
+	scatter.FenceUnlock("xid2")
+}
+
+func TestProxyCommitFenceTables(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+
+	// Mock peer.
+	var mu sync.Mutex
+	var tables []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &fenceParams{}
+		json.NewDecoder(r.Body).Decode(p)
+		mu.Lock()
+		tables = append(tables, p.Tables...)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	peer := strings.TrimPrefix(server.URL, "http://")
+
+	syncer := proxy.Syncer()
+	assert.Nil(t, syncer.AddPeer(peer))
+	scatter := proxy.Scatter()
+	fence := NewPeerFence(log, proxy.PeerAddress(), 0, syncer, scatter)
+
+	err := fence.FenceTables("xid1", []string{"test.t1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test.t1"}, tables)
+	// Self is gated.
+	assert.NotNil(t, scatter.GateLock("xid1", []string{"test.t1"}, 0))
+	fence.Unfence("xid1", nil)
+	assert.Nil(t, scatter.GateLock("xid1", []string{"test.t1"}, 0))
+	scatter.FenceUnlock("xid1")
+}
+
+func TestProxyCommitFencePeerError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+
+	// Peer returns error.
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == fenceRestURL {
+			http.Error(w, "mock.fence.error", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	peer := strings.TrimPrefix(server.URL, "http://")
+
+	syncer := proxy.Syncer()
+	assert.Nil(t, syncer.AddPeer(peer))
+
+	scatter := proxy.Scatter()
+	backends := scatter.Backends()
+	fence := NewPeerFence(log, proxy.PeerAddress(), time.Second, syncer, scatter)
+	fence.Grant(peer, time.Minute)
+	err := fence.Fence("xid1", backends)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(fence.fenced))
+	// The failed peer and self are unfenced.
+	assert.Equal(t, []string{fenceRestURL, unfenceRestURL}, requests)
+	assert.Nil(t, scatter.FenceLock("xid1", backends, 0))
+	scatter.FenceUnlock("xid1")
+}
+
+func TestProxyCommitFenceRenew(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+
+	// Mock peer.
+	var mu sync.Mutex
+	renews := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &fenceParams{}
+		json.NewDecoder(r.Body).Decode(p)
+		mu.Lock()
+		if p.Renew {
+			renews++
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+	peer := strings.TrimPrefix(server.URL, "http://")
+
+	syncer := proxy.Syncer()
+	assert.Nil(t, syncer.AddPeer(peer))
+
+	scatter := proxy.Scatter()
+	backends := scatter.Backends()
+	fence := NewPeerFence(log, proxy.PeerAddress(), 60*time.Millisecond, syncer, scatter)
+	err := fence.Fence("xid1", backends)
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+	fence.Unfence("xid1", backends)
+
+	mu.Lock()
+	got := renews
+	mu.Unlock()
+	assert.True(t, got > 0)
+
+	// The renewal stops after unfence.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, got, renews)
+	mu.Unlock()
+}
+
+func TestProxyCommitFenceLease(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+
+	// Mock peer.
+	var mu sync.Mutex
+	var grants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &fenceParams{}
+		json.NewDecoder(r.Body).Decode(p)
+		mu.Lock()
+		grants = append(grants, p.Peer)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	peer := strings.TrimPrefix(server.URL, "http://")
+
+	self := proxy.PeerAddress()
+	syncer := proxy.Syncer()
+	assert.Nil(t, syncer.AddPeer(peer))
+	fence := NewPeerFence(log, self, 300*time.Millisecond, syncer, proxy.Scatter())
+
+	// The lease is asked from the peer.
+	assert.Nil(t, fence.Leased())
+	assert.Equal(t, []string{self}, grants)
+	// Still leased.
+	assert.Nil(t, fence.Leased())
+	assert.Equal(t, 1, len(grants))
+	// Renewed once it's expiring.
+	time.Sleep(250 * time.Millisecond)
+	assert.Nil(t, fence.Leased())
+	assert.Equal(t, 2, len(grants))
+
+	// The lease of the unreachable peer can't be renewed.
+	assert.Nil(t, syncer.AddPeer("127.0.0.1:1"))
+	err := fence.Leased()
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "peer[127.0.0.1:1].read.lease.expired"))
+
+	// The lease granted to the peer expires.
+	assert.True(t, fence.expired(peer))
+	fence.Grant(peer, 100*time.Millisecond)
+	assert.False(t, fence.expired(peer))
+	time.Sleep(150 * time.Millisecond)
+	assert.True(t, fence.expired(peer))
+}
@@ -10,9 +10,16 @@ package proxy
 
 import (
 	"strings"
+
+	"xtrace"
 )
 
 // isConnectorFilter -- used to check the query is JDBC/Connector set.
+// The query with the traceparent comment is from the application, not the connector.
 func (spanner *Spanner) isConnectorFilter(query string) bool {
-	return strings.HasPrefix(query, "/*") || strings.HasPrefix(query, "SET NAMES")
+	if strings.HasPrefix(query, "/*") {
+		_, traced := xtrace.ExtractTraceparent(query)
+		return !traced
+	}
+	return strings.HasPrefix(query, "SET NAMES")
 }
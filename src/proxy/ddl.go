@@ -138,7 +138,7 @@ func checkTableExists(database string, table string, router *router.Router) bool
 // Here we need to deal with database.table grammar.
 // Supports:
 // 1. CREATE/DROP DATABASE
-// 2. CREATE/DROP TABLE ... PARTITION BY HASH(shardkey)
+// 2. CREATE/DROP TABLE ... [TABLEGROUP name] PARTITION BY HASH(shardkey)
 // 3. CREATE/DROP INDEX ON TABLE(columns...)
 // 4. ALTER TABLE .. ENGINE=xx
 // 5. ALTER TABLE .. ADD COLUMN (column definition)
@@ -149,6 +149,11 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 	route := spanner.router
 	scatter := spanner.scatter
 
+	// The DDL causes an implicit commit of the session's transaction as mysql.
+	if err := spanner.implicitCommit(session, query, node); err != nil {
+		return nil, err
+	}
+
 	ddl := node
 	database := session.Schema()
 	// Database operation.
@@ -249,6 +254,7 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 		}
 		extra := &router.Extra{
 			AutoIncrement: autoinc,
+			TableGroup:    ddl.TableSpec.Options.TableGroup,
 		}
 
 		switch tableType {
@@ -283,7 +289,7 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 		// After sqlparser.String(ddl), the quote '`' in table name will be removed, but the colName with quote '`' will be reserved. e.g.:
 		// sql: create table `db`.`tbl`(`col` int ....
 		// after string(): create table db.tbl(`col` int ....
-		r, err := spanner.ExecuteDDL(session, database, sqlparser.String(ddl), node)
+		r, err := spanner.ddlJobs.Execute(session, database, sqlparser.String(ddl), node)
 		if err != nil {
 			// Try to drop table.
 			route.DropTable(database, table)
@@ -317,8 +323,13 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 				return &sqltypes.Result{}, nil
 			}
 
+			// The table is being altered online.
+			if err := spanner.onlineDDL.busy(db, table); err != nil {
+				return nil, err
+			}
+
 			// Execute.
-			r, err := spanner.ExecuteDDL(session, db, query, node)
+			r, err := spanner.ddlJobs.Execute(session, db, query, node)
 			if err != nil {
 				log.Error("spanner.ddl.execute[%v].error[%+v]", query, err)
 			}
@@ -334,6 +345,8 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 	case sqlparser.CreateIndexStr, sqlparser.DropIndexStr,
 		sqlparser.AlterEngineStr, sqlparser.AlterCharsetStr,
 		sqlparser.AlterAddColumnStr, sqlparser.AlterDropColumnStr, sqlparser.AlterModifyColumnStr,
+		sqlparser.AlterChangeColumnStr, sqlparser.AlterRenameColumnStr, sqlparser.AlterAddIndexStr,
+		sqlparser.AlterAddForeignKeyStr, sqlparser.AlterMultiStr,
 		sqlparser.TruncateTableStr:
 
 		// Check the database and table is exists.
@@ -345,8 +358,11 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 		if !checkTableExists(database, table, route) {
 			return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
 		}
+		if err := spanner.onlineDDL.busy(database, table); err != nil {
+			return nil, err
+		}
 		// Execute.
-		r, err := spanner.ExecuteDDL(session, database, query, node)
+		r, err := spanner.ddlJobs.Execute(session, database, query, node)
 		if err != nil {
 			log.Error("spanner.ddl[%v].error[%+v]", query, err)
 		}
@@ -370,9 +386,12 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 		if checkTableExists(database, toTable, route) {
 			return nil, sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, toTable)
 		}
+		if err := spanner.onlineDDL.busy(database, fromTable); err != nil {
+			return nil, err
+		}
 
 		// Execute.
-		r, err := spanner.ExecuteDDL(session, database, query, node)
+		r, err := spanner.ddlJobs.Execute(session, database, query, node)
 		if err != nil {
 			log.Error("spanner.ddl.execute[%v].error[%+v]", query, err)
 			return r, err
@@ -724,6 +724,84 @@ func TestProxyDDLColumn(t *testing.T) {
 	}
 }
 
+func TestProxyDDLAlterSpecs(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	querys := []string{
+		"create table t1(id int, b int) partition by hash(id)",
+		"alter table t1 change b c bigint not null",
+		"alter table t1 change column c b int",
+		"alter table t1 rename column b to c",
+		"alter table t1 add c2 int",
+		"alter table t1 add index idx_c(c)",
+		"alter table t1 add unique key uk_id_c(id, c)",
+		"alter table t1 add constraint pk primary key(id)",
+		"alter table t1 drop index idx_c",
+		"alter table t1 add column c3 int, add index idx_c3(c3), drop column c2",
+		"create table t2(id int, b int) global",
+		"alter table t2 change column id id2 int",
+		"alter table t2 add unique key uk_b(b)",
+	}
+	queryerr := []string{
+		"alter table t1 change id id2 bigint",
+		"alter table t1 rename column id to id2",
+		"alter table t1 add unique key uk_c(c)",
+		"alter table t1 add primary key(c)",
+		"alter table t1 add foreign key(c) references t2(id)",
+		"alter table t2 add constraint fk_b foreign key(b) references t1(id)",
+		"alter table t1 add column c4 int, modify column id bigint",
+	}
+	wants := []string{
+		"unsupported: cannot.change.the.column.on.shard.key (errno 1105) (sqlstate HY000)",
+		"unsupported: cannot.rename.the.column.on.shard.key (errno 1105) (sqlstate HY000)",
+		"The unique/primary constraint should be only defined on the sharding key column[id] (errno 1105) (sqlstate HY000)",
+		"The unique/primary constraint should be only defined on the sharding key column[id] (errno 1105) (sqlstate HY000)",
+		"unsupported: foreign.key (errno 1105) (sqlstate HY000)",
+		"unsupported: foreign.key (errno 1105) (sqlstate HY000)",
+		"unsupported: cannot.modify.the.column.on.shard.key (errno 1105) (sqlstate HY000)",
+	}
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("alter table .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop index .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	// change/rename column, add index and multi-clause alter.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+	}
+
+	// shard key and foreign key errors.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		for i, query := range queryerr {
+			_, err = client.FetchAll(query, -1)
+			got := err.Error()
+			assert.Equal(t, wants[i], got)
+		}
+	}
+}
+
 func TestProxyDDLUnsupported(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
@@ -1321,6 +1399,58 @@ func TestProxyDDLCreateTableDistributed(t *testing.T) {
 	}
 }
 
+func TestProxyDDLCreateTableGroup(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"create table t1(a int, b int) tablegroup=g1 partition by hash(a)",
+		"create table t2(a int primary key, b int) tablegroup g1",
+	}
+
+	queryErrs := []string{
+		"create table t3(a int, b int) global tablegroup=g1",
+		"create table t4(a int, b int) tablegroup=g1 distributed by (backend0)",
+	}
+
+	for _, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	for _, query := range queryErrs {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+	}
+
+	route := proxy.Router()
+	assert.Equal(t, []string{"t1", "t2"}, route.Schemas["test"].TableGroups["g1"].Tables)
+	conf, err := route.TableConfig("test", "t2")
+	assert.Nil(t, err)
+	assert.Equal(t, "g1", conf.TableGroup)
+}
+
 func TestProxyDDLUnsupportedAlter(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
@@ -0,0 +1,1104 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend"
+	"config"
+	"planner"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// ddlJobHistorySize is the max number of the resolved jobs kept for the status.
+	ddlJobHistorySize = 64
+
+	// ddlJobsJSONFile is the file in the metadir where the jobs are persisted.
+	ddlJobsJSONFile = "ddljobs.json"
+)
+
+// The states of the ddl job.
+const (
+	ddlJobStateRunning    = "running"
+	ddlJobStateDone       = "done"
+	ddlJobStateFailed     = "failed"
+	ddlJobStateRolledBack = "rolledback"
+	// ddlJobStateDiverged is the online ddl job broken in the cutover, it can't be retried or rolled back.
+	ddlJobStateDiverged = "diverged"
+)
+
+// The states of the segment of the ddl job.
+const (
+	ddlSegmentStatePending = "pending"
+	ddlSegmentStateDone    = "done"
+	ddlSegmentStateFailed  = "failed"
+	ddlSegmentStateUndone  = "undone"
+)
+
+var (
+	// ddlAutoIncrementRegexp matches the AUTO_INCREMENT table option of the SHOW CREATE TABLE,
+	// the next values of the sub-tables are always different.
+	ddlAutoIncrementRegexp = regexp.MustCompile(` AUTO_INCREMENT=[0-9]+`)
+)
+
+// ddlJobSegment is the sub-table ddl of the job.
+type ddlJobSegment struct {
+	backend string
+	table   string
+	query   string
+	state   string
+	err     error
+	// drift is true if the definition differs from the most sub-tables.
+	drift bool
+}
+
+// ddlJob is the ddl on all the sub-tables of the table.
+type ddlJob struct {
+	id uint64
+	// owner is the peer address which runs the job.
+	owner    string
+	database string
+	table    string
+	query    string
+	node     *sqlparser.DDL
+	start    time.Time
+	end      time.Time
+	state    string
+	segments []*ddlJobSegment
+	// drifted is the number of the drifted sub-tables, -1 if unchecked.
+	drifted int
+	err     error
+}
+
+// ddlJobJSON is the persisted job.
+type ddlJobJSON struct {
+	ID       uint64            `json:"id"`
+	Owner    string            `json:"owner"`
+	Database string            `json:"database"`
+	Table    string            `json:"table"`
+	Query    string            `json:"query"`
+	State    string            `json:"state"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Drifted  int               `json:"drifted"`
+	Error    string            `json:"error,omitempty"`
+	Segments []*ddlSegmentJSON `json:"segments"`
+}
+
+// ddlSegmentJSON is the persisted segment.
+type ddlSegmentJSON struct {
+	Backend string `json:"backend"`
+	Table   string `json:"table"`
+	Query   string `json:"query"`
+	State   string `json:"state"`
+	Drift   bool   `json:"drift"`
+	Error   string `json:"error,omitempty"`
+}
+
+// toJSON returns the persisted job.
+func (job *ddlJob) toJSON() *ddlJobJSON {
+	j := &ddlJobJSON{
+		ID:       job.id,
+		Owner:    job.owner,
+		Database: job.database,
+		Table:    job.table,
+		Query:    job.query,
+		State:    job.state,
+		Start:    job.start,
+		End:      job.end,
+		Drifted:  job.drifted,
+	}
+	if job.err != nil {
+		j.Error = job.err.Error()
+	}
+	for _, seg := range job.segments {
+		sj := &ddlSegmentJSON{
+			Backend: seg.backend,
+			Table:   seg.table,
+			Query:   seg.query,
+			State:   seg.state,
+			Drift:   seg.drift,
+		}
+		if seg.err != nil {
+			sj.Error = seg.err.Error()
+		}
+		j.Segments = append(j.Segments, sj)
+	}
+	return j
+}
+
+// newDDLJobFromJSON returns the job of the persisted, the node is parsed from the query.
+func newDDLJobFromJSON(j *ddlJobJSON) (*ddlJob, error) {
+	stmt, err := sqlparser.Parse(j.Query)
+	if err != nil {
+		return nil, errors.Errorf("ddl.job[%d].parse.query[%s].error:%v", j.ID, j.Query, err)
+	}
+	node, ok := stmt.(*sqlparser.DDL)
+	if !ok {
+		return nil, errors.Errorf("ddl.job[%d].query[%s].is.not.ddl", j.ID, j.Query)
+	}
+	job := &ddlJob{
+		id:       j.ID,
+		owner:    j.Owner,
+		database: j.Database,
+		table:    j.Table,
+		query:    j.Query,
+		node:     node,
+		start:    j.Start,
+		end:      j.End,
+		state:    j.State,
+		drifted:  j.Drifted,
+	}
+	if j.Error != "" {
+		job.err = errors.New(j.Error)
+	}
+	for _, sj := range j.Segments {
+		seg := &ddlJobSegment{
+			backend: sj.Backend,
+			table:   sj.Table,
+			query:   sj.Query,
+			state:   sj.State,
+			drift:   sj.Drift,
+		}
+		if sj.Error != "" {
+			seg.err = errors.New(sj.Error)
+		}
+		job.segments = append(job.segments, seg)
+	}
+	return job, nil
+}
+
+// count returns the number of the segments in the state.
+func (job *ddlJob) count(state string) int {
+	n := 0
+	for _, seg := range job.segments {
+		if seg.state == state {
+			n++
+		}
+	}
+	return n
+}
+
+// resolved returns true if the job needs no more retry or rollback.
+func (job *ddlJob) resolved() bool {
+	return job.state == ddlJobStateDone || job.state == ddlJobStateRolledBack
+}
+
+// DDLJobs records the distributed ddl as jobs with the status of every sub-table.
+// If the ddl fails on some sub-tables, the altered sub-tables are not the same as the others,
+// the job can be completed by RADON DDL RETRY or undone by RADON DDL ROLLBACK.
+// The jobs are persisted in the metadir which is synced to all the peers, every peer resolves the failed jobs.
+type DDLJobs struct {
+	log     *xlog.Log
+	spanner *Spanner
+	mu      sync.Mutex
+	seq     uint64
+	jobs    []*ddlJob
+	// version is the meta version which the jobs are loaded at.
+	version int64
+}
+
+// NewDDLJobs creates the new DDLJobs.
+func NewDDLJobs(log *xlog.Log, spanner *Spanner) *DDLJobs {
+	return &DDLJobs{
+		log:     log,
+		spanner: spanner,
+	}
+}
+
+// readJobs returns the persisted jobs.
+func (d *DDLJobs) readJobs() ([]*ddlJob, error) {
+	file := path.Join(d.spanner.conf.Proxy.MetaDir, ddlJobsJSONFile)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	var persisted []*ddlJobJSON
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var jobs []*ddlJob
+	for _, j := range persisted {
+		job, err := newDDLJobFromJSON(j)
+		if err != nil {
+			d.log.Error("spanner.ddl.jobs.read.error:%+v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Init used to load the jobs, the jobs of this peer broken by the restart are failed.
+func (d *DDLJobs) Init() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	jobs, err := d.readJobs()
+	if err != nil {
+		return err
+	}
+	broken := false
+	owner := d.spanner.conf.Proxy.PeerAddress
+	for _, job := range jobs {
+		if job.owner == owner && job.state == ddlJobStateRunning {
+			d.log.Warning("spanner.ddl.job[%d].broken.by.restart", job.id)
+			job.state = ddlJobStateFailed
+			job.err = errors.Errorf("ddl.job[%d].interrupted.by.restart", job.id)
+			job.end = time.Now()
+			broken = true
+		}
+		if job.id > d.seq {
+			d.seq = job.id
+		}
+	}
+	d.jobs = jobs
+	d.version = config.ReadVersion(d.spanner.conf.Proxy.MetaDir)
+	if broken {
+		d.flush()
+	}
+	return nil
+}
+
+// load used to reload the jobs if the meta is changed by the peers, the running jobs of this peer are kept.
+// The caller must hold the d.mu.
+func (d *DDLJobs) load() {
+	version := config.ReadVersion(d.spanner.conf.Proxy.MetaDir)
+	if version == d.version {
+		return
+	}
+	jobs, err := d.readJobs()
+	if err != nil {
+		d.log.Error("spanner.ddl.jobs.load.error:%+v", err)
+		return
+	}
+
+	owner := d.spanner.conf.Proxy.PeerAddress
+	running := make(map[uint64]*ddlJob)
+	for _, job := range d.jobs {
+		if job.owner == owner && job.state == ddlJobStateRunning {
+			running[job.id] = job
+		}
+	}
+	for i, job := range jobs {
+		if r, ok := running[job.id]; ok {
+			jobs[i] = r
+			delete(running, job.id)
+		}
+	}
+	for _, job := range d.jobs {
+		if _, ok := running[job.id]; ok {
+			jobs = append(jobs, job)
+		}
+	}
+	for _, job := range jobs {
+		if job.id > d.seq {
+			d.seq = job.id
+		}
+	}
+	d.jobs = jobs
+	d.version = version
+}
+
+// flush used to persist the jobs and bump the meta version for the sync, the caller must hold the d.mu.
+func (d *DDLJobs) flush() {
+	log := d.log
+	jobs := make([]*ddlJobJSON, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		jobs = append(jobs, job.toJSON())
+	}
+
+	metadir := d.spanner.conf.Proxy.MetaDir
+	if err := config.WriteConfig(path.Join(metadir, ddlJobsJSONFile), jobs); err != nil {
+		log.Error("spanner.ddl.jobs.flush.error:%+v", err)
+		return
+	}
+	if err := config.UpdateVersion(metadir); err != nil {
+		log.Error("spanner.ddl.jobs.flush.update.version.error:%+v", err)
+		return
+	}
+	d.version = config.ReadVersion(metadir)
+}
+
+// add used to record the job of the plan.
+func (d *DDLJobs) add(database string, query string, node *sqlparser.DDL, plan *planner.DDLPlan) (*ddlJob, error) {
+	if !node.Table.Qualifier.IsEmpty() {
+		database = node.Table.Qualifier.String()
+	}
+	table := node.Table.Name.String()
+	segments, err := d.spanner.router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The querys of the plan are built in the order of the segments.
+	if len(segments) != len(plan.Querys) {
+		return nil, errors.Errorf("ddl.job.segments[%d].mismatch.querys[%d]", len(segments), len(plan.Querys))
+	}
+
+	// The node of the DROP TABLE is reused for every table.
+	ddl := *node
+	job := &ddlJob{
+		owner:    d.spanner.conf.Proxy.PeerAddress,
+		database: database,
+		table:    table,
+		query:    query,
+		node:     &ddl,
+		start:    time.Now(),
+		state:    ddlJobStateRunning,
+		drifted:  -1,
+	}
+	for i, segment := range segments {
+		job.segments = append(job.segments, &ddlJobSegment{
+			backend: plan.Querys[i].Backend,
+			table:   segment.Table,
+			query:   plan.Querys[i].Query,
+			state:   ddlSegmentStatePending,
+		})
+	}
+
+	d.record(job)
+	return job, nil
+}
+
+// addDiverged used to record the diverged online ddl job: the sub-tables on the swapped backends are altered
+// and the others are pending, they must be fixed by hand.
+func (d *DDLJobs) addDiverged(online *onlineDDLJob) *ddlJob {
+	job := &ddlJob{
+		owner:    d.spanner.conf.Proxy.PeerAddress,
+		database: online.database,
+		table:    online.table,
+		query:    sqlparser.String(online.node),
+		node:     online.node,
+		start:    online.start,
+		end:      time.Now(),
+		state:    ddlJobStateDiverged,
+		drifted:  -1,
+		err:      errors.Errorf("from.online.ddl.job[%d]:%v", online.id, online.err),
+	}
+	for _, seg := range online.segments {
+		alter := *online.node
+		alter.Table = sqlparser.TableName{
+			Name:      sqlparser.NewTableIdent(seg.table),
+			Qualifier: sqlparser.NewTableIdent(online.database),
+		}
+		alter.NewName = alter.Table
+		state := ddlSegmentStatePending
+		if online.swapped[seg.backend] {
+			state = ddlSegmentStateDone
+		}
+		job.segments = append(job.segments, &ddlJobSegment{
+			backend: seg.backend,
+			table:   seg.table,
+			query:   sqlparser.String(&alter),
+			state:   state,
+		})
+	}
+	d.record(job)
+	return job
+}
+
+// record used to add the job with a new id and persist it.
+func (d *DDLJobs) record(job *ddlJob) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+	d.seq++
+	job.id = d.seq
+	d.jobs = append(d.jobs, job)
+	// Trim the oldest resolved jobs, the unresolved are kept until they are resolved.
+	resolved := 0
+	for _, j := range d.jobs {
+		if j.resolved() {
+			resolved++
+		}
+	}
+	jobs := d.jobs[:0]
+	for _, j := range d.jobs {
+		if j.resolved() && resolved > ddlJobHistorySize {
+			resolved--
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	d.jobs = jobs
+	d.flush()
+}
+
+// acquire used to get the failed job and mark it running, the job can be retried or rolled back by one session at a time.
+func (d *DDLJobs) acquire(id uint64) (*ddlJob, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+	for _, job := range d.jobs {
+		if job.id == id {
+			if job.state != ddlJobStateFailed {
+				return nil, errors.Errorf("ddl.job[%d].can.not.be.resolved.in.state[%s]", id, job.state)
+			}
+			job.owner = d.spanner.conf.Proxy.PeerAddress
+			job.state = ddlJobStateRunning
+			job.err = nil
+			d.flush()
+			return job, nil
+		}
+	}
+	return nil, errors.Errorf("ddl.job[%d].not.found", id)
+}
+
+// release used to set the final state of the job.
+func (d *DDLJobs) release(job *ddlJob, state string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job.state = state
+	job.err = err
+	job.end = time.Now()
+	d.flush()
+}
+
+// run used to execute the querys of the segments, the segments on one backend are executed in order
+// and stop at the first error as the txn does, the rest are left pending.
+// If undo is true, the segments are undone by the querys instead.
+func (d *DDLJobs) run(txn backend.Transaction, profile *xcontext.Profile, segs []*ddlJobSegment, querys map[*ddlJobSegment][]string, undo bool) (*sqltypes.Result, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	qr := &sqltypes.Result{}
+
+	var backends []string
+	groups := make(map[string][]*ddlJobSegment)
+	for _, seg := range segs {
+		if _, ok := groups[seg.backend]; !ok {
+			backends = append(backends, seg.backend)
+		}
+		groups[seg.backend] = append(groups[seg.backend], seg)
+	}
+
+	oneBackend := func(segs []*ddlJobSegment) {
+		defer wg.Done()
+		for _, seg := range segs {
+			var x error
+			for _, query := range querys[seg] {
+				reqCtx := xcontext.NewRequestContext()
+				reqCtx.Querys = []xcontext.QueryTuple{{Query: query, Backend: seg.backend}}
+				reqCtx.RawQuery = query
+				reqCtx.Profile = profile
+				var innerqr *sqltypes.Result
+				if innerqr, x = txn.Execute(reqCtx); x != nil {
+					break
+				}
+				mu.Lock()
+				qr.AppendResult(innerqr)
+				mu.Unlock()
+			}
+
+			d.mu.Lock()
+			seg.err = x
+			switch {
+			case x != nil && undo:
+				// The segment is still done.
+			case x != nil:
+				seg.state = ddlSegmentStateFailed
+			case undo:
+				seg.state = ddlSegmentStateUndone
+			default:
+				seg.state = ddlSegmentStateDone
+			}
+			d.mu.Unlock()
+			if x != nil {
+				mu.Lock()
+				errs = append(errs, x)
+				mu.Unlock()
+				return
+			}
+		}
+	}
+
+	for _, back := range backends {
+		wg.Add(1)
+		go oneBackend(groups[back])
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return qr, nil
+}
+
+// execute used to run the querys of the segments in the txn bound to the session.
+func (d *DDLJobs) execute(session *driver.Session, job *ddlJob, segs []*ddlJobSegment, querys map[*ddlJobSegment][]string, undo bool) (*sqltypes.Result, error) {
+	spanner := d.spanner
+	sessions := spanner.sessions
+
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(spanner.conf.Proxy.DDLTimeout)
+	// The sub-tables on one backend are altered one by one on a connection.
+	txn.SetReuseConnections()
+
+	sessions.TxnBinding(session, txn, job.node, job.query)
+	defer sessions.TxnUnBinding(session)
+	profile := sessions.getProfile(session)
+	txn.SetProfile(profile)
+	return d.run(txn, profile, segs, querys, undo)
+}
+
+// Execute used to execute the ddl on all the sub-tables as a job.
+func (d *DDLJobs) Execute(session *driver.Session, database string, query string, node *sqlparser.DDL) (*sqltypes.Result, error) {
+	log := d.log
+	spanner := d.spanner
+	log.Info("spanner.execute.ddl.job.query:%s", query)
+
+	plan := planner.NewDDLPlan(log, database, query, node, spanner.router)
+	if err := plan.Build(); err != nil {
+		return nil, err
+	}
+	job, err := d.add(database, query, node, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	querys := make(map[*ddlJobSegment][]string)
+	for _, seg := range job.segments {
+		querys[seg] = []string{seg.query}
+	}
+	qr, err := d.execute(session, job, job.segments, querys, false)
+	if err != nil {
+		log.Error("spanner.ddl.job[%d].failed.segments[%d/%d].error:%+v", job.id, len(job.segments)-job.count(ddlSegmentStateDone), len(job.segments), err)
+		d.drift(job)
+		d.release(job, ddlJobStateFailed, err)
+		return nil, err
+	}
+	d.release(job, ddlJobStateDone, nil)
+	return qr, nil
+}
+
+// Retry used to execute the ddl on the failed and pending sub-tables of the failed job.
+func (d *DDLJobs) Retry(session *driver.Session, id uint64) error {
+	spanner := d.spanner
+	route := spanner.router
+
+	job, err := d.acquire(id)
+	if err != nil {
+		return err
+	}
+	if err := d.checkRetry(job); err != nil {
+		d.release(job, ddlJobStateFailed, err)
+		return err
+	}
+
+	var segs []*ddlJobSegment
+	querys := make(map[*ddlJobSegment][]string)
+	for _, seg := range job.segments {
+		if seg.state == ddlSegmentStateFailed || seg.state == ddlSegmentStatePending {
+			segs = append(segs, seg)
+			querys[seg] = []string{seg.query}
+		}
+	}
+	if _, err := d.execute(session, job, segs, querys, false); err != nil {
+		d.drift(job)
+		d.release(job, ddlJobStateFailed, err)
+		return err
+	}
+
+	// The router is renamed after all the sub-tables are renamed.
+	if job.node.Action == sqlparser.RenameStr {
+		if err := route.RenameTable(job.database, job.table, job.node.NewName.Name.String()); err != nil {
+			d.release(job, ddlJobStateFailed, err)
+			return err
+		}
+	}
+	spanner.ClearPlanCache()
+	d.drift(job)
+	d.release(job, ddlJobStateDone, nil)
+	return nil
+}
+
+// checkRetry used to check whether the job can be retried.
+func (d *DDLJobs) checkRetry(job *ddlJob) error {
+	route := d.spanner.router
+	switch job.node.Action {
+	case sqlparser.CreateTableStr:
+		// The table is dropped from the router when the create fails.
+		return errors.Errorf("unsupported: ddl.job[%d].retry.action[%s]", job.id, job.node.Action)
+	case sqlparser.DropTableStr:
+		return nil
+	case sqlparser.RenameStr:
+		toTable := job.node.NewName.Name.String()
+		if checkTableExists(job.database, toTable, route) {
+			return sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, toTable)
+		}
+	}
+	if !checkTableExists(job.database, job.table, route) {
+		return sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, job.table)
+	}
+	return d.spanner.onlineDDL.busy(job.database, job.table)
+}
+
+// Rollback used to undo the ddl on the done sub-tables of the failed job.
+func (d *DDLJobs) Rollback(session *driver.Session, id uint64) error {
+	job, err := d.acquire(id)
+	if err != nil {
+		return err
+	}
+	if err := d.spanner.onlineDDL.busy(job.database, job.table); err != nil {
+		d.release(job, ddlJobStateFailed, err)
+		return err
+	}
+
+	var segs []*ddlJobSegment
+	querys := make(map[*ddlJobSegment][]string)
+	ref, err := d.reference(session, job)
+	if err == nil {
+		for _, seg := range job.segments {
+			if seg.state != ddlSegmentStateDone {
+				continue
+			}
+			var undo []string
+			if undo, err = ddlUndoQuerys(job, seg, ref); err != nil {
+				break
+			}
+			segs = append(segs, seg)
+			querys[seg] = undo
+		}
+	}
+	if err != nil {
+		d.release(job, ddlJobStateFailed, err)
+		return err
+	}
+
+	if _, err := d.execute(session, job, segs, querys, true); err != nil {
+		d.drift(job)
+		d.release(job, ddlJobStateFailed, err)
+		return err
+	}
+	d.spanner.ClearPlanCache()
+	d.drift(job)
+	var lost error
+	var dropped []string
+	for _, spec := range ddlSpecs(job.node) {
+		if spec.Action == sqlparser.AlterDropColumnStr {
+			dropped = append(dropped, spec.DropColumnName)
+		}
+	}
+	if len(dropped) > 0 && len(segs) > 0 {
+		lost = errors.Errorf("ddl.job[%d].column[%s].is.re-added.by.the.rollback.but.the.data.of.it.is.lost", job.id, strings.Join(dropped, ","))
+	}
+	d.release(job, ddlJobStateRolledBack, lost)
+	return nil
+}
+
+// reference returns the definition of the sub-table which isn't altered by the job, it's used to undo the ddl
+// which drops or changes the definition. Returns nil if the undo doesn't need it.
+func (d *DDLJobs) reference(session *driver.Session, job *ddlJob) (*sqlparser.DDL, error) {
+	needed := false
+	for _, spec := range ddlSpecs(job.node) {
+		switch spec.Action {
+		case sqlparser.DropIndexStr, sqlparser.AlterEngineStr, sqlparser.AlterCharsetStr,
+			sqlparser.AlterDropColumnStr, sqlparser.AlterModifyColumnStr, sqlparser.AlterChangeColumnStr:
+			needed = true
+		}
+	}
+	if !needed {
+		return nil, nil
+	}
+
+	var ref *ddlJobSegment
+	for _, state := range []string{ddlSegmentStatePending, ddlSegmentStateFailed} {
+		for _, seg := range job.segments {
+			if seg.state == state {
+				ref = seg
+				break
+			}
+		}
+		if ref != nil {
+			break
+		}
+	}
+	if ref == nil {
+		return nil, errors.Errorf("ddl.job[%d].can.not.find.the.unchanged.sub-table", job.id)
+	}
+
+	create, err := d.showCreate(job.database, ref)
+	if err != nil {
+		return nil, err
+	}
+	node, err := sqlparser.Parse(create)
+	if err != nil {
+		return nil, errors.Errorf("ddl.job[%d].can.not.parse.the.definition.of.sub-table[%s]:%v", job.id, ref.table, err)
+	}
+	ddl, ok := node.(*sqlparser.DDL)
+	if !ok || ddl.TableSpec == nil {
+		return nil, errors.Errorf("ddl.job[%d].can.not.parse.the.definition.of.sub-table[%s]", job.id, ref.table)
+	}
+	return ddl, nil
+}
+
+// showCreate returns the SHOW CREATE TABLE of the sub-table.
+func (d *DDLJobs) showCreate(database string, seg *ddlJobSegment) (string, error) {
+	spanner := d.spanner
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return "", err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(spanner.conf.Proxy.QueryTimeout)
+
+	qr, err := txn.ExecuteOnThisBackend(seg.backend, fmt.Sprintf("SHOW CREATE TABLE `%s`.`%s`", database, seg.table))
+	if err != nil {
+		return "", err
+	}
+	if len(qr.Rows) == 0 || len(qr.Rows[0]) < 2 {
+		return "", errors.Errorf("ddl.show.create.table[%s.%s].empty", database, seg.table)
+	}
+	return qr.Rows[0][1].ToString(), nil
+}
+
+// definitions used to fetch the SHOW CREATE TABLE of the sub-tables of the table, it returns the normalized
+// definition of each sub-table and the definition of the most sub-tables.
+func (d *DDLJobs) definitions(database string, table string, segments []*ddlJobSegment) (map[*ddlJobSegment]string, string, error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var checkErr error
+	defs := make(map[*ddlJobSegment]string, len(segments))
+	for _, seg := range segments {
+		wg.Add(1)
+		go func(seg *ddlJobSegment) {
+			defer wg.Done()
+			create, err := d.showCreate(database, seg)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				checkErr = err
+				return
+			}
+			defs[seg] = ddlNormalizeCreate(create, seg.table, table)
+		}(seg)
+	}
+	wg.Wait()
+	if checkErr != nil {
+		return nil, "", checkErr
+	}
+
+	counts := make(map[string]int)
+	most := ""
+	for _, seg := range segments {
+		def := defs[seg]
+		counts[def]++
+		if counts[def] > counts[most] {
+			most = def
+		}
+	}
+	return defs, most, nil
+}
+
+// drift used to compare the SHOW CREATE TABLE of all the sub-tables, the sub-tables differ from
+// the most are drifted. It only checks the ddl changes the definition.
+func (d *DDLJobs) drift(job *ddlJob) {
+	switch job.node.Action {
+	case sqlparser.CreateIndexStr, sqlparser.DropIndexStr, sqlparser.AlterEngineStr, sqlparser.AlterCharsetStr,
+		sqlparser.AlterAddColumnStr, sqlparser.AlterDropColumnStr, sqlparser.AlterModifyColumnStr,
+		sqlparser.AlterChangeColumnStr, sqlparser.AlterRenameColumnStr, sqlparser.AlterAddIndexStr, sqlparser.AlterMultiStr:
+	default:
+		return
+	}
+
+	defs, most, err := d.definitions(job.database, job.table, job.segments)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.log.Error("spanner.ddl.job[%d].drift.check.error:%+v", job.id, err)
+		job.drifted = -1
+		return
+	}
+	job.drifted = 0
+	for _, seg := range job.segments {
+		seg.drift = defs[seg] != most
+		if seg.drift {
+			job.drifted++
+		}
+	}
+	if job.drifted > 0 {
+		d.log.Warning("spanner.ddl.job[%d].table[%s.%s].drifted.sub-tables[%d]", job.id, job.database, job.table, job.drifted)
+	}
+}
+
+// ddlNormalizeCreate used to make the SHOW CREATE TABLE of the sub-tables comparable.
+func ddlNormalizeCreate(create string, subTable string, table string) string {
+	create = strings.Replace(create, fmt.Sprintf("CREATE TABLE `%s`", subTable), fmt.Sprintf("CREATE TABLE `%s`", table), 1)
+	return ddlAutoIncrementRegexp.ReplaceAllString(create, "")
+}
+
+// ddlSpecs returns the alter specifications of the multi-clause ALTER TABLE, or the ddl itself.
+func ddlSpecs(node *sqlparser.DDL) []*sqlparser.DDL {
+	if node.Action == sqlparser.AlterMultiStr {
+		return node.AlterSpecs
+	}
+	return []*sqlparser.DDL{node}
+}
+
+// ddlUndoQuerys returns the querys to undo the ddl on the sub-table, the ref is the unchanged definition.
+// The multi-clause ALTER TABLE is undone by the undos of its specifications in the reverse order.
+func ddlUndoQuerys(job *ddlJob, seg *ddlJobSegment, ref *sqlparser.DDL) ([]string, error) {
+	specs := ddlSpecs(job.node)
+	var querys []string
+	for i := len(specs) - 1; i >= 0; i-- {
+		undos, err := ddlUndoSpec(job, seg, specs[i], ref)
+		if err != nil {
+			return nil, err
+		}
+		querys = append(querys, undos...)
+	}
+	return querys, nil
+}
+
+// ddlUndoSpec returns the querys to undo the ddl or one specification of the ALTER TABLE on the sub-table.
+func ddlUndoSpec(job *ddlJob, seg *ddlJobSegment, node *sqlparser.DDL, ref *sqlparser.DDL) ([]string, error) {
+	table := sqlparser.TableName{
+		Name:      sqlparser.NewTableIdent(seg.table),
+		Qualifier: sqlparser.NewTableIdent(job.database),
+	}
+	name := fmt.Sprintf("`%s`.`%s`", job.database, seg.table)
+
+	var querys []string
+	switch node.Action {
+	case sqlparser.CreateTableStr:
+		querys = append(querys, fmt.Sprintf("DROP TABLE IF EXISTS %s", name))
+	case sqlparser.RenameStr:
+		// The segment query is: rename table db.from to db.to.
+		stmt, err := sqlparser.Parse(seg.query)
+		if err != nil {
+			return nil, err
+		}
+		rename := stmt.(*sqlparser.DDL)
+		querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.RenameStr, Table: rename.NewName, NewName: rename.Table}))
+	case sqlparser.CreateIndexStr:
+		querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.DropIndexStr, IndexName: node.IndexName, Table: table}))
+	case sqlparser.AlterAddColumnStr:
+		for _, col := range node.TableSpec.Columns {
+			querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.AlterDropColumnStr, NewName: table, DropColumnName: col.Name.String()}))
+		}
+	case sqlparser.AlterDropColumnStr:
+		col := ddlRefColumn(ref, node.DropColumnName)
+		if col == nil {
+			return nil, errors.Errorf("ddl.job[%d].can.not.find.the.column[%s]", job.id, node.DropColumnName)
+		}
+		// The column is re-added at the same position, the data of it is lost.
+		querys = append(querys, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", name, sqlparser.String(col), ddlRefColumnPosition(ref, col)))
+	case sqlparser.AlterModifyColumnStr:
+		col := ddlRefColumn(ref, node.ModifyColumnDef.Name.String())
+		if col == nil {
+			return nil, errors.Errorf("ddl.job[%d].can.not.find.the.column[%s]", job.id, node.ModifyColumnDef.Name.String())
+		}
+		querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.AlterModifyColumnStr, NewName: table, ModifyColumnDef: col}))
+	case sqlparser.AlterChangeColumnStr:
+		col := ddlRefColumn(ref, node.OldColumnName)
+		if col == nil {
+			return nil, errors.Errorf("ddl.job[%d].can.not.find.the.column[%s]", job.id, node.OldColumnName)
+		}
+		querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.AlterChangeColumnStr, NewName: table, OldColumnName: node.ModifyColumnDef.Name.String(), ModifyColumnDef: col}))
+	case sqlparser.AlterRenameColumnStr:
+		querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.AlterRenameColumnStr, NewName: table, OldColumnName: node.NewColumnName, NewColumnName: node.OldColumnName}))
+	case sqlparser.AlterAddIndexStr:
+		info := node.TableSpec.Indexes[0].Info
+		if info.Primary {
+			querys = append(querys, fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", name))
+		} else {
+			querys = append(querys, fmt.Sprintf("ALTER TABLE %s DROP INDEX `%s`", name, info.Name.String()))
+		}
+	case sqlparser.DropIndexStr:
+		var index *sqlparser.IndexDefinition
+		for _, idx := range ref.TableSpec.Indexes {
+			if strings.EqualFold(idx.Info.Name.String(), node.IndexName) {
+				index = idx
+				break
+			}
+		}
+		if index == nil {
+			return nil, errors.Errorf("ddl.job[%d].can.not.find.the.index[%s]", job.id, node.IndexName)
+		}
+		querys = append(querys, fmt.Sprintf("ALTER TABLE %s ADD %s", name, sqlparser.String(index)))
+	case sqlparser.AlterEngineStr:
+		querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.AlterEngineStr, NewName: table, Engine: ref.TableSpec.Options.Engine}))
+	case sqlparser.AlterCharsetStr:
+		querys = append(querys, sqlparser.String(&sqlparser.DDL{Action: sqlparser.AlterCharsetStr, NewName: table, Charset: ref.TableSpec.Options.Charset}))
+	default:
+		return nil, errors.Errorf("unsupported: ddl.job[%d].rollback.action[%s]", job.id, node.Action)
+	}
+	return querys, nil
+}
+
+// ddlRefColumn returns the column definition of the ref table.
+func ddlRefColumn(ref *sqlparser.DDL, column string) *sqlparser.ColumnDefinition {
+	for _, col := range ref.TableSpec.Columns {
+		if strings.EqualFold(col.Name.String(), column) {
+			return col
+		}
+	}
+	return nil
+}
+
+// ddlRefColumnPosition returns the position of the column in the ref table for the ADD COLUMN.
+func ddlRefColumnPosition(ref *sqlparser.DDL, col *sqlparser.ColumnDefinition) string {
+	for i, c := range ref.TableSpec.Columns {
+		if c == col && i > 0 {
+			return fmt.Sprintf("AFTER `%s`", ref.TableSpec.Columns[i-1].Name.String())
+		}
+	}
+	return "FIRST"
+}
+
+// Status returns the jobs, or the segments of the job if the id isn't 0.
+func (d *DDLJobs) Status(id uint64) (*sqltypes.Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+
+	qr := &sqltypes.Result{}
+	if id != 0 {
+		var job *ddlJob
+		for _, j := range d.jobs {
+			if j.id == id {
+				job = j
+				break
+			}
+		}
+		if job == nil {
+			return nil, errors.Errorf("ddl.job[%d].not.found", id)
+		}
+		qr.Fields = []*querypb.Field{
+			{Name: "Backend", Type: querypb.Type_VARCHAR},
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Query", Type: querypb.Type_VARCHAR},
+			{Name: "State", Type: querypb.Type_VARCHAR},
+			{Name: "Drift", Type: querypb.Type_VARCHAR},
+			{Name: "Error", Type: querypb.Type_VARCHAR},
+		}
+		for _, seg := range job.segments {
+			drift := sqltypes.NULL
+			if job.drifted >= 0 {
+				drift = sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("NO"))
+				if seg.drift {
+					drift = sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("YES"))
+				}
+			}
+			errMsg := ""
+			if seg.err != nil {
+				errMsg = seg.err.Error()
+			}
+			qr.Rows = append(qr.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(seg.backend)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(seg.table)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(seg.query)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(seg.state)),
+				drift,
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(errMsg)),
+			})
+		}
+		return qr, nil
+	}
+
+	qr.Fields = []*querypb.Field{
+		{Name: "Id", Type: querypb.Type_UINT64},
+		{Name: "Database", Type: querypb.Type_VARCHAR},
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Query", Type: querypb.Type_VARCHAR},
+		{Name: "State", Type: querypb.Type_VARCHAR},
+		{Name: "Segments", Type: querypb.Type_VARCHAR},
+		{Name: "Failed", Type: querypb.Type_INT64},
+		{Name: "Drifted", Type: querypb.Type_INT64},
+		{Name: "Elapsed", Type: querypb.Type_VARCHAR},
+		{Name: "Error", Type: querypb.Type_VARCHAR},
+	}
+	for _, job := range d.jobs {
+		drifted := sqltypes.NULL
+		if job.drifted >= 0 {
+			drifted = sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", job.drifted)))
+		}
+		end := job.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		errMsg := ""
+		if job.err != nil {
+			errMsg = job.err.Error()
+		}
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", job.id))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.database)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.table)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.query)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.state)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("%d/%d", job.count(ddlSegmentStateDone), len(job.segments)))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", job.count(ddlSegmentStateFailed)))),
+			drifted,
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(end.Sub(job.start).Truncate(time.Millisecond).String())),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(errMsg)),
+		})
+	}
+	return qr, nil
+}
+
+// handleRadonDDL used to handle the command: radon ddl status|retry|rollback.
+func (spanner *Spanner) handleRadonDDL(session *driver.Session, query string, node *sqlparser.Radon) (*sqltypes.Result, error) {
+	ddlJobs := spanner.ddlJobs
+	privilegePlug := spanner.plugins.PlugPrivilege()
+
+	var id uint64
+	if node.Job != "" {
+		var err error
+		if id, err = strconv.ParseUint(node.Job, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	switch node.Action {
+	case sqlparser.DDLStatusStr:
+		return ddlJobs.Status(id)
+	}
+
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+	var err error
+	switch node.Action {
+	case sqlparser.DDLRetryStr:
+		err = ddlJobs.Retry(session, id)
+	default:
+		err = ddlJobs.Rollback(session, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqltypes.Result{}, nil
+}
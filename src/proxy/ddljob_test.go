@@ -0,0 +1,373 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+
+	"config"
+	"fakedb"
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// ddlJobShowCreateResult returns the SHOW CREATE TABLE result of the table t1.
+func ddlJobShowCreateResult(columns string) *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Create Table", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("CREATE TABLE `t1` (\n  `id` int(11) DEFAULT NULL,\n%s) ENGINE=InnoDB AUTO_INCREMENT=7 DEFAULT CHARSET=utf8", columns))),
+			},
+		},
+	}
+}
+
+func mockDDLJobQuerys(fakedbs *fakedb.DB) {
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("alter table .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("rename table .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("truncate table .*", &sqltypes.Result{})
+}
+
+// ddlJobStatus returns the status row of the job.
+func ddlJobStatus(t *testing.T, client driver.Conn, id string) []sqltypes.Value {
+	qr, err := client.FetchAll("radon ddl status", -1)
+	assert.Nil(t, err)
+	for _, row := range qr.Rows {
+		if row[0].ToString() == id {
+			return row
+		}
+	}
+	assert.Fail(t, "ddl.job["+id+"].not.found")
+	return nil
+}
+
+// ddlJobOtherSegment returns the first segment not on the backend of the first segment.
+func ddlJobOtherSegment(segments []router.Segment) router.Segment {
+	for _, segment := range segments {
+		if segment.Backend != segments[0].Backend {
+			return segment
+		}
+	}
+	return segments[0]
+}
+
+func TestProxyDDLJob(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	mockDDLJobQuerys(fakedbs)
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	segments, err := proxy.Router().Lookup("test", "t1", nil, nil)
+	assert.Nil(t, err)
+	first := segments[0]
+	other := ddlJobOtherSegment(segments)
+	var firstBackend int
+	for _, segment := range segments {
+		if segment.Backend == first.Backend {
+			firstBackend++
+		}
+	}
+
+	// The create table is done.
+	{
+		row := ddlJobStatus(t, client, "1")
+		assert.Equal(t, "test", row[1].ToString())
+		assert.Equal(t, "t1", row[2].ToString())
+		assert.Equal(t, "done", row[4].ToString())
+		assert.Equal(t, fmt.Sprintf("%d/%d", len(segments), len(segments)), row[5].ToString())
+		assert.True(t, row[7].IsNull())
+	}
+
+	// The alter fails on the first sub-table, the rest of the backend are pending.
+	{
+		fakedbs.AddQueryErrorPattern("alter table test.t1_0000 add column .*", errors.New("mock.alter.error"))
+		fakedbs.AddQueryPattern("show create table `test`.`t1_0000`", ddlJobShowCreateResult("  `b` int(11) DEFAULT NULL\n"))
+		fakedbs.AddQueryPattern("show create table .*", ddlJobShowCreateResult("  `b` int(11) DEFAULT NULL,\n  `c` int(11) DEFAULT NULL\n"))
+		_, err := client.FetchAll("alter table test.t1 add column(c int)", -1)
+		assert.Equal(t, "mock.alter.error (errno 1105) (sqlstate HY000)", err.Error())
+
+		row := ddlJobStatus(t, client, "2")
+		assert.Equal(t, "failed", row[4].ToString())
+		assert.Equal(t, fmt.Sprintf("%d/%d", len(segments)-firstBackend, len(segments)), row[5].ToString())
+		assert.Equal(t, "1", row[6].ToString())
+		assert.Equal(t, "1", row[7].ToString())
+		assert.Equal(t, "mock.alter.error (errno 1105) (sqlstate HY000)", row[9].ToString())
+
+		qr, err := client.FetchAll("radon ddl status 2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, len(segments), len(qr.Rows))
+		for i, row := range qr.Rows {
+			assert.Equal(t, segments[i].Backend, row[0].ToString())
+			assert.Equal(t, segments[i].Table, row[1].ToString())
+			switch {
+			case i == 0:
+				assert.Equal(t, "failed", row[3].ToString())
+				assert.Equal(t, "YES", row[4].ToString())
+				assert.Equal(t, "mock.alter.error (errno 1105) (sqlstate HY000)", row[5].ToString())
+			case segments[i].Backend == first.Backend:
+				assert.Equal(t, "pending", row[3].ToString())
+			default:
+				assert.Equal(t, "done", row[3].ToString())
+				assert.Equal(t, "NO", row[4].ToString())
+			}
+		}
+	}
+
+	// Retry.
+	{
+		_, err := client.FetchAll("radon ddl retry 2", -1)
+		assert.Equal(t, "mock.alter.error (errno 1105) (sqlstate HY000)", err.Error())
+		assert.Equal(t, "failed", ddlJobStatus(t, client, "2")[4].ToString())
+
+		fakedbs.ResetAll()
+		mockDDLJobQuerys(fakedbs)
+		fakedbs.AddQueryPattern("show create table .*", ddlJobShowCreateResult("  `b` int(11) DEFAULT NULL,\n  `c` int(11) DEFAULT NULL\n"))
+		failed := fakedbs.GetQueryCalledNum("alter table test.t1_0000 add column (\n\t`c` int\n)")
+		done := fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table test.%s add column (\n\t`c` int\n)", other.Table))
+		_, err = client.FetchAll("radon ddl retry 2", -1)
+		assert.Nil(t, err)
+		row := ddlJobStatus(t, client, "2")
+		assert.Equal(t, "done", row[4].ToString())
+		assert.Equal(t, "0", row[6].ToString())
+		assert.Equal(t, "0", row[7].ToString())
+		assert.Equal(t, "", row[9].ToString())
+		// Only the failed and pending sub-tables are altered.
+		assert.Equal(t, failed+1, fakedbs.GetQueryCalledNum("alter table test.t1_0000 add column (\n\t`c` int\n)"))
+		assert.Equal(t, done, fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table test.%s add column (\n\t`c` int\n)", other.Table)))
+
+		// The done job can't be retried.
+		_, err = client.FetchAll("radon ddl retry 2", -1)
+		assert.Equal(t, "ddl.job[2].can.not.be.resolved.in.state[done] (errno 1105) (sqlstate HY000)", err.Error())
+	}
+
+	// Rollback the drop column by the definition of the unchanged sub-table.
+	{
+		fakedbs.AddQueryErrorPattern("alter table test.t1_0000 drop column .*", errors.New("mock.alter.error"))
+		_, err := client.FetchAll("alter table test.t1 drop column b", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("radon ddl rollback 3", -1)
+		assert.Nil(t, err)
+		row := ddlJobStatus(t, client, "3")
+		assert.Equal(t, "rolledback", row[4].ToString())
+		assert.Equal(t, "0", row[7].ToString())
+		assert.Equal(t, "ddl.job[3].column[b].is.re-added.by.the.rollback.but.the.data.of.it.is.lost", row[9].ToString())
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table `test`.`%s` add column `b` int(11) default null after `id`", other.Table)))
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("alter table `test`.`t1_0000` add column `b` int(11) default null after `id`"))
+
+		qr, err := client.FetchAll("radon ddl status 3", -1)
+		assert.Nil(t, err)
+		for _, row := range qr.Rows {
+			if row[0].ToString() != first.Backend {
+				assert.Equal(t, "undone", row[3].ToString())
+			}
+		}
+		fakedbs.ResetPatternErrors()
+	}
+
+	// Rollback the create index.
+	{
+		fakedbs.AddQueryErrorPattern("create index idx_b on test.t1_0000", errors.New("mock.index.error"))
+		_, err := client.FetchAll("create index idx_b on test.t1(b)", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("radon ddl rollback 4", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("drop index idx_b on test.%s", other.Table)))
+		fakedbs.ResetPatternErrors()
+	}
+
+	// The truncate can't be rolled back.
+	{
+		fakedbs.AddQueryErrorPattern("truncate table test.t1_0000", errors.New("mock.truncate.error"))
+		_, err := client.FetchAll("truncate table test.t1", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("radon ddl rollback 5", -1)
+		assert.Equal(t, "unsupported: ddl.job[5].rollback.action[truncate table] (errno 1105) (sqlstate HY000)", err.Error())
+		assert.Equal(t, "failed", ddlJobStatus(t, client, "5")[4].ToString())
+		fakedbs.ResetPatternErrors()
+	}
+
+	// The router is renamed after the retry.
+	{
+		fakedbs.AddQueryErrorPattern("rename table test.t1_0000 .*", errors.New("mock.rename.error"))
+		_, err := client.FetchAll("alter table test.t1 rename to t2", -1)
+		assert.Equal(t, "mock.rename.error (errno 1105) (sqlstate HY000)", err.Error())
+		assert.True(t, checkTableExists("test", "t1", proxy.Router()))
+		fakedbs.ResetPatternErrors()
+
+		_, err = client.FetchAll("radon ddl retry 6", -1)
+		assert.Nil(t, err)
+		assert.False(t, checkTableExists("test", "t1", proxy.Router()))
+		assert.True(t, checkTableExists("test", "t2", proxy.Router()))
+	}
+
+	// Rollback the add unique key and the change column.
+	{
+		segments, err := proxy.Router().Lookup("test", "t2", nil, nil)
+		assert.Nil(t, err)
+		other := ddlJobOtherSegment(segments)
+		fakedbs.AddQueryErrorPattern(fmt.Sprintf("alter table test.%s .*", segments[0].Table), errors.New("mock.alter.error"))
+
+		_, err = client.FetchAll("alter table test.t2 add unique key uk_id_b(id, b)", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("radon ddl rollback 7", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table `test`.`%s` drop index `uk_id_b`", other.Table)))
+
+		_, err = client.FetchAll("alter table test.t2 change b b2 bigint", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("radon ddl rollback 8", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table test.%s change column `b2` `b` int(11) default null", other.Table)))
+		fakedbs.ResetPatternErrors()
+	}
+
+	// Rollback the multi-clause alter by the undos of the specifications in the reverse order.
+	{
+		segments, err := proxy.Router().Lookup("test", "t2", nil, nil)
+		assert.Nil(t, err)
+		other := ddlJobOtherSegment(segments)
+		fakedbs.AddQueryErrorPattern(fmt.Sprintf("alter table test.%s .*", segments[0].Table), errors.New("mock.alter.error"))
+
+		_, err = client.FetchAll("alter table test.t2 add column d int, modify column b bigint, drop column c", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("radon ddl rollback 9", -1)
+		assert.Nil(t, err)
+		row := ddlJobStatus(t, client, "9")
+		assert.Equal(t, "rolledback", row[4].ToString())
+		assert.Equal(t, "ddl.job[9].column[c].is.re-added.by.the.rollback.but.the.data.of.it.is.lost", row[9].ToString())
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table `test`.`%s` add column `c` int(11) default null after `b`", other.Table)))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table test.%s modify column `b` int(11) default null", other.Table)))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(fmt.Sprintf("alter table test.%s drop column `d`", other.Table)))
+		fakedbs.ResetPatternErrors()
+	}
+
+	// Errors.
+	{
+		sqls := []string{
+			"radon ddl status 100",
+			"radon ddl retry 100",
+			"radon ddl rollback 100",
+			"radon ddl rollback 1",
+		}
+		for _, sql := range sqls {
+			_, err := client.FetchAll(sql, -1)
+			assert.NotNil(t, err, sql)
+		}
+	}
+}
+
+func TestProxyDDLJobPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("radon ddl status", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("radon ddl retry 1", -1)
+	want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
+
+func TestProxyDDLJobPersist(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	mockDDLJobQuerys(fakedbs)
+	fakedbs.AddQueryPattern("show create table .*", ddlJobShowCreateResult("  `b` int(11) DEFAULT NULL\n"))
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	fakedbs.AddQueryErrorPattern("alter table test.t1_0000 .*", errors.New("mock.alter.error"))
+	_, err = client.FetchAll("alter table test.t1 add column(c int)", -1)
+	assert.NotNil(t, err)
+
+	// The jobs are loaded after the restart, the running job of this peer is failed.
+	conf := proxy.Config()
+	jobs := proxy.spanner.ddlJobs
+	jobs.mu.Lock()
+	persisted := []*ddlJobJSON{jobs.jobs[0].toJSON(), jobs.jobs[1].toJSON()}
+	jobs.mu.Unlock()
+	persisted[1].State = ddlJobStateRunning
+	assert.Nil(t, config.WriteConfig(path.Join(conf.Proxy.MetaDir, ddlJobsJSONFile), persisted))
+	restarted := NewDDLJobs(log, proxy.spanner)
+	assert.Nil(t, restarted.Init())
+	assert.Equal(t, uint64(2), restarted.seq)
+	job := restarted.jobs[1]
+	assert.Equal(t, ddlJobStateFailed, job.state)
+	assert.Equal(t, "ddl.job[2].interrupted.by.restart", job.err.Error())
+	assert.Equal(t, sqlparser.AlterAddColumnStr, job.node.Action)
+	assert.Equal(t, ddlSegmentStateFailed, job.segments[0].state)
+	assert.Equal(t, "mock.alter.error (errno 1105) (sqlstate HY000)", job.segments[0].err.Error())
+
+	// The jobs of the peers are reloaded by the meta version.
+	persisted = []*ddlJobJSON{job.toJSON()}
+	persisted[0].Owner = "127.0.0.1:1"
+	assert.Nil(t, config.WriteConfig(path.Join(conf.Proxy.MetaDir, ddlJobsJSONFile), persisted))
+	assert.Nil(t, config.UpdateVersion(conf.Proxy.MetaDir))
+	qr, err := client.FetchAll("radon ddl status", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(qr.Rows))
+	assert.Equal(t, "failed", qr.Rows[0][4].ToString())
+
+	// The job is resolved by this peer.
+	fakedbs.ResetPatternErrors()
+	_, err = client.FetchAll("radon ddl retry 2", -1)
+	assert.Nil(t, err)
+	restarted = NewDDLJobs(log, proxy.spanner)
+	assert.Nil(t, restarted.Init())
+	assert.Equal(t, ddlJobStateDone, restarted.jobs[0].state)
+	assert.Equal(t, conf.Proxy.PeerAddress, restarted.jobs[0].owner)
+}
@@ -0,0 +1,298 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// The latency histogram buckets of the digest, the upper bound of the bucket i is 1.2^i microseconds,
+	// the last bucket is about 3.5 hours.
+	digestBuckets    = 128
+	digestBucketBase = 1.2
+)
+
+// digestFormatter formats the literals as '?' and collapses the value lists, so the statements
+// only differ in the literals have the same digest text.
+func digestFormatter(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+	switch node := node.(type) {
+	case *sqlparser.SQLVal, sqlparser.BoolVal:
+		buf.WriteString("?")
+	case sqlparser.ValTuple:
+		for _, expr := range node {
+			switch expr.(type) {
+			case *sqlparser.SQLVal, sqlparser.BoolVal, *sqlparser.NullVal:
+			default:
+				node.Format(buf)
+				return
+			}
+		}
+		buf.WriteString("(...)")
+	case sqlparser.Values:
+		buf.WriteString("values (...)")
+	default:
+		node.Format(buf)
+	}
+}
+
+// digestText returns the normalized statement and its digest.
+func digestText(node sqlparser.SQLNode) (string, string) {
+	buf := sqlparser.NewTrackedBuffer(digestFormatter)
+	buf.Myprintf("%v", node)
+	text := buf.String()
+	sum := md5.Sum([]byte(text))
+	return hex.EncodeToString(sum[:]), text
+}
+
+// fanout returns the number of the distinct shard querys executed by the statement.
+func fanout(tuples []xcontext.QueryTuple) int {
+	if len(tuples) < 2 {
+		return len(tuples)
+	}
+	type shardQuery struct {
+		backend string
+		query   string
+	}
+	seen := make(map[shardQuery]bool, len(tuples))
+	for _, tuple := range tuples {
+		seen[shardQuery{backend: tuple.Backend, query: tuple.Query}] = true
+	}
+	return len(seen)
+}
+
+// DigestInfo tuple, the stats of the statements with the same digest in the schema.
+type DigestInfo struct {
+	Schema     string        `json:"schema"`
+	Digest     string        `json:"digest"`
+	DigestText string        `json:"digest_text"`
+	Count      uint64        `json:"count"`
+	SumLatency time.Duration `json:"sum_latency"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	MaxLatency time.Duration `json:"max_latency"`
+	P99Latency time.Duration `json:"p99_latency"`
+	RowsSent   uint64        `json:"rows_sent"`
+	Errors     uint64        `json:"errors"`
+	SumShards  uint64        `json:"sum_shards"`
+	MaxShards  int           `json:"max_shards"`
+	FirstSeen  time.Time     `json:"first_seen"`
+	LastSeen   time.Time     `json:"last_seen"`
+}
+
+type digestStat struct {
+	info    DigestInfo
+	buckets [digestBuckets]uint64
+}
+
+func bucketOf(latency time.Duration) int {
+	us := float64(latency) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(us) / math.Log(digestBucketBase)))
+	if i >= digestBuckets {
+		i = digestBuckets - 1
+	}
+	return i
+}
+
+// p99 returns the upper bound of the bucket which the 99th percentile falls in.
+func (s *digestStat) p99() time.Duration {
+	want := uint64(math.Ceil(float64(s.info.Count) * 0.99))
+	var n uint64
+	for i, c := range s.buckets {
+		n += c
+		if n >= want {
+			d := time.Duration(math.Pow(digestBucketBase, float64(i)) * float64(time.Microsecond))
+			if d > s.info.MaxLatency {
+				d = s.info.MaxLatency
+			}
+			return d
+		}
+	}
+	return s.info.MaxLatency
+}
+
+// Digests tuple, the statements summary by digest like the performance_schema.events_statements_summary_by_digest.
+// At most size digests are kept, the others are summarized into the digest with empty text.
+type Digests struct {
+	mu       sync.Mutex
+	size     int
+	stats    map[string]*digestStat
+	overflow *digestStat
+}
+
+// NewDigests creates the new Digests, the size 0 means disabled.
+func NewDigests(size int) *Digests {
+	return &Digests{
+		size:  size,
+		stats: make(map[string]*digestStat),
+	}
+}
+
+// Enabled returns true if the digests are enabled.
+func (d *Digests) Enabled() bool {
+	return d.size > 0
+}
+
+// Record used to summarize the statement into its digest.
+func (d *Digests) Record(schema string, node sqlparser.Statement, latency time.Duration, rowsSent uint64, err error, shards int) {
+	if !d.Enabled() || node == nil {
+		return
+	}
+	digest, text := digestText(node)
+	key := schema + "/" + digest
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stat, ok := d.stats[key]
+	if !ok {
+		if len(d.stats) < d.size {
+			stat = &digestStat{info: DigestInfo{Schema: schema, Digest: digest, DigestText: text, FirstSeen: now}}
+			d.stats[key] = stat
+		} else {
+			if d.overflow == nil {
+				d.overflow = &digestStat{info: DigestInfo{FirstSeen: now}}
+			}
+			stat = d.overflow
+		}
+	}
+
+	info := &stat.info
+	info.Count++
+	info.SumLatency += latency
+	if latency > info.MaxLatency {
+		info.MaxLatency = latency
+	}
+	info.RowsSent += rowsSent
+	if err != nil {
+		info.Errors++
+	}
+	info.SumShards += uint64(shards)
+	if shards > info.MaxShards {
+		info.MaxShards = shards
+	}
+	info.LastSeen = now
+	stat.buckets[bucketOf(latency)]++
+}
+
+// Rows returns the digests order by the sum latency desc.
+func (d *Digests) Rows() []DigestInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rows := make([]DigestInfo, 0, len(d.stats)+1)
+	stats := make([]*digestStat, 0, len(d.stats)+1)
+	for _, stat := range d.stats {
+		stats = append(stats, stat)
+	}
+	if d.overflow != nil {
+		stats = append(stats, d.overflow)
+	}
+	for _, stat := range stats {
+		info := stat.info
+		info.AvgLatency = info.SumLatency / time.Duration(info.Count)
+		info.P99Latency = stat.p99()
+		rows = append(rows, info)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].SumLatency > rows[j].SumLatency
+	})
+	return rows
+}
+
+// Reset used to clear all the digests.
+func (d *Digests) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stats = make(map[string]*digestStat)
+	d.overflow = nil
+}
+
+// Digests returns the query digests.
+func (spanner *Spanner) Digests() *Digests {
+	return spanner.digests
+}
+
+// queryDigest used to summarize the statement into the query digests.
+func (spanner *Spanner) queryDigest(session *driver.Session, node sqlparser.Statement, timeStart time.Time, qr *sqltypes.Result, err error) {
+	if !spanner.digests.Enabled() {
+		return
+	}
+	rows := uint64(0)
+	if qr != nil {
+		rows = uint64(len(qr.Rows))
+	}
+	_, tuples := spanner.sessions.QueryInfo(session)
+	spanner.digests.Record(session.Schema(), node, time.Since(timeStart), rows, err, fanout(tuples))
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.6f", d.Seconds())
+}
+
+// handleShowQueryDigests used to handle the query "SHOW QUERY DIGESTS".
+func (spanner *Spanner) handleShowQueryDigests(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Schema", Type: querypb.Type_VARCHAR},
+		{Name: "Digest", Type: querypb.Type_VARCHAR},
+		{Name: "Digest_text", Type: querypb.Type_VARCHAR},
+		{Name: "Count", Type: querypb.Type_UINT64},
+		{Name: "Sum_latency", Type: querypb.Type_DECIMAL},
+		{Name: "Avg_latency", Type: querypb.Type_DECIMAL},
+		{Name: "Max_latency", Type: querypb.Type_DECIMAL},
+		{Name: "P99_latency", Type: querypb.Type_DECIMAL},
+		{Name: "Rows_sent", Type: querypb.Type_UINT64},
+		{Name: "Errors", Type: querypb.Type_UINT64},
+		{Name: "Sum_shards", Type: querypb.Type_UINT64},
+		{Name: "Max_shards", Type: querypb.Type_INT64},
+		{Name: "First_seen", Type: querypb.Type_VARCHAR},
+		{Name: "Last_seen", Type: querypb.Type_VARCHAR},
+	}
+	for _, info := range spanner.digests.Rows() {
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(info.Schema)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(info.Digest)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(info.DigestText)),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", info.Count))),
+			sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte(formatSeconds(info.SumLatency))),
+			sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte(formatSeconds(info.AvgLatency))),
+			sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte(formatSeconds(info.MaxLatency))),
+			sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte(formatSeconds(info.P99Latency))),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", info.RowsSent))),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", info.Errors))),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", info.SumShards))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", info.MaxShards))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(info.FirstSeen.Format("20060102150405.000"))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(info.LastSeen.Format("20060102150405.000"))),
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	return qr, nil
+}
@@ -0,0 +1,213 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyDigestText(t *testing.T) {
+	querys := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "select * from t1 where id=1 and name='x'",
+			want:  "select * from t1 where id = ? and name = ?",
+		},
+		{
+			query: "select a, 3 from t1 where id in (1, 2, 3) limit 10",
+			want:  "select a, ? from t1 where id in (...) limit ?",
+		},
+		{
+			query: "select * from t1 where (a, b) in ((1, 2), (3, 4)) and c=true",
+			want:  "select * from t1 where (a, b) in ((...), (...)) and c = ?",
+		},
+		{
+			query: "insert into t1(id, b) values(1,2),(3,4)",
+			want:  "insert into t1(id, b) values (...)",
+		},
+		{
+			query: "update t1 set b=b+1 where id=2",
+			want:  "update t1 set b = b + ? where id = ?",
+		},
+	}
+
+	digests := make(map[string]bool)
+	for _, q := range querys {
+		node, err := sqlparser.Parse(q.query)
+		assert.Nil(t, err)
+		digest, text := digestText(node)
+		assert.Equal(t, q.want, text)
+		assert.Equal(t, 32, len(digest))
+		digests[digest] = true
+	}
+	assert.Equal(t, len(querys), len(digests))
+
+	// Only the literals are different.
+	{
+		node1, _ := sqlparser.Parse("select * from t1 where id=1")
+		node2, _ := sqlparser.Parse("select * from t1 where id='abc'")
+		digest1, _ := digestText(node1)
+		digest2, _ := digestText(node2)
+		assert.Equal(t, digest1, digest2)
+	}
+}
+
+func TestProxyDigestFanout(t *testing.T) {
+	tuples := []xcontext.QueryTuple{
+		{Backend: "b1", Query: "select 1", Range: "[0-64)"},
+		{Backend: "b1", Query: "select 1", Range: "[0-64)"},
+		{Backend: "b2", Query: "select 1"},
+		{Backend: "b2", Query: "select 2"},
+	}
+	assert.Equal(t, 0, fanout(nil))
+	assert.Equal(t, 1, fanout(tuples[:1]))
+	assert.Equal(t, 3, fanout(tuples))
+}
+
+func TestProxyDigestsRecord(t *testing.T) {
+	digests := NewDigests(2)
+	node1, _ := sqlparser.Parse("select * from t1 where id=1")
+	node2, _ := sqlparser.Parse("select * from t2 where id=1")
+	node3, _ := sqlparser.Parse("select * from t3 where id=1")
+
+	for i := 1; i <= 100; i++ {
+		digests.Record("db", node1, time.Duration(i)*time.Millisecond, 2, nil, 1)
+	}
+	digests.Record("db", node2, time.Second, 0, errors.New("mock"), 4)
+	digests.Record("db", node2, 3*time.Second, 1, nil, 2)
+	// Overflow.
+	digests.Record("db", node3, time.Millisecond, 1, nil, 1)
+	digests.Record("db1", node1, time.Millisecond, 1, nil, 1)
+
+	rows := digests.Rows()
+	assert.Equal(t, 3, len(rows))
+
+	// Order by sum latency desc.
+	{
+		row := rows[0]
+		assert.Equal(t, "db", row.Schema)
+		assert.Equal(t, uint64(100), row.Count)
+		assert.Equal(t, 5050*time.Millisecond, row.SumLatency)
+		assert.Equal(t, 50500*time.Microsecond, row.AvgLatency)
+		assert.Equal(t, 100*time.Millisecond, row.MaxLatency)
+		assert.True(t, row.P99Latency >= 99*time.Millisecond, row.P99Latency)
+		assert.True(t, row.P99Latency <= 100*time.Millisecond, row.P99Latency)
+		assert.Equal(t, uint64(200), row.RowsSent)
+		assert.Equal(t, uint64(0), row.Errors)
+		assert.Equal(t, uint64(100), row.SumShards)
+		assert.Equal(t, 1, row.MaxShards)
+	}
+	{
+		row := rows[1]
+		assert.Equal(t, "select * from t2 where id = ?", row.DigestText)
+		assert.Equal(t, uint64(2), row.Count)
+		assert.Equal(t, 3*time.Second, row.MaxLatency)
+		assert.Equal(t, 3*time.Second, row.P99Latency)
+		assert.Equal(t, uint64(1), row.Errors)
+		assert.Equal(t, uint64(6), row.SumShards)
+		assert.Equal(t, 4, row.MaxShards)
+	}
+	{
+		row := rows[2]
+		assert.Equal(t, "", row.Digest)
+		assert.Equal(t, "", row.DigestText)
+		assert.Equal(t, uint64(2), row.Count)
+	}
+
+	digests.Reset()
+	assert.Equal(t, 0, len(digests.Rows()))
+
+	// Disabled.
+	{
+		digests := NewDigests(0)
+		digests.Record("db", node1, time.Millisecond, 1, nil, 1)
+		assert.Equal(t, 0, len(digests.Rows()))
+	}
+}
+
+func TestProxyShowQueryDigests(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"use test",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	proxy.Spanner().Digests().Reset()
+
+	for i := 0; i < 3; i++ {
+		_, err = client.FetchAll(fmt.Sprintf("insert into t1(id, b) values(%d,1),(%d,2)", i, i+100), -1)
+		assert.Nil(t, err)
+	}
+
+	qr, err := client.FetchAll("show query digests", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 14, len(qr.Fields))
+	assert.Equal(t, 1, len(qr.Rows))
+	row := qr.Rows[0]
+	assert.Equal(t, "test", row[0].String())
+	assert.Equal(t, "insert into t1(id, b) values (...)", row[2].String())
+	assert.Equal(t, "3", row[3].String())
+	assert.Equal(t, "0", row[9].String())
+	assert.Equal(t, "2", row[11].String())
+
+	// The show itself is summarized too.
+	qr, err = client.FetchAll("show query digests", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(qr.Rows))
+}
+
+func TestProxyShowQueryDigestsPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("show query digests", -1)
+	want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
@@ -10,7 +10,6 @@ package proxy
 
 import (
 	"executor"
-	"optimizer"
 	"planner"
 	"planner/builder"
 	"xcontext"
@@ -24,17 +23,20 @@ import (
 // ExecuteMultiStmtsInTxn used to execute multiple statements in the transaction.
 func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
-	router := spanner.router
 	sessions := spanner.sessions
 	txSession := sessions.getTxnSession(session)
 
 	sessions.MultiStmtTxnBinding(session, nil, node, query)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	profile := sessions.getProfile(session)
+	txSession.transaction.SetProfile(profile)
+	plans, bindVars, err := spanner.buildPlanTree(profile, database, query, node)
 	if err != nil {
 		return nil, err
 	}
 	executors := executor.NewTree(log, plans, txSession.transaction)
+	executors.SetProfile(profile)
+	executors.SetBindVars(bindVars)
 	qr, err := executors.Execute()
 	if err != nil {
 		// need the user to rollback
@@ -49,7 +51,6 @@ func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database
 func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
-	router := spanner.router
 	scatter := spanner.scatter
 	sessions := spanner.sessions
 
@@ -62,14 +63,20 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	defer txn.Finish()
 
 	// txn limits.
-	txn.SetTimeout(conf.Proxy.QueryTimeout)
-	txn.SetMaxResult(conf.Proxy.MaxResultSize)
+	timeout, maxResult := spanner.sessionLimits(session, queryDatabases(node, session.Schema()), conf.Proxy.QueryTimeout, conf.Proxy.MaxResultSize)
+	txn.SetTimeout(timeout)
+	txn.SetMaxResult(maxResult)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetRecordQuerys(spanner.recordQuerys())
+	txn.SetCharacteristics(sessions.takeTxnCharacteristics(session, nil))
 
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
 	defer sessions.TxnUnBinding(session)
 
+	profile := sessions.getProfile(session)
+	txn.SetProfile(profile)
+
 	// Transaction begin.
 	if err := txn.Begin(); err != nil {
 		log.Error("spanner.execute.2pc.txn.begin.error:[%v]", err)
@@ -77,12 +84,14 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	}
 
 	// Transaction execute.
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, bindVars, err := spanner.buildPlanTree(profile, database, query, node)
 	if err != nil {
 		return nil, err
 	}
 
 	executors := executor.NewTree(log, plans, txn)
+	executors.SetProfile(profile)
+	executors.SetBindVars(bindVars)
 	qr, err := executors.Execute()
 	if err != nil {
 		if x := txn.Rollback(); x != nil {
@@ -101,20 +110,7 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 
 // ExecuteNormal used to execute non-2pc querys to shards with QueryTimeout limits.
 func (spanner *Spanner) ExecuteNormal(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
-	timeout := spanner.conf.Proxy.QueryTimeout
-	return spanner.executeWithTimeout(session, database, query, node, timeout)
-}
-
-// ExecuteDDL used to execute ddl querys to the shards with DDLTimeout limits, used for create/drop index long time operation.
-func (spanner *Spanner) ExecuteDDL(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
-	spanner.log.Info("spanner.execute.ddl.query:%s", query)
-	timeout := spanner.conf.Proxy.DDLTimeout
-
-	txSession := spanner.sessions.getTxnSession(session)
-	if spanner.isTwoPC() && txSession.transaction != nil {
-		return nil, errors.Errorf("in.multiStmtTrans.unsupported.DDL:%v.", query)
-	}
-
+	timeout, _ := spanner.sessionLimits(session, queryDatabases(node, session.Schema()), spanner.conf.Proxy.QueryTimeout, 0)
 	return spanner.executeWithTimeout(session, database, query, node, timeout)
 }
 
@@ -125,7 +121,6 @@ func (spanner *Spanner) ExecuteDDL(session *driver.Session, database string, que
 func (spanner *Spanner) executeWithTimeout(session *driver.Session, database string, query string, node sqlparser.Statement, timeout int) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
-	router := spanner.router
 	scatter := spanner.scatter
 	sessions := spanner.sessions
 
@@ -138,19 +133,27 @@ func (spanner *Spanner) executeWithTimeout(session *driver.Session, database str
 	defer txn.Finish()
 
 	// txn limits.
+	_, maxResult := spanner.sessionLimits(session, queryDatabases(node, session.Schema()), 0, conf.Proxy.MaxResultSize)
 	txn.SetTimeout(timeout)
-	txn.SetMaxResult(conf.Proxy.MaxResultSize)
+	txn.SetMaxResult(maxResult)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetRecordQuerys(spanner.recordQuerys())
+	// The autocommit statement is a transaction too, the READ ONLY refuses the writes.
+	txn.SetCharacteristics(sessions.takeTxnCharacteristics(session, nil))
 
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
 	defer sessions.TxnUnBinding(session)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	profile := sessions.getProfile(session)
+	txn.SetProfile(profile)
+	plans, bindVars, err := spanner.buildPlanTree(profile, database, query, node)
 	if err != nil {
 		return nil, err
 	}
 	executors := executor.NewTree(log, plans, txn)
+	executors.SetProfile(profile)
+	executors.SetBindVars(bindVars)
 	qr, err := executors.Execute()
 	if err != nil {
 		return nil, err
@@ -172,6 +175,7 @@ func (spanner *Spanner) ExecuteStreamFetch(session *driver.Session, database str
 		return err
 	}
 	defer txn.Finish()
+	txn.SetRecordQuerys(spanner.recordQuerys())
 
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
@@ -182,6 +186,13 @@ func (spanner *Spanner) ExecuteStreamFetch(session *driver.Session, database str
 		return errors.New("ExecuteStreamFetch.only.support.select")
 	}
 
+	// The snapshot makes the rows fetched from all the shards a point-in-time image.
+	if sessions.getTxnSession(session).getStreamingSnapshotVar() {
+		if _, err := txn.BeginSnapshot(); err != nil {
+			return err
+		}
+	}
+
 	plan := planner.NewSelectPlan(log, database, query, selectNode, router)
 	if err := plan.Build(); err != nil {
 		return err
@@ -205,16 +216,18 @@ func (spanner *Spanner) ExecuteDML(session *driver.Session, database string, que
 		return nil, err
 	}
 
-	if spanner.isTwoPC() {
-		txSession := spanner.sessions.getTxnSession(session)
-		if spanner.IsDML(node) {
-			if txSession.transaction == nil {
-				return spanner.ExecuteSingleStmtTxnTwoPC(session, database, query, node)
-			} else {
-				return spanner.ExecuteMultiStmtsInTxn(session, database, query, node)
-			}
+	if spanner.IsDML(node) {
+		// The session is in a transaction, or autocommit=0 to begin one implicitly.
+		txn, err := spanner.implicitBegin(session)
+		if err != nil {
+			return nil, err
+		}
+		if txn != nil {
+			return spanner.ExecuteMultiStmtsInTxn(session, database, query, node)
+		}
+		if spanner.isTwoPC() {
+			return spanner.ExecuteSingleStmtTxnTwoPC(session, database, query, node)
 		}
-		return spanner.ExecuteNormal(session, database, query, node)
 	}
 	return spanner.ExecuteNormal(session, database, query, node)
 }
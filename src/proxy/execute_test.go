@@ -359,7 +359,7 @@ func TestProxyExecuteStreamFetch(t *testing.T) {
 	}
 }
 
-func TestProxyExecuteMultiStmtTxnDDLError(t *testing.T) {
+func TestProxyExecuteMultiStmtTxnDDLImplicitCommit(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
 	defer cleanup()
@@ -383,7 +383,7 @@ func TestProxyExecuteMultiStmtTxnDDLError(t *testing.T) {
 		assert.Nil(t, err)
 	}
 
-	//begin && create test table.
+	//begin && create test table, the DDL commits the txn implicitly.
 	{
 		proxy.conf.Proxy.TwopcEnable = true
 		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
@@ -394,7 +394,10 @@ func TestProxyExecuteMultiStmtTxnDDLError(t *testing.T) {
 
 		query1 := "create table test.t1(id int, b int) partition by hash(id)"
 		_, err = client.FetchAll(query1, -1)
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
+
+		txSession := proxy.Spanner().sessions.getSession(client.ConnectionID())
+		assert.Nil(t, txSession.transaction)
 
 		query2 := "rollback;"
 		_, err = client.FetchAll(query2, -1)
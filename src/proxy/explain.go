@@ -11,6 +11,7 @@ package proxy
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"optimizer"
 
@@ -22,6 +23,44 @@ import (
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
+const (
+	explainFormatTraditional = "TRADITIONAL"
+	explainFormatJSON        = "JSON"
+	explainFormatTree        = "TREE"
+)
+
+var (
+	explainOptionsReg = regexp.MustCompile(`(?i)^\s+(analyze\s+)?(format\s*=\s*(\w+)\s+)?`)
+)
+
+// parseExplainOptions used to parse the 'ANALYZE' and 'FORMAT=xx' options after the EXPLAIN,
+// returns the query without options.
+func parseExplainOptions(query string) (bool, string, string, error) {
+	analyze := false
+	format := explainFormatTraditional
+	match := explainOptionsReg.FindStringSubmatchIndex(query)
+	if match == nil || (match[2] < 0 && match[4] < 0) {
+		return analyze, format, query, nil
+	}
+
+	if match[2] >= 0 {
+		analyze = true
+		format = explainFormatTree
+	}
+	if match[6] >= 0 {
+		format = strings.ToUpper(query[match[6]:match[7]])
+		switch format {
+		case explainFormatTraditional, explainFormatJSON, explainFormatTree:
+		default:
+			return false, "", "", errors.Errorf("unknown.explain.format.name:'%s'", format)
+		}
+		if analyze && format != explainFormatTree {
+			return false, "", "", errors.Errorf("explain.analyze.only.supports.format.tree")
+		}
+	}
+	return analyze, format, query[match[1]:], nil
+}
+
 // handleExplain used to handle the EXPLAIN command.
 func (spanner *Spanner) handleExplain(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
@@ -38,7 +77,10 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 	if len(idx) != 2 {
 		return nil, errors.Errorf("explain.query[%s].syntax.error", query)
 	}
-	cutQuery := query[idx[1]:]
+	analyze, format, cutQuery, err := parseExplainOptions(query[idx[1]:])
+	if err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
 	subNode, err := sqlparser.Parse(cutQuery)
 	if err != nil {
 		msg := fmt.Sprintf("query[%s].parser.error: %v", cutQuery, err)
@@ -83,6 +125,33 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 		return qr, nil
 	}
 
+	if analyze {
+		// Only the read-only querys can be executed by the EXPLAIN ANALYZE.
+		switch subNode.(type) {
+		case *sqlparser.Select, *sqlparser.Union:
+		default:
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain analyze only supports SELECT/UNION")
+		}
+		profile, err := spanner.explainAnalyze(session, cutQuery, subNode, planTree)
+		if err != nil {
+			log.Error("proxy.explain.analyze.error:%+v", err)
+			return nil, err
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(explainTree(planTree, profile))),
+		}
+		qr.Rows = append(qr.Rows, row)
+		return qr, nil
+	}
+
+	if format == explainFormatTree {
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(explainTree(planTree, nil))),
+		}
+		qr.Rows = append(qr.Rows, row)
+		return qr, nil
+	}
+
 	if len(planTree.Plans()) > 0 {
 		msg := planTree.Plans()[0].JSON()
 		row := []sqltypes.Value{
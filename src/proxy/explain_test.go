@@ -9,10 +9,13 @@
 package proxy
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -315,3 +318,164 @@ func TestProxyExplainPrivilege(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestProxyExplainTree(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "b", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("11")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("12")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", result)
+		fakedbs.AddQuery("BEGIN", &sqltypes.Result{})
+		fakedbs.AddQuery("COMMIT", &sqltypes.Result{})
+	}
+
+	// create database and tables.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		querys := []string{
+			"create database test",
+			"create table test.t1(id int, b int) partition by hash(id)",
+			"create table test.t2(id int, b int) partition by hash(id)",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// format=tree.
+	{
+		query := "explain format=tree select t1.id, t1.b from t1 left join t2 on t1.b=t2.b where t1.id=1 and t2.id=2 order by t1.id desc limit 1"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		want := `-> Limit: 1 offset 0
+    -> Sort: t1.id DESC
+        -> Left join (sort merge)
+            -> Merge: 1 route(s)
+                -> Route: backend2 [2278-2457): select t1.id, t1.b from test.t1_0017 as t1 where t1.id = 1 order by t1.b asc
+            -> Merge: 1 route(s)
+                -> Route: backend4 [3916-4096): select t2.b from test.t2_0029 as t2 where t2.id = 2 order by t2.b asc`
+		got := string(qr.Rows[0][0].Raw())
+		assert.Equal(t, want, got)
+	}
+
+	// format=traditional.
+	{
+		query := "EXPLAIN FORMAT = traditional select * from t1 where id=1"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		got := string(qr.Rows[0][0].Raw())
+		assert.True(t, strings.HasPrefix(got, `{
+	"RawQuery": "select * from t1 where id=1"`))
+	}
+
+	// analyze.
+	{
+		query := "explain analyze select t1.id, t1.b from t1 left join t2 on t1.b=t2.b where t1.id=1 and t2.id=2 order by t1.id desc limit 1"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		got := string(qr.Rows[0][0].Raw())
+		lines := strings.Split(got, "\n")
+		assert.Equal(t, 10, len(lines))
+		assert.Regexp(t, `^-> Limit: 1 offset 0 \(actual time=\S+ms rows=0 loops=1\)$`, lines[0])
+		assert.Regexp(t, `^    -> Sort: t1.id DESC \(actual time=\S+ms rows=0 loops=1\)$`, lines[1])
+		assert.Regexp(t, `^        -> Left join \(sort merge\) \(actual time=\S+ms rows=0 loops=1\)$`, lines[2])
+		assert.Regexp(t, `^            -> Merge: 1 route\(s\) \(actual time=\S+ms rows=2 loops=1\)$`, lines[3])
+		assert.Regexp(t, `^            -> Merge: 1 route\(s\) \(actual time=\S+ms rows=2 loops=1\)$`, lines[5])
+		assert.Equal(t, "Backend querys:", lines[7])
+		for _, line := range lines[8:] {
+			assert.Regexp(t, `^-> backend[24]: select .* \(actual time=\S+ms rows=2 bytes=6\)$`, line)
+		}
+	}
+
+	// analyze in the transaction.
+	{
+		querys := []string{
+			"begin",
+			"explain analyze select * from t1 where id=1",
+			"commit",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("BEGIN"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("COMMIT"))
+	}
+}
+
+func TestProxyExplainTreeError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryErrorPattern("select .*", errors.New("mock.select.error"))
+	}
+
+	// create database and tables.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		querys := []string{
+			"create database test",
+			"create table test.t1(id int, b int) partition by hash(id)",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	querys := []struct {
+		query string
+		err   string
+	}{
+		{"explain format=xx select * from t1", "unknown.explain.format.name:'XX'"},
+		{"explain analyze format=json select * from t1", "explain.analyze.only.supports.format.tree"},
+		{"explain analyze delete from t1 where id=1", "explain analyze only supports SELECT/UNION"},
+		{"explain analyze select * from t1 where id=1", "mock.select.error"},
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query.query, -1)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), query.err)
+	}
+}
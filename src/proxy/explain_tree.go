@@ -0,0 +1,219 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend"
+	"executor"
+	"planner"
+	"planner/builder"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// explainAnalyze used to execute the plans and collect the execution stats.
+// If the session is in a transaction, the plans are executed in it, so the uncommitted changes are seen.
+func (spanner *Spanner) explainAnalyze(session *driver.Session, query string, node sqlparser.Statement, planTree *planner.PlanTree) (*xcontext.Profile, error) {
+	log := spanner.log
+	conf := spanner.conf
+	sessions := spanner.sessions
+
+	var txn backend.Transaction
+	sessionTxn, err := spanner.implicitBegin(session)
+	if err != nil {
+		return nil, err
+	}
+	if sessionTxn != nil {
+		txn = sessionTxn
+		sessions.MultiStmtTxnBinding(session, nil, node, query)
+	} else {
+		if txn, err = spanner.scatter.CreateTransaction(); err != nil {
+			log.Error("spanner.txn.create.error:[%v]", err)
+			return nil, err
+		}
+		defer txn.Finish()
+
+		timeout, maxResult := spanner.sessionLimits(session, queryDatabases(node, session.Schema()), conf.Proxy.QueryTimeout, conf.Proxy.MaxResultSize)
+		txn.SetTimeout(timeout)
+		txn.SetMaxResult(maxResult)
+		txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+
+		// binding, so the query can be killed.
+		sessions.TxnBinding(session, txn, node, query)
+		defer sessions.TxnUnBinding(session)
+	}
+
+	profile := xcontext.NewAnalyzeProfile()
+	txn.SetProfile(profile)
+	executors := executor.NewTree(log, planTree, txn)
+	executors.SetProfile(profile)
+	if _, err := executors.Execute(); err != nil {
+		return nil, err
+	}
+	if sessionTxn != nil {
+		sessions.MultiStmtTxnUnBinding(session, false)
+	}
+	return profile, nil
+}
+
+// treeWriter used to format the plans as a tree, like the EXPLAIN FORMAT=TREE of MySQL 8.0.
+// If the profile is not nil, the actual execution stats will be attached to each node.
+type treeWriter struct {
+	buf     *bytes.Buffer
+	profile *xcontext.Profile
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
+
+// line used to write one node, the key is used to find the stats from the profile.
+func (w *treeWriter) line(depth int, key interface{}, format string, args ...interface{}) {
+	fmt.Fprintf(w.buf, "%s-> %s", strings.Repeat("    ", depth), fmt.Sprintf(format, args...))
+	if w.profile != nil && key != nil {
+		if op := w.profile.Operator(key); op != nil {
+			fmt.Fprintf(w.buf, " (actual time=%s rows=%d loops=%d)", formatDuration(op.Duration), op.Rows, op.Loops)
+		} else {
+			w.buf.WriteString(" (never executed)")
+		}
+	}
+	w.buf.WriteString("\n")
+}
+
+func (w *treeWriter) routes(depth int, querys []xcontext.QueryTuple) {
+	for _, tuple := range querys {
+		if tuple.Range != "" {
+			w.line(depth, nil, "Route: %s %s: %s", tuple.Backend, tuple.Range, tuple.Query)
+		} else {
+			w.line(depth, nil, "Route: %s: %s", tuple.Backend, tuple.Query)
+		}
+	}
+}
+
+// childPlan returns the description of the child plan.
+func childPlan(plan builder.ChildPlan) string {
+	switch plan := plan.(type) {
+	case *builder.AggregatePlan:
+		var aggrs, groups []string
+		for _, aggr := range plan.NormalAggregators() {
+			aggrs = append(aggrs, aggr.Field)
+		}
+		for _, aggr := range plan.GroupAggregators() {
+			groups = append(groups, aggr.Field)
+		}
+		desc := fmt.Sprintf("Aggregate: %s", strings.Join(aggrs, ", "))
+		if len(groups) > 0 {
+			desc += fmt.Sprintf(" group by: %s", strings.Join(groups, ", "))
+		}
+		return desc
+	case *builder.OrderByPlan:
+		var orders []string
+		for _, order := range plan.OrderBys {
+			field := order.Field
+			if order.Table != "" {
+				field = order.Table + "." + field
+			}
+			orders = append(orders, fmt.Sprintf("%s %s", field, order.Direction))
+		}
+		return fmt.Sprintf("Sort: %s", strings.Join(orders, ", "))
+	case *builder.LimitPlan:
+		return fmt.Sprintf("Limit: %d offset %d", plan.Limit, plan.Offset)
+	}
+	return string(plan.Type())
+}
+
+func joinStrategy(strategy builder.JoinStrategy) string {
+	switch strategy {
+	case builder.SortMerge:
+		return "sort merge"
+	case builder.NestLoop:
+		return "nest loop"
+	}
+	return "cartesian"
+}
+
+// node used to write the plan node and its children plans.
+func (w *treeWriter) node(depth int, node builder.PlanNode) {
+	// The child plans are executed in order, so the last one is the outermost.
+	children := node.Children()
+	for i := len(children) - 1; i >= 0; i-- {
+		w.line(depth, children[i], "%s", childPlan(children[i]))
+		depth++
+	}
+
+	switch node := node.(type) {
+	case *builder.MergeNode:
+		w.line(depth, node, "Merge: %d route(s)", len(node.Querys))
+		w.routes(depth+1, node.Querys)
+	case *builder.JoinNode:
+		typ := "Inner join"
+		if node.IsLeftJoin {
+			typ = "Left join"
+		}
+		w.line(depth, node, "%s (%s)", typ, joinStrategy(node.Strategy))
+		w.node(depth+1, node.Left)
+		w.node(depth+1, node.Right)
+	case *builder.UnionNode:
+		w.line(depth, node, "Union: %s", node.Typ)
+		w.node(depth+1, node.Left)
+		w.node(depth+1, node.Right)
+	}
+}
+
+// querys used to write the stats of the backend querys in the execution order.
+func (w *treeWriter) querys() {
+	w.buf.WriteString("Backend querys:\n")
+	for _, qp := range w.profile.Querys() {
+		fmt.Fprintf(w.buf, "-> %s: %s (actual time=%s rows=%d bytes=%d", qp.Backend, qp.Query, formatDuration(qp.Duration), qp.Rows, qp.Bytes)
+		if qp.Error != "" {
+			fmt.Fprintf(w.buf, " error=%s", qp.Error)
+		}
+		w.buf.WriteString(")\n")
+	}
+}
+
+// explainTree returns the plans in the tree format.
+func explainTree(planTree *planner.PlanTree, profile *xcontext.Profile) string {
+	w := &treeWriter{
+		buf:     bytes.NewBuffer(make([]byte, 0, 1024)),
+		profile: profile,
+	}
+
+	for _, plan := range planTree.Plans() {
+		switch plan := plan.(type) {
+		case *planner.SelectPlan:
+			w.node(0, plan.Root)
+		case *planner.UnionPlan:
+			w.node(0, plan.Root)
+		case *planner.InsertPlan:
+			w.line(0, nil, "Insert")
+			w.routes(1, plan.Querys)
+		case *planner.UpdatePlan:
+			w.line(0, nil, "Update")
+			w.routes(1, plan.Querys)
+		case *planner.DeletePlan:
+			w.line(0, nil, "Delete")
+			w.routes(1, plan.Querys)
+		case *planner.OthersPlan:
+			w.line(0, nil, "Others")
+			w.routes(1, plan.Querys)
+		}
+	}
+	if profile != nil {
+		w.querys()
+	}
+	return strings.TrimSuffix(w.buf.String(), "\n")
+}
@@ -0,0 +1,119 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"sync"
+
+	"backend"
+	"syncer"
+	"xbase"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	backendzRestURL = "/v1/debug/backendz"
+)
+
+// PeerQuorum is the FailoverQuorum across all the radon peers.
+// The backend is failed over only if the majority of the peers(or the only one) see it down at the
+// same address by their own health checks, and only the elected peer(the least address of the
+// agreeing peers) fails it over, so a peer partitioned from the backend alone never promotes the
+// standby and the peers never promote it concurrently.
+type PeerQuorum struct {
+	log     *xlog.Log
+	self    string
+	syncer  *syncer.Syncer
+	scatter *backend.Scatter
+}
+
+// NewPeerQuorum creates the PeerQuorum tuple.
+func NewPeerQuorum(log *xlog.Log, self string, syncer *syncer.Syncer, scatter *backend.Scatter) *PeerQuorum {
+	return &PeerQuorum{
+		log:     log,
+		self:    self,
+		syncer:  syncer,
+		scatter: scatter,
+	}
+}
+
+// peers returns all the peers, including itself.
+func (q *PeerQuorum) peers() []string {
+	peers := q.syncer.Peers()
+	for _, peer := range peers {
+		if peer == q.self {
+			return peers
+		}
+	}
+	return append(peers, q.self)
+}
+
+// isDown returns true if the health check of the peer sees the backend down at the address.
+func (q *PeerQuorum) isDown(peer string, name string, address string) (bool, error) {
+	var status []*backend.BackendStatus
+	if peer == q.self {
+		status = q.scatter.Backendz()
+	} else {
+		body, err := xbase.HTTPGet("http://" + path.Join(peer, backendzRestURL))
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal([]byte(body), &status); err != nil {
+			return false, errors.Errorf("peer[%s].backendz.unmarshal.error:%v", peer, err)
+		}
+	}
+	for _, s := range status {
+		if s.BackendConfig != nil && s.Name == name && s.Address == address {
+			return s.Health != nil && !s.Health.Up, nil
+		}
+	}
+	return false, nil
+}
+
+// Agree impl.
+func (q *PeerQuorum) Agree(name string, address string) error {
+	log := q.log
+	peers := q.peers()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var voters []string
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			down, err := q.isDown(peer, name, address)
+			if err != nil {
+				log.Warning("proxy.failover.backend[%s].peer[%s].health.error:%v", name, peer, err)
+				return
+			}
+			if down {
+				mu.Lock()
+				voters = append(voters, peer)
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+
+	if len(voters) == 0 || len(voters)*2 <= len(peers) {
+		return errors.Errorf("proxy.failover.backend[%s].down.on.peers%v.of%v.not.quorum", name, voters, peers)
+	}
+	sort.Strings(voters)
+	if voters[0] != q.self {
+		return errors.Errorf("proxy.failover.backend[%s].elected.peer.is[%s]", name, voters[0])
+	}
+	log.Warning("proxy.failover.backend[%s].address[%s].agreed.by.peers%v", name, address, voters)
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend"
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyPeerQuorum(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := MockDefaultConfig()
+	conf.Scatter.HealthCheckInterval = 20
+	conf.Scatter.HealthCheckTimeout = 200
+	conf.Scatter.HealthCheckFailures = 1
+	_, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+
+	// The backend is down on self.
+	scatter := proxy.Scatter()
+	dead := &config.BackendConfig{Name: "dead", Address: "127.0.0.1:1", User: "mock", Password: "pwd", MaxConnections: 16}
+	assert.Nil(t, scatter.Add(dead))
+	down := false
+	for i := 0; i < 200 && !down; i++ {
+		for _, s := range scatter.Backendz() {
+			if s.Name == dead.Name && s.Health != nil && !s.Health.Up {
+				down = true
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, down)
+
+	// Mock peer.
+	var mu sync.Mutex
+	peerUp := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		up := peerUp
+		mu.Unlock()
+		status := []*backend.BackendStatus{{BackendConfig: dead, Health: &backend.Health{Up: up}}}
+		json.NewEncoder(w).Encode(status)
+	}))
+	defer server.Close()
+	peer := strings.TrimPrefix(server.URL, "http://")
+
+	self := proxy.PeerAddress()
+	syncer := proxy.Syncer()
+	quorum := NewPeerQuorum(log, self, syncer, scatter)
+
+	// The only peer is the quorum.
+	err := quorum.Agree(dead.Name, dead.Address)
+	assert.Nil(t, err)
+
+	// Down on self only.
+	assert.Nil(t, syncer.AddPeer(peer))
+	err = quorum.Agree(dead.Name, dead.Address)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "not.quorum"))
+
+	// Down on both peers.
+	mu.Lock()
+	peerUp = false
+	mu.Unlock()
+	err = quorum.Agree(dead.Name, dead.Address)
+	if self < peer {
+		assert.Nil(t, err)
+	} else {
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "elected.peer.is["+peer+"]"))
+	}
+
+	// Address changed.
+	err = quorum.Agree(dead.Name, "127.0.0.1:2")
+	assert.NotNil(t, err)
+
+	// Unreachable peers are counted but can't agree: 2 of 4 is not the majority.
+	assert.Nil(t, syncer.AddPeer("127.0.0.1:1"))
+	assert.Nil(t, syncer.AddPeer("127.0.0.1:2"))
+	err = quorum.Agree(dead.Name, dead.Address)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "not.quorum"))
+}
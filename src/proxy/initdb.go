@@ -38,6 +38,11 @@ func (spanner *Spanner) ComInitDB(session *driver.Session, database string) erro
 		}
 	}
 
+	// Check the max connections quota of the database.
+	if err := spanner.databaseConnectionsCheck(session, database); err != nil {
+		return err
+	}
+
 	query := fmt.Sprintf("use %s", database)
 	if _, err := spanner.ExecuteSingle(query); err != nil {
 		return err
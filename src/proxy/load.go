@@ -0,0 +1,561 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend"
+	"router"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// loadBatchSize is the max bytes of the INSERT batch sent to the shard.
+	loadBatchSize = 1024 * 1024
+
+	// loadAutoIncrementStep is the number of the auto-increment values reserved at a time.
+	loadAutoIncrementStep = 1024
+)
+
+// loadExpr tuple, the SET expression rendered to the parts, the refs are the field indexes between the parts.
+type loadExpr struct {
+	parts []string
+	refs  []int
+}
+
+// loadValue tuple, the value source of the insert column.
+// The field is the index of the file field, it's -1 if the value is the expression or the auto-increment value.
+type loadValue struct {
+	field int
+	expr  *loadExpr
+}
+
+// loadPlan tuple, how the lines of the file are inserted into the shards.
+type loadPlan struct {
+	database string
+	table    string
+	action   string
+	charset  string
+	columns  []string
+	values   []loadValue
+	autoinc  int
+	shardKey int
+	keyType  sqlparser.ValType
+	segments []router.Segment
+}
+
+// loadShard tuple, the INSERT batch of the segment.
+type loadShard struct {
+	backend string
+	prefix  string
+	counted bool
+	rows    uint64
+	buf     *bytes.Buffer
+}
+
+// loadBatch tuple, the INSERT batch sent to the backend.
+type loadBatch struct {
+	backend string
+	query   string
+	rows    uint64
+	counted bool
+}
+
+// handleLoad used to handle the LOAD DATA LOCAL INFILE command.
+// The file is sent by the client and parsed in the proxy, the rows are routed by the shard key and
+// inserted into the segments in batches, the batches of the backends are executed in parallel.
+// Every batch commits itself, the rows of the committed batches are kept if the load fails.
+func (spanner *Spanner) handleLoad(session *driver.Session, query string, node *sqlparser.Load) (*sqltypes.Result, error) {
+	log := spanner.log
+	router := spanner.router
+	sessions := spanner.sessions
+	privilegePlug := spanner.plugins.PlugPrivilege()
+
+	if !node.Local {
+		return nil, errors.New("unsupported: load.data.infile.without.local")
+	}
+	txSession := sessions.getTxnSession(session)
+	if txSession.transaction != nil || !txSession.getAutocommitVar() {
+		return nil, errors.New("unsupported: load.data.in.transaction")
+	}
+	if err := privilegePlug.Check(session.Schema(), session.User(), node); err != nil {
+		return nil, err
+	}
+
+	database := session.Schema()
+	if !node.Table.Qualifier.IsEmpty() {
+		database = node.Table.Qualifier.String()
+	}
+	if database == "" {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_DB_ERROR)
+	}
+	if err := router.DatabaseACL(database); err != nil {
+		return nil, err
+	}
+
+	format, err := newLoadFormat(node)
+	if err != nil {
+		return nil, err
+	}
+	var ignoreLines uint64
+	if node.IgnoreLines != nil {
+		if ignoreLines, err = strconv.ParseUint(string(node.IgnoreLines.Val), 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	plan, err := spanner.buildLoadPlan(database, node)
+	if err != nil {
+		return nil, err
+	}
+
+	// All checked, request the file from the client.
+	file, err := session.LocalInfile(node.Infile)
+	if err != nil {
+		return nil, err
+	}
+	// Close drains the rest of the file if the load fails.
+	defer file.Close()
+
+	reader := newLoadReader(file, format)
+	if err := reader.skip(ignoreLines); err != nil && err != io.EOF {
+		return nil, err
+	}
+	qr, err := spanner.loadRows(session, plan, reader)
+	if err != nil {
+		log.Error("proxy.load.data[%s.%s].line[%v].error:%+v", plan.database, plan.table, reader.line, err)
+		return nil, err
+	}
+	return qr, nil
+}
+
+// buildLoadPlan used to build the plan of the LOAD DATA, the columns of the table are fetched from the first segment.
+// The field list is the columns of the table if it's not specified, the @var entries in it are the user variables
+// which can be used in the SET expressions.
+func (spanner *Spanner) buildLoadPlan(database string, node *sqlparser.Load) (*loadPlan, error) {
+	router := spanner.router
+	table := node.Table.Name.String()
+	tableConfig, err := router.TableConfig(database, table)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The columns and types of the table.
+	qr, err := spanner.ExecuteOnThisBackend(segments[0].Backend, fmt.Sprintf("SHOW COLUMNS FROM `%s`.`%s`", database, segments[0].Table))
+	if err != nil {
+		return nil, err
+	}
+	var tableColumns []string
+	types := make(map[string]string)
+	for _, row := range qr.Rows {
+		if len(row) < 2 {
+			continue
+		}
+		name := row[0].String()
+		tableColumns = append(tableColumns, name)
+		types[strings.ToLower(name)] = strings.ToLower(row[1].String())
+	}
+
+	plan := &loadPlan{
+		database: database,
+		table:    table,
+		action:   "INSERT IGNORE",
+		charset:  node.Charset,
+		autoinc:  -1,
+		shardKey: -1,
+	}
+	// The server can't stop the client sending the file, the duplicates are skipped as MySQL LOCAL does.
+	if node.Dup == sqlparser.LoadReplaceStr {
+		plan.action = "REPLACE"
+	}
+
+	// The file fields.
+	fields := tableColumns
+	if node.Columns != nil {
+		fields = make([]string, len(node.Columns))
+		for i, col := range node.Columns {
+			fields[i] = col.String()
+		}
+	}
+	vars := make(map[string]int)
+	index := make(map[string]int)
+	for i, field := range fields {
+		name := strings.ToLower(field)
+		if strings.HasPrefix(name, "@") {
+			vars[name] = i
+			continue
+		}
+		if _, ok := types[name]; !ok {
+			return nil, errors.Errorf("load.data.unknown.column[%s].in.table[%s]", field, table)
+		}
+		if _, ok := index[name]; ok {
+			return nil, errors.Errorf("load.data.duplicate.column[%s]", field)
+		}
+		index[name] = len(plan.columns)
+		plan.columns = append(plan.columns, field)
+		plan.values = append(plan.values, loadValue{field: i})
+	}
+
+	// The SET expressions, the refs are resolved to the file fields.
+	for _, set := range node.SetExprs {
+		name := set.Name.Name.Lowered()
+		if _, ok := types[name]; !ok {
+			return nil, errors.Errorf("load.data.unknown.column[%s].in.table[%s]", set.Name.Name.String(), table)
+		}
+		expr, err := buildLoadExpr(set.Expr, vars, fields)
+		if err != nil {
+			return nil, err
+		}
+		value := loadValue{field: -1, expr: expr}
+		if i, ok := index[name]; ok {
+			plan.values[i] = value
+			continue
+		}
+		index[name] = len(plan.columns)
+		plan.columns = append(plan.columns, set.Name.Name.String())
+		plan.values = append(plan.values, value)
+	}
+
+	// The auto-increment column is appended if it's not loaded.
+	if autoinc := tableConfig.AutoIncrement; autoinc != nil {
+		name := strings.ToLower(autoinc.Column)
+		i, ok := index[name]
+		if !ok {
+			i = len(plan.columns)
+			index[name] = i
+			plan.columns = append(plan.columns, autoinc.Column)
+			plan.values = append(plan.values, loadValue{field: -1})
+		}
+		plan.autoinc = i
+	}
+
+	// The table without shard key, such as the global and the single table.
+	if tableConfig.ShardKey == "" {
+		plan.segments = segments
+		return plan, nil
+	}
+	key := strings.ToLower(tableConfig.ShardKey)
+	i, ok := index[key]
+	if !ok {
+		return nil, errors.Errorf("unsupported: shardkey.column[%v].missing", tableConfig.ShardKey)
+	}
+	if plan.values[i].expr != nil {
+		return nil, errors.Errorf("unsupported: shardkey.column[%v].can.not.be.set.by.expression", tableConfig.ShardKey)
+	}
+	plan.shardKey = i
+	plan.keyType = loadKeyType(types[key])
+	return plan, nil
+}
+
+// buildLoadExpr used to render the SET expression, the user variables and the columns in the field list
+// are replaced by the file fields.
+func buildLoadExpr(expr sqlparser.Expr, vars map[string]int, fields []string) (*loadExpr, error) {
+	var err error
+	mark := 0
+	le := &loadExpr{}
+	buf := sqlparser.NewTrackedBuffer(func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+		col, ok := node.(*sqlparser.ColName)
+		if !ok {
+			node.Format(buf)
+			return
+		}
+		name := col.Name.Lowered()
+		ref, ok := vars[name]
+		if !ok {
+			ref = -1
+			for i, field := range fields {
+				if strings.EqualFold(field, name) {
+					ref = i
+					break
+				}
+			}
+		}
+		if ref < 0 {
+			err = errors.Errorf("unsupported: load.data.set.reference[%s].not.in.field.list", col.Name.String())
+			return
+		}
+		le.parts = append(le.parts, buf.String()[mark:])
+		le.refs = append(le.refs, ref)
+		mark = buf.Len()
+	})
+	buf.Myprintf("%v", expr)
+	if err != nil {
+		return nil, err
+	}
+	le.parts = append(le.parts, buf.String()[mark:])
+	return le, nil
+}
+
+// loadKeyType returns the value type of the shard key by the column type, it must be same as the literal
+// in the INSERT, so the rows are routed to the same segment.
+func loadKeyType(typ string) sqlparser.ValType {
+	if i := strings.IndexAny(typ, "( "); i > 0 {
+		typ = typ[:i]
+	}
+	switch typ {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint":
+		return sqlparser.IntVal
+	case "float", "double", "decimal", "numeric", "real":
+		return sqlparser.FloatVal
+	}
+	return sqlparser.StrVal
+}
+
+// encodeField used to write the field as the SQL value, the missing field is the def.
+func (plan *loadPlan) encodeField(buf *bytes.Buffer, fields []loadField, i int, def string) {
+	if i >= len(fields) {
+		buf.WriteString(def)
+		return
+	}
+	if fields[i].null {
+		buf.WriteString("NULL")
+		return
+	}
+	if plan.charset != "" {
+		buf.WriteString("_")
+		buf.WriteString(plan.charset)
+	}
+	sqltypes.MakeTrusted(querypb.Type_VARBINARY, fields[i].val).EncodeSQL(buf)
+}
+
+// generated returns true if the auto-increment value of the line is generated by the plugin, as the INSERT does:
+// the column isn't loaded, or the loaded field is missing, NULL or 0(the empty field is 0 for the number column).
+func (plan *loadPlan) generated(fields []loadField) bool {
+	if plan.autoinc < 0 {
+		return false
+	}
+	value := plan.values[plan.autoinc]
+	if value.expr != nil {
+		return false
+	}
+	if value.field < 0 || value.field >= len(fields) || fields[value.field].null {
+		return true
+	}
+	val := strings.TrimSpace(string(fields[value.field].val))
+	if val == "" {
+		return true
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	return err == nil && f == 0
+}
+
+// encodeRow used to write the row values of the line, the seq is the generated auto-increment value or 0.
+func (plan *loadPlan) encodeRow(buf *bytes.Buffer, fields []loadField, seq uint64) {
+	buf.WriteByte('(')
+	for i, value := range plan.values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		switch {
+		case value.expr != nil:
+			for j, part := range value.expr.parts {
+				buf.WriteString(part)
+				if j < len(value.expr.refs) {
+					plan.encodeField(buf, fields, value.expr.refs[j], "NULL")
+				}
+			}
+		case i == plan.autoinc && seq > 0:
+			buf.WriteString(strconv.FormatUint(seq, 10))
+		default:
+			plan.encodeField(buf, fields, value.field, "DEFAULT")
+		}
+	}
+	buf.WriteByte(')')
+}
+
+// route returns the segments of the line, the seq is the generated auto-increment value or 0.
+func (plan *loadPlan) route(router *router.Router, fields []loadField, seq uint64) ([]router.Segment, error) {
+	if plan.shardKey < 0 {
+		return plan.segments, nil
+	}
+
+	var key *sqlparser.SQLVal
+	value := plan.values[plan.shardKey]
+	if plan.shardKey == plan.autoinc && seq > 0 {
+		key = sqlparser.NewIntVal([]byte(strconv.FormatUint(seq, 10)))
+	} else {
+		if value.field >= len(fields) || fields[value.field].null {
+			return nil, errors.Errorf("load.data.shardkey.column[%s].can.not.be.null", plan.columns[plan.shardKey])
+		}
+		key = &sqlparser.SQLVal{Type: plan.keyType, Val: fields[value.field].val}
+	}
+	return router.Lookup(plan.database, plan.table, key, key)
+}
+
+// loadRows used to read the lines and insert them into the segments.
+// Every backend has a worker to execute its batches in order, the reader waits if the worker is busy.
+func (spanner *Spanner) loadRows(session *driver.Session, plan *loadPlan, reader *loadReader) (*sqltypes.Result, error) {
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var loadErr error
+	var failed int32
+	var affected, batches, seq, seqEnd, id uint64
+
+	log := spanner.log
+	router := spanner.router
+	sessions := spanner.sessions
+	autoincPlug := spanner.plugins.PlugAutoIncrement()
+	start := time.Now()
+
+	vars := sessions.getSessionVars(session)
+	chars := sessions.takeTxnCharacteristics(session, nil)
+	timeout, _ := spanner.sessionLimits(session, []string{plan.database}, spanner.conf.Proxy.QueryTimeout, 0)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			loadErr = err
+			atomic.StoreInt32(&failed, 1)
+		})
+	}
+
+	workers := make(map[string]chan *loadBatch)
+	send := func(shard *loadShard) {
+		batch := &loadBatch{backend: shard.backend, query: shard.buf.String(), rows: shard.rows, counted: shard.counted}
+		shard.buf.Reset()
+		shard.rows = 0
+		batches++
+
+		ch, ok := workers[batch.backend]
+		if !ok {
+			ch = make(chan *loadBatch, 1)
+			workers[batch.backend] = ch
+			wg.Add(1)
+			go func(ch chan *loadBatch) {
+				defer wg.Done()
+				for batch := range ch {
+					if atomic.LoadInt32(&failed) == 1 {
+						continue
+					}
+					qr, err := spanner.executeLoadBatch(session, vars, chars, timeout, batch)
+					if err != nil {
+						fail(err)
+						continue
+					}
+					if batch.counted {
+						atomic.AddUint64(&affected, qr.RowsAffected)
+					}
+				}
+			}(ch)
+		}
+		ch <- batch
+	}
+
+	var lines uint64
+	row := bytes.NewBuffer(make([]byte, 0, 1024))
+	shards := make(map[string]*loadShard)
+	order := make([]*loadShard, 0, 16)
+	for atomic.LoadInt32(&failed) == 0 {
+		fields, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(err)
+			break
+		}
+		lines++
+
+		id = 0
+		if plan.generated(fields) {
+			if seq == seqEnd {
+				seq = autoincPlug.Reserve(loadAutoIncrementStep)
+				seqEnd = seq + loadAutoIncrementStep
+			}
+			seq++
+			id = seq
+		}
+		segments, err := plan.route(router, fields, id)
+		if err != nil {
+			fail(err)
+			break
+		}
+
+		row.Reset()
+		plan.encodeRow(row, fields, id)
+		for i, segment := range segments {
+			key := segment.Backend + "." + segment.Table
+			shard, ok := shards[key]
+			if !ok {
+				shard = &loadShard{
+					backend: segment.Backend,
+					prefix:  fmt.Sprintf("%s INTO `%s`.`%s`(`%s`) VALUES ", plan.action, plan.database, segment.Table, strings.Join(plan.columns, "`,`")),
+					counted: i == 0,
+					buf:     bytes.NewBuffer(make([]byte, 0, loadBatchSize+row.Len())),
+				}
+				shards[key] = shard
+				order = append(order, shard)
+			}
+			if shard.buf.Len() == 0 {
+				shard.buf.WriteString(shard.prefix)
+			} else {
+				shard.buf.WriteByte(',')
+			}
+			shard.buf.Write(row.Bytes())
+			shard.rows++
+			if shard.buf.Len() >= loadBatchSize {
+				send(shard)
+			}
+		}
+	}
+	if atomic.LoadInt32(&failed) == 0 {
+		for _, shard := range order {
+			if shard.rows > 0 {
+				send(shard)
+			}
+		}
+	}
+	for _, ch := range workers {
+		close(ch)
+	}
+	wg.Wait()
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	log.Warning("proxy.load.data[%s.%s].done[lines:%v, affected:%v, batches:%v, duration:%v]", plan.database, plan.table, lines, affected, batches, time.Since(start))
+	return &sqltypes.Result{RowsAffected: affected}, nil
+}
+
+// executeLoadBatch used to execute the batch on its backend, the batch commits itself.
+func (spanner *Spanner) executeLoadBatch(session *driver.Session, vars map[string]string, chars backend.TxnCharacteristics, timeout int, batch *loadBatch) (*sqltypes.Result, error) {
+	log := spanner.log
+	scatter := spanner.scatter
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		log.Error("spanner.load.txn.create.error:[%v]", err)
+		return nil, err
+	}
+	defer txn.Finish()
+
+	txn.SetTimeout(timeout)
+	txn.SetSessionID(session.ID())
+	txn.SetSessionVars(vars)
+	txn.SetCharacteristics(chars)
+
+	req := xcontext.NewRequestContext()
+	req.TxnMode = xcontext.TxnWrite
+	req.Querys = append(req.Querys, xcontext.QueryTuple{Query: batch.query, Backend: batch.backend})
+	return txn.Execute(req)
+}
@@ -0,0 +1,292 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+const (
+	// loadReaderBufferSize is the buffer size of the file reader.
+	loadReaderBufferSize = 256 * 1024
+)
+
+// loadFormat tuple, the FIELDS and LINES options of the LOAD DATA.
+type loadFormat struct {
+	fieldTerm []byte
+	enclosed  []byte
+	escaped   []byte
+	lineTerm  []byte
+	lineStart []byte
+}
+
+// newLoadFormat returns the format of the LOAD DATA, the defaults are same as MySQL:
+// the fields are terminated by tab, not enclosed, escaped by backslash, the lines are terminated by newline.
+func newLoadFormat(node *sqlparser.Load) (*loadFormat, error) {
+	format := &loadFormat{
+		fieldTerm: []byte("\t"),
+		escaped:   []byte("\\"),
+		lineTerm:  []byte("\n"),
+	}
+	if fields := node.Fields; fields != nil {
+		if fields.Terminated != nil {
+			format.fieldTerm = fields.Terminated.Val
+		}
+		if fields.Enclosed != nil {
+			format.enclosed = fields.Enclosed.Val
+		}
+		if fields.Escaped != nil {
+			format.escaped = fields.Escaped.Val
+		}
+	}
+	if lines := node.Lines; lines != nil {
+		if lines.Terminated != nil {
+			format.lineTerm = lines.Terminated.Val
+		}
+		if lines.Starting != nil {
+			format.lineStart = lines.Starting.Val
+		}
+	}
+
+	if len(format.fieldTerm) == 0 || len(format.lineTerm) == 0 {
+		return nil, errors.New("unsupported: load.data.fixed-row.format")
+	}
+	if len(format.enclosed) > 1 {
+		return nil, errors.New("load.data.fields.enclosed.by.must.be.a.single.character")
+	}
+	if len(format.escaped) > 1 {
+		return nil, errors.New("load.data.fields.escaped.by.must.be.a.single.character")
+	}
+	return format, nil
+}
+
+// loadField tuple, the field of the line, the val is nil if the field is NULL.
+type loadField struct {
+	val  []byte
+	null bool
+}
+
+// loadReader used to read the lines of the file in the LOAD DATA format.
+type loadReader struct {
+	r      *bufio.Reader
+	format *loadFormat
+	line   uint64
+
+	// buf holds the field values of the current line.
+	buf    []byte
+	ends   []int
+	nulls  []bool
+	fields []loadField
+}
+
+// newLoadReader creates the loadReader.
+func newLoadReader(r io.Reader, format *loadFormat) *loadReader {
+	return &loadReader{
+		r:      bufio.NewReaderSize(r, loadReaderBufferSize),
+		format: format,
+		buf:    make([]byte, 0, 1024),
+	}
+}
+
+// fieldEnd tells how the field ends.
+type fieldEnd int
+
+const (
+	endOfField fieldEnd = iota
+	endOfLine
+	endOfFile
+)
+
+// next returns the fields of the next line, the fields are valid until the next call.
+// It returns io.EOF if there is no more line.
+func (lr *loadReader) next() ([]loadField, error) {
+	format := lr.format
+
+	// The line starts after the prefix, the part before the prefix is skipped.
+	if len(format.lineStart) > 0 {
+		if err := lr.skipTo(format.lineStart); err != nil {
+			return nil, err
+		}
+	} else if _, err := lr.r.Peek(1); err != nil {
+		return nil, err
+	}
+
+	lr.line++
+	lr.buf = lr.buf[:0]
+	lr.ends = lr.ends[:0]
+	lr.nulls = lr.nulls[:0]
+	for {
+		null, end, err := lr.readField()
+		if err != nil {
+			return nil, err
+		}
+		lr.ends = append(lr.ends, len(lr.buf))
+		lr.nulls = append(lr.nulls, null)
+		if end != endOfField {
+			break
+		}
+	}
+
+	lr.fields = lr.fields[:0]
+	start := 0
+	for i, end := range lr.ends {
+		field := loadField{null: lr.nulls[i]}
+		if !field.null {
+			field.val = lr.buf[start:end]
+		}
+		lr.fields = append(lr.fields, field)
+		start = end
+	}
+	return lr.fields, nil
+}
+
+// skip used to skip the next n lines.
+func (lr *loadReader) skip(n uint64) error {
+	for i := uint64(0); i < n; i++ {
+		if _, err := lr.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipTo used to skip the bytes until the prefix, the prefix is consumed.
+func (lr *loadReader) skipTo(prefix []byte) error {
+	for {
+		c, err := lr.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == prefix[0] && lr.consume(prefix[1:]) {
+			return nil
+		}
+	}
+}
+
+// consume used to consume the bytes if they are the next bytes of the reader.
+func (lr *loadReader) consume(b []byte) bool {
+	if len(b) == 0 {
+		return true
+	}
+	next, err := lr.r.Peek(len(b))
+	if err != nil || !bytes.Equal(next, b) {
+		return false
+	}
+	lr.r.Discard(len(b))
+	return true
+}
+
+// readField used to read the next field into the buf, the rules are same as MySQL:
+// 1. The escape sequences are \0 \b \n \r \t \Z, the other escaped character is itself.
+// 2. The field \N is NULL, the unquoted field NULL is NULL too if the ENCLOSED BY is not empty.
+// 3. The doubled enclosed character in the quoted field is the character itself.
+func (lr *loadReader) readField() (bool, fieldEnd, error) {
+	format := lr.format
+	start := len(lr.buf)
+	quoted := false
+	escapedN := false
+
+	if len(format.enclosed) > 0 {
+		if next, err := lr.r.Peek(1); err == nil && next[0] == format.enclosed[0] {
+			lr.r.Discard(1)
+			quoted = true
+		}
+	}
+
+	end := endOfFile
+	for {
+		c, err := lr.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, end, err
+		}
+
+		if quoted && c == format.enclosed[0] {
+			// The doubled enclosed character.
+			if lr.consume(format.enclosed) {
+				lr.buf = append(lr.buf, c)
+				continue
+			}
+			if lr.consume(format.fieldTerm) {
+				end = endOfField
+				break
+			}
+			if lr.consume(format.lineTerm) {
+				end = endOfLine
+				break
+			}
+			if _, err := lr.r.Peek(1); err == io.EOF {
+				break
+			}
+			lr.buf = append(lr.buf, c)
+			continue
+		}
+
+		if len(format.escaped) > 0 && c == format.escaped[0] {
+			e, err := lr.r.ReadByte()
+			if err == io.EOF {
+				lr.buf = append(lr.buf, c)
+				break
+			}
+			if err != nil {
+				return false, end, err
+			}
+			if e == 'N' && len(lr.buf) == start {
+				escapedN = true
+			}
+			lr.buf = append(lr.buf, unescapeLoadByte(e))
+			continue
+		}
+
+		if !quoted {
+			if c == format.fieldTerm[0] && lr.consume(format.fieldTerm[1:]) {
+				end = endOfField
+				break
+			}
+			if c == format.lineTerm[0] && lr.consume(format.lineTerm[1:]) {
+				end = endOfLine
+				break
+			}
+		}
+		lr.buf = append(lr.buf, c)
+	}
+
+	val := lr.buf[start:]
+	null := (escapedN && len(val) == 1) || (!quoted && len(format.enclosed) > 0 && string(val) == "NULL")
+	if null {
+		lr.buf = lr.buf[:start]
+	}
+	return null, end, nil
+}
+
+// unescapeLoadByte returns the character of the escape sequence.
+func unescapeLoadByte(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'b':
+		return '\b'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'Z':
+		return 26
+	}
+	return c
+}
@@ -0,0 +1,120 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+func readLoadLines(t *testing.T, sql string, data string) [][]string {
+	node, err := sqlparser.Parse(sql)
+	assert.Nil(t, err)
+	format, err := newLoadFormat(node.(*sqlparser.Load))
+	assert.Nil(t, err)
+
+	var lines [][]string
+	reader := newLoadReader(strings.NewReader(data), format)
+	for {
+		fields, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		var line []string
+		for _, field := range fields {
+			if field.null {
+				line = append(line, "<null>")
+			} else {
+				line = append(line, string(field.val))
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestProxyLoadReader(t *testing.T) {
+	tests := []struct {
+		sql  string
+		data string
+		want [][]string
+	}{
+		// The defaults.
+		{
+			sql:  "load data local infile 'x' into table t1",
+			data: "1\ta\n2\t\\N\n3\tNULL\n\n4\ta\\tb\\nc\\\\d\\\te\\Z\\0\n5",
+			want: [][]string{{"1", "a"}, {"2", "<null>"}, {"3", "NULL"}, {""}, {"4", "a\tb\nc\\d\te\x1a\x00"}, {"5"}},
+		},
+		// The \N is NULL only if it's the whole field.
+		{
+			sql:  "load data local infile 'x' into table t1",
+			data: "\\NN\tx\\N\t\\",
+			want: [][]string{{"NN", "xN", "\\"}},
+		},
+		// CSV.
+		{
+			sql:  "load data local infile 'x' into table t1 fields terminated by ',' optionally enclosed by '\"' lines terminated by '\\r\\n'",
+			data: "1,\"a,b\",\"x\"\"y\"\r\n2,NULL,\"NULL\"\r\n3,\"line\r\nbreak\",\"q\"z\"\r\n4,\"\",",
+			want: [][]string{{"1", "a,b", "x\"y"}, {"2", "<null>", "NULL"}, {"3", "line\r\nbreak", "q\"z"}, {"4", "", ""}},
+		},
+		// Multi-byte terminators and no escape.
+		{
+			sql:  "load data local infile 'x' into table t1 fields terminated by '||' escaped by '' lines terminated by '##'",
+			data: "1||a\\N||b|c##2||\\N##",
+			want: [][]string{{"1", "a\\N", "b|c"}, {"2", "\\N"}},
+		},
+		// The lines starting by the prefix.
+		{
+			sql:  "load data local infile 'x' into table t1 fields terminated by ',' lines starting by 'xxx'",
+			data: "xxx1,a\nskipped\nyyyxxx2,b\nxx3,c\n",
+			want: [][]string{{"1", "a"}, {"2", "b"}},
+		},
+	}
+	for _, test := range tests {
+		got := readLoadLines(t, test.sql, test.data)
+		assert.Equal(t, test.want, got, test.data)
+	}
+
+	// Skip the lines.
+	{
+		node, err := sqlparser.Parse("load data local infile 'x' into table t1")
+		assert.Nil(t, err)
+		format, err := newLoadFormat(node.(*sqlparser.Load))
+		assert.Nil(t, err)
+		reader := newLoadReader(strings.NewReader("h1\th2\n1\ta\n"), format)
+		err = reader.skip(1)
+		assert.Nil(t, err)
+		fields, err := reader.next()
+		assert.Nil(t, err)
+		assert.Equal(t, "a", string(fields[1].val))
+		assert.Equal(t, uint64(2), reader.line)
+		err = reader.skip(1)
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestProxyLoadFormatError(t *testing.T) {
+	sqls := []string{
+		"load data local infile 'x' into table t1 fields terminated by ''",
+		"load data local infile 'x' into table t1 lines terminated by ''",
+		"load data local infile 'x' into table t1 fields enclosed by 'ab'",
+		"load data local infile 'x' into table t1 fields escaped by 'ab'",
+	}
+	for _, sql := range sqls {
+		node, err := sqlparser.Parse(sql)
+		assert.Nil(t, err)
+		_, err = newLoadFormat(node.(*sqlparser.Load))
+		assert.NotNil(t, err, sql)
+	}
+}
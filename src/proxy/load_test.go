@@ -0,0 +1,298 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var loadShowColumnsResult = &sqltypes.Result{
+	Fields: []*querypb.Field{
+		{Name: "Field", Type: querypb.Type_VARCHAR},
+		{Name: "Type", Type: querypb.Type_VARCHAR},
+		{Name: "Null", Type: querypb.Type_VARCHAR},
+		{Name: "Key", Type: querypb.Type_VARCHAR},
+		{Name: "Default", Type: querypb.Type_VARCHAR},
+		{Name: "Extra", Type: querypb.Type_VARCHAR},
+	},
+	Rows: [][]sqltypes.Value{
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("id")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("bigint(20)")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("YES")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+			sqltypes.NULL,
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+		},
+		{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("name")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("varchar(32)")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("YES")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+			sqltypes.NULL,
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+		},
+	},
+}
+
+// loadExpectQuerys returns the INSERT querys of the rows grouped by the segments of the keys.
+func loadExpectQuerys(t *testing.T, route *router.Router, table string, keys []string, rows []string) []string {
+	var querys []string
+	index := make(map[string]int)
+	for i, key := range keys {
+		val := sqlparser.NewIntVal([]byte(key))
+		segments, err := route.Lookup("test", table, val, val)
+		assert.Nil(t, err)
+		j, ok := index[segments[0].Table]
+		if !ok {
+			index[segments[0].Table] = len(querys)
+			querys = append(querys, fmt.Sprintf("INSERT IGNORE INTO `test`.`%s`(`id`,`name`) VALUES %s", segments[0].Table, rows[i]))
+			continue
+		}
+		querys[j] += "," + rows[i]
+	}
+	return querys
+}
+
+func TestProxyLoadData(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	backends := proxy.Scatter().AllBackends()
+
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("show columns from .*", loadShowColumnsResult)
+	fakedbs.AddQueryPattern("insert ignore into .*", &sqltypes.Result{RowsAffected: 1})
+	fakedbs.AddQueryPattern("replace into .*", &sqltypes.Result{RowsAffected: 1})
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	files := map[string]string{
+		"t1.txt":  "1\talice\n2\tbob\n3\t\\N\n100\tit's\n",
+		"t1.csv":  "id,name\n\"5\",\"x,y\"\n",
+		"g1.txt":  "1\ta\n2\tb\n",
+		"a1.txt":  "x\ny\nz\n",
+		"a2.txt":  "7\tx\n",
+		"bad.txt": "\\N\ta\n",
+	}
+	client.SetLocalInfileHandler(func(filename string) (io.ReadCloser, error) {
+		data, ok := files[filename]
+		if !ok {
+			return nil, errors.New("file.not.found")
+		}
+		return ioutil.NopCloser(strings.NewReader(data)), nil
+	})
+
+	querys := []string{
+		"create database test",
+		"use test",
+		"create table test.t1(id bigint, name varchar(32)) partition by hash(id)",
+		"create table test.g1(id bigint, name varchar(32)) global",
+		"create table test.a1(id bigint not null auto_increment, name varchar(32), primary key(id)) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	// The rows are routed by the shard key.
+	{
+		want := loadExpectQuerys(t, proxy.Router(), "t1", []string{"1", "2", "3", "100"}, []string{"('1','alice')", "('2','bob')", "('3',NULL)", "('100','it\\'s')"})
+		qr, err := client.FetchAll("load data local infile 't1.txt' into table t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(len(want)), qr.RowsAffected)
+		for _, query := range want {
+			assert.Equal(t, 1, fakedbs.GetQueryCalledNum(query), query)
+		}
+	}
+
+	// The CSV with the SET expression.
+	{
+		want := loadExpectQuerys(t, proxy.Router(), "t1", []string{"5"}, []string{"(_utf8mb4'5',concat(_utf8mb4'x,y', '!'))"})
+		qr, err := client.FetchAll("load data local infile 't1.csv' into table test.t1 character set utf8mb4 fields terminated by ',' enclosed by '\"' ignore 1 lines (id, @name) set name = concat(@name, '!')", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(want[0]), want[0])
+	}
+
+	// The global table rows are loaded to all the backends, the affected rows are counted once.
+	{
+		query := "REPLACE INTO `test`.`g1`(`id`,`name`) VALUES ('1','a'),('2','b')"
+		qr, err := client.FetchAll("load data local infile 'g1.txt' replace into table g1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected)
+		assert.Equal(t, len(backends), fakedbs.GetQueryCalledNum(query))
+	}
+
+	// The auto-increment values are the shard keys.
+	{
+		qr, err := client.FetchAll("load data local infile 'a1.txt' into table a1 (name)", -1)
+		assert.Nil(t, err)
+		assert.True(t, qr.RowsAffected > 0)
+	}
+
+	// The loaded auto-increment value is kept if it's not NULL or 0.
+	{
+		want := loadExpectQuerys(t, proxy.Router(), "a1", []string{"7"}, []string{"('7','x')"})
+		_, err := client.FetchAll("load data local infile 'a2.txt' into table a1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(want[0]), want[0])
+	}
+
+	// Errors before the file is requested.
+	{
+		sqls := []string{
+			"load data infile 't1.txt' into table t1",
+			"load data local infile 't1.txt' into table t1 (name)",
+			"load data local infile 't1.txt' into table t1 (@id, name) set id = @id",
+			"load data local infile 't1.txt' into table t1 (id, xx)",
+			"load data local infile 't1.txt' into table t1 (id, name) set name = @x",
+			"load data local infile 't1.txt' into table t1 fields terminated by ''",
+			"load data local infile 't1.txt' into table xx",
+		}
+		for _, sql := range sqls {
+			_, err := client.FetchAll(sql, -1)
+			assert.NotNil(t, err, sql)
+		}
+	}
+
+	// The shard key is NULL.
+	{
+		_, err := client.FetchAll("load data local infile 'bad.txt' into table t1", -1)
+		assert.NotNil(t, err)
+	}
+
+	// The local file error, the connection is still usable.
+	{
+		_, err := client.FetchAll("load data local infile 'notexists.txt' into table t1", -1)
+		assert.Equal(t, "file.not.found", err.Error())
+		_, err = client.FetchAll("load data local infile 't1.txt' into table t1", -1)
+		assert.Nil(t, err)
+	}
+
+	// The backend error stops the load, the rest of the file is drained.
+	{
+		var lines bytes.Buffer
+		for i := 0; i < 10000; i++ {
+			fmt.Fprintf(&lines, "%d\tname%d\n", i, i)
+		}
+		files["big.txt"] = lines.String()
+		fakedbs.AddQueryErrorPattern("insert ignore into .*", errors.New("mock.insert.error"))
+		_, err := client.FetchAll("load data local infile 'big.txt' into table t1", -1)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "mock.insert.error"))
+		fakedbs.ResetPatternErrors()
+
+		_, err = client.FetchAll("load data local infile 't1.txt' into table t1", -1)
+		assert.Nil(t, err)
+	}
+
+	// The load isn't allowed in the transaction.
+	{
+		_, err := client.FetchAll("set autocommit=0", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("load data local infile 't1.txt' into table t1", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("set autocommit=1", -1)
+		assert.Nil(t, err)
+	}
+
+	// The client refuses the LOCAL INFILE.
+	{
+		client.SetLocalInfileHandler(nil)
+		_, err := client.FetchAll("load data local infile 't1.txt' into table t1", -1)
+		assert.True(t, strings.Contains(err.Error(), "errno 1148"))
+	}
+}
+
+func TestProxyLoadDataAutoIncrement(t *testing.T) {
+	plan := &loadPlan{
+		columns:  []string{"id", "name"},
+		values:   []loadValue{{field: 0}, {field: 1}},
+		autoinc:  0,
+		shardKey: 0,
+	}
+	tests := []struct {
+		fields    []loadField
+		generated bool
+		row       string
+	}{
+		{[]loadField{{val: []byte("7")}, {val: []byte("x")}}, false, "('7','x')"},
+		{[]loadField{{null: true}, {val: []byte("x")}}, true, "(100,'x')"},
+		{[]loadField{{val: []byte("0")}, {val: []byte("x")}}, true, "(100,'x')"},
+		{[]loadField{{val: []byte("")}, {val: []byte("x")}}, true, "(100,'x')"},
+		{[]loadField{}, true, "(100,DEFAULT)"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.generated, plan.generated(test.fields))
+		var seq uint64
+		if test.generated {
+			seq = 100
+		}
+		var buf bytes.Buffer
+		plan.encodeRow(&buf, test.fields, seq)
+		assert.Equal(t, test.row, buf.String())
+	}
+
+	// The value set by the expression is never generated.
+	plan.values[0] = loadValue{field: -1, expr: &loadExpr{parts: []string{"1"}}}
+	assert.False(t, plan.generated([]loadField{{null: true}}))
+}
+
+func TestProxyLoadDataReadOnly(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	proxy.SetReadOnly(true)
+	_, err = client.FetchAll("load data local infile 't1.txt' into table test.t1", -1)
+	want := "The MySQL server is running with the --read-only option so it cannot execute this statement (errno 1290) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
+
+func TestProxyLoadDataPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("load data local infile 't1.txt' into table t1", -1)
+	want := "Access denied for user 'mock'@'%' to database 'test' (errno 1045) (sqlstate 28000)"
+	assert.Equal(t, want, err.Error())
+}
@@ -79,6 +79,8 @@ func MockDefaultConfig() *config.Config {
 	conf := &config.Config{
 		Proxy:   config.DefaultProxyConfig(),
 		Audit:   config.DefaultAuditConfig(),
+		SlowLog: config.DefaultSlowLogConfig(),
+		Trace:   config.DefaultTraceConfig(),
 		Router:  config.DefaultRouterConfig(),
 		Log:     config.DefaultLogConfig(),
 		Scatter: config.DefaultScatterConfig(),
@@ -128,6 +130,9 @@ func MockProxy1(log *xlog.Log, conf *config.Config) (*fakedb.DB, *Proxy, func())
 	timestamp := t.Format(fileFormat)
 	metaDir := tmpDir + "/test_radonmeta_" + timestamp
 	conf.Proxy.MetaDir = metaDir
+	if conf.Scatter != nil {
+		conf.Scatter.XaCheckDir = path.Join(tmpDir, "xacheck")
+	}
 
 	if x := os.MkdirAll(metaDir, 0777); x != nil {
 		log.Panic("%+v", x)
@@ -171,6 +176,9 @@ func MockProxyPrivilegeN(log *xlog.Log, conf *config.Config) (*fakedb.DB, *Proxy
 	timestamp := t.Format(fileFormat)
 	metaDir := tmpDir + "/test_radonmeta_" + timestamp
 	conf.Proxy.MetaDir = metaDir
+	if conf.Scatter != nil {
+		conf.Scatter.XaCheckDir = path.Join(tmpDir, "xacheck")
+	}
 
 	if x := os.MkdirAll(metaDir, 0777); x != nil {
 		log.Panic("%+v", x)
@@ -213,6 +221,9 @@ func MockProxyPrivilegeNotSuper(log *xlog.Log, conf *config.Config) (*fakedb.DB,
 	timestamp := t.Format(fileFormat)
 	metaDir := tmpDir + "/test_radonmeta_" + timestamp
 	conf.Proxy.MetaDir = metaDir
+	if conf.Scatter != nil {
+		conf.Scatter.XaCheckDir = path.Join(tmpDir, "xacheck")
+	}
 
 	if x := os.MkdirAll(metaDir, 0777); x != nil {
 		log.Panic("%+v", x)
@@ -255,6 +266,9 @@ func MockProxyPrivilegeUsers(log *xlog.Log, conf *config.Config) (*fakedb.DB, *P
 	timestamp := t.Format(fileFormat)
 	metaDir := tmpDir + "/test_radonmeta_" + timestamp
 	conf.Proxy.MetaDir = metaDir
+	if conf.Scatter != nil {
+		conf.Scatter.XaCheckDir = path.Join(tmpDir, "xacheck")
+	}
 
 	if x := os.MkdirAll(metaDir, 0777); x != nil {
 		log.Panic("%+v", x)
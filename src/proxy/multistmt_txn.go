@@ -31,6 +31,8 @@ func (spanner *Spanner) handleMultiStmtTxn(session *driver.Session, query string
 		qr, err = spanner.handleRollback(session, snode.Action, node)
 	case sqlparser.CommitTxnStr:
 		qr, err = spanner.handleCommit(session, snode.Action, node)
+	case sqlparser.SavepointStr, sqlparser.RollbackToSavepointStr, sqlparser.ReleaseSavepointStr:
+		qr, err = spanner.ExecuteSavepoint(session, query, snode)
 	}
 	if err != nil {
 		log.Error("proxy.query.multistmt.txn.[%s].error:%s", query, err)
@@ -67,21 +69,10 @@ func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node
 	var txn backend.Transaction
 	var err error
 
-	if !spanner.isTwoPC() {
-		log.Error("spanner.execute.2pc.disable")
-		return nil, errors.Errorf("spanner.query.execute.multistmt.txn.error[twopc-disable]")
-	}
-
-	currentSession := sessions.getTxnSession(session)
-	txn = currentSession.transaction
-
-	//1. If the previous cmd is not in transaction, and the autocommit = 0, the begin will implicit commit in mysql,
-	// the case is not supported. https://dev.mysql.com/doc/refman/5.7/en/implicit-commit.html
-	//2. If txn is not nil, it isn't supported. e.g., begin;sql1;sql2;.. begin;(return err, and the txn isn't free);
-	if txn != nil {
-		// the last txn isn't free
-		log.Error("spanner.execute.multistmt.begin.nestedTxn.unsupported.")
-		return nil, errors.Errorf("ExecuteMultiStatBegin.nestedTxn.unsupported")
+	// If the session is in a transaction, the begin will implicit commit it as mysql.
+	// https://dev.mysql.com/doc/refman/5.7/en/implicit-commit.html
+	if err = spanner.implicitCommit(session, query, node); err != nil {
+		return nil, err
 	}
 
 	txn, err = scatter.CreateTransaction()
@@ -89,13 +80,27 @@ func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node
 		log.Error("spanner.txn.create.error:[%v]", err)
 		return nil, err
 	}
-	txn.SetTimeout(conf.Proxy.QueryTimeout)
-	txn.SetMaxResult(conf.Proxy.MaxResultSize)
+	timeout, maxResult := spanner.sessionLimits(session, queryDatabases(node, session.Schema()), conf.Proxy.QueryTimeout, conf.Proxy.MaxResultSize)
+	txn.SetTimeout(timeout)
+	txn.SetMaxResult(maxResult)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetRecordQuerys(spanner.recordQuerys())
 	txn.SetMultiStmtTxn()
 
+	var startChars []string
+	if snode, ok := node.(*sqlparser.Transaction); ok {
+		startChars = snode.Characteristics
+	}
+	txn.SetCharacteristics(sessions.takeTxnCharacteristics(session, startChars))
+
 	sessions.MultiStmtTxnBinding(session, txn, node, query)
-	if err := txn.BeginScatter(); err != nil {
+	// Without 2PC, the txn works as a local transaction on single backend.
+	if !spanner.isTwoPC() {
+		err = txn.BeginLocal()
+	} else {
+		err = txn.BeginScatter()
+	}
+	if err != nil {
 		txn.Finish()
 		sessions.MultiStmtTxnUnBinding(session, true)
 		log.Error("spanner.execute.multistmt.txn.begin.scatter.error:[%v]", err)
@@ -112,24 +117,17 @@ func (spanner *Spanner) ExecuteRollback(session *driver.Session, query string, n
 	sessions := spanner.sessions
 	var txn backend.Transaction
 
-	if !spanner.isTwoPC() {
-		log.Error("spanner.execute.multistmt.txn.rollback.2pc.disable")
-		qr := &sqltypes.Result{Warnings: 1}
-		return qr, errors.Errorf("spanner.execute.multistmt.txn.rollback.error[twopc-disable]")
-	}
-
 	// transaction.
 	currentSession := sessions.getTxnSession(session)
 	txn = currentSession.transaction
 
-	// return err if query is "rollback" without begin a multi-transaction.
+	// "rollback" without begin a multi-transaction does nothing as mysql.
 	if txn == nil {
-		log.Error("spanner.execute.multistmt.txn.rollback.error.txn.not.begin")
-		qr := &sqltypes.Result{}
-		return qr, errors.Errorf("unsupported: rollback.without.txn.begin")
+		return &sqltypes.Result{}, nil
 	}
 
 	sessions.MultiStmtTxnBinding(session, nil, node, query)
+	txn.SetProfile(sessions.getProfile(session))
 	if err := txn.RollbackScatter(); err != nil {
 		log.Error("spanner.execute.multistmt.txn.rollback.scattr.error:[%v]", err)
 		return nil, err
@@ -147,24 +145,17 @@ func (spanner *Spanner) ExecuteCommit(session *driver.Session, query string, nod
 	sessions := spanner.sessions
 	var txn backend.Transaction
 
-	if !spanner.isTwoPC() {
-		log.Error("spanner.execute.multistmt.txn.commit.error.2pc.disable")
-		qr := &sqltypes.Result{Warnings: 1}
-		return qr, errors.Errorf("spanner.execute.multistmt.txn.commit.error:[twopc-disable]")
-	}
-
 	// transaction.
 	currentSession := sessions.getTxnSession(session)
 	txn = currentSession.transaction
 
-	// return err if "commit" was sent without begin a multi-transaction.
+	// "commit" without begin a multi-transaction does nothing as mysql.
 	if txn == nil {
-		log.Error("spanner.execute.multistmt.txn.commit.error.txn.not.begin")
-		qr := &sqltypes.Result{}
-		return qr, errors.Errorf("unsupported: commit.without.txn.begin")
+		return &sqltypes.Result{}, nil
 	}
 
 	sessions.MultiStmtTxnBinding(session, nil, node, query)
+	txn.SetProfile(sessions.getProfile(session))
 	if err := txn.CommitScatter(); err != nil {
 		log.Error("spanner.execute.multistmt.txn.commit.scattr.error:[%v]", err)
 		return nil, err
@@ -175,3 +166,69 @@ func (spanner *Spanner) ExecuteCommit(session *driver.Session, query string, nod
 	qr := &sqltypes.Result{}
 	return qr, nil
 }
+
+// ExecuteSavepoint used to execute multiple-statement transaction sql:
+// "savepoint", "rollback to savepoint" and "release savepoint".
+func (spanner *Spanner) ExecuteSavepoint(session *driver.Session, query string, node *sqlparser.Transaction) (*sqltypes.Result, error) {
+	var err error
+	log := spanner.log
+	sessions := spanner.sessions
+	var txn backend.Transaction
+
+	// transaction.
+	if txn, err = spanner.implicitBegin(session); err != nil {
+		return nil, err
+	}
+
+	// return err if the savepoint was sent without begin a multi-transaction.
+	if txn == nil {
+		log.Error("spanner.execute.multistmt.txn.savepoint.error.txn.not.begin")
+		qr := &sqltypes.Result{}
+		return qr, errors.Errorf("unsupported: savepoint.without.txn.begin")
+	}
+
+	sessions.MultiStmtTxnBinding(session, nil, node, query)
+	name := node.Savepoint.String()
+	switch node.Action {
+	case sqlparser.SavepointStr:
+		err = txn.Savepoint(name)
+	case sqlparser.RollbackToSavepointStr:
+		err = txn.RollbackToSavepoint(name)
+	case sqlparser.ReleaseSavepointStr:
+		err = txn.ReleaseSavepoint(name)
+	}
+	if err != nil {
+		log.Error("spanner.execute.multistmt.txn.%s.error:[%v]", node.Action, err)
+		return nil, err
+	}
+
+	// The txn is still in progress.
+	sessions.MultiStmtTxnUnBinding(session, false)
+	return &sqltypes.Result{}, nil
+}
+
+// implicitBegin returns the transaction of the session.
+// If the session is autocommit=0 and not in a transaction, a new transaction is started implicitly as mysql.
+func (spanner *Spanner) implicitBegin(session *driver.Session) (backend.Transaction, error) {
+	txSession := spanner.sessions.getTxnSession(session)
+	if txSession.transaction == nil && !txSession.getAutocommitVar() {
+		node := &sqlparser.Transaction{Action: sqlparser.BeginTxnStr}
+		if _, err := spanner.ExecuteBegin(session, sqlparser.BeginTxnStr, node); err != nil {
+			return nil, err
+		}
+	}
+	return txSession.transaction, nil
+}
+
+// implicitCommit used to commit the transaction of the session before the statement which causes an implicit commit,
+// such as DDL, BEGIN and SET autocommit=1.
+func (spanner *Spanner) implicitCommit(session *driver.Session, query string, node sqlparser.Statement) error {
+	txSession := spanner.sessions.getTxnSession(session)
+	if txSession.transaction == nil {
+		return nil
+	}
+
+	spanner.log.Warning("spanner.implicit.commit.by[%s].from.session[%v]", query, session.ID())
+	_, err := spanner.ExecuteCommit(session, query, node)
+	return err
+}
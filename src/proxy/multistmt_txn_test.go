@@ -67,7 +67,7 @@ func TestProxyHandleMStmtTxnRollback(t *testing.T) {
 		query := "rollback;"
 		fakedbs.AddQuery(query, fakedb.Result3)
 		_, err = client.FetchAll(query, -1)
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
 	}
 
 	client.Close()
@@ -93,7 +93,7 @@ func TestProxyHandleMStmtTxnCommit(t *testing.T) {
 		query := "commit;"
 		fakedbs.AddQuery(query, fakedb.Result3)
 		_, err := client.FetchAll(query, -1)
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
 	}
 
 	client.Close()
@@ -193,7 +193,7 @@ func TestProxyHandleMStmtTxnDoubleBegin(t *testing.T) {
 		query := "start transaction;"
 		fakedbs.AddQuery(query, fakedb.Result3)
 		_, err = client.FetchAll(query, -1)
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
 	}
 
 	client.Close()
@@ -314,21 +314,21 @@ func TestProxyHandleMStmtTxnTwoPCFalse(t *testing.T) {
 		query := "begin;"
 		fakedbs.AddQuery(query, fakedb.Result3)
 		_, err = client.FetchAll(query, -1)
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
 	}
 
 	{
 		query := "commit;"
 		fakedbs.AddQuery(query, fakedb.Result3)
 		_, err = client.FetchAll(query, -1)
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
 	}
 
 	{
 		query := "rollback;"
 		fakedbs.AddQuery(query, fakedb.Result3)
 		_, err = client.FetchAll(query, -1)
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
 	}
 
 	client.Close()
@@ -643,3 +643,264 @@ func TestProxyHandleMStmtTxnNOUseDB(t *testing.T) {
 
 	client1.Close()
 }
+
+func TestProxyHandleMStmtTxnSavepoint(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("XA .*", result1)
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("SAVEPOINT .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("ROLLBACK TO SAVEPOINT .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("RELEASE SAVEPOINT .*", &sqltypes.Result{})
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	// twopc disable.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("savepoint sp1", -1)
+		assert.NotNil(t, err)
+		client.Close()
+	}
+
+	proxy.SetTwoPC(true)
+	// without begin.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("savepoint sp1", -1)
+		want := "unsupported: savepoint.without.txn.begin (errno 1105) (sqlstate HY000)"
+		got := err.Error()
+		assert.Equal(t, want, got)
+		client.Close()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"begin",
+		"insert into test.t1(id, b) values(1, 1)",
+		"savepoint sp1",
+		"insert into test.t1(id, b) values(2, 2)",
+		"savepoint sp2",
+		"rollback to savepoint sp1",
+		"rollback to sp1",
+		"release savepoint sp1",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	// sp2 is removed by the rollback and sp1 is released.
+	{
+		_, err = client.FetchAll("rollback to savepoint sp2", -1)
+		want := "SAVEPOINT sp2 does not exist (errno 1105) (sqlstate HY000)"
+		got := err.Error()
+		assert.Equal(t, want, got)
+
+		_, err = client.FetchAll("release savepoint sp1", -1)
+		assert.NotNil(t, err)
+	}
+
+	// savepoint error.
+	{
+		fakedbs.ResetPatternErrors()
+		fakedbs.AddQueryErrorPattern("SAVEPOINT .*", errors.New("mock.savepoint.error"))
+		_, err = client.FetchAll("savepoint sp3", -1)
+		assert.NotNil(t, err)
+	}
+
+	_, err = client.FetchAll("commit", -1)
+	assert.Nil(t, err)
+}
+
+func TestProxyHandleMStmtTxnLocal(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	assert.Equal(t, false, proxy.conf.Proxy.TwopcEnable)
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("SAVEPOINT .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("ROLLBACK TO SAVEPOINT .*", &sqltypes.Result{})
+		fakedbs.AddQuery("BEGIN", &sqltypes.Result{})
+		fakedbs.AddQuery("COMMIT", &sqltypes.Result{})
+		fakedbs.AddQuery("ROLLBACK", &sqltypes.Result{})
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Begin, the local txn starts on the backend by the first statement.
+	{
+		querys := []string{
+			"begin",
+			"select * from t1",
+			"insert into t1(id, b) values(1, 1)",
+			"savepoint sp1",
+			"insert into t1(id, b) values(1, 2)",
+			"rollback to savepoint sp1",
+			"select * from t1",
+			"commit",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("BEGIN"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("COMMIT"))
+	}
+
+	// The write across the backends requires twopc.
+	{
+		_, err = client.FetchAll("begin", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1), (2, 2), (3, 3), (4, 4), (5, 5), (6, 6)", -1)
+		want := "txn.local.write.across.backends.unsupported[twopc-disable] (errno 1105) (sqlstate HY000)"
+		got := err.Error()
+		assert.Equal(t, want, got)
+		_, err = client.FetchAll("rollback", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("ROLLBACK"))
+	}
+}
+
+func TestProxyHandleMStmtTxnAutocommit(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("XA .*", result1)
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	proxy.SetTwoPC(true)
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	txSession := proxy.Spanner().sessions.getSession(client.ConnectionID())
+
+	// autocommit=0, the statement starts the txn implicitly.
+	{
+		_, err = client.FetchAll("set autocommit=0", -1)
+		assert.Nil(t, err)
+		assert.Nil(t, txSession.transaction)
+
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1), (2, 2)", -1)
+		assert.Nil(t, err)
+		assert.NotNil(t, txSession.transaction)
+
+		_, err = client.FetchAll("commit", -1)
+		assert.Nil(t, err)
+		assert.Nil(t, txSession.transaction)
+
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+		assert.NotNil(t, txSession.transaction)
+	}
+
+	// DDL commits the txn implicitly.
+	{
+		_, err = client.FetchAll("create table t2(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		assert.Nil(t, txSession.transaction)
+	}
+
+	// begin commits the txn implicitly.
+	{
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+		txn := txSession.transaction
+		assert.NotNil(t, txn)
+
+		_, err = client.FetchAll("begin", -1)
+		assert.Nil(t, err)
+		assert.NotNil(t, txSession.transaction)
+		assert.NotEqual(t, txn, txSession.transaction)
+	}
+
+	// autocommit=1 commits the txn implicitly.
+	{
+		_, err = client.FetchAll("set autocommit=1", -1)
+		assert.Nil(t, err)
+		assert.Nil(t, txSession.transaction)
+
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+		assert.Nil(t, txSession.transaction)
+	}
+
+	// implicit commit error.
+	{
+		_, err = client.FetchAll("set autocommit=off", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+
+		fakedbs.AddQueryErrorPattern("XA END .*", errors.New("mock.xa.end.error"))
+		_, err = client.FetchAll("set autocommit=1", -1)
+		assert.NotNil(t, err)
+		fakedbs.ResetPatternErrors()
+		_, err = client.FetchAll("rollback", -1)
+		assert.Nil(t, err)
+	}
+}
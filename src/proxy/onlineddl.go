@@ -0,0 +1,1304 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"config"
+	"planner"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// onlineDDLCutoverTimeout(ms) is the timeout of the RENAME on the backend, the DML of the table
+	// are blocked in radon during the cutover.
+	onlineDDLCutoverTimeout = 3000
+
+	// onlineDDLCutoverRetries is the max attempts of the cutover.
+	onlineDDLCutoverRetries = 3
+
+	// onlineDDLLagInterval is the interval of the replica lag checks.
+	onlineDDLLagInterval = time.Second
+
+	// onlineDDLHistorySize is the max number of the finished jobs kept for the status.
+	onlineDDLHistorySize = 64
+
+	// onlineDDLTriggerPrefix is the prefix of the triggers created on the sub-table.
+	onlineDDLTriggerPrefix = "radon_osc_"
+
+	// onlineDDLMaxNameLen is the max length of the MySQL table and trigger names.
+	onlineDDLMaxNameLen = 64
+
+	// onlineDDLDir is the dir in the metadir where the jobs are persisted, one file per owner peer.
+	onlineDDLDir = "onlineddl"
+)
+
+// The states of the online ddl job.
+const (
+	onlineDDLStateRunning  = "running"
+	onlineDDLStatePaused   = "paused"
+	onlineDDLStateCutover  = "cutover"
+	onlineDDLStateDone     = "done"
+	onlineDDLStateFailed   = "failed"
+	onlineDDLStateCanceled = "canceled"
+	// onlineDDLStateDiverged means the cutover is broken: the sub-tables on some backends are swapped and can't
+	// be reverted, the job is recorded in the RADON DDL STATUS and the sub-tables must be fixed by hand.
+	onlineDDLStateDiverged = "diverged"
+)
+
+// onlineDDLSegment tuple, the shadow copy of one sub-table.
+type onlineDDLSegment struct {
+	backend string
+	table   string
+	shadow  string
+	old     string
+	// columns are the columns copied to the shadow table.
+	columns []string
+	// keys are the primary key columns which the rows are copied in order of.
+	keys     []string
+	estimate uint64
+	copied   uint64
+	done     int32
+}
+
+func newOnlineDDLSegment(backend string, table string) *onlineDDLSegment {
+	return &onlineDDLSegment{
+		backend: backend,
+		table:   table,
+		shadow:  fmt.Sprintf("_%s_new", table),
+		old:     fmt.Sprintf("_%s_old", table),
+	}
+}
+
+// trigger returns the trigger name of the event.
+func (seg *onlineDDLSegment) trigger(event string) string {
+	return fmt.Sprintf("%s%s_%s", onlineDDLTriggerPrefix, seg.table, event)
+}
+
+// onlineDDLJob tuple, the online schema change of a table.
+type onlineDDLJob struct {
+	id       uint64
+	database string
+	table    string
+	node     *sqlparser.DDL
+	segments []*onlineDDLSegment
+	start    time.Time
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	state    string
+	canceled bool
+	err      error
+	end      time.Time
+	// lags are the replica lags of the backends, -1 if it's unknown.
+	lags map[string]int
+	// swapped are the backends whose sub-tables are swapped by the broken cutover.
+	swapped map[string]bool
+}
+
+// onlineDDLJobJSON is the persisted job, the jobs of all the peers are kept in one file by the owner.
+type onlineDDLJobJSON struct {
+	ID       uint64                  `json:"id"`
+	Owner    string                  `json:"owner"`
+	Database string                  `json:"database"`
+	Table    string                  `json:"table"`
+	Alter    string                  `json:"alter"`
+	State    string                  `json:"state"`
+	Segments []*onlineDDLSegmentJSON `json:"segments"`
+	Start    time.Time               `json:"start"`
+	End      time.Time               `json:"end"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+// onlineDDLSegmentJSON is the persisted segment.
+type onlineDDLSegmentJSON struct {
+	Backend string `json:"backend"`
+	Table   string `json:"table"`
+}
+
+// toJSON returns the persisted job, the caller must hold the job.mu.
+func (job *onlineDDLJob) toJSON(owner string) *onlineDDLJobJSON {
+	j := &onlineDDLJobJSON{
+		ID:       job.id,
+		Owner:    owner,
+		Database: job.database,
+		Table:    job.table,
+		Alter:    sqlparser.String(job.node),
+		State:    job.state,
+		Start:    job.start,
+		End:      job.end,
+	}
+	if job.err != nil {
+		j.Error = job.err.Error()
+	}
+	for _, seg := range job.segments {
+		j.Segments = append(j.Segments, &onlineDDLSegmentJSON{Backend: seg.backend, Table: seg.table})
+	}
+	return j
+}
+
+// checkpoint used to wait while the job is paused, it returns error if the job is canceled or failed.
+func (job *onlineDDLJob) checkpoint() error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	for job.state == onlineDDLStatePaused && !job.canceled && job.err == nil {
+		job.cond.Wait()
+	}
+	if job.canceled {
+		return errors.Errorf("online.ddl.job[%d].canceled", job.id)
+	}
+	return job.err
+}
+
+// fail used to record the first error of the job, the other workers stop at the next checkpoint.
+func (job *onlineDDLJob) fail(err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.err == nil && !job.canceled {
+		job.err = err
+	}
+	job.cond.Broadcast()
+}
+
+func (job *onlineDDLJob) setState(state string) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.state = state
+	job.cond.Broadcast()
+}
+
+// diverged returns true if the swapped sub-tables of the broken cutover can't be reverted.
+func (job *onlineDDLJob) diverged() bool {
+	return len(job.swapped) > 0
+}
+
+func (job *onlineDDLJob) setLag(backend string, lag int) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.lags[backend] = lag
+}
+
+// finished returns true if the job is done, failed, canceled or diverged.
+func (job *onlineDDLJob) finished() bool {
+	switch job.state {
+	case onlineDDLStateDone, onlineDDLStateFailed, onlineDDLStateCanceled, onlineDDLStateDiverged:
+		return true
+	}
+	return false
+}
+
+// OnlineDDL runs the online schema change jobs(RADON ALTER) in the background.
+// Every sub-table is altered by the shadow copy:
+//  1. create the shadow table like the sub-table and alter it.
+//  2. create the triggers on the sub-table to apply the changes to the shadow table.
+//  3. copy the rows to the shadow table chunk by chunk in order of the primary key, throttled by the replica lag.
+//  4. cut over all the sub-tables together: the DML of the table are blocked on all the radon peers by the gates,
+//     and the sub-tables on each backend are swapped with the shadow tables by one atomic RENAME.
+//
+// The jobs are persisted in the metadir by the owner peer, the job broken by the restart of the peer is resolved
+// when it starts again.
+type OnlineDDL struct {
+	log     *xlog.Log
+	spanner *Spanner
+	// fence used to gate the DML of the table on all the peers during the cutover, only this peer is gated if nil.
+	fence *PeerFence
+	mu    sync.Mutex
+	wg    sync.WaitGroup
+	seq   uint64
+	jobs  []*onlineDDLJob
+}
+
+// NewOnlineDDL creates the OnlineDDL.
+func NewOnlineDDL(log *xlog.Log, spanner *Spanner) *OnlineDDL {
+	return &OnlineDDL{
+		log:     log,
+		spanner: spanner,
+	}
+}
+
+// SetPeerFence used to set the fence of the peers for the cutover.
+func (o *OnlineDDL) SetPeerFence(fence *PeerFence) {
+	o.fence = fence
+}
+
+// owner returns the peer address which owns the jobs.
+func (o *OnlineDDL) owner() string {
+	return o.spanner.conf.Proxy.PeerAddress
+}
+
+// jobsFile returns the file of the jobs owned by this peer, each peer writes its own file only.
+func (o *OnlineDDL) jobsFile() string {
+	return path.Join(o.spanner.conf.Proxy.MetaDir, onlineDDLDir, o.owner()+".json")
+}
+
+// readJobs returns the persisted jobs of this peer.
+func (o *OnlineDDL) readJobs() ([]*onlineDDLJobJSON, error) {
+	data, err := ioutil.ReadFile(o.jobsFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	var jobs []*onlineDDLJobJSON
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return jobs, nil
+}
+
+// flush used to write the jobs of this peer to its file in the metadir.
+func (o *OnlineDDL) flush() {
+	log := o.log
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	owner := o.owner()
+	jobs := make([]*onlineDDLJobJSON, 0, len(o.jobs))
+	for _, job := range o.jobs {
+		job.mu.Lock()
+		jobs = append(jobs, job.toJSON(owner))
+		job.mu.Unlock()
+	}
+
+	file := o.jobsFile()
+	if err := os.MkdirAll(path.Dir(file), 0744); err != nil {
+		log.Error("proxy.online.ddl.flush.jobs.mkdir.error:%+v", err)
+		return
+	}
+	if err := config.WriteConfig(file, jobs); err != nil {
+		log.Error("proxy.online.ddl.flush.jobs.error:%+v", err)
+		return
+	}
+	if err := config.UpdateVersion(o.spanner.conf.Proxy.MetaDir); err != nil {
+		log.Error("proxy.online.ddl.flush.jobs.update.version.error:%+v", err)
+	}
+}
+
+// Init used to load the jobs of this peer, and resolve the jobs broken by the restart:
+// the running or paused job drops its triggers and shadow tables and fails, the job in cutover is done if all the
+// sub-tables are swapped, failed if none is swapped, or else diverged.
+func (o *OnlineDDL) Init() error {
+	log := o.log
+	persisted, err := o.readJobs()
+	if err != nil {
+		return err
+	}
+
+	var broken []*onlineDDLJob
+	for _, j := range persisted {
+		stmt, err := sqlparser.Parse(j.Alter)
+		if err != nil {
+			return errors.Errorf("online.ddl.job[%d].parse.alter[%s].error:%v", j.ID, j.Alter, err)
+		}
+		node, ok := stmt.(*sqlparser.DDL)
+		if !ok {
+			return errors.Errorf("online.ddl.job[%d].alter[%s].is.not.ddl", j.ID, j.Alter)
+		}
+		job := &onlineDDLJob{
+			id:       j.ID,
+			database: j.Database,
+			table:    j.Table,
+			node:     node,
+			start:    j.Start,
+			state:    j.State,
+			end:      j.End,
+			lags:     make(map[string]int),
+		}
+		if j.Error != "" {
+			job.err = errors.New(j.Error)
+		}
+		job.cond = sync.NewCond(&job.mu)
+		for _, seg := range j.Segments {
+			job.segments = append(job.segments, newOnlineDDLSegment(seg.Backend, seg.Table))
+		}
+		if !job.finished() {
+			broken = append(broken, job)
+		}
+		o.jobs = append(o.jobs, job)
+		if job.id > o.seq {
+			o.seq = job.id
+		}
+	}
+
+	for _, job := range broken {
+		log.Warning("proxy.online.ddl.job[%d].broken.by.restart.in.state[%s].resolving", job.id, job.state)
+		var err error
+		if job.state == onlineDDLStateCutover {
+			err = o.resolveCutover(job)
+		} else {
+			err = errors.Errorf("online.ddl.job[%d].interrupted.by.restart", job.id)
+			o.cleanup(job)
+		}
+		o.finish(job, err)
+	}
+	if len(broken) > 0 {
+		o.flush()
+	}
+	return nil
+}
+
+// resolveCutover used to resolve the job broken in the cutover by the swapped sub-tables, which have the old tables.
+func (o *OnlineDDL) resolveCutover(job *onlineDDLJob) error {
+	swapped := make(map[string]bool)
+	for _, seg := range job.segments {
+		exists, err := o.exists(seg.backend, job.database, seg.old)
+		if err != nil {
+			// Unknown, it's diverged until checked by hand.
+			swapped[seg.backend] = true
+			continue
+		}
+		if exists {
+			swapped[seg.backend] = true
+		}
+	}
+
+	switch {
+	case len(swapped) == 0:
+		o.cleanup(job)
+		return errors.Errorf("online.ddl.job[%d].interrupted.by.restart", job.id)
+	case len(swapped) == len(o.backends(job)):
+		o.dropOld(job)
+		return nil
+	}
+	job.swapped = swapped
+	return errors.Errorf("online.ddl.job[%d].interrupted.by.restart.in.cutover", job.id)
+}
+
+// backends returns the backends of the job.
+func (o *OnlineDDL) backends(job *onlineDDLJob) map[string]bool {
+	backends := make(map[string]bool)
+	for _, seg := range job.segments {
+		backends[seg.backend] = true
+	}
+	return backends
+}
+
+// exists returns true if the table exists on the backend.
+func (o *OnlineDDL) exists(backend string, database string, table string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM information_schema.TABLES WHERE TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s'", database, table)
+	qr, err := o.execute(backend, query, 0)
+	if err != nil {
+		return false, err
+	}
+	return len(qr.Rows) > 0, nil
+}
+
+// Close used to cancel the running jobs and wait for them.
+func (o *OnlineDDL) Close() {
+	o.mu.Lock()
+	for _, job := range o.jobs {
+		job.mu.Lock()
+		if job.state != onlineDDLStateCutover {
+			job.canceled = true
+			job.cond.Broadcast()
+		}
+		job.mu.Unlock()
+	}
+	o.mu.Unlock()
+	o.wg.Wait()
+}
+
+// busy returns error if the table is being altered.
+func (o *OnlineDDL) busy(database string, table string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.busyLocked(database, table)
+}
+
+func (o *OnlineDDL) busyLocked(database string, table string) error {
+	for _, job := range o.jobs {
+		job.mu.Lock()
+		finished := job.finished()
+		job.mu.Unlock()
+		if !finished && job.database == database && job.table == table {
+			return errors.Errorf("online.ddl.job[%d].is.altering.table[%s.%s]", job.id, database, table)
+		}
+	}
+	return nil
+}
+
+// owns returns true if the table on the backend is the shadow or old table of the unfinished or diverged job.
+func (o *OnlineDDL) owns(database string, backend string, table string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, job := range o.jobs {
+		job.mu.Lock()
+		owned := !job.finished() || job.state == onlineDDLStateDiverged
+		job.mu.Unlock()
+		if !owned || job.database != database {
+			continue
+		}
+		for _, seg := range job.segments {
+			if seg.backend == backend && (seg.shadow == table || seg.old == table) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enterDML used to wait for the cutover of the 'db.table's, the returned func must be called after the DML.
+// The gates are held by the cutover on all the peers.
+func (o *OnlineDDL) enterDML(tables []string) (func(), error) {
+	// The cutover may skip this peer once the read lease granted to it has expired.
+	if o.fence != nil {
+		if err := o.fence.Leased(); err != nil {
+			return nil, err
+		}
+	}
+	return o.spanner.scatter.GateRLock(tables), nil
+}
+
+// gate used to hold the gates of the 'db.table's on all the peers for the cutover, the returned func releases them.
+func (o *OnlineDDL) gate(xid string, tables []string) (func(), error) {
+	if o.fence == nil {
+		scatter := o.spanner.scatter
+		if err := scatter.GateLock(xid, tables, 0); err != nil {
+			return nil, err
+		}
+		return func() { scatter.FenceUnlock(xid) }, nil
+	}
+	if err := o.fence.FenceTables(xid, tables); err != nil {
+		return nil, err
+	}
+	return func() { o.fence.Unfence(xid, nil) }, nil
+}
+
+// Start used to start the online schema change job of the table.
+func (o *OnlineDDL) Start(database string, table string, node *sqlparser.DDL) (*onlineDDLJob, error) {
+	router := o.spanner.router
+	segments, err := router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	job := &onlineDDLJob{
+		database: database,
+		table:    table,
+		node:     node,
+		start:    time.Now(),
+		state:    onlineDDLStateRunning,
+		lags:     make(map[string]int),
+	}
+	job.cond = sync.NewCond(&job.mu)
+	for _, segment := range segments {
+		seg := newOnlineDDLSegment(segment.Backend, segment.Table)
+		if len(seg.shadow) > onlineDDLMaxNameLen || len(seg.trigger("ins")) > onlineDDLMaxNameLen {
+			return nil, errors.Errorf("unsupported: online.ddl.table[%s].name.too.long", segment.Table)
+		}
+		job.segments = append(job.segments, seg)
+	}
+
+	o.mu.Lock()
+	if err := o.busyLocked(database, table); err != nil {
+		o.mu.Unlock()
+		return nil, err
+	}
+	o.seq++
+	job.id = o.seq
+	o.jobs = append(o.jobs, job)
+	o.wg.Add(1)
+	o.mu.Unlock()
+
+	o.flush()
+	o.log.Warning("proxy.online.ddl.job[%d].start[%s].segments[%d]", job.id, sqlparser.String(node), len(job.segments))
+	go o.run(job)
+	return job, nil
+}
+
+func (o *OnlineDDL) lookup(id uint64) (*onlineDDLJob, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, job := range o.jobs {
+		if job.id == id {
+			return job, nil
+		}
+	}
+	return nil, errors.Errorf("online.ddl.job[%d].not.found", id)
+}
+
+// Pause used to pause the copy of the job.
+func (o *OnlineDDL) Pause(id uint64) error {
+	job, err := o.lookup(id)
+	if err != nil {
+		return err
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.state != onlineDDLStateRunning {
+		return errors.Errorf("online.ddl.job[%d].can.not.be.paused.in.state[%s]", id, job.state)
+	}
+	job.state = onlineDDLStatePaused
+	o.log.Warning("proxy.online.ddl.job[%d].paused", id)
+	return nil
+}
+
+// Resume used to resume the paused job.
+func (o *OnlineDDL) Resume(id uint64) error {
+	job, err := o.lookup(id)
+	if err != nil {
+		return err
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.state != onlineDDLStatePaused {
+		return errors.Errorf("online.ddl.job[%d].can.not.be.resumed.in.state[%s]", id, job.state)
+	}
+	job.state = onlineDDLStateRunning
+	job.cond.Broadcast()
+	o.log.Warning("proxy.online.ddl.job[%d].resumed", id)
+	return nil
+}
+
+// Cancel used to cancel the job, the shadow tables and the triggers are dropped.
+func (o *OnlineDDL) Cancel(id uint64) error {
+	job, err := o.lookup(id)
+	if err != nil {
+		return err
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.state != onlineDDLStateRunning && job.state != onlineDDLStatePaused {
+		return errors.Errorf("online.ddl.job[%d].can.not.be.canceled.in.state[%s]", id, job.state)
+	}
+	job.canceled = true
+	job.cond.Broadcast()
+	o.log.Warning("proxy.online.ddl.job[%d].canceling", id)
+	return nil
+}
+
+// Status returns the status of the jobs.
+func (o *OnlineDDL) Status() *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Id", Type: querypb.Type_UINT64},
+		{Name: "Database", Type: querypb.Type_VARCHAR},
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Alter", Type: querypb.Type_VARCHAR},
+		{Name: "State", Type: querypb.Type_VARCHAR},
+		{Name: "Segments", Type: querypb.Type_VARCHAR},
+		{Name: "Copied_Rows", Type: querypb.Type_UINT64},
+		{Name: "Estimated_Rows", Type: querypb.Type_UINT64},
+		{Name: "Progress", Type: querypb.Type_VARCHAR},
+		{Name: "Replica_Lag", Type: querypb.Type_INT64},
+		{Name: "Elapsed", Type: querypb.Type_VARCHAR},
+		{Name: "Error", Type: querypb.Type_VARCHAR},
+	}
+
+	o.mu.Lock()
+	jobs := append([]*onlineDDLJob(nil), o.jobs...)
+	o.mu.Unlock()
+	for _, job := range jobs {
+		var done int
+		var copied, estimate uint64
+		var progress float64
+		for _, seg := range job.segments {
+			segCopied := atomic.LoadUint64(&seg.copied)
+			copied += segCopied
+			estimate += seg.estimate
+			switch {
+			case atomic.LoadInt32(&seg.done) == 1:
+				done++
+				progress++
+			case seg.estimate > 0:
+				// The estimate of the rows may be less than the real.
+				progress += math.Min(float64(segCopied)/float64(seg.estimate), 0.99)
+			}
+		}
+
+		job.mu.Lock()
+		state, end, jobErr := job.state, job.end, job.err
+		lag := sqltypes.NULL
+		max := 0
+		for _, l := range job.lags {
+			if l < 0 {
+				max = -1
+				break
+			}
+			if l > max {
+				max = l
+			}
+		}
+		if max >= 0 && len(job.lags) > 0 {
+			lag = sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", max)))
+		}
+		job.mu.Unlock()
+
+		if state == onlineDDLStateDone {
+			progress = float64(len(job.segments))
+		}
+		if len(job.segments) > 0 {
+			progress = progress * 100 / float64(len(job.segments))
+		}
+		if end.IsZero() {
+			end = time.Now()
+		}
+		errMsg := ""
+		if jobErr != nil {
+			errMsg = jobErr.Error()
+		}
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", job.id))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.database)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.table)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(sqlparser.String(job.node))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(state)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("%d/%d", done, len(job.segments)))),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", copied))),
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", estimate))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("%.2f%%", progress))),
+			lag,
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(end.Sub(job.start).Truncate(time.Second).String())),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(errMsg)),
+		})
+	}
+	return qr
+}
+
+// execute used to execute the query on the backend, the query is killed if the timeout(ms) is exceeded.
+func (o *OnlineDDL) execute(backend string, query string, timeout int) (*sqltypes.Result, error) {
+	txn, err := o.spanner.scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(timeout)
+	return txn.ExecuteOnThisBackend(backend, query)
+}
+
+func (o *OnlineDDL) run(job *onlineDDLJob) {
+	defer o.wg.Done()
+	log := o.log
+
+	err := o.prepare(job)
+	if err == nil {
+		err = o.copy(job)
+	}
+	if err == nil {
+		err = o.cutover(job)
+	}
+	if err != nil {
+		log.Error("proxy.online.ddl.job[%d].error:%+v", job.id, err)
+		// The diverged sub-tables are left as they are for the fixing by hand.
+		if !job.diverged() {
+			o.cleanup(job)
+		}
+	}
+	o.finish(job, err)
+	o.flush()
+}
+
+// finish used to set the final state of the job by the error, the diverged job is recorded in the ddl jobs.
+func (o *OnlineDDL) finish(job *onlineDDLJob, err error) {
+	log := o.log
+	job.mu.Lock()
+	switch {
+	case err == nil:
+		job.state = onlineDDLStateDone
+	case job.diverged():
+		job.state = onlineDDLStateDiverged
+		job.err = err
+	case job.canceled:
+		job.state = onlineDDLStateCanceled
+		job.err = nil
+	default:
+		job.state = onlineDDLStateFailed
+		job.err = err
+	}
+	job.end = time.Now()
+	job.mu.Unlock()
+
+	if job.diverged() {
+		o.spanner.ddlJobs.addDiverged(job)
+	}
+
+	o.mu.Lock()
+	// Trim the oldest finished jobs.
+	if len(o.jobs) > onlineDDLHistorySize {
+		jobs := make([]*onlineDDLJob, 0, len(o.jobs))
+		trim := len(o.jobs) - onlineDDLHistorySize
+		for _, j := range o.jobs {
+			j.mu.Lock()
+			finished := j.finished()
+			j.mu.Unlock()
+			if finished && trim > 0 {
+				trim--
+				continue
+			}
+			jobs = append(jobs, j)
+		}
+		o.jobs = jobs
+	}
+	o.mu.Unlock()
+	log.Warning("proxy.online.ddl.job[%d].%s[duration:%v]", job.id, job.state, job.end.Sub(job.start))
+}
+
+// prepare used to create the shadow tables and the triggers.
+func (o *OnlineDDL) prepare(job *onlineDDLJob) error {
+	db := job.database
+	for _, seg := range job.segments {
+		if err := job.checkpoint(); err != nil {
+			return err
+		}
+
+		// Drop the leftovers of the broken job.
+		querys := onlineDDLCleanupQuerys(db, seg)
+		querys = append(querys, fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s`", db, seg.old))
+		// The shadow table.
+		alter := *job.node
+		alter.Table = sqlparser.TableName{
+			Name:      sqlparser.NewTableIdent(seg.shadow),
+			Qualifier: sqlparser.NewTableIdent(db),
+		}
+		alter.NewName = alter.Table
+		querys = append(querys,
+			fmt.Sprintf("CREATE TABLE `%s`.`%s` LIKE `%s`.`%s`", db, seg.shadow, db, seg.table),
+			sqlparser.String(&alter),
+		)
+		for _, query := range querys {
+			if _, err := o.execute(seg.backend, query, 0); err != nil {
+				return err
+			}
+		}
+
+		// The columns in both the tables are copied.
+		oldColumns, err := o.columns(seg.backend, fmt.Sprintf("SHOW COLUMNS FROM `%s`.`%s`", db, seg.table), "Field")
+		if err != nil {
+			return err
+		}
+		newColumns, err := o.columns(seg.backend, fmt.Sprintf("SHOW COLUMNS FROM `%s`.`%s`", db, seg.shadow), "Field")
+		if err != nil {
+			return err
+		}
+		olds := make(map[string]bool)
+		for _, column := range oldColumns {
+			olds[strings.ToLower(column)] = true
+		}
+		copied := make(map[string]bool)
+		for _, column := range newColumns {
+			if olds[strings.ToLower(column)] {
+				seg.columns = append(seg.columns, column)
+				copied[strings.ToLower(column)] = true
+			}
+		}
+
+		// The rows are copied in order of the primary key, and the triggers apply the changes by it.
+		keys, err := o.columns(seg.backend, fmt.Sprintf("SHOW KEYS FROM `%s`.`%s` WHERE Key_name = 'PRIMARY'", db, seg.table), "Column_name")
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return errors.Errorf("unsupported: online.ddl.table[%s].without.primary.key", seg.table)
+		}
+		for _, key := range keys {
+			if !copied[strings.ToLower(key)] {
+				return errors.Errorf("unsupported: online.ddl.primary.key.column[%s].is.dropped", key)
+			}
+		}
+		seg.keys = keys
+
+		qr, err := o.execute(seg.backend, fmt.Sprintf("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s'", db, seg.table), 0)
+		if err != nil {
+			return err
+		}
+		if len(qr.Rows) > 0 {
+			seg.estimate, _ = strconv.ParseUint(qr.Rows[0][0].ToString(), 10, 64)
+		}
+
+		for _, query := range onlineDDLTriggerQuerys(db, seg) {
+			if _, err := o.execute(seg.backend, query, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columns returns the values of the column of the query result.
+func (o *OnlineDDL) columns(backend string, query string, column string) ([]string, error) {
+	qr, err := o.execute(backend, query, 0)
+	if err != nil {
+		return nil, err
+	}
+	idx := -1
+	for i, field := range qr.Fields {
+		if strings.EqualFold(field.Name, column) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, errors.Errorf("online.ddl.column[%s].not.found.in.result.of[%s]", column, query)
+	}
+	var values []string
+	for _, row := range qr.Rows {
+		values = append(values, row[idx].ToString())
+	}
+	return values, nil
+}
+
+// copy used to copy the rows to the shadow tables, the backends are copied in parallel.
+func (o *OnlineDDL) copy(job *onlineDDLJob) error {
+	var wg sync.WaitGroup
+	confs := make(map[string]*config.BackendConfig)
+	for _, conf := range o.spanner.scatter.BackendConfigsClone() {
+		confs[conf.Name] = conf
+	}
+	backends := make(map[string][]*onlineDDLSegment)
+	var order []string
+	for _, seg := range job.segments {
+		if _, ok := backends[seg.backend]; !ok {
+			order = append(order, seg.backend)
+		}
+		backends[seg.backend] = append(backends[seg.backend], seg)
+	}
+	for _, backend := range order {
+		wg.Add(1)
+		go func(backend string, segs []*onlineDDLSegment) {
+			defer wg.Done()
+			lag := &onlineDDLLag{conf: confs[backend]}
+			defer lag.close()
+			for _, seg := range segs {
+				if err := o.copySegment(job, seg, lag); err != nil {
+					job.fail(err)
+					return
+				}
+			}
+		}(backend, backends[backend])
+	}
+	wg.Wait()
+	return job.checkpoint()
+}
+
+// copySegment used to copy the rows of the sub-table chunk by chunk, the chunk is (last, upper].
+func (o *OnlineDDL) copySegment(job *onlineDDLJob, seg *onlineDDLSegment, lag *onlineDDLLag) error {
+	db := job.database
+	chunkSize := o.spanner.conf.Proxy.OnlineDDLChunkSize
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultProxyConfig().OnlineDDLChunkSize
+	}
+	keys := onlineDDLColumnList(seg.keys)
+	columns := onlineDDLColumnList(seg.columns)
+
+	var last []sqltypes.Value
+	for {
+		if err := o.throttle(job, seg.backend, lag); err != nil {
+			return err
+		}
+
+		// The upper bound of the chunk, nil if it's the last chunk.
+		var upper []sqltypes.Value
+		var where []string
+		if last != nil {
+			where = append(where, fmt.Sprintf("(%s) > %s", keys, onlineDDLValueTuple(last)))
+		}
+		query := fmt.Sprintf("SELECT %s FROM `%s`.`%s` FORCE INDEX(PRIMARY)%s ORDER BY %s LIMIT 1 OFFSET %d", keys, db, seg.table, onlineDDLWhere(where), keys, chunkSize-1)
+		qr, err := o.execute(seg.backend, query, 0)
+		if err != nil {
+			return err
+		}
+		if len(qr.Rows) > 0 {
+			upper = qr.Rows[0]
+			where = append(where, fmt.Sprintf("(%s) <= %s", keys, onlineDDLValueTuple(upper)))
+		}
+
+		query = fmt.Sprintf("INSERT LOW_PRIORITY IGNORE INTO `%s`.`%s`(%s) SELECT %s FROM `%s`.`%s` FORCE INDEX(PRIMARY)%s LOCK IN SHARE MODE", db, seg.shadow, columns, columns, db, seg.table, onlineDDLWhere(where))
+		if qr, err = o.execute(seg.backend, query, 0); err != nil {
+			return err
+		}
+		atomic.AddUint64(&seg.copied, qr.RowsAffected)
+		if upper == nil {
+			break
+		}
+		last = upper
+	}
+	atomic.StoreInt32(&seg.done, 1)
+	return nil
+}
+
+// throttle used to wait until the replica lag of the backend is less than the max lag.
+// The job fails if the lag stays over the max lag or unknown for the lag timeout, the paused time is not counted.
+func (o *OnlineDDL) throttle(job *onlineDDLJob, backend string, lag *onlineDDLLag) error {
+	log := o.log
+	maxLag := o.spanner.conf.Proxy.OnlineDDLMaxLag
+	timeout := time.Duration(o.spanner.conf.Proxy.OnlineDDLLagTimeout) * time.Second
+	var waited time.Duration
+	for {
+		if err := job.checkpoint(); err != nil {
+			return err
+		}
+		n, err := lag.check()
+		if err != nil {
+			log.Warning("proxy.online.ddl.job[%d].backend[%s].lag.check.error:%v", job.id, backend, err)
+			n = -1
+		}
+		job.setLag(backend, n)
+		if n >= 0 && n <= maxLag {
+			return nil
+		}
+		if waited >= timeout {
+			return errors.Errorf("online.ddl.job[%d].backend[%s].lag[%d].over.max.lag[%d].for[%v]", job.id, backend, n, maxLag, waited)
+		}
+		time.Sleep(onlineDDLLagInterval)
+		waited += onlineDDLLagInterval
+	}
+}
+
+// cutover used to swap the sub-tables with the shadow tables on all the backends.
+func (o *OnlineDDL) cutover(job *onlineDDLJob) error {
+	log := o.log
+	job.setState(onlineDDLStateCutover)
+	o.flush()
+
+	var err error
+	for i := 0; i < onlineDDLCutoverRetries; i++ {
+		if err = o.swap(job, i+1); err == nil || job.diverged() {
+			break
+		}
+		log.Warning("proxy.online.ddl.job[%d].cutover.attempt[%d].error:%v", job.id, i+1, err)
+		time.Sleep(onlineDDLLagInterval)
+	}
+	if err != nil {
+		return err
+	}
+	o.spanner.ClearPlanCache()
+	o.dropOld(job)
+	return nil
+}
+
+// dropOld used to drop the old tables after the cutover, the triggers are dropped with the old tables.
+func (o *OnlineDDL) dropOld(job *onlineDDLJob) {
+	for _, seg := range job.segments {
+		query := fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s`", job.database, seg.old)
+		if _, err := o.execute(seg.backend, query, 0); err != nil {
+			o.log.Error("proxy.online.ddl.job[%d].drop.old.table[%s].on[%s].error:%v", job.id, seg.old, seg.backend, err)
+		}
+	}
+}
+
+// swap used to rename the sub-tables and the shadow tables on each backend atomically,
+// the backends renamed are reverted if any of them fails. The DML of the table are gated on all the peers.
+// If a renamed backend can't be reverted, the job is diverged by the job.swapped.
+func (o *OnlineDDL) swap(job *onlineDDLJob, attempt int) error {
+	log := o.log
+	db := job.database
+	xid := fmt.Sprintf("%s%d_%d", onlineDDLTriggerPrefix, job.id, attempt)
+	release, err := o.gate(xid, []string{fmt.Sprintf("%s.%s", db, job.table)})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	renames := make(map[string][]string)
+	reverts := make(map[string][]string)
+	olds := make(map[string]string)
+	var order []string
+	for _, seg := range job.segments {
+		if _, ok := renames[seg.backend]; !ok {
+			order = append(order, seg.backend)
+			olds[seg.backend] = seg.old
+		}
+		renames[seg.backend] = append(renames[seg.backend],
+			fmt.Sprintf("`%s`.`%s` TO `%s`.`%s`", db, seg.table, db, seg.old),
+			fmt.Sprintf("`%s`.`%s` TO `%s`.`%s`", db, seg.shadow, db, seg.table))
+		reverts[seg.backend] = append(reverts[seg.backend],
+			fmt.Sprintf("`%s`.`%s` TO `%s`.`%s`", db, seg.table, db, seg.shadow),
+			fmt.Sprintf("`%s`.`%s` TO `%s`.`%s`", db, seg.old, db, seg.table))
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var swapErr error
+	failed := make(map[string]bool)
+	for _, backend := range order {
+		wg.Add(1)
+		go func(backend string) {
+			defer wg.Done()
+			query := fmt.Sprintf("RENAME TABLE %s", strings.Join(renames[backend], ", "))
+			if _, err := o.execute(backend, query, onlineDDLCutoverTimeout); err != nil {
+				mu.Lock()
+				failed[backend] = true
+				if swapErr == nil {
+					swapErr = err
+				}
+				mu.Unlock()
+			}
+		}(backend)
+	}
+	wg.Wait()
+	if swapErr == nil {
+		return nil
+	}
+
+	// The RENAME is atomic, the backends renamed are reverted. The backend which the RENAME fails on may be
+	// renamed too if the reply is lost, it's checked by the old table.
+	swapped := make(map[string]bool)
+	for _, backend := range order {
+		if failed[backend] {
+			exists, err := o.exists(backend, db, olds[backend])
+			if err != nil {
+				log.Error("proxy.online.ddl.job[%d].check.rename.on[%s].error:%v", job.id, backend, err)
+				swapped[backend] = true
+				continue
+			}
+			if !exists {
+				continue
+			}
+		}
+		query := fmt.Sprintf("RENAME TABLE %s", strings.Join(reverts[backend], ", "))
+		if _, err := o.execute(backend, query, 0); err != nil {
+			log.Error("proxy.online.ddl.job[%d].revert.on[%s].error:%v", job.id, backend, err)
+			swapped[backend] = true
+		}
+	}
+	if len(swapped) > 0 {
+		job.swapped = swapped
+		var backends []string
+		for backend := range swapped {
+			backends = append(backends, backend)
+		}
+		sort.Strings(backends)
+		return errors.Errorf("online.ddl.job[%d].cutover.diverged.on%v:%v", job.id, backends, swapErr)
+	}
+	return swapErr
+}
+
+// cleanup used to drop the triggers and the shadow tables of the failed job.
+func (o *OnlineDDL) cleanup(job *onlineDDLJob) {
+	for _, seg := range job.segments {
+		for _, query := range onlineDDLCleanupQuerys(job.database, seg) {
+			if _, err := o.execute(seg.backend, query, 0); err != nil {
+				o.log.Error("proxy.online.ddl.job[%d].cleanup[%s].on[%s].error:%v", job.id, query, seg.backend, err)
+			}
+		}
+	}
+}
+
+func onlineDDLCleanupQuerys(db string, seg *onlineDDLSegment) []string {
+	return []string{
+		fmt.Sprintf("DROP TRIGGER IF EXISTS `%s`.`%s`", db, seg.trigger("ins")),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS `%s`.`%s`", db, seg.trigger("upd")),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS `%s`.`%s`", db, seg.trigger("del")),
+		fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s`", db, seg.shadow),
+	}
+}
+
+// onlineDDLTriggerQuerys returns the triggers which apply the changes of the sub-table to the shadow table.
+func onlineDDLTriggerQuerys(db string, seg *onlineDDLSegment) []string {
+	shadow := fmt.Sprintf("`%s`.`%s`", db, seg.shadow)
+	columns := onlineDDLColumnList(seg.columns)
+	newValues := onlineDDLPrefixList("NEW.", seg.columns, ",")
+	var match, changed []string
+	for _, key := range seg.keys {
+		match = append(match, fmt.Sprintf("%s.`%s` <=> OLD.`%s`", shadow, key, key))
+		changed = append(changed, fmt.Sprintf("OLD.`%s` <=> NEW.`%s`", key, key))
+	}
+	replace := fmt.Sprintf("REPLACE INTO %s(%s) VALUES (%s)", shadow, columns, newValues)
+	del := fmt.Sprintf("DELETE IGNORE FROM %s WHERE %s", shadow, strings.Join(match, " AND "))
+	on := fmt.Sprintf("ON `%s`.`%s` FOR EACH ROW", db, seg.table)
+	return []string{
+		fmt.Sprintf("CREATE TRIGGER `%s`.`%s` AFTER INSERT %s %s", db, seg.trigger("ins"), on, replace),
+		fmt.Sprintf("CREATE TRIGGER `%s`.`%s` AFTER UPDATE %s BEGIN %s AND !(%s); %s; END", db, seg.trigger("upd"), on, del, strings.Join(changed, " AND "), replace),
+		fmt.Sprintf("CREATE TRIGGER `%s`.`%s` AFTER DELETE %s %s", db, seg.trigger("del"), on, del),
+	}
+}
+
+func onlineDDLColumnList(columns []string) string {
+	return onlineDDLPrefixList("", columns, ",")
+}
+
+func onlineDDLPrefixList(prefix string, columns []string, sep string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = fmt.Sprintf("%s`%s`", prefix, column)
+	}
+	return strings.Join(quoted, sep)
+}
+
+func onlineDDLValueTuple(row []sqltypes.Value) string {
+	buf := bytes.NewBufferString("(")
+	for i, v := range row {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		v.EncodeSQL(buf)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func onlineDDLWhere(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// onlineDDLLag used to check the replica lag of the backend by its standby.
+type onlineDDLLag struct {
+	conf *config.BackendConfig
+	conn driver.Conn
+	last time.Time
+	lag  int
+}
+
+// check returns the Seconds_Behind_Master of the standby, the result is cached in the lag interval.
+// It returns 0 if the backend has no standby, and -1 if the replication isn't running.
+func (l *onlineDDLLag) check() (int, error) {
+	if l.conf == nil || l.conf.Standby == "" {
+		return 0, nil
+	}
+	if time.Since(l.last) < onlineDDLLagInterval {
+		return l.lag, nil
+	}
+	if l.conn == nil {
+		conn, err := driver.NewConn(l.conf.User, l.conf.Password, l.conf.Standby, "", l.conf.Charset)
+		if err != nil {
+			return 0, err
+		}
+		l.conn = conn
+	}
+	qr, err := l.conn.FetchAll("SHOW SLAVE STATUS", -1)
+	if err != nil {
+		l.close()
+		return 0, err
+	}
+	l.last = time.Now()
+	l.lag = 0
+	for i, field := range qr.Fields {
+		if field.Name == "Seconds_Behind_Master" && len(qr.Rows) > 0 {
+			l.lag = -1
+			if v := qr.Rows[0][i]; !v.IsNull() {
+				if l.lag, err = strconv.Atoi(v.ToString()); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	return l.lag, nil
+}
+
+func (l *onlineDDLLag) close() {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+}
+
+// handleRadonAlter used to handle the command: radon alter table ...|status|pause|resume|cancel.
+func (spanner *Spanner) handleRadonAlter(session *driver.Session, query string, node *sqlparser.Radon) (*sqltypes.Result, error) {
+	route := spanner.router
+	onlineDDL := spanner.onlineDDL
+	privilegePlug := spanner.plugins.PlugPrivilege()
+
+	switch node.Action {
+	case sqlparser.OnlineAlterStatusStr:
+		return onlineDDL.Status(), nil
+	case sqlparser.OnlineAlterPauseStr, sqlparser.OnlineAlterResumeStr, sqlparser.OnlineAlterCancelStr:
+		if !privilegePlug.IsSuperPriv(session.User()) {
+			return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+		}
+		id, err := strconv.ParseUint(node.Job, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		switch node.Action {
+		case sqlparser.OnlineAlterPauseStr:
+			err = onlineDDL.Pause(id)
+		case sqlparser.OnlineAlterResumeStr:
+			err = onlineDDL.Resume(id)
+		default:
+			err = onlineDDL.Cancel(id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &sqltypes.Result{}, nil
+	}
+
+	ddl := node.Alter
+	switch ddl.Action {
+	case sqlparser.AlterEngineStr, sqlparser.AlterCharsetStr,
+		sqlparser.AlterAddColumnStr, sqlparser.AlterDropColumnStr, sqlparser.AlterModifyColumnStr:
+	case sqlparser.AlterAddIndexStr:
+		// The rows violating the unique key are lost by the INSERT IGNORE of the copy.
+		if info := ddl.TableSpec.Indexes[0].Info; info.Unique || info.Primary {
+			return nil, errors.Errorf("unsupported: online.ddl.add.unique.index")
+		}
+	case sqlparser.DropIndexStr:
+		if strings.EqualFold(ddl.IndexName, "primary") {
+			return nil, errors.Errorf("unsupported: online.ddl.drop.primary.key")
+		}
+	default:
+		return nil, errors.Errorf("unsupported: online.ddl.action[%s]", ddl.Action)
+	}
+
+	database := session.Schema()
+	if !ddl.Table.Qualifier.IsEmpty() {
+		database = ddl.Table.Qualifier.String()
+	}
+	if database == "" {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_DB_ERROR)
+	}
+	if err := route.DatabaseACL(database); err != nil {
+		return nil, err
+	}
+	if err := privilegePlug.Check(database, session.User(), ddl); err != nil {
+		return nil, err
+	}
+	table := ddl.Table.Name.String()
+	if !checkTableExists(database, table, route) {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
+	}
+
+	// The shard key checks are same as the ALTER TABLE.
+	plan := planner.NewDDLPlan(spanner.log, database, query, ddl, route)
+	if err := plan.Build(); err != nil {
+		return nil, err
+	}
+	job, err := onlineDDL.Start(database, table, ddl)
+	if err != nil {
+		return nil, err
+	}
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Id", Type: querypb.Type_UINT64},
+		{Name: "Database", Type: querypb.Type_VARCHAR},
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Segments", Type: querypb.Type_INT64},
+	}
+	qr.Rows = append(qr.Rows, []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", job.id))),
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(database)),
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(table)),
+		sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(job.segments)))),
+	})
+	return qr, nil
+}
@@ -0,0 +1,590 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	onlineDDLColumnsResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Field", Type: querypb.Type_VARCHAR},
+			{Name: "Type", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("id")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("b")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)")),
+			},
+		},
+	}
+
+	onlineDDLNewColumnsResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Field", Type: querypb.Type_VARCHAR},
+			{Name: "Type", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("id")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("b")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("c")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)")),
+			},
+		},
+	}
+
+	onlineDDLKeysResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Non_unique", Type: querypb.Type_INT64},
+			{Name: "Key_name", Type: querypb.Type_VARCHAR},
+			{Name: "Seq_in_index", Type: querypb.Type_INT64},
+			{Name: "Column_name", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("PRIMARY")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("id")),
+			},
+		},
+	}
+
+	onlineDDLTableRowsResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "TABLE_ROWS", Type: querypb.Type_UINT64},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_UINT64, []byte("10")),
+			},
+		},
+	}
+
+	onlineDDLBoundResult = &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+		},
+	}
+)
+
+func mockOnlineDDLQuerys(fakedbs *fakedb.DB, keys *sqltypes.Result) {
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("alter table .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("rename table .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("show columns from `test`.`_.*_new`", onlineDDLNewColumnsResult)
+	fakedbs.AddQueryPattern("show columns from .*", onlineDDLColumnsResult)
+	fakedbs.AddQueryPattern("show keys from .*", keys)
+	fakedbs.AddQueryPattern("select table_rows from .*", onlineDDLTableRowsResult)
+	fakedbs.AddQueryPattern("insert low_priority ignore into .*", &sqltypes.Result{RowsAffected: 5})
+}
+
+// waitOnlineDDL used to wait for the job in the state, it returns the status row of the job.
+func waitOnlineDDL(t *testing.T, client driver.Conn, id string, state string) []sqltypes.Value {
+	for i := 0; i < 100; i++ {
+		qr, err := client.FetchAll("radon alter status", -1)
+		assert.Nil(t, err)
+		for _, row := range qr.Rows {
+			if row[0].ToString() == id && row[4].ToString() == state {
+				return row
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Fail(t, "online.ddl.job["+id+"].not.in.state["+state+"]")
+	return nil
+}
+
+func TestProxyOnlineDDL(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+	fakedbs.AddQueryPattern("select `id` from .*", onlineDDLBoundResult)
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int, primary key(id)) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	segments, err := proxy.Router().Lookup("test", "t1", nil, nil)
+	assert.Nil(t, err)
+
+	// The job runs in the background.
+	{
+		qr, err := client.FetchAll("radon alter table test.t1 add column(c int)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", qr.Rows[0][0].ToString())
+		row := waitOnlineDDL(t, client, "1", "done")
+		assert.Equal(t, "alter table test.t1 add column (\n\t`c` int\n)", row[3].ToString())
+		assert.Equal(t, fmt.Sprintf("%d/%d", len(segments), len(segments)), row[5].ToString())
+		assert.Equal(t, fmt.Sprintf("%d", 5*len(segments)), row[6].ToString())
+		assert.Equal(t, fmt.Sprintf("%d", 10*len(segments)), row[7].ToString())
+		assert.Equal(t, "100.00%", row[8].ToString())
+		assert.Equal(t, "0", row[9].ToString())
+
+		seg := segments[0]
+		want := []string{
+			"create table `test`.`_t1_0000_new` like `test`.`t1_0000`",
+			"alter table test._t1_0000_new add column (\n\t`c` int\n)",
+			"create trigger `test`.`radon_osc_t1_0000_ins` after insert on `test`.`t1_0000` for each row replace into `test`.`_t1_0000_new`(`id`,`b`) values (new.`id`,new.`b`)",
+			"create trigger `test`.`radon_osc_t1_0000_upd` after update on `test`.`t1_0000` for each row begin delete ignore from `test`.`_t1_0000_new` where `test`.`_t1_0000_new`.`id` <=> old.`id` and !(old.`id` <=> new.`id`); replace into `test`.`_t1_0000_new`(`id`,`b`) values (new.`id`,new.`b`); end",
+			"create trigger `test`.`radon_osc_t1_0000_del` after delete on `test`.`t1_0000` for each row delete ignore from `test`.`_t1_0000_new` where `test`.`_t1_0000_new`.`id` <=> old.`id`",
+			"select `id` from `test`.`t1_0000` force index(primary) order by `id` limit 1 offset 999",
+			"insert low_priority ignore into `test`.`_t1_0000_new`(`id`,`b`) select `id`,`b` from `test`.`t1_0000` force index(primary) lock in share mode",
+		}
+		for _, query := range want {
+			assert.Equal(t, 1, fakedbs.GetQueryCalledNum(query), query)
+		}
+		// The old sub-table is dropped before the copy and after the cutover.
+		assert.Equal(t, 2, fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_old`"))
+		// The sub-tables on the backend are renamed by one RENAME.
+		var renames []string
+		for _, segment := range segments {
+			if segment.Backend == seg.Backend {
+				renames = append(renames, "`test`.`"+segment.Table+"` to `test`.`_"+segment.Table+"_old`", "`test`.`_"+segment.Table+"_new` to `test`.`"+segment.Table+"`")
+			}
+		}
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("rename table "+strings.Join(renames, ", ")))
+	}
+
+	// The index changes.
+	{
+		qr, err := client.FetchAll("radon alter table test.t1 add index idx_b(b)", -1)
+		assert.Nil(t, err)
+		waitOnlineDDL(t, client, qr.Rows[0][0].ToString(), "done")
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("alter table test._t1_0000_new add index `idx_b` (`b`)"))
+
+		qr, err = client.FetchAll("radon alter table test.t1 drop index idx_b", -1)
+		assert.Nil(t, err)
+		waitOnlineDDL(t, client, qr.Rows[0][0].ToString(), "done")
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("drop index idx_b on test._t1_0000_new"))
+	}
+
+	// Errors.
+	{
+		sqls := []string{
+			"radon alter table test.t1 rename to t2",
+			"radon alter table test.t2 add column(c int)",
+			"radon alter table t1 add column(c int)",
+			"radon alter table test.t1 drop column id",
+			"radon alter table test.t1 add unique index idx_b(b)",
+			"radon alter pause 100",
+			"radon alter resume 1",
+			"radon alter cancel 1",
+		}
+		for _, sql := range sqls {
+			_, err := client.FetchAll(sql, -1)
+			assert.NotNil(t, err, sql)
+		}
+	}
+
+	// The chunks of the single table.
+	{
+		proxy.Config().Proxy.OnlineDDLChunkSize = 2
+		fakedbs.ResetAll()
+		mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+		_, err := client.FetchAll("create table test.s1(id int, b int, primary key(id)) single", -1)
+		assert.Nil(t, err)
+		upper := &sqltypes.Result{
+			Fields: onlineDDLBoundResult.Fields,
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2"))},
+			},
+		}
+		fakedbs.AddQuery("select `id` from `test`.`s1` force index(primary) where (`id`) > (2) order by `id` limit 1 offset 1", onlineDDLBoundResult)
+		fakedbs.AddQuery("select `id` from `test`.`s1` force index(primary) order by `id` limit 1 offset 1", upper)
+
+		qr, err := client.FetchAll("radon alter table test.s1 engine=tokudb", -1)
+		assert.Nil(t, err)
+		row := waitOnlineDDL(t, client, qr.Rows[0][0].ToString(), "done")
+		assert.Equal(t, "1/1", row[5].ToString())
+		assert.Equal(t, "10", row[6].ToString())
+		want := []string{
+			"alter table test._s1_new engine = tokudb",
+			"insert low_priority ignore into `test`.`_s1_new`(`id`,`b`) select `id`,`b` from `test`.`s1` force index(primary) where (`id`) <= (2) lock in share mode",
+			"insert low_priority ignore into `test`.`_s1_new`(`id`,`b`) select `id`,`b` from `test`.`s1` force index(primary) where (`id`) > (2) lock in share mode",
+			"rename table `test`.`s1` to `test`.`_s1_old`, `test`.`_s1_new` to `test`.`s1`",
+		}
+		for _, query := range want {
+			assert.Equal(t, 1, fakedbs.GetQueryCalledNum(query), query)
+		}
+	}
+}
+
+func TestProxyOnlineDDLFailed(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	mockOnlineDDLQuerys(fakedbs, &sqltypes.Result{Fields: onlineDDLKeysResult.Fields})
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	// The table without primary key.
+	{
+		_, err := client.FetchAll("radon alter table test.t1 drop column b", -1)
+		assert.Nil(t, err)
+		row := waitOnlineDDL(t, client, "1", "failed")
+		assert.Equal(t, "unsupported: online.ddl.table[t1_0000].without.primary.key", row[11].ToString())
+		// The shadow tables and the triggers are dropped.
+		assert.Equal(t, 2, fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_new`"))
+		assert.Equal(t, 2, fakedbs.GetQueryCalledNum("drop trigger if exists `test`.`radon_osc_t1_0000_ins`"))
+	}
+
+	// The cutover fails.
+	{
+		fakedbs.ResetAll()
+		mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+		fakedbs.AddQueryPattern("select `id` from .*", onlineDDLBoundResult)
+		fakedbs.AddQueryErrorPattern("rename table `test`.`t1_0000` .*", errors.New("mock.rename.error"))
+		fakedbs.AddQueryPattern("select 1 from information_schema.tables .*", &sqltypes.Result{})
+		_, err := client.FetchAll("radon alter table test.t1 drop column b", -1)
+		assert.Nil(t, err)
+		row := waitOnlineDDL(t, client, "2", "failed")
+		assert.True(t, strings.Contains(row[11].ToString(), "mock.rename.error"))
+	}
+
+	// The cutover is diverged: the RENAME is done but the reply is lost, and the revert fails.
+	{
+		fakedbs.ResetAll()
+		mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+		fakedbs.AddQueryPattern("select `id` from .*", onlineDDLBoundResult)
+		fakedbs.AddQueryErrorPattern("rename table `test`.`t1_0000` .*", errors.New("mock.rename.error"))
+		fakedbs.AddQueryPattern("select 1 from information_schema.tables .*", &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "1", Type: querypb.Type_INT64}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1"))}},
+		})
+		drops := fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_new`")
+		_, err := client.FetchAll("radon alter table test.t1 drop column b", -1)
+		assert.Nil(t, err)
+		row := waitOnlineDDL(t, client, "3", "diverged")
+		assert.True(t, strings.Contains(row[11].ToString(), "cutover.diverged"))
+		// No retry and no cleanup.
+		assert.Equal(t, drops+1, fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_new`"))
+
+		// The job is recorded in the ddl jobs, it can't be retried.
+		qr, err := client.FetchAll("radon ddl status", -1)
+		assert.Nil(t, err)
+		last := qr.Rows[len(qr.Rows)-1]
+		assert.Equal(t, "diverged", last[4].ToString())
+		_, err = client.FetchAll("radon ddl retry "+last[0].ToString(), -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyOnlineDDLControl(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+	fakedbs.AddQueryPattern("select `id` from .*", onlineDDLBoundResult)
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("select .* from test.t1_.*", &sqltypes.Result{})
+	// The standby lags behind.
+	fakedbs.AddQuery("show slave status", &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Slave_IO_Running", Type: querypb.Type_VARCHAR},
+			{Name: "Seconds_Behind_Master", Type: querypb.Type_INT64},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("Yes")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("100")),
+			},
+		},
+	})
+	for _, conf := range proxy.Scatter().BackendConfigsClone() {
+		conf.Standby = conf.Address
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int, primary key(id)) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	_, err = client.FetchAll("radon alter table test.t1 modify column b bigint", -1)
+	assert.Nil(t, err)
+	// The copy is throttled.
+	for i := 0; i < 100; i++ {
+		qr, err := client.FetchAll("radon alter status", -1)
+		assert.Nil(t, err)
+		if qr.Rows[0][9].ToString() == "100" {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	row := waitOnlineDDL(t, client, "1", "running")
+	assert.Equal(t, "100", row[9].ToString())
+	assert.Equal(t, "0", row[6].ToString())
+
+	// The DDL and the online DDL of the table are refused, the DML are not blocked.
+	{
+		_, err := client.FetchAll("alter table test.t1 engine=tokudb", -1)
+		assert.Equal(t, "online.ddl.job[1].is.altering.table[test.t1] (errno 1105) (sqlstate HY000)", err.Error())
+		_, err = client.FetchAll("radon alter table test.t1 engine=tokudb", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("select * from test.t1", -1)
+		assert.Nil(t, err)
+	}
+
+	// Pause and resume.
+	{
+		_, err := client.FetchAll("radon alter pause 1", -1)
+		assert.Nil(t, err)
+		waitOnlineDDL(t, client, "1", "paused")
+		_, err = client.FetchAll("radon alter pause 1", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("radon alter resume 1", -1)
+		assert.Nil(t, err)
+		waitOnlineDDL(t, client, "1", "running")
+	}
+
+	// Cancel.
+	{
+		_, err := client.FetchAll("radon alter cancel 1", -1)
+		assert.Nil(t, err)
+		row := waitOnlineDDL(t, client, "1", "canceled")
+		assert.Equal(t, "", row[11].ToString())
+		assert.True(t, fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_new`") >= 2)
+		_, err = client.FetchAll("alter table test.t1 engine=tokudb", -1)
+		assert.Nil(t, err)
+	}
+}
+
+func TestProxyOnlineDDLLagTimeout(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := MockDefaultConfig()
+	conf.Proxy.OnlineDDLLagTimeout = 1
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+	mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+	fakedbs.AddQueryPattern("select `id` from .*", onlineDDLBoundResult)
+	// The lag of the standby is unknown.
+	fakedbs.AddQueryError("show slave status", errors.New("mock.show.slave.status.error"))
+	for _, conf := range proxy.Scatter().BackendConfigsClone() {
+		conf.Standby = conf.Address
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int, primary key(id)) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	// The job fails instead of waiting forever.
+	_, err = client.FetchAll("radon alter table test.t1 modify column b bigint", -1)
+	assert.Nil(t, err)
+	row := waitOnlineDDL(t, client, "1", "failed")
+	assert.True(t, strings.Contains(row[11].ToString(), "lag[-1].over.max.lag[1]"))
+}
+
+func TestProxyOnlineDDLPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("radon alter pause 1", -1)
+	want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
+
+func TestProxyOnlineDDLGate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	onlineDDL := proxy.spanner.onlineDDL
+	enter := func(tables []string) {
+		exit, err := onlineDDL.enterDML(tables)
+		assert.Nil(t, err)
+		exit()
+	}
+
+	// No table is gated.
+	enter([]string{"test.t1"})
+
+	// The cutover gates the table by the peer fence.
+	release, err := onlineDDL.gate("radon_osc_1_1", []string{"test.t1"})
+	assert.Nil(t, err)
+
+	// The DML of other tables are not blocked.
+	enter([]string{"test.t2"})
+
+	// The DML waits for the cutover.
+	var entered int32
+	done := make(chan struct{})
+	go func() {
+		release, err := onlineDDL.enterDML([]string{"test.t2", "test.t1"})
+		assert.Nil(t, err)
+		atomic.StoreInt32(&entered, 1)
+		release()
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&entered))
+	release()
+	<-done
+	assert.Equal(t, int32(1), atomic.LoadInt32(&entered))
+
+	// The read lease of the unreachable peer can't be renewed, the DML fails since the cutover may skip this peer.
+	assert.Nil(t, proxy.Syncer().AddPeer("127.0.0.1:1"))
+	_, err = onlineDDL.enterDML([]string{"test.t1"})
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "peer[127.0.0.1:1].read.lease.expired"))
+}
+
+func TestProxyOnlineDDLInit(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+	// The sub-tables are swapped.
+	fakedbs.AddQueryPattern("select 1 from information_schema.tables .*", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "1", Type: querypb.Type_INT64}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1"))}},
+	})
+
+	conf := proxy.Config()
+	backends := proxy.Scatter().Backends()
+	owner := conf.Proxy.PeerAddress
+	segments := []*onlineDDLSegmentJSON{
+		{Backend: backends[0], Table: "t1_0000"},
+		{Backend: backends[1], Table: "t1_0001"},
+	}
+	jobs := []*onlineDDLJobJSON{
+		{ID: 1, Owner: owner, Database: "test", Table: "t1", Alter: "alter table test.t1 engine = tokudb", State: onlineDDLStateDone, Segments: segments},
+		{ID: 2, Owner: owner, Database: "test", Table: "t2", Alter: "alter table test.t2 engine = tokudb", State: onlineDDLStatePaused, Segments: segments},
+		{ID: 3, Owner: owner, Database: "test", Table: "t3", Alter: "alter table test.t3 engine = tokudb", State: onlineDDLStateCutover, Segments: segments},
+		{ID: 9, Owner: "127.0.0.1:1", Database: "test", Table: "t4", Alter: "alter table test.t4 engine = tokudb", State: onlineDDLStateRunning, Segments: segments},
+	}
+	// Each peer has its own file.
+	onlineDDL := NewOnlineDDL(log, proxy.spanner)
+	dir := path.Join(conf.Proxy.MetaDir, onlineDDLDir)
+	assert.Nil(t, os.MkdirAll(dir, 0744))
+	assert.Nil(t, config.WriteConfig(onlineDDL.jobsFile(), jobs[:3]))
+	other := path.Join(dir, "127.0.0.1:1.json")
+	assert.Nil(t, config.WriteConfig(other, jobs[3:]))
+	assert.Nil(t, onlineDDL.Init())
+	assert.Equal(t, uint64(3), onlineDDL.seq)
+	assert.Equal(t, 3, len(onlineDDL.jobs))
+	// The paused job is cleaned up and failed.
+	assert.Equal(t, onlineDDLStateFailed, onlineDDL.jobs[1].state)
+	assert.Equal(t, "online.ddl.job[2].interrupted.by.restart", onlineDDL.jobs[1].err.Error())
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_new`"))
+	// The cutover is done on all the backends.
+	assert.Equal(t, onlineDDLStateDone, onlineDDL.jobs[2].state)
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_old`"))
+
+	persisted, err := onlineDDL.readJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(persisted))
+	assert.Equal(t, onlineDDLStateFailed, persisted[1].State)
+
+	// The file of the other peer is untouched.
+	data, err := ioutil.ReadFile(other)
+	assert.Nil(t, err)
+	persisted = nil
+	assert.Nil(t, json.Unmarshal(data, &persisted))
+	assert.Equal(t, 1, len(persisted))
+	assert.Equal(t, uint64(9), persisted[0].ID)
+	assert.Equal(t, onlineDDLStateRunning, persisted[0].State)
+
+	// The cutover is diverged if the sub-tables are swapped on some backends.
+	fakedbs.ResetAll()
+	mockOnlineDDLQuerys(fakedbs, onlineDDLKeysResult)
+	fakedbs.AddQueryPattern("select 1 from information_schema.tables .*", &sqltypes.Result{})
+	fakedbs.AddQueryErrorPattern("select 1 from information_schema.tables where table_schema = 'test' and table_name = '_t1_0001_old'", errors.New("mock.check.error"))
+	persisted[0].Owner = owner
+	persisted[0].State = onlineDDLStateCutover
+	assert.Nil(t, config.WriteConfig(onlineDDL.jobsFile(), persisted[:1]))
+	drops := fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_new`")
+	onlineDDL = NewOnlineDDL(log, proxy.spanner)
+	assert.Nil(t, onlineDDL.Init())
+	assert.Equal(t, onlineDDLStateDiverged, onlineDDL.jobs[0].state)
+	// The diverged sub-tables are left as they are.
+	assert.Equal(t, drops, fakedbs.GetQueryCalledNum("drop table if exists `test`.`_t1_0000_new`"))
+	qr, err := proxy.spanner.ddlJobs.Status(0)
+	assert.Nil(t, err)
+	assert.Equal(t, ddlJobStateDiverged, qr.Rows[len(qr.Rows)-1][4].ToString())
+}
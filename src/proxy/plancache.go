@@ -0,0 +1,336 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"optimizer"
+	"planner"
+	"xcontext"
+	"xtrace"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// planArgPrefix is the prefix of the bind variables of the parameterized literals.
+	planArgPrefix = "_radon_lit"
+)
+
+// planParams tuple, the literals parameterized from the statement.
+type planParams struct {
+	database string
+	table    string
+	bindVars map[string]*querypb.BindVariable
+	// The routing signatures of the shard key literals, the plan routes by the segments
+	// so the literals in the same segments share the plan.
+	signatures []string
+}
+
+// planTable returns the database and the table of the single table statement which
+// can be parameterized, the others return false.
+func planTable(database string, node sqlparser.Statement) (string, string, bool) {
+	var table sqlparser.TableName
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		if len(node.From) != 1 {
+			return "", "", false
+		}
+		aliased, ok := node.From[0].(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return "", "", false
+		}
+		if table, ok = aliased.Expr.(sqlparser.TableName); !ok {
+			return "", "", false
+		}
+	case *sqlparser.Update:
+		table = node.Table
+	case *sqlparser.Delete:
+		table = node.Table
+	default:
+		return "", "", false
+	}
+	if table.Name.String() == "dual" {
+		return "", "", false
+	}
+	subquery := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if _, ok := node.(*sqlparser.Subquery); ok {
+			subquery = true
+			return false, nil
+		}
+		return true, nil
+	}, node)
+	if subquery {
+		return "", "", false
+	}
+	if !table.Qualifier.IsEmpty() {
+		database = table.Qualifier.String()
+	}
+	return database, table.Name.String(), true
+}
+
+// isPlanLiteral returns true if the literal can be parameterized, the planner never
+// interprets the value except routing by the shard key.
+func isPlanLiteral(expr sqlparser.Expr) (*sqlparser.SQLVal, bool) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok || val.Arg != "" {
+		return nil, false
+	}
+	switch val.Type {
+	case sqlparser.IntVal, sqlparser.FloatVal, sqlparser.StrVal:
+		return val, true
+	}
+	return nil, false
+}
+
+// parameterize replaces the literal with the bind variable, the literal compared with
+// the shard key by '=' or 'in' signs the routing segment.
+func (spanner *Spanner) parameterize(params *planParams, val *sqlparser.SQLVal, routing bool) {
+	name := fmt.Sprintf("%s%d", planArgPrefix, len(params.bindVars)+1)
+	val.Arg = ":" + name
+
+	var typ querypb.Type
+	switch val.Type {
+	case sqlparser.IntVal:
+		typ = querypb.Type_INT64
+	case sqlparser.FloatVal:
+		typ = querypb.Type_DECIMAL
+	default:
+		typ = querypb.Type_VARBINARY
+	}
+	params.bindVars[name] = &querypb.BindVariable{Type: typ, Value: val.Val}
+
+	if routing {
+		signature := "v:" + string(val.Val)
+		if index, err := spanner.router.GetIndex(params.database, params.table, val); err == nil {
+			signature = fmt.Sprintf("%d", index)
+		}
+		params.signatures = append(params.signatures, fmt.Sprintf("%s=%s", name, signature))
+	}
+}
+
+// parameterizePlan used to parameterize the literals of the where clause and the
+// update values, the other literals(limit, order by, select exprs...) are kept in the
+// key because the planner interprets them.
+func (spanner *Spanner) parameterizePlan(database string, node sqlparser.Statement) *planParams {
+	database, table, ok := planTable(database, node)
+	if !ok {
+		return nil
+	}
+	shardkey, err := spanner.router.ShardKey(database, table)
+	if err != nil {
+		return nil
+	}
+	params := &planParams{
+		database: database,
+		table:    table,
+		bindVars: make(map[string]*querypb.BindVariable),
+	}
+
+	isShardKey := func(expr sqlparser.Expr) bool {
+		col, ok := expr.(*sqlparser.ColName)
+		return ok && shardkey != "" && strings.EqualFold(col.Name.String(), shardkey)
+	}
+	visit := func(node sqlparser.SQLNode) (bool, error) {
+		expr, ok := node.(*sqlparser.ComparisonExpr)
+		if !ok {
+			return true, nil
+		}
+		switch expr.Operator {
+		case sqlparser.InStr, sqlparser.NotInStr:
+			if _, ok := expr.Left.(*sqlparser.ColName); !ok {
+				return true, nil
+			}
+			tuple, ok := expr.Right.(sqlparser.ValTuple)
+			if !ok {
+				return true, nil
+			}
+			routing := expr.Operator == sqlparser.InStr && isShardKey(expr.Left)
+			for _, e := range tuple {
+				if val, ok := isPlanLiteral(e); ok {
+					spanner.parameterize(params, val, routing)
+				}
+			}
+		default:
+			for _, pair := range [][2]sqlparser.Expr{{expr.Left, expr.Right}, {expr.Right, expr.Left}} {
+				if _, ok := pair[0].(*sqlparser.ColName); !ok {
+					continue
+				}
+				if val, ok := isPlanLiteral(pair[1]); ok {
+					spanner.parameterize(params, val, expr.Operator == sqlparser.EqualStr && isShardKey(pair[0]))
+				}
+			}
+		}
+		return true, nil
+	}
+
+	var where *sqlparser.Where
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		where = node.Where
+	case *sqlparser.Update:
+		for _, update := range node.Exprs {
+			if val, ok := isPlanLiteral(update.Expr); ok {
+				spanner.parameterize(params, val, false)
+			}
+		}
+		where = node.Where
+	case *sqlparser.Delete:
+		where = node.Where
+	}
+	if where != nil {
+		sqlparser.Walk(visit, where.Expr)
+	}
+	if len(params.bindVars) == 0 {
+		return nil
+	}
+	return params
+}
+
+// planCacheKey returns the key of the plan cache, the statement is normalized with the literals
+// parameterized and digested. The router version is included so that the plans will be rebuilt
+// once the route rules changed.
+func (spanner *Spanner) planCacheKey(database string, node sqlparser.Statement, params *planParams) string {
+	text := sqlparser.String(node)
+	if params != nil {
+		text += " /* " + strings.Join(params.signatures, ",") + " */"
+	}
+	sum := md5.Sum([]byte(text))
+	return fmt.Sprintf("%s:%d:%s", database, spanner.router.Version(), hex.EncodeToString(sum[:]))
+}
+
+// buildPlanTree used to get the plans from the plan cache, if missed build it by the optimizer.
+// The literals of the plans are parameterized if the plan cache is enabled, the bind variables
+// returned must be bound to execute the plans.
+// The planning is traced if the statement is traced by the profile.
+func (spanner *Spanner) buildPlanTree(profile *xcontext.Profile, database string, query string, node sqlparser.Statement) (*planner.PlanTree, map[string]*querypb.BindVariable, error) {
+	span := profile.Span().StartChild("plan", xtrace.SpanKindInternal)
+	defer span.End()
+
+	cacheable := false
+	switch node.(type) {
+	case *sqlparser.Select, *sqlparser.Union, *sqlparser.Update, *sqlparser.Delete:
+		cacheable = spanner.planCache.Capacity() > 0
+	}
+	if !cacheable {
+		plans, err := optimizer.NewSimpleOptimizer(spanner.log, database, query, node, spanner.router).BuildPlanTree()
+		if err != nil {
+			span.SetError(err)
+			return nil, nil, err
+		}
+		return plans, nil, nil
+	}
+
+	var bindVars map[string]*querypb.BindVariable
+	params := spanner.parameterizePlan(database, node)
+	if params != nil {
+		bindVars = params.bindVars
+	}
+	key := spanner.planCacheKey(database, node, params)
+	if v, ok := spanner.planCache.Get(key); ok {
+		span.SetAttribute("radon.plan_cache_hit", true)
+		return v.(*planner.PlanTree), bindVars, nil
+	}
+
+	plans, err := optimizer.NewSimpleOptimizer(spanner.log, database, query, node, spanner.router).BuildPlanTree()
+	if err != nil {
+		span.SetError(err)
+		return nil, nil, err
+	}
+	if plans.Cacheable() {
+		spanner.planCache.Set(key, plans)
+	}
+	return plans, bindVars, nil
+}
+
+// parseWithBindVars used to get the parsed query of the statement with the bind locations,
+// the parsed querys are cached to avoid parsing the same statement again.
+// The statement is returned if it's parsed by the cache miss, the caller can bind it in place.
+func (spanner *Spanner) parseWithBindVars(query string) (*sqlparser.ParsedQuery, sqlparser.Statement, error) {
+	if v, ok := spanner.stmtCache.Get(query); ok {
+		return v.(*sqlparser.ParsedQuery), nil, nil
+	}
+
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsedQuery := sqlparser.NewParsedQuery(node)
+	spanner.stmtCache.Set(query, parsedQuery)
+	return parsedQuery, node, nil
+}
+
+// bindStatement used to replace the bind args of the statement with the values in place,
+// returns false if some value can't be a literal and the statement must be parsed from the
+// generated query.
+func bindStatement(node sqlparser.Statement, bindVariables map[string]*querypb.BindVariable) bool {
+	var args []*sqlparser.SQLVal
+	var vals []*querypb.BindVariable
+	bindable := true
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		arg, ok := node.(*sqlparser.SQLVal)
+		if !ok || arg.Type != sqlparser.ValArg {
+			return true, nil
+		}
+		name := string(arg.Val)
+		if !strings.HasPrefix(name, ":") || strings.HasPrefix(name, "::") {
+			bindable = false
+			return false, nil
+		}
+		val, ok := bindVariables[name[1:]]
+		if !ok {
+			bindable = false
+			return false, nil
+		}
+		args = append(args, arg)
+		vals = append(vals, val)
+		return true, nil
+	}, node)
+	if !bindable {
+		return false
+	}
+
+	types := make([]sqlparser.ValType, len(vals))
+	for i, val := range vals {
+		switch {
+		case sqltypes.IsIntegral(val.Type):
+			types[i] = sqlparser.IntVal
+		case sqltypes.IsFloat(val.Type) || val.Type == querypb.Type_DECIMAL:
+			types[i] = sqlparser.FloatVal
+		case sqltypes.IsQuoted(val.Type):
+			types[i] = sqlparser.StrVal
+		default:
+			return false
+		}
+	}
+	for i, arg := range args {
+		arg.Type = types[i]
+		arg.Val = vals[i].Value
+	}
+	return true
+}
+
+// ClearPlanCache used to clear the plan cache.
+func (spanner *Spanner) ClearPlanCache() {
+	spanner.planCache.Clear()
+}
+
+// SetStmtCacheSize used to resize the statement cache of the bind variables, the cached
+// statements are invalidated.
+func (spanner *Spanner) SetStmtCacheSize(size int) {
+	spanner.stmtCache.Clear()
+	spanner.stmtCache.SetCapacity(size)
+}
@@ -0,0 +1,196 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyPlanCache(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	planCache := proxy.Spanner().planCache
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("update .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// create database and tables.
+	{
+		querys := []string{
+			"create database test",
+			"create table test.t1(id int, b int) partition by hash(id)",
+			"create table test.g1(id int, b int) global",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 0, planCache.Len())
+	}
+
+	// Cached.
+	{
+		querys := []string{
+			"select * from test.t1 where id=1",
+			"select * from test.t1 where id=1",
+			"update test.t1 set b=1 where id=2",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 2, planCache.Len())
+		assert.Equal(t, int64(1), planCache.Hits())
+	}
+
+	// Uncacheable.
+	{
+		querys := []string{
+			"select * from test.g1",
+			"insert into test.t1(id, b) values(1, 1)",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 2, planCache.Len())
+	}
+
+	// The literals are parameterized.
+	{
+		querys := []string{
+			"select * from test.t1 where b=1",
+			"select * from test.t1 where b=2",
+			"update test.t1 set b=5 where id=2",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 3, planCache.Len())
+		assert.Equal(t, int64(3), planCache.Hits())
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("select * from test.t1_0000 as t1 where b = 2"))
+	}
+
+	// The router version changed.
+	{
+		query := "create table test.t2(id int, b int) partition by hash(id)"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, planCache.Len())
+
+		query = "select * from test.t1 where id=1"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, planCache.Len())
+
+		err = proxy.Router().ReLoad()
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		// The old plan is unreachable and will be evicted.
+		assert.Equal(t, 2, planCache.Len())
+		assert.Equal(t, int64(3), planCache.Hits())
+	}
+}
+
+func TestProxyPlanCacheDisabled(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := MockDefaultConfig()
+	conf.Proxy.PlanCacheSize = 0
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"select * from test.t1 where id=1",
+		"select * from test.t1 where id=1",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 0, proxy.Spanner().planCache.Len())
+}
+
+func TestProxyPlanCacheStmt(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	planCache := proxy.Spanner().planCache
+	stmtCache := proxy.Spanner().stmtCache
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	stmt, err := client.ComStatementPrepare("select * from test.t1 where b=?")
+	assert.Nil(t, err)
+	defer stmt.ComStatementClose()
+	for _, b := range []string{"6", "7"} {
+		params := []sqltypes.Value{
+			sqltypes.MakeTrusted(sqltypes.Int32, []byte(b)),
+		}
+		_, err = stmt.ComStatementQuery(params)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("select * from test.t1_0000 as t1 where b = "+b))
+	}
+	assert.Equal(t, 1, stmtCache.Len())
+	assert.Equal(t, int64(1), stmtCache.Hits())
+	assert.Equal(t, 1, planCache.Len())
+	assert.Equal(t, int64(1), planCache.Hits())
+
+	proxy.SetStmtCacheSize(0)
+	assert.Equal(t, 0, stmtCache.Len())
+	assert.Equal(t, 0, stmtCache.Capacity())
+}
@@ -10,14 +10,18 @@ package proxy
 
 import (
 	"sync"
+	"time"
 
 	"audit"
 	"backend"
 	"config"
 	"plugins"
+	"quota"
 	"router"
+	"slowlog"
 	"syncer"
 	"xbase"
+	"xtrace"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -30,6 +34,8 @@ type Proxy struct {
 	conf          *config.Config
 	confPath      string
 	audit         *audit.Audit
+	slowLog       *slowlog.SlowLog
+	tracer        *xtrace.Tracer
 	router        *router.Router
 	scatter       *backend.Scatter
 	syncer        *syncer.Syncer
@@ -39,21 +45,28 @@ type Proxy struct {
 	sessions      *Sessions
 	listener      *driver.Listener
 	throttle      *xbase.Throttle
+	quota         *quota.Quota
+	fence         *PeerFence
 	serverVersion string
 }
 
 // NewProxy creates new proxy.
 func NewProxy(log *xlog.Log, path string, serverVersion string, conf *config.Config) *Proxy {
 	audit := audit.NewAudit(log, conf.Audit)
+	slowLog := slowlog.NewSlowLog(log, conf.SlowLog)
+	tracer := xtrace.NewTracer(log, conf.Trace)
 	router := router.NewRouter(log, conf.Proxy.MetaDir, conf.Router)
 	scatter := backend.NewScatter(log, conf.Proxy.MetaDir)
-	syncer := syncer.NewSyncer(log, conf.Proxy.MetaDir, conf.Proxy.PeerAddress, router, scatter)
+	quota := quota.NewQuota(log, conf.Proxy.MetaDir)
+	syncer := syncer.NewSyncer(log, conf.Proxy.MetaDir, conf.Proxy.PeerAddress, router, scatter, quota)
 	plugins := plugins.NewPlugin(log, conf, router, scatter)
 	return &Proxy{
 		log:           log,
 		conf:          conf,
 		confPath:      path,
 		audit:         audit,
+		slowLog:       slowLog,
+		tracer:        tracer,
 		router:        router,
 		scatter:       scatter,
 		syncer:        syncer,
@@ -61,6 +74,7 @@ func NewProxy(log *xlog.Log, path string, serverVersion string, conf *config.Con
 		sessions:      NewSessions(log),
 		iptable:       NewIPTable(log, conf.Proxy),
 		throttle:      xbase.NewThrottle(0),
+		quota:         quota,
 		serverVersion: serverVersion,
 	}
 }
@@ -70,6 +84,8 @@ func (p *Proxy) Start() {
 	log := p.log
 	conf := p.conf
 	audit := p.audit
+	slowLog := p.slowLog
+	tracer := p.tracer
 	iptable := p.iptable
 	syncer := p.syncer
 	router := p.router
@@ -78,6 +94,7 @@ func (p *Proxy) Start() {
 	sessions := p.sessions
 	endpoint := conf.Proxy.Endpoint
 	throttle := p.throttle
+	quota := p.quota
 	serverVersion := p.serverVersion
 
 	log.Info("proxy.config[%+v]...", conf.Proxy)
@@ -86,6 +103,12 @@ func (p *Proxy) Start() {
 	if err := audit.Init(); err != nil {
 		log.Panic("proxy.audit.init.panic:%+v", err)
 	}
+	if err := slowLog.Init(); err != nil {
+		log.Panic("proxy.slowlog.init.panic:%+v", err)
+	}
+	if err := tracer.Init(); err != nil {
+		log.Panic("proxy.tracer.init.panic:%+v", err)
+	}
 	if err := syncer.Init(); err != nil {
 		log.Panic("proxy.syncer.init.panic:%+v", err)
 	}
@@ -95,16 +118,28 @@ func (p *Proxy) Start() {
 	if err := scatter.LoadConfig(); err != nil {
 		log.Panic("proxy.scatter.load.config.panic:%+v", err)
 	}
+	if err := quota.LoadConfig(); err != nil {
+		log.Panic("proxy.quota.load.config.panic:%+v", err)
+	}
 
 	if err := scatter.Init(p.conf.Scatter); err != nil {
 		log.Panic("proxy.scatter.init.panic:%+v", err)
 	}
+	// The fence is shared by the commits and the cutovers of the online ddl, the peers grant the read lease to it.
+	lease := time.Duration(conf.Proxy.CommitFenceLease) * time.Millisecond
+	fence := NewPeerFence(log, conf.Proxy.PeerAddress, lease, syncer, scatter)
+	if conf.Proxy.CommitFenceEnable {
+		scatter.SetCommitFence(fence)
+	}
+	scatter.SetFailoverQuorum(NewPeerQuorum(log, conf.Proxy.PeerAddress, syncer, scatter))
 
 	if err := plugins.Init(); err != nil {
 		log.Panic("proxy.plugins.init.panic:%+v", err)
 	}
 
-	spanner := NewSpanner(log, conf, iptable, router, scatter, sessions, audit, throttle, plugins, serverVersion)
+	spanner := NewSpanner(log, conf, iptable, router, scatter, sessions, audit, slowLog, tracer, throttle, quota, plugins, serverVersion)
+	// The cutover of the online ddl is always gated on all the peers.
+	spanner.onlineDDL.SetPeerFence(fence)
 	if err := spanner.Init(); err != nil {
 		log.Panic("proxy.spanner.init.panic:%+v", err)
 	}
@@ -114,6 +149,7 @@ func (p *Proxy) Start() {
 	}
 	p.spanner = spanner
 	p.listener = svr
+	p.fence = fence
 	log.Info("proxy.start[%v]...", endpoint)
 	go svr.Accept()
 }
@@ -128,6 +164,8 @@ func (p *Proxy) Stop() {
 	p.listener.Close()
 	p.scatter.Close()
 	p.audit.Close()
+	p.slowLog.Close()
+	p.tracer.Close()
 	p.syncer.Close()
 	p.plugins.Close()
 	log.Info("proxy.shutdown.complete...")
@@ -153,6 +191,11 @@ func (p *Proxy) Scatter() *backend.Scatter {
 	return p.scatter
 }
 
+// PeerFence returns the fence of the peers.
+func (p *Proxy) PeerFence() *PeerFence {
+	return p.fence
+}
+
 // Router returns the router.
 func (p *Proxy) Router() *router.Router {
 	return p.router
@@ -168,6 +211,21 @@ func (p *Proxy) Sessions() *Sessions {
 	return p.sessions
 }
 
+// Quota returns the quota.
+func (p *Proxy) Quota() *quota.Quota {
+	return p.quota
+}
+
+// SlowLog returns the slow log.
+func (p *Proxy) SlowLog() *slowlog.SlowLog {
+	return p.slowLog
+}
+
+// Tracer returns the tracer.
+func (p *Proxy) Tracer() *xtrace.Tracer {
+	return p.tracer
+}
+
 // Spanner returns the spanner.
 func (p *Proxy) Spanner() *Spanner {
 	return p.spanner
@@ -294,6 +352,15 @@ func (p *Proxy) SetStreamBufferSize(streamBufferSize int) {
 	p.conf.Proxy.StreamBufferSize = streamBufferSize
 }
 
+// SetStmtCacheSize used to set the statement cache size, the cached statements are invalidated.
+func (p *Proxy) SetStmtCacheSize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.Info("proxy.SetStmtCacheSize:[%d->%d]", p.conf.Proxy.StmtCacheSize, size)
+	p.conf.Proxy.StmtCacheSize = size
+	p.spanner.SetStmtCacheSize(size)
+}
+
 // SetBlocks used to set router blocks.
 func (p *Proxy) SetBlocks(blocks int) {
 	p.mu.Lock()
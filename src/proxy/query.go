@@ -13,7 +13,10 @@ import (
 	"time"
 
 	"monitor"
+	"router"
 	"xbase"
+	"xcontext"
+	"xtrace"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
@@ -31,6 +34,35 @@ func returnQuery(qr *sqltypes.Result, callback func(qr *sqltypes.Result) error,
 	return nil
 }
 
+// parseQuery returns the query with the bind variables and its statement.
+func (spanner *Spanner) parseQuery(query string, bindVariables map[string]*querypb.BindVariable) (string, sqlparser.Statement, error) {
+	log := spanner.log
+
+	if bindVariables != nil {
+		// Bind variables.
+		parsedQuery, node, err := spanner.parseWithBindVars(query)
+		if err != nil {
+			log.Error("query[%v].parser.error: %v", query, err)
+			return query, nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+		}
+		if query, err = parsedQuery.GenerateQuery(bindVariables, nil); err != nil {
+			log.Error("query[%v].parsed.GenerateQuery.error: %v, bind:%+v", query, err, bindVariables)
+			return query, nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+		}
+		// The statement parsed by the cache miss is bound in place, no need to parse the query again.
+		if node != nil && bindStatement(node, bindVariables) {
+			return query, node, nil
+		}
+	}
+
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		log.Error("query[%v].parser.error: %v", query, err)
+		return query, nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+	return query, node, nil
+}
+
 // ComQuery impl.
 // Supports statements are:
 // 1. DDL
@@ -66,30 +98,32 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 	query = strings.TrimSpace(query)
 	query = strings.TrimSuffix(query, ";")
 
-	node, err := sqlparser.Parse(query)
-	if err != nil {
-		log.Error("query[%v].parser.error: %v", query, err)
-		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
-	}
+	var err error
+	var node sqlparser.Statement
 
-	// Bind variables.
-	if bindVariables != nil {
-		parsedQuery := sqlparser.NewParsedQuery(node)
-		query, err = parsedQuery.GenerateQuery(bindVariables, nil)
-		if err != nil {
-			log.Error("query[%v].parsed.GenerateQuery.error: %v, bind:%+v", query, err, bindVariables)
-			return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
-		}
+	// Tracing, the span is nil if the statement isn't traced.
+	span := spanner.startTrace(session, query, timeStart)
+	defer func() {
+		endTrace(span, qr, err)
+	}()
 
-		// This sucks.
-		node, err = sqlparser.Parse(query)
-		if err != nil {
-			log.Error("query[%v].parser.error: %v", query, err)
-			return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
-		}
+	parseSpan := span.StartChild("parse", xtrace.SpanKindInternal)
+	query, node, err = spanner.parseQuery(query, bindVariables)
+	parseSpan.SetError(err)
+	parseSpan.End()
+	if err != nil {
+		return err
 	}
 	log.Debug("query:%v", query)
 
+	// Quota of the user and the databases the query references.
+	user, databases := session.User(), queryDatabases(node, session.Schema())
+	if err = spanner.quota.Acquire(user, databases...); err != nil {
+		log.Warning("proxy.query[%s].quota.exceeded:%v", query, err)
+		return err
+	}
+	defer spanner.quota.Release(user, databases...)
+
 	// Readonly check.
 	if spanner.ReadOnly() {
 		// DML Write denied.
@@ -102,8 +136,30 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		}
 	}
 
+	// The DML waits while the online schema change of its tables is cutting over.
+	if spanner.IsDML(node) || spanner.IsDMLWrite(node) {
+		var exit func()
+		if exit, err = spanner.onlineDDL.enterDML(queryTables(node, session.Schema())); err != nil {
+			return err
+		}
+		defer exit()
+	}
+
+	// The profile collects the stats of the shard querys for the slow log and the tracing.
+	var profile *xcontext.Profile
+	if spanner.slowLog.Enabled() || span != nil {
+		profile = xcontext.NewProfile()
+		profile.SetSpan(span)
+		spanner.sessions.setProfile(session, profile)
+	}
 	defer func() {
-		queryStat(node, timeStart, slowQueryTime, err)
+		if profile != nil {
+			spanner.sessions.setProfile(session, nil)
+		}
+		queryStat(spanner.router, node, session.Schema(), timeStart, slowQueryTime, qr, err)
+		spanner.slowQueryLog(session, query, profile, timeStart, slowQueryTime, qr, err)
+		spanner.queryDigest(session, node, timeStart, qr, err)
+		spanner.sessions.ResetQueryInfo(session)
 	}()
 	// The status of the execution result, zero for success and non-zero for failure.
 	status := uint16(0)
@@ -113,14 +169,16 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			log.Error("proxy.usedb[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, R, xbase.USEDB, query, qr, status)
+		spanner.auditLog(session, R, xbase.USEDB, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.DDL:
 		if qr, err = spanner.handleDDL(session, query, node); err != nil {
 			log.Error("proxy.DDL[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
+		} else {
+			spanner.ClearPlanCache()
 		}
-		spanner.auditLog(session, W, xbase.DDL, query, qr, status)
+		spanner.auditLog(session, W, xbase.DDL, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Show:
 		show := node
@@ -177,6 +235,16 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 				log.Error("proxy.show.txnz[%s].from.session[%v].error:%+v", query, session.ID(), err)
 				status = 1
 			}
+		case sqlparser.ShowXaTransactionsStr:
+			if qr, err = spanner.handleShowXaTransactions(session, query, node); err != nil {
+				log.Error("proxy.show.xa.transactions[%s].from.session[%v].error:%+v", query, session.ID(), err)
+				status = 1
+			}
+		case sqlparser.ShowQueryDigestsStr:
+			if qr, err = spanner.handleShowQueryDigests(session, query, node); err != nil {
+				log.Error("proxy.show.query.digests[%s].from.session[%v].error:%+v", query, session.ID(), err)
+				status = 1
+			}
 		case sqlparser.ShowCreateDatabaseStr:
 			// Support for myloader.
 			if qr, err = spanner.handleShowCreateDatabase(session, query, node); err != nil {
@@ -200,7 +268,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			status = sqldb.ER_UNKNOWN_ERROR
 			err = sqldb.NewSQLErrorf(status, "unsupported.query:%v", query)
 		}
-		spanner.auditLog(session, R, xbase.SHOW, query, qr, status)
+		spanner.auditLog(session, R, xbase.SHOW, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Insert:
 		if qr, err = spanner.handleInsert(session, query, node); err != nil {
@@ -209,24 +277,31 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		}
 		switch node.Action {
 		case sqlparser.InsertStr:
-			spanner.auditLog(session, W, xbase.INSERT, query, qr, status)
+			spanner.auditLog(session, W, xbase.INSERT, query, node, qr, err, status)
 		case sqlparser.ReplaceStr:
-			spanner.auditLog(session, W, xbase.REPLACE, query, qr, status)
+			spanner.auditLog(session, W, xbase.REPLACE, query, node, qr, err, status)
 		}
 		return returnQuery(qr, callback, err)
+	case *sqlparser.Load:
+		if qr, err = spanner.handleLoad(session, query, node); err != nil {
+			log.Error("proxy.load[%s].from.session[%v].error:%+v", query, session.ID(), err)
+			status = 1
+		}
+		spanner.auditLog(session, W, xbase.LOAD, query, node, qr, err, status)
+		return returnQuery(qr, callback, err)
 	case *sqlparser.Delete:
 		if qr, err = spanner.handleDelete(session, query, node); err != nil {
 			log.Error("proxy.delete[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, W, xbase.DELETE, query, qr, status)
+		spanner.auditLog(session, W, xbase.DELETE, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Update:
 		if qr, err = spanner.handleUpdate(session, query, node); err != nil {
 			log.Error("proxy.update[%s].from.session[%v].error:%+v", xbase.TruncateQuery(query, 256), session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, W, xbase.UPDATE, query, qr, status)
+		spanner.auditLog(session, W, xbase.UPDATE, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Select:
 		txSession := spanner.sessions.getTxnSession(session)
@@ -269,14 +344,14 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 					}
 				}
 			}
-			spanner.auditLog(session, R, xbase.SELECT, query, qr, status)
+			spanner.auditLog(session, R, xbase.SELECT, query, node, qr, err, status)
 			return returnQuery(qr, callback, err)
 		default: // ParenTableExpr, JoinTableExpr
 			if qr, err = spanner.handleSelect(session, query, node); err != nil {
 				log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
 				status = 1
 			}
-			spanner.auditLog(session, R, xbase.SELECT, query, qr, status)
+			spanner.auditLog(session, R, xbase.SELECT, query, node, qr, err, status)
 			return returnQuery(qr, callback, err)
 		}
 	case *sqlparser.Union:
@@ -284,21 +359,21 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			log.Error("proxy.union[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, W, xbase.UPDATE, query, qr, status)
+		spanner.auditLog(session, W, xbase.UPDATE, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Kill:
 		if qr, err = spanner.handleKill(session, query, node); err != nil {
 			log.Error("proxy.kill[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, R, xbase.KILL, query, qr, status)
+		spanner.auditLog(session, R, xbase.KILL, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Explain:
 		if qr, err = spanner.handleExplain(session, query, node); err != nil {
 			log.Error("proxy.explain[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, R, xbase.EXPLAIN, query, qr, status)
+		spanner.auditLog(session, R, xbase.EXPLAIN, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Transaction:
 		// Support for myloader.
@@ -307,14 +382,24 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			log.Error("proxy.transaction[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, R, xbase.TRANSACTION, query, qr, status)
+		spanner.auditLog(session, R, xbase.TRANSACTION, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Radon:
 		if qr, err = spanner.handleRadon(session, query, node); err != nil {
 			log.Error("proxy.admin[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, R, xbase.RADON, query, qr, status)
+		m := R
+		switch node.Action {
+		case sqlparser.XaCommitStr, sqlparser.XaRollbackStr:
+			// The manual xa resolution changes the data.
+			m = W
+		case sqlparser.OnlineAlterStr, sqlparser.OnlineAlterPauseStr,
+			sqlparser.OnlineAlterResumeStr, sqlparser.OnlineAlterCancelStr,
+			sqlparser.DDLRetryStr, sqlparser.DDLRollbackStr:
+			m = W
+		}
+		spanner.auditLog(session, m, xbase.RADON, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Set:
 		log.Warning("proxy.query.set.query:%s", query)
@@ -322,7 +407,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			log.Error("proxy.set[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, R, xbase.SET, query, qr, status)
+		spanner.auditLog(session, R, xbase.SET, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Checksum:
 		log.Warning("proxy.query.checksum.query:%s", query)
@@ -330,13 +415,13 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			log.Error("proxy.checksum[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			status = 1
 		}
-		spanner.auditLog(session, R, xbase.CHECKSUM, query, qr, status)
+		spanner.auditLog(session, R, xbase.CHECKSUM, query, node, qr, err, status)
 		return returnQuery(qr, callback, err)
 	default:
 		log.Error("proxy.unsupported[%s].from.session[%v]", query, session.ID())
 		status = sqldb.ER_UNKNOWN_ERROR
 		err = sqldb.NewSQLErrorf(status, "unsupported.query:%v", query)
-		spanner.auditLog(session, R, xbase.UNSUPPORT, query, qr, status)
+		spanner.auditLog(session, R, xbase.UNSUPPORT, query, node, qr, err, status)
 		return err
 	}
 }
@@ -353,7 +438,7 @@ func (spanner *Spanner) IsDML(node sqlparser.Statement) bool {
 // IsDMLWrite returns the DML write or not.
 func (spanner *Spanner) IsDMLWrite(node sqlparser.Statement) bool {
 	switch node.(type) {
-	case *sqlparser.Insert, *sqlparser.Delete, *sqlparser.Update:
+	case *sqlparser.Insert, *sqlparser.Delete, *sqlparser.Update, *sqlparser.Load:
 		return true
 	}
 	return false
@@ -368,7 +453,22 @@ func (spanner *Spanner) IsDDL(node sqlparser.Statement) bool {
 	return false
 }
 
-func queryStat(node sqlparser.Statement, timeStart time.Time, slowQueryTime time.Duration, err error) {
+// queryStatTable returns the table label of the query latency: the first table of the query known by the router,
+// or empty if none. The label values are bounded by the tables of the router, not by the raw table names.
+func queryStatTable(route *router.Router, node sqlparser.Statement, db string) string {
+	for _, table := range queryTables(node, db) {
+		names := strings.SplitN(table, ".", 2)
+		if len(names) != 2 {
+			continue
+		}
+		if ok, _ := route.CheckTable(names[0], names[1]); ok {
+			return table
+		}
+	}
+	return ""
+}
+
+func queryStat(route *router.Router, node sqlparser.Statement, db string, timeStart time.Time, slowQueryTime time.Duration, qr *sqltypes.Result, err error) {
 	var command string
 	switch node.(type) {
 	case *sqlparser.Use:
@@ -379,6 +479,8 @@ func queryStat(node sqlparser.Statement, timeStart time.Time, slowQueryTime time
 		command = "Show"
 	case *sqlparser.Insert:
 		command = "Insert"
+	case *sqlparser.Load:
+		command = "Load"
 	case *sqlparser.Delete:
 		command = "Delete"
 	case *sqlparser.Update:
@@ -410,4 +512,10 @@ func queryStat(node sqlparser.Statement, timeStart time.Time, slowQueryTime time
 		}
 		monitor.QueryTotalCounterInc(command, "OK")
 	}
+
+	// The latency is observed once per query.
+	monitor.QueryLatencyObserve(command, queryStatTable(route, node, db), queryTime)
+	if qr != nil {
+		monitor.RowsReturnedAdd(command, len(qr.Rows))
+	}
 }
@@ -14,6 +14,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -696,3 +697,34 @@ func TestProxyQuerySubQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestProxyQueryStatTable(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", proxy.Address(), "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+
+	// The label is the first table known by the router, the raw names are not labeled.
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select * from t1", "test.t1"},
+		{"select * from xx.t9 join test.t1", "test.t1"},
+		{"select * from t2", ""},
+		{"select 1", ""},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, queryStatTable(proxy.Router(), node, "test"), test.query)
+	}
+}
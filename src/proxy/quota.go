@@ -0,0 +1,88 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"strings"
+
+	"quota"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+// userConnectionsCheck used to check the max connections quota of the user.
+// The session itself has been registered to the sessions.
+func (spanner *Spanner) userConnectionsCheck(s *driver.Session) error {
+	user := s.User()
+	max, _ := spanner.quota.MaxConnections(user, "")
+	if max <= 0 {
+		return nil
+	}
+	if spanner.sessions.UserCount(user, s.ID()) >= max {
+		spanner.log.Warning("proxy.quota.user[%s].too.many.connections(max:%d)", user, max)
+		return sqldb.NewSQLErrorf(sqldb.ER_TOO_MANY_USER_CONNECTIONS, "User %s already has more than 'max_user_connections' active connections", user)
+	}
+	return nil
+}
+
+// databaseConnectionsCheck used to check the max connections quota of the database before the session uses it.
+func (spanner *Spanner) databaseConnectionsCheck(s *driver.Session, database string) error {
+	if database == s.Schema() {
+		return nil
+	}
+	_, max := spanner.quota.MaxConnections("", database)
+	if max <= 0 {
+		return nil
+	}
+	if spanner.sessions.DatabaseCount(database, s.ID()) >= max {
+		spanner.log.Warning("proxy.quota.database[%s].too.many.connections(max:%d)", database, max)
+		return sqldb.NewSQLErrorf(sqldb.ER_TOO_MANY_USER_CONNECTIONS, "Database %s already has more than 'max_connections' active connections", database)
+	}
+	return nil
+}
+
+// sessionLimits returns the query timeout and the max result size of the session, limited by the quotas of
+// the user and every database the query references(the databases charged by the quota).
+func (spanner *Spanner) sessionLimits(s *driver.Session, databases []string, timeout int, maxResultSize int) (int, int) {
+	return quotaLimits(spanner.quota, s.User(), databases, timeout, maxResultSize)
+}
+
+// quotaLimits returns the strictest limits of the user and the databases.
+func quotaLimits(q *quota.Quota, user string, databases []string, timeout int, maxResultSize int) (int, int) {
+	if len(databases) == 0 {
+		return q.Limits(user, "", timeout, maxResultSize)
+	}
+	for _, database := range databases {
+		timeout, maxResultSize = q.Limits(user, database, timeout, maxResultSize)
+	}
+	return timeout, maxResultSize
+}
+
+// queryDatabases returns the databases the query references, the session database is
+// charged if the query references no tables.
+func queryDatabases(node sqlparser.Statement, database string) []string {
+	var databases []string
+	seen := make(map[string]bool)
+	for _, table := range queryTables(node, database) {
+		idx := strings.Index(table, ".")
+		if idx < 0 {
+			continue
+		}
+		if db := table[:idx]; !seen[db] {
+			seen[db] = true
+			databases = append(databases, db)
+		}
+	}
+	if len(databases) == 0 && database != "" {
+		databases = append(databases, database)
+	}
+	return databases
+}
@@ -0,0 +1,180 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyQuotaMaxConnections(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	quota := proxy.Quota()
+
+	fakedbs.AddQuery("select version() as version", resultVersion57)
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	// User.
+	{
+		err := quota.SetUser("mock", &config.QuotaConfig{MaxConnections: 2})
+		assert.Nil(t, err)
+
+		var clients []driver.Conn
+		for i := 0; i < 2; i++ {
+			client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+			assert.Nil(t, err)
+			clients = append(clients, client)
+		}
+		_, err = driver.NewConn("mock", "mock", address, "", "utf8")
+		want := "User mock already has more than 'max_user_connections' active connections (errno 1203) (sqlstate 42000)"
+		assert.Equal(t, want, err.Error())
+
+		for _, client := range clients {
+			client.Close()
+		}
+		err = quota.RemoveUser("mock")
+		assert.Nil(t, err)
+	}
+
+	// Database.
+	{
+		err := quota.SetDatabase("test", &config.QuotaConfig{MaxConnections: 1})
+		assert.Nil(t, err)
+
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = driver.NewConn("mock", "mock", address, "test", "utf8")
+		want := "Database test already has more than 'max_connections' active connections (errno 1203) (sqlstate 42000)"
+		assert.Equal(t, want, err.Error())
+
+		// Use db.
+		client1, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client1.Close()
+		_, err = client1.FetchAll("use test", -1)
+		assert.Equal(t, want, err.Error())
+
+		// The session already uses the db.
+		_, err = client.FetchAll("use test", -1)
+		assert.Nil(t, err)
+	}
+}
+
+func TestProxyQuotaQPS(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	quota := proxy.Quota()
+
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	err := quota.SetDatabase("test", &config.QuotaConfig{MaxQPS: 1})
+	assert.Nil(t, err)
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	want := "Database 'test' has exceeded the 'max_qps' resource (current value: 1) (errno 1226) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+
+	err = quota.RemoveDatabase("test")
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+}
+
+func TestProxyQuotaReferencedDatabases(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	quota := proxy.Quota()
+
+	err := quota.SetDatabase("db2", &config.QuotaConfig{MaxQPS: 1})
+	assert.Nil(t, err)
+
+	// The session database isn't charged by the query referencing the other database.
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("select * from db2.t1", -1)
+	assert.NotNil(t, err)
+	_, err = client.FetchAll("select * from db2.t1", -1)
+	want := "Database 'db2' has exceeded the 'max_qps' resource (current value: 1) (errno 1226) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
+
+func TestProxyQuotaLimits(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	quota := proxy.Quota()
+
+	assert.Nil(t, quota.SetUser("mock", &config.QuotaConfig{QueryTimeout: 5000}))
+	assert.Nil(t, quota.SetDatabase("db1", &config.QuotaConfig{MaxResultSize: 1024}))
+	assert.Nil(t, quota.SetDatabase("db2", &config.QuotaConfig{QueryTimeout: 1000, MaxResultSize: 4096}))
+
+	// The strictest limit of the user and all the referenced databases wins.
+	node, err := sqlparser.Parse("select * from db1.t1 join db2.t2")
+	assert.Nil(t, err)
+	timeout, maxResult := quotaLimits(quota, "mock", queryDatabases(node, "test"), 10000, 10240)
+	assert.Equal(t, 1000, timeout)
+	assert.Equal(t, 1024, maxResult)
+
+	// The session database is not limited if it's not referenced.
+	node, err = sqlparser.Parse("select * from db2.t2")
+	assert.Nil(t, err)
+	timeout, maxResult = quotaLimits(quota, "mock", queryDatabases(node, "db1"), 10000, 10240)
+	assert.Equal(t, 1000, timeout)
+	assert.Equal(t, 4096, maxResult)
+
+	// No database.
+	timeout, maxResult = quotaLimits(quota, "mock", nil, 10000, 10240)
+	assert.Equal(t, 5000, timeout)
+	assert.Equal(t, 10240, maxResult)
+}
+
+func TestProxyQueryDatabases(t *testing.T) {
+	querys := []struct {
+		query string
+		want  []string
+	}{
+		{"select 1", []string{"test"}},
+		{"select * from t1", []string{"test"}},
+		{"select * from db1.t1 join t2 on db1.t1.a=t2.a", []string{"db1", "test"}},
+		{"insert into db1.t1 select * from db1.t2", []string{"db1"}},
+	}
+	for _, q := range querys {
+		node, err := sqlparser.Parse(q.query)
+		assert.Nil(t, err)
+		assert.Equal(t, q.want, queryDatabases(node, "test"))
+	}
+
+	node, err := sqlparser.Parse("select 1")
+	assert.Nil(t, err)
+	assert.Nil(t, queryDatabases(node, ""))
+}
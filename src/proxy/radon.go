@@ -17,7 +17,7 @@ import (
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
-// handleRadon used to handle the command: radon attach/detach/attachlist.
+// handleRadon used to handle the command: radon attach/detach/attachlist/reshard/xa/backup/alter/ddl/check.
 func (spanner *Spanner) handleRadon(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	var err error
 	var qr *sqltypes.Result
@@ -62,7 +62,17 @@ func (spanner *Spanner) handleRadon(session *driver.Session, query string, node
 		reshard := NewReshard(log, spanner.scatter, spanner.router, spanner, session.User())
 		reshard.SetHandle(reshard)
 		qr, err = reshard.ReShardTable(database, table, newDatabase, newTable)
-
+	case sqlparser.XaCommitStr, sqlparser.XaRollbackStr:
+		qr, err = spanner.handleRadonXa(session, query, snode)
+	case sqlparser.BackupStr:
+		qr, err = spanner.handleRadonBackup(session, query, snode)
+	case sqlparser.OnlineAlterStr, sqlparser.OnlineAlterStatusStr,
+		sqlparser.OnlineAlterPauseStr, sqlparser.OnlineAlterResumeStr, sqlparser.OnlineAlterCancelStr:
+		qr, err = spanner.handleRadonAlter(session, query, snode)
+	case sqlparser.DDLStatusStr, sqlparser.DDLRetryStr, sqlparser.DDLRollbackStr:
+		qr, err = spanner.handleRadonDDL(session, query, snode)
+	case sqlparser.CheckTableStr:
+		qr, err = spanner.handleRadonCheckTable(session, query, snode)
 	default:
 		log.Error("proxy.radon.unsupported[%s]", query)
 		err = sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "unsupported.query: %v", query)
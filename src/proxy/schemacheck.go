@@ -0,0 +1,483 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"router"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// The sub-table or the database is routed but not on the backend.
+	schemaCheckMissing = "missing"
+	// The sub-table is on the backend but not routed.
+	schemaCheckOrphan = "orphan"
+	// The sub-table definition differs from the most of the sub-tables.
+	schemaCheckDefinition = "definition"
+	// The table json file in the metadir differs from the router.
+	schemaCheckFrm = "frm"
+)
+
+var (
+	schemaCheckSubTableRegexp = regexp.MustCompile(`^(.+)_[0-9]{4}$`)
+)
+
+// SchemaCheckIssue is an inconsistency found by the schema check.
+type SchemaCheckIssue struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Backend  string `json:"backend"`
+	SubTable string `json:"sub-table"`
+	Type     string `json:"type"`
+	Detail   string `json:"detail"`
+	Repair   string `json:"repair,omitempty"`
+}
+
+// CheckSchema used to check the sub-tables on the backends are consistent with the router and with each other.
+// If the table is empty all the tables of the database are checked, if the database is empty all the databases
+// are checked. The repair ddls are only generated if repair is true, they are never executed.
+func (spanner *Spanner) CheckSchema(database string, table string, repair bool) ([]*SchemaCheckIssue, error) {
+	tables := spanner.router.Tables()
+
+	var databases []string
+	if database == "" {
+		for db := range tables {
+			databases = append(databases, db)
+		}
+		sort.Strings(databases)
+	} else {
+		names, ok := tables[database]
+		if !ok {
+			return nil, sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, database)
+		}
+		if table != "" {
+			found := false
+			for _, name := range names {
+				if name == table {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
+			}
+		}
+		databases = []string{database}
+	}
+
+	var issues []*SchemaCheckIssue
+	for _, db := range databases {
+		names := tables[db]
+		if table != "" {
+			names = []string{table}
+		}
+		sort.Strings(names)
+		dbIssues, err := spanner.checkDatabaseSchema(db, table, names)
+		if err != nil {
+			spanner.log.Error("spanner.schema.check.database[%s].error:%+v", db, err)
+			return nil, err
+		}
+		issues = append(issues, dbIssues...)
+	}
+	if !repair {
+		for _, issue := range issues {
+			issue.Repair = ""
+		}
+	}
+	return issues, nil
+}
+
+// checkDatabaseSchema used to check the tables of the database, the filter is the table to check or empty for all.
+func (spanner *Spanner) checkDatabaseSchema(database string, filter string, names []string) ([]*SchemaCheckIssue, error) {
+	var issues []*SchemaCheckIssue
+
+	// The frm files.
+	mismatches, err := spanner.router.CheckFrm(database)
+	if err != nil {
+		return nil, err
+	}
+	var frms []string
+	for name := range mismatches {
+		if filter == "" || name == filter {
+			frms = append(frms, name)
+		}
+	}
+	sort.Strings(frms)
+	for _, name := range frms {
+		issues = append(issues, &SchemaCheckIssue{Database: database, Table: name, Type: schemaCheckFrm, Detail: mismatches[name]})
+	}
+
+	// The routed sub-tables.
+	segments := make(map[string][]router.Segment, len(names))
+	expected := make(map[string]map[string]bool)
+	for _, name := range names {
+		segs, err := spanner.router.Lookup(database, name, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		segments[name] = segs
+		for _, seg := range segs {
+			if expected[seg.Backend] == nil {
+				expected[seg.Backend] = make(map[string]bool)
+			}
+			expected[seg.Backend][seg.Table] = true
+		}
+	}
+
+	// The physical tables.
+	backends := spanner.scatter.Backends()
+	physicals, err := spanner.showTables(backends, database)
+	if err != nil {
+		return nil, err
+	}
+	for _, backend := range backends {
+		tables, ok := physicals[backend]
+		if !ok {
+			issues = append(issues, &SchemaCheckIssue{
+				Database: database,
+				Backend:  backend,
+				Type:     schemaCheckMissing,
+				Detail:   "database.missing",
+				Repair:   fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", database),
+			})
+			continue
+		}
+		var orphans []string
+		for table := range tables {
+			if expected[backend][table] {
+				continue
+			}
+			// The shadow and old tables of the online ddl job are not orphans until the job is finished.
+			if spanner.onlineDDL.owns(database, backend, table) {
+				continue
+			}
+			if filter != "" && schemaCheckOwner(table) != filter {
+				continue
+			}
+			orphans = append(orphans, table)
+		}
+		sort.Strings(orphans)
+		for _, table := range orphans {
+			issues = append(issues, &SchemaCheckIssue{
+				Database: database,
+				Table:    schemaCheckOwner(table),
+				Backend:  backend,
+				SubTable: table,
+				Type:     schemaCheckOrphan,
+				Detail:   "sub-table.not.in.router",
+				Repair:   fmt.Sprintf("DROP TABLE `%s`.`%s`", database, table),
+			})
+		}
+	}
+
+	// The definitions.
+	for _, name := range names {
+		tableIssues, err := spanner.checkTableSchema(database, name, segments[name], physicals)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, tableIssues...)
+	}
+	return issues, nil
+}
+
+// checkTableSchema used to check the sub-tables of the table exist and have the same definition.
+func (spanner *Spanner) checkTableSchema(database string, table string, segments []router.Segment, physicals map[string]map[string]bool) ([]*SchemaCheckIssue, error) {
+	var missings []router.Segment
+	var segs []*ddlJobSegment
+	for _, seg := range segments {
+		if !physicals[seg.Backend][seg.Table] {
+			missings = append(missings, seg)
+			continue
+		}
+		segs = append(segs, &ddlJobSegment{backend: seg.Backend, table: seg.Table})
+	}
+	defs, most, err := spanner.ddlJobs.definitions(database, table, segs)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []*SchemaCheckIssue
+	for _, seg := range missings {
+		issue := &SchemaCheckIssue{
+			Database: database,
+			Table:    table,
+			Backend:  seg.Backend,
+			SubTable: seg.Table,
+			Type:     schemaCheckMissing,
+			Detail:   "sub-table.missing",
+		}
+		if most != "" {
+			issue.Repair = strings.Replace(most, fmt.Sprintf("CREATE TABLE `%s`", table), fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`.`%s`", database, seg.Table), 1)
+		}
+		issues = append(issues, issue)
+	}
+
+	ref, refErr := schemaCheckParse(most)
+	for _, seg := range segs {
+		def := defs[seg]
+		if def == most {
+			continue
+		}
+		var diffs [][2]string
+		if refErr == nil {
+			if node, err := schemaCheckParse(def); err == nil {
+				diffs = schemaCheckDiff(fmt.Sprintf("`%s`.`%s`", database, seg.table), ref, node)
+			}
+		}
+		// The definitions differ in something not comparable, such as the order of the columns.
+		if len(diffs) == 0 {
+			diffs = [][2]string{{"definition.differs", ""}}
+		}
+		for _, diff := range diffs {
+			issues = append(issues, &SchemaCheckIssue{
+				Database: database,
+				Table:    table,
+				Backend:  seg.backend,
+				SubTable: seg.table,
+				Type:     schemaCheckDefinition,
+				Detail:   diff[0],
+				Repair:   diff[1],
+			})
+		}
+	}
+	return issues, nil
+}
+
+// showTables returns the tables of the database on each backend, the backend without the database is absent.
+func (spanner *Spanner) showTables(backends []string, database string) (map[string]map[string]bool, error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var checkErr error
+	physicals := make(map[string]map[string]bool, len(backends))
+	for _, backend := range backends {
+		wg.Add(1)
+		go func(backend string) {
+			defer wg.Done()
+			tables, err := spanner.showBackendTables(backend, database)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if sqlErr, ok := err.(*sqldb.SQLError); ok && sqlErr.Num == sqldb.ER_BAD_DB_ERROR {
+					return
+				}
+				checkErr = err
+				return
+			}
+			physicals[backend] = tables
+		}(backend)
+	}
+	wg.Wait()
+	if checkErr != nil {
+		return nil, checkErr
+	}
+	return physicals, nil
+}
+
+// showBackendTables returns the tables of the database on the backend.
+func (spanner *Spanner) showBackendTables(backend string, database string) (map[string]bool, error) {
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(spanner.conf.Proxy.QueryTimeout)
+
+	qr, err := txn.ExecuteOnThisBackend(backend, fmt.Sprintf("SHOW TABLES FROM `%s`", database))
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]bool, len(qr.Rows))
+	for _, row := range qr.Rows {
+		if len(row) > 0 {
+			tables[row[0].ToString()] = true
+		}
+	}
+	return tables, nil
+}
+
+// schemaCheckOwner returns the table which the sub-table belongs to.
+func schemaCheckOwner(subTable string) string {
+	if matches := schemaCheckSubTableRegexp.FindStringSubmatch(subTable); matches != nil {
+		return matches[1]
+	}
+	return subTable
+}
+
+// schemaCheckParse used to parse the SHOW CREATE TABLE.
+func schemaCheckParse(create string) (*sqlparser.DDL, error) {
+	node, err := sqlparser.Parse(create)
+	if err != nil {
+		return nil, err
+	}
+	ddl, ok := node.(*sqlparser.DDL)
+	if !ok || ddl.TableSpec == nil {
+		return nil, errors.Errorf("spanner.schema.check.can.not.parse[%s]", create)
+	}
+	return ddl, nil
+}
+
+// schemaCheckIndexName returns the name of the index, the primary key is named PRIMARY.
+func schemaCheckIndexName(idx *sqlparser.IndexDefinition) string {
+	if idx.Info.Primary {
+		return "PRIMARY"
+	}
+	return idx.Info.Name.String()
+}
+
+// schemaCheckDropIndex returns the clause to drop the index.
+func schemaCheckDropIndex(idx *sqlparser.IndexDefinition) string {
+	if idx.Info.Primary {
+		return "DROP PRIMARY KEY"
+	}
+	return fmt.Sprintf("DROP INDEX `%s`", idx.Info.Name.String())
+}
+
+// schemaCheckDiff returns the differences of the definition from the reference, each with the ddl to repair it.
+func schemaCheckDiff(name string, ref *sqlparser.DDL, def *sqlparser.DDL) [][2]string {
+	var diffs [][2]string
+
+	// Columns.
+	cols := make(map[string]*sqlparser.ColumnDefinition, len(def.TableSpec.Columns))
+	for _, col := range def.TableSpec.Columns {
+		cols[col.Name.Lowered()] = col
+	}
+	for _, refCol := range ref.TableSpec.Columns {
+		col, ok := cols[refCol.Name.Lowered()]
+		switch {
+		case !ok:
+			diffs = append(diffs, [2]string{
+				fmt.Sprintf("column[%s].missing", refCol.Name.String()),
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", name, sqlparser.String(refCol)),
+			})
+		case sqlparser.String(col) != sqlparser.String(refCol):
+			diffs = append(diffs, [2]string{
+				fmt.Sprintf("column[%s].differs", refCol.Name.String()),
+				fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", name, sqlparser.String(refCol)),
+			})
+		}
+		delete(cols, refCol.Name.Lowered())
+	}
+	for _, col := range def.TableSpec.Columns {
+		if _, ok := cols[col.Name.Lowered()]; ok {
+			diffs = append(diffs, [2]string{
+				fmt.Sprintf("column[%s].unexpected", col.Name.String()),
+				fmt.Sprintf("ALTER TABLE %s DROP COLUMN `%s`", name, col.Name.String()),
+			})
+		}
+	}
+
+	// Indexes.
+	idxs := make(map[string]*sqlparser.IndexDefinition, len(def.TableSpec.Indexes))
+	for _, idx := range def.TableSpec.Indexes {
+		idxs[strings.ToLower(schemaCheckIndexName(idx))] = idx
+	}
+	for _, refIdx := range ref.TableSpec.Indexes {
+		key := strings.ToLower(schemaCheckIndexName(refIdx))
+		idx, ok := idxs[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, [2]string{
+				fmt.Sprintf("index[%s].missing", schemaCheckIndexName(refIdx)),
+				fmt.Sprintf("ALTER TABLE %s ADD %s", name, sqlparser.String(refIdx)),
+			})
+		case sqlparser.String(idx) != sqlparser.String(refIdx):
+			diffs = append(diffs, [2]string{
+				fmt.Sprintf("index[%s].differs", schemaCheckIndexName(refIdx)),
+				fmt.Sprintf("ALTER TABLE %s %s, ADD %s", name, schemaCheckDropIndex(idx), sqlparser.String(refIdx)),
+			})
+		}
+		delete(idxs, key)
+	}
+	for _, idx := range def.TableSpec.Indexes {
+		if _, ok := idxs[strings.ToLower(schemaCheckIndexName(idx))]; ok {
+			diffs = append(diffs, [2]string{
+				fmt.Sprintf("index[%s].unexpected", schemaCheckIndexName(idx)),
+				fmt.Sprintf("ALTER TABLE %s %s", name, schemaCheckDropIndex(idx)),
+			})
+		}
+	}
+
+	// Options.
+	refOpts, opts := ref.TableSpec.Options, def.TableSpec.Options
+	if !strings.EqualFold(opts.Engine, refOpts.Engine) && refOpts.Engine != "" {
+		diffs = append(diffs, [2]string{
+			fmt.Sprintf("engine[%s].differs.from[%s]", opts.Engine, refOpts.Engine),
+			fmt.Sprintf("ALTER TABLE %s ENGINE=%s", name, refOpts.Engine),
+		})
+	}
+	if !strings.EqualFold(opts.Charset, refOpts.Charset) && refOpts.Charset != "" {
+		diffs = append(diffs, [2]string{
+			fmt.Sprintf("charset[%s].differs.from[%s]", opts.Charset, refOpts.Charset),
+			fmt.Sprintf("ALTER TABLE %s DEFAULT CHARSET=%s", name, refOpts.Charset),
+		})
+	}
+	return diffs
+}
+
+// handleRadonCheckTable used to handle the command: radon check table [table] [with repair].
+func (spanner *Spanner) handleRadonCheckTable(session *driver.Session, query string, node *sqlparser.Radon) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	database := session.Schema()
+	if !node.Table.Qualifier.IsEmpty() {
+		database = node.Table.Qualifier.String()
+	}
+	if database == "" {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_DB_ERROR)
+	}
+	if err := spanner.router.DatabaseACL(database); err != nil {
+		return nil, err
+	}
+	issues, err := spanner.CheckSchema(database, node.Table.Name.String(), node.Repair)
+	if err != nil {
+		return nil, err
+	}
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Backend", Type: querypb.Type_VARCHAR},
+		{Name: "Sub_Table", Type: querypb.Type_VARCHAR},
+		{Name: "Type", Type: querypb.Type_VARCHAR},
+		{Name: "Detail", Type: querypb.Type_VARCHAR},
+	}
+	if node.Repair {
+		qr.Fields = append(qr.Fields, &querypb.Field{Name: "Repair", Type: querypb.Type_VARCHAR})
+	}
+	for _, issue := range issues {
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(issue.Table)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(issue.Backend)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(issue.SubTable)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(issue.Type)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(issue.Detail)),
+		}
+		if node.Repair {
+			row = append(row, sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(issue.Repair)))
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	return qr, nil
+}
@@ -0,0 +1,263 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"fakedb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	schemaCheckCreate        = "CREATE TABLE `t1` (\n  `id` int(11) DEFAULT NULL,\n  `b` int(11) DEFAULT NULL,\n  KEY `idx_b` (`b`)\n) ENGINE=InnoDB AUTO_INCREMENT=7 DEFAULT CHARSET=utf8"
+	schemaCheckCreateDrifted = "CREATE TABLE `t1_0001` (\n  `id` bigint(20) DEFAULT NULL,\n  `c` int(11) DEFAULT NULL,\n  KEY `idx_c` (`c`)\n) ENGINE=MyISAM DEFAULT CHARSET=latin1"
+	schemaCheckCreateOrdered = "CREATE TABLE `t1_0002` (\n  `b` int(11) DEFAULT NULL,\n  `id` int(11) DEFAULT NULL,\n  KEY `idx_b` (`b`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8"
+)
+
+// schemaCheckResult returns the single column result.
+func schemaCheckResult(name string, values ...string) *sqltypes.Result {
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: name, Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, value := range values {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(value))})
+	}
+	return qr
+}
+
+// schemaCheckShowCreateResult returns the SHOW CREATE TABLE result.
+func schemaCheckShowCreateResult(create string) *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Create Table", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(create)),
+			},
+		},
+	}
+}
+
+// mockSchemaCheck used to mock the tables on the first backend only:
+// test.t1 with t1_0000 missing and t1_0001/t1_0002 drifted, test.g with frm missing, test.s missing,
+// test.t1_0099 and test.t2_0000 orphans, and database test2 missing.
+func mockSchemaCheck(log *xlog.Log) (*fakedb.DB, *Proxy, func()) {
+	fakedbs, proxy, cleanup := MockProxy(log)
+	for _, conf := range fakedbs.BackendConfs()[1:] {
+		if err := proxy.Scatter().Remove(conf); err != nil {
+			panic(err)
+		}
+	}
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", proxy.Address(), "", "utf8")
+	if err != nil {
+		panic(err)
+	}
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create database test2",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"create table test.g(id int) global",
+		"create table test.s(id int) single",
+	}
+	for _, query := range querys {
+		if _, err := client.FetchAll(query, -1); err != nil {
+			panic(err)
+		}
+	}
+	if err := os.Remove(path.Join(proxy.Config().Proxy.MetaDir, "test", "g.json")); err != nil {
+		panic(err)
+	}
+
+	segments, err := proxy.Router().Lookup("test", "t1", nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	tables := []string{"g", "t1_0099", "t2_0000"}
+	for _, segment := range segments[1:] {
+		tables = append(tables, segment.Table)
+	}
+	fakedbs.AddQuery("show tables from `test`", schemaCheckResult("Tables_in_test", tables...))
+	fakedbs.AddQueryError("show tables from `test2`", sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, "test2"))
+	fakedbs.AddQuery("show create table `test`.`t1_0001`", schemaCheckShowCreateResult(schemaCheckCreateDrifted))
+	fakedbs.AddQuery("show create table `test`.`t1_0002`", schemaCheckShowCreateResult(schemaCheckCreateOrdered))
+	fakedbs.AddQueryPattern("show create table .*", schemaCheckShowCreateResult(schemaCheckCreate))
+	return fakedbs, proxy, cleanup
+}
+
+func TestProxySchemaCheck(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := mockSchemaCheck(log)
+	defer cleanup()
+	backend := proxy.Scatter().Backends()[0]
+
+	client, err := driver.NewConn("mock", "mock", proxy.Address(), "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Check all the tables of the database.
+	{
+		qr, err := client.FetchAll("radon check table", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(qr.Fields))
+		want := [][]string{
+			{"g", "", "", "frm", "frm.file.missing"},
+			{"t1", backend, "t1_0099", "orphan", "sub-table.not.in.router"},
+			{"t2", backend, "t2_0000", "orphan", "sub-table.not.in.router"},
+			{"s", backend, "s", "missing", "sub-table.missing"},
+			{"t1", backend, "t1_0000", "missing", "sub-table.missing"},
+			{"t1", backend, "t1_0001", "definition", "column[id].differs"},
+			{"t1", backend, "t1_0001", "definition", "column[b].missing"},
+			{"t1", backend, "t1_0001", "definition", "column[c].unexpected"},
+			{"t1", backend, "t1_0001", "definition", "index[idx_b].missing"},
+			{"t1", backend, "t1_0001", "definition", "index[idx_c].unexpected"},
+			{"t1", backend, "t1_0001", "definition", "engine[MyISAM].differs.from[InnoDB]"},
+			{"t1", backend, "t1_0001", "definition", "charset[latin1].differs.from[utf8]"},
+			{"t1", backend, "t1_0002", "definition", "definition.differs"},
+		}
+		var got [][]string
+		for _, row := range qr.Rows {
+			var values []string
+			for _, v := range row {
+				values = append(values, v.ToString())
+			}
+			got = append(got, values)
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// Check the table with repair.
+	{
+		qr, err := client.FetchAll("radon check table test.t1 with repair", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "Repair", qr.Fields[5].Name)
+		want := []string{
+			"DROP TABLE `test`.`t1_0099`",
+			"CREATE TABLE IF NOT EXISTS `test`.`t1_0000` (\n  `id` int(11) DEFAULT NULL,\n  `b` int(11) DEFAULT NULL,\n  KEY `idx_b` (`b`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8",
+			"ALTER TABLE `test`.`t1_0001` MODIFY COLUMN `id` int(11) default null",
+			"ALTER TABLE `test`.`t1_0001` ADD COLUMN `b` int(11) default null",
+			"ALTER TABLE `test`.`t1_0001` DROP COLUMN `c`",
+			"ALTER TABLE `test`.`t1_0001` ADD key `idx_b` (`b`)",
+			"ALTER TABLE `test`.`t1_0001` DROP INDEX `idx_c`",
+			"ALTER TABLE `test`.`t1_0001` ENGINE=InnoDB",
+			"ALTER TABLE `test`.`t1_0001` DEFAULT CHARSET=utf8",
+			"",
+		}
+		var got []string
+		for _, row := range qr.Rows {
+			got = append(got, row[5].ToString())
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// The database is missing on the backend.
+	{
+		_, err := client.FetchAll("use test2", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("radon check table with repair", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "database.missing", qr.Rows[0][4].ToString())
+		assert.Equal(t, "CREATE DATABASE IF NOT EXISTS `test2`", qr.Rows[0][5].ToString())
+	}
+
+	// The shadow and old tables of the running online ddl job are not orphans.
+	{
+		job := &onlineDDLJob{
+			database: "test",
+			table:    "t1",
+			state:    onlineDDLStateRunning,
+			segments: []*onlineDDLSegment{newOnlineDDLSegment(backend, "t1_0001")},
+		}
+		job.cond = sync.NewCond(&job.mu)
+		onlineDDL := proxy.spanner.onlineDDL
+		onlineDDL.mu.Lock()
+		onlineDDL.jobs = append(onlineDDL.jobs, job)
+		onlineDDL.mu.Unlock()
+		fakedbs.AddQuery("show tables from `test`", schemaCheckResult("Tables_in_test", "g", "t1_0001", "t1_0002", "_t1_0001_new", "_t1_0001_old", "_t1_0002_new"))
+
+		_, err := client.FetchAll("use test", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("radon check table with repair", -1)
+		assert.Nil(t, err)
+		var orphans []string
+		for _, row := range qr.Rows {
+			if row[3].ToString() == "orphan" {
+				orphans = append(orphans, row[2].ToString()+":"+row[5].ToString())
+			}
+		}
+		assert.Equal(t, []string{"_t1_0002_new:DROP TABLE `test`.`_t1_0002_new`"}, orphans)
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"radon check table test.t3",
+			"radon check table test3.t1",
+			"radon check table mysql.user",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+	}
+}
+
+func TestProxySchemaCheckAll(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := mockSchemaCheck(log)
+	defer cleanup()
+
+	issues, err := proxy.Spanner().CheckSchema("", "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 14, len(issues))
+	assert.Equal(t, "test2", issues[13].Database)
+	for _, issue := range issues {
+		assert.Equal(t, "", issue.Repair)
+	}
+
+	issues, err = proxy.Spanner().CheckSchema("test", "g", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(issues))
+	assert.Equal(t, "frm", issues[0].Type)
+	assert.Equal(t, "frm.file.missing", issues[0].Detail)
+}
+
+func TestProxySchemaCheckPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+
+	client, err := driver.NewConn("mock", "mock", proxy.Address(), "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("radon check table test.t1", -1)
+	want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+	assert.Equal(t, want, err.Error())
+}
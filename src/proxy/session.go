@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"backend"
+	"xcontext"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
@@ -23,7 +24,9 @@ type bitmask uint32
 
 // session variables capabilities.
 const (
-	cap_streaming_fetch bitmask = 1 << iota // streaming fetch for this session
+	cap_streaming_fetch    bitmask = 1 << iota // streaming fetch for this session
+	cap_autocommit_off                         // autocommit=0 for this session
+	cap_streaming_snapshot                     // streaming fetch in the consistent snapshot for this session
 )
 
 type session struct {
@@ -35,6 +38,20 @@ type session struct {
 	timestamp    int64
 	capabilities bitmask
 	transaction  backend.Transaction
+
+	// The xid and the query tuples of the current statement, used by the audit and the query digests.
+	queryXID    string
+	queryTuples []xcontext.QueryTuple
+
+	// The profile of the current statement, only set if the slow log is enabled.
+	profile *xcontext.Profile
+
+	// The session variables(name->value in sql) replayed on the backend connections.
+	vars map[string]string
+
+	// The transaction characteristics of the session, and the ones only for the next transaction.
+	txnChars     backend.TxnCharacteristics
+	nextTxnChars *backend.TxnCharacteristics
 }
 
 func (s *session) setStreamingFetchVar(r bool) {
@@ -49,6 +66,120 @@ func (s *session) getStreamingFetchVar() bool {
 	return s.capabilities&cap_streaming_fetch != 0
 }
 
+func (s *session) setStreamingSnapshotVar(r bool) {
+	if r {
+		s.capabilities |= cap_streaming_snapshot
+	} else {
+		s.capabilities &= ^cap_streaming_snapshot
+	}
+}
+
+func (s *session) getStreamingSnapshotVar() bool {
+	return s.capabilities&cap_streaming_snapshot != 0
+}
+
+func (s *session) setAutocommitVar(r bool) {
+	if r {
+		s.capabilities &= ^cap_autocommit_off
+	} else {
+		s.capabilities |= cap_autocommit_off
+	}
+}
+
+func (s *session) getAutocommitVar() bool {
+	return s.capabilities&cap_autocommit_off == 0
+}
+
+// setSessionVar used to set the session variable, the empty value means to reset it to the default.
+func (s *session) setSessionVar(name string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vars = applySessionVar(s.vars, name, value)
+}
+
+// applySessionVar used to set the variable in the vars, the empty value means to reset it to the default.
+func applySessionVar(vars map[string]string, name string, value string) map[string]string {
+	// The charset is overwritten by the names and vice versa.
+	switch name {
+	case "names":
+		delete(vars, "charset")
+	case "charset":
+		delete(vars, "names")
+	}
+	if value == "" {
+		delete(vars, name)
+		return vars
+	}
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	vars[name] = value
+	return vars
+}
+
+// sessionVars returns a copy of the session variables, the caller must hold the lock.
+func (s *session) sessionVars() map[string]string {
+	if len(s.vars) == 0 {
+		return nil
+	}
+	vars := make(map[string]string, len(s.vars))
+	for name, value := range s.vars {
+		vars[name] = value
+	}
+	return vars
+}
+
+// setTxnIsolation used to set the isolation level, the empty level means the default.
+// If next is true, it only works for the next transaction as SET TRANSACTION without scope.
+func (s *session) setTxnIsolation(level string, next bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txnCharsFor(next).Isolation = level
+}
+
+// setTxnReadOnly used to set the access mode of the transaction.
+func (s *session) setTxnReadOnly(readOnly bool, next bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txnCharsFor(next).ReadOnly = readOnly
+}
+
+// txnCharsFor returns the characteristics to change, the caller must hold the lock.
+func (s *session) txnCharsFor(next bool) *backend.TxnCharacteristics {
+	if !next {
+		return &s.txnChars
+	}
+	if s.nextTxnChars == nil {
+		chars := s.txnChars
+		s.nextTxnChars = &chars
+	}
+	return s.nextTxnChars
+}
+
+// takeTxnCharacteristics returns the characteristics of the transaction to start, the ones for the next
+// transaction are consumed, and the START TRANSACTION characteristics override the access mode.
+func (s *session) takeTxnCharacteristics(startChars []string) backend.TxnCharacteristics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chars := s.txnChars
+	if s.nextTxnChars != nil {
+		chars = *s.nextTxnChars
+		s.nextTxnChars = nil
+	}
+	for _, char := range startChars {
+		switch char {
+		case sqlparser.ReadOnlyStr:
+			chars.ReadOnly = true
+		case sqlparser.ReadWriteStr:
+			chars.ReadOnly = false
+		case sqlparser.WithConsistentSnapshotStr:
+			chars.ConsistentSnapshot = true
+		}
+	}
+	return chars
+}
+
 func newSession(log *xlog.Log, s *driver.Session) *session {
 	log.Debug("session[%v].created", s.ID())
 	return &session{
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"backend"
+	"xcontext"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
@@ -87,6 +88,34 @@ func (ss *Sessions) Reaches(quota int) bool {
 	return (len(ss.sessions) >= quota)
 }
 
+// UserCount returns the number of the sessions of the user, except the session id.
+func (ss *Sessions) UserCount(user string, except uint32) int {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	count := 0
+	for id, v := range ss.sessions {
+		if id != except && v.session.User() == user {
+			count++
+		}
+	}
+	return count
+}
+
+// DatabaseCount returns the number of the sessions using the database, except the session id.
+func (ss *Sessions) DatabaseCount(database string, except uint32) int {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	count := 0
+	for id, v := range ss.sessions {
+		if id != except && v.session.Schema() == database {
+			count++
+		}
+	}
+	return count
+}
+
 // getTxnSession used to get current connection session.
 func (ss *Sessions) getTxnSession(session *driver.Session) *session {
 	ss.mu.RLock()
@@ -120,9 +149,12 @@ func (ss *Sessions) TxnBinding(s *driver.Session, txn backend.Transaction, node
 	}
 	session.query = q
 	session.node = node
+	session.queryXID = ""
+	session.queryTuples = nil
 
 	// Bind sid to txn.
 	txn.SetSessionID(s.ID())
+	txn.SetSessionVars(session.sessionVars())
 	session.transaction = txn
 	session.timestamp = time.Now().Unix()
 }
@@ -141,6 +173,10 @@ func (ss *Sessions) TxnUnBinding(s *driver.Session) {
 	defer session.mu.Unlock()
 	session.node = nil
 	session.query = ""
+	if session.transaction != nil {
+		session.queryXID = session.transaction.XID()
+		session.queryTuples = session.transaction.PopQueryTuples()
+	}
 	session.transaction = nil
 	session.timestamp = time.Now().Unix()
 }
@@ -164,12 +200,18 @@ func (ss *Sessions) MultiStmtTxnBinding(s *driver.Session, txn backend.Transacti
 	}
 	session.query = q
 	session.node = node
+	session.queryXID = ""
+	session.queryTuples = nil
 	// txn should not be nil when "begin" or "start transaction" is executed, to be set just once during the trans.
 	if txn != nil {
 		// Bind sid to txn.
 		txn.SetSessionID(s.ID())
 		session.transaction = txn
 	}
+	// The session variables may be changed in the transaction.
+	if session.transaction != nil {
+		session.transaction.SetSessionVars(session.sessionVars())
+	}
 	session.timestamp = time.Now().Unix()
 }
 
@@ -187,6 +229,10 @@ func (ss *Sessions) MultiStmtTxnUnBinding(s *driver.Session, isEnd bool) {
 	defer session.mu.Unlock()
 	session.node = nil
 	session.query = ""
+	if session.transaction != nil {
+		session.queryXID = session.transaction.XID()
+		session.queryTuples = session.transaction.PopQueryTuples()
+	}
 	// If multiple-statement transaction is end or some errors happen, set transaction to be nil
 	if isEnd {
 		session.transaction = nil
@@ -194,6 +240,86 @@ func (ss *Sessions) MultiStmtTxnUnBinding(s *driver.Session, isEnd bool) {
 	session.timestamp = time.Now().Unix()
 }
 
+// QueryInfo returns the xid and the query tuples of the current statement.
+func (ss *Sessions) QueryInfo(s *driver.Session) (string, []xcontext.QueryTuple) {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	ss.mu.RUnlock()
+	if !ok {
+		return "", nil
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.queryXID, session.queryTuples
+}
+
+// ResetQueryInfo used to clear the query info when the statement is done, or it's leaked to the next statement.
+func (ss *Sessions) ResetQueryInfo(s *driver.Session) {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	ss.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.queryXID = ""
+	session.queryTuples = nil
+}
+
+func (ss *Sessions) setProfile(s *driver.Session, profile *xcontext.Profile) {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	ss.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.profile = profile
+}
+
+func (ss *Sessions) getProfile(s *driver.Session) *xcontext.Profile {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	ss.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.profile
+}
+
+// takeTxnCharacteristics returns the characteristics of the transaction to start on the session.
+func (ss *Sessions) takeTxnCharacteristics(s *driver.Session, startChars []string) backend.TxnCharacteristics {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	ss.mu.RUnlock()
+	if !ok {
+		return backend.TxnCharacteristics{}
+	}
+	return session.takeTxnCharacteristics(startChars)
+}
+
+// getSessionVars returns a copy of the session variables.
+func (ss *Sessions) getSessionVars(s *driver.Session) map[string]string {
+	ss.mu.RLock()
+	session, ok := ss.sessions[s.ID()]
+	ss.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.sessionVars()
+}
+
 // Close used to close all sessions.
 func (ss *Sessions) Close() {
 	i := 0
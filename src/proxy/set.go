@@ -13,24 +13,195 @@ import (
 	"strings"
 
 	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
 const (
-	var_mysql_autocommit      = "autocommit"
-	var_radon_streaming_fetch = "radon_streaming_fetch"
+	var_mysql_autocommit         = "autocommit"
+	var_radon_streaming_fetch    = "radon_streaming_fetch"
+	var_radon_streaming_snapshot = "radon_streaming_snapshot"
+
+	// The session variables replayed on the backend connections.
+	var_mysql_sql_mode                 = "sql_mode"
+	var_mysql_time_zone                = "time_zone"
+	var_mysql_names                    = "names"
+	var_mysql_charset                  = "charset"
+	var_mysql_character_set_client     = "character_set_client"
+	var_mysql_character_set_connection = "character_set_connection"
+	var_mysql_character_set_results    = "character_set_results"
+	var_mysql_collation_connection     = "collation_connection"
+
+	// The transaction characteristics applied to the backend branches when the txn starts.
+	var_mysql_transaction_isolation = "transaction_isolation"
+	var_mysql_tx_isolation          = "tx_isolation"
+	var_mysql_transaction_read_only = "transaction_read_only"
+	var_mysql_tx_read_only          = "tx_read_only"
 )
 
+// sessionVarNames is the session variables replayed on the backend connections.
+var sessionVarNames = map[string]bool{
+	var_mysql_sql_mode:                 true,
+	var_mysql_time_zone:                true,
+	var_mysql_names:                    true,
+	var_mysql_charset:                  true,
+	var_mysql_character_set_client:     true,
+	var_mysql_character_set_connection: true,
+	var_mysql_character_set_results:    true,
+	var_mysql_collation_connection:     true,
+}
+
+// isolationLevelValue returns the isolation level of the value such as 'READ-COMMITTED', the empty level means the default.
+func isolationLevelValue(name string, expr sqlparser.Expr) (string, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.Default:
+		return "", nil
+	case *sqlparser.SQLVal:
+		if expr.Type == sqlparser.StrVal {
+			level := strings.ToLower(strings.Replace(string(expr.Val), "-", " ", -1))
+			switch level {
+			case sqlparser.RepeatableReadStr, sqlparser.ReadCommittedStr, sqlparser.ReadUncommittedStr, sqlparser.SerializableStr:
+				return level, nil
+			}
+		}
+	}
+	return "", sqldb.NewSQLError1(1231, "42000", "Variable '%s' can't be set to the value of '%s'", name, sqlparser.String(expr))
+}
+
+// boolVarValue returns the boolean of the value such as 1, 'ON' and OFF.
+func boolVarValue(name string, expr sqlparser.Expr) (bool, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.Default:
+		return false, nil
+	case sqlparser.BoolVal:
+		return bool(expr), nil
+	case *sqlparser.SQLVal:
+		switch strings.ToLower(string(expr.Val)) {
+		case "1", "on":
+			return true, nil
+		case "0", "off":
+			return false, nil
+		}
+	case *sqlparser.ColName:
+		switch expr.Name.Lowered() {
+		case "on":
+			return true, nil
+		case "off":
+			return false, nil
+		}
+	}
+	return false, sqldb.NewSQLError1(1231, "42000", "Variable '%s' can't be set to the value of '%s'", name, sqlparser.String(expr))
+}
+
+// handleSetTransaction used to handle the SET [SESSION] TRANSACTION, the characteristics are applied to
+// the backend branches when the transaction starts.
+func (spanner *Spanner) handleSetTransaction(session *driver.Session, query string, node *sqlparser.Set) (*sqltypes.Result, error) {
+	log := spanner.log
+	txSession := spanner.sessions.getTxnSession(session)
+
+	switch node.Scope {
+	case sqlparser.GlobalStr:
+		log.Warning("unhandle.set.global.transaction:%v", query)
+		return &sqltypes.Result{Warnings: 1}, nil
+	case "":
+		// Without scope, the characteristics only work for the next transaction.
+		if txSession.transaction != nil {
+			return nil, sqldb.NewSQLError1(1568, "25001", "Transaction characteristics can't be changed while a transaction is in progress")
+		}
+	}
+
+	next := node.Scope == ""
+	for _, expr := range node.Exprs {
+		chars := string(expr.Expr.(*sqlparser.SQLVal).Val)
+		switch chars {
+		case sqlparser.ReadOnlyStr:
+			txSession.setTxnReadOnly(true, next)
+		case sqlparser.ReadWriteStr:
+			txSession.setTxnReadOnly(false, next)
+		default:
+			txSession.setTxnIsolation(chars, next)
+		}
+	}
+	return &sqltypes.Result{}, nil
+}
+
+// sessionVarValue returns the value in sql of the literal expr, the empty value means the default.
+func sessionVarValue(expr sqlparser.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *sqlparser.SQLVal:
+		return sqlparser.String(expr), true
+	case *sqlparser.NullVal:
+		return "NULL", true
+	case *sqlparser.Default:
+		return "", true
+	case *sqlparser.ColName:
+		// SET sql_mode = TRADITIONAL.
+		if expr.Qualifier.IsEmpty() {
+			return sqlparser.String(sqlparser.NewStrVal([]byte(expr.Name.String()))), true
+		}
+	}
+	return "", false
+}
+
+// setVarName returns the name of the variable without the scope prefix, and whether the @@name without scope
+// of the transaction characteristics only works for the next transaction.
+func setVarName(expr *sqlparser.SetExpr, scope string) (string, bool) {
+	name := expr.Name.Lowered()
+	for _, prefix := range []string{"@@session.", "@@local.", "@@"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix), (prefix == "@@" && scope == "")
+		}
+	}
+	return name, false
+}
+
+// checkSessionVars used to check the replayed session variables of the SET on a backend before any of them is set,
+// the invalid value such as an unknown time zone fails the SET as a whole rather than the later queries.
+func (spanner *Spanner) checkSessionVars(session *driver.Session, node *sqlparser.Set) error {
+	vars := spanner.sessions.getSessionVars(session)
+	changed := false
+	for _, expr := range node.Exprs {
+		name, _ := setVarName(expr, node.Scope)
+		if !sessionVarNames[name] {
+			continue
+		}
+		if value, ok := sessionVarValue(expr.Expr); ok {
+			vars = applySessionVar(vars, name, value)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return spanner.scatter.CheckSessionVars(vars)
+}
+
 // handleSet used to handle the SET command.
 func (spanner *Spanner) handleSet(session *driver.Session, query string, node *sqlparser.Set) (*sqltypes.Result, error) {
 	log := spanner.log
 	txSession := spanner.sessions.getTxnSession(session)
 
+	if node.IsTransaction() {
+		return spanner.handleSetTransaction(session, query, node)
+	}
+
+	if err := spanner.checkSessionVars(session, node); err != nil {
+		log.Error("proxy.set[%s].from.session[%v].check.error:%v", query, session.ID(), err)
+		return nil, err
+	}
+
 	for _, expr := range node.Exprs {
-		name := expr.Name.Lowered()
-		if strings.HasPrefix(name, "@@session.") {
-			name = strings.TrimPrefix(name, "@@session.")
+		name, next := setVarName(expr, node.Scope)
+		if sessionVarNames[name] {
+			// The non-literal value is not replayed, such as CONCAT(@@sql_mode, ...).
+			value, ok := sessionVarValue(expr.Expr)
+			if !ok {
+				log.Warning("unhandle.set[%v].value:%v", name, query)
+				continue
+			}
+			txSession.setSessionVar(name, value)
+			continue
 		}
 
 		switch name {
@@ -57,6 +228,13 @@ func (spanner *Spanner) handleSet(session *driver.Session, query string, node *s
 				}
 			}
 
+		case var_radon_streaming_snapshot:
+			snapshot, err := boolVarValue(name, expr.Expr)
+			if err != nil {
+				return nil, err
+			}
+			txSession.setStreamingSnapshotVar(snapshot)
+
 		case var_mysql_autocommit:
 			var autocommit = true
 
@@ -67,20 +245,55 @@ func (spanner *Spanner) handleSet(session *driver.Session, query string, node *s
 					if expr.Val[0] == '0' {
 						autocommit = false
 					}
+				case sqlparser.StrVal:
+					if strings.ToLower(string(expr.Val)) == "off" {
+						autocommit = false
+					}
+				}
+			case sqlparser.BoolVal:
+				autocommit = bool(expr)
+			case *sqlparser.ColName:
+				if expr.Name.Lowered() == "off" {
+					autocommit = false
 				}
 			}
-			if !autocommit && spanner.isAutocommitFalseIsTxn() {
-				query := "begin"
-				node := &sqlparser.Transaction{
-					Action: "begin",
+
+			if autocommit {
+				// Changing autocommit from 0 to 1 commits the active transaction as mysql.
+				if !txSession.getAutocommitVar() {
+					if err := spanner.implicitCommit(session, query, node); err != nil {
+						log.Error("proxy.transaction[%s](by.autocommit).from.session[%v].error:%+v", query, session.ID(), err)
+						return nil, err
+					}
 				}
-				qr, err := spanner.handleMultiStmtTxn(session, query, node)
-				if err != nil {
-					log.Error("proxy.transaction[%s](by.autocommit).from.session[%v].error:%+v", query, session.ID(), err)
-					return nil, err
+				txSession.setAutocommitVar(true)
+			} else {
+				txSession.setAutocommitVar(false)
+				// The transaction is started by the next statement implicitly,
+				// autocommit-false-is-txn starts it right now.
+				if spanner.isAutocommitFalseIsTxn() && txSession.transaction == nil {
+					query := "begin"
+					node := &sqlparser.Transaction{
+						Action: "begin",
+					}
+					if _, err := spanner.handleMultiStmtTxn(session, query, node); err != nil {
+						log.Error("proxy.transaction[%s](by.autocommit).from.session[%v].error:%+v", query, session.ID(), err)
+						return nil, err
+					}
 				}
-				return qr, nil
 			}
+		case var_mysql_transaction_isolation, var_mysql_tx_isolation:
+			level, err := isolationLevelValue(name, expr.Expr)
+			if err != nil {
+				return nil, err
+			}
+			txSession.setTxnIsolation(level, next)
+		case var_mysql_transaction_read_only, var_mysql_tx_read_only:
+			readOnly, err := boolVarValue(name, expr.Expr)
+			if err != nil {
+				return nil, err
+			}
+			txSession.setTxnReadOnly(readOnly, next)
 		default:
 			log.Warning("unhandle.set[%v]:%v", name, query)
 		}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -117,7 +118,7 @@ func TestProxySetAutocommit(t *testing.T) {
 
 			query = "commit"
 			_, err = client.FetchAll(query, -1)
-			assert.NotNil(t, err)
+			assert.Nil(t, err)
 		}
 	}
 
@@ -136,7 +137,7 @@ func TestProxySetAutocommit(t *testing.T) {
 
 			query = "commit"
 			_, err = client.FetchAll(query, -1)
-			assert.NotNil(t, err)
+			assert.Nil(t, err)
 		}
 		{
 			query := "set autocommit=1"
@@ -144,8 +145,242 @@ func TestProxySetAutocommit(t *testing.T) {
 			assert.Nil(t, err)
 
 			query = "commit"
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestProxySetSessionVars(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	set := "SET NAMES 'utf8mb4', @@SESSION.sql_mode = 'TRADITIONAL', @@SESSION.time_zone = '+00:00'"
+	reset := "SET NAMES 'utf8', @@SESSION.sql_mode = DEFAULT, @@SESSION.time_zone = DEFAULT"
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQuery(set, &sqltypes.Result{})
+		fakedbs.AddQuery(reset, &sqltypes.Result{})
+		// The variables are checked on a backend by the SET.
+		fakedbs.AddQueryPattern("set .*", &sqltypes.Result{})
+		fakedbs.AddQueryErrorPattern("set .*time_zone = 'bogus'.*", sqldb.NewSQLError1(1298, "HY000", "Unknown or incorrect time zone: 'bogus'"))
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+	}
+
+	// The session variables are replayed on the backend connections.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		querys := []string{
+			"set @@time_zone='+00:00'",
+			"set names utf8mb4",
+			"set session sql_mode=TRADITIONAL",
+			// The non-literal value is ignored.
+			"set sql_mode=concat(@@sql_mode, ',ANSI')",
+			"set wait_timeout=100",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+
+		_, err = client.FetchAll("select * from test.t1", -1)
+		assert.Nil(t, err)
+		sets := fakedbs.GetQueryCalledNum(set)
+		assert.True(t, sets > 0)
+
+		// Cached by the connections.
+		_, err = client.FetchAll("select * from test.t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, sets, fakedbs.GetQueryCalledNum(set))
+	}
+
+	// The connections are reset for the session without the variables.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = client.FetchAll("select * from test.t1", -1)
+		assert.Nil(t, err)
+		assert.True(t, fakedbs.GetQueryCalledNum(reset) > 0)
+	}
+
+	// Set to default.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		querys := []string{
+			"set time_zone='+00:00'",
+			"set time_zone=default",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		txSession := proxy.sessions.getSession(client.ConnectionID())
+		assert.Equal(t, 0, len(txSession.vars))
+	}
+
+	// The invalid value fails the SET as a whole and is not stored.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = client.FetchAll("set sql_mode='ANSI', time_zone='bogus'", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "Unknown or incorrect time zone: 'bogus' (errno 1298) (sqlstate HY000)", err.Error())
+		txSession := proxy.sessions.getSession(client.ConnectionID())
+		assert.Equal(t, 0, len(txSession.vars))
+
+		_, err = client.FetchAll("select * from test.t1", -1)
+		assert.Nil(t, err)
+	}
+}
+
+func TestProxySetTransaction(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	proxy.conf.Proxy.TwopcEnable = true
+
+	readCommitted := "SET TRANSACTION ISOLATION LEVEL READ COMMITTED"
+	serializable := "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE"
+	readOnly := "SET TRANSACTION ISOLATION LEVEL READ COMMITTED, READ ONLY"
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("XA .*", &sqltypes.Result{})
+		fakedbs.AddQuery(readCommitted, &sqltypes.Result{})
+		fakedbs.AddQuery(serializable, &sqltypes.Result{})
+		fakedbs.AddQuery(readOnly, &sqltypes.Result{})
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+	}
+
+	// The session isolation level is applied to the branches.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = client.FetchAll("set session transaction isolation level read committed", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into test.t1(id, b) values(1, 2), (3, 4)", -1)
+		assert.Nil(t, err)
+		n := fakedbs.GetQueryCalledNum(readCommitted)
+		assert.True(t, n > 0)
+
+		_, err = client.FetchAll("begin", -1)
+		assert.Nil(t, err)
+		assert.True(t, fakedbs.GetQueryCalledNum(readCommitted) > n)
+
+		// The characteristics can't be changed in the transaction.
+		_, err = client.FetchAll("set transaction isolation level serializable", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("commit", -1)
+		assert.Nil(t, err)
+	}
+
+	// The characteristics without scope only work for the next transaction.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = client.FetchAll("set transaction isolation level serializable", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into test.t1(id, b) values(1, 2), (3, 4)", -1)
+		assert.Nil(t, err)
+		n := fakedbs.GetQueryCalledNum(serializable)
+		assert.True(t, n > 0)
+
+		_, err = client.FetchAll("insert into test.t1(id, b) values(1, 2), (3, 4)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, n, fakedbs.GetQueryCalledNum(serializable))
+	}
+
+	// The READ ONLY transaction refuses the writes.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = client.FetchAll("set @@session.tx_isolation = 'READ-COMMITTED'", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("start transaction read only", -1)
+		assert.Nil(t, err)
+		assert.True(t, fakedbs.GetQueryCalledNum(readOnly) > 0)
+		_, err = client.FetchAll("select * from test.t1", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into test.t1(id, b) values(1, 2), (3, 4)", -1)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "errno 1792")
+		_, err = client.FetchAll("rollback", -1)
+		assert.Nil(t, err)
+
+		_, err = client.FetchAll("set transaction_read_only = 1", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into test.t1(id, b) values(1, 2), (3, 4)", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("set transaction_read_only = 0", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into test.t1(id, b) values(1, 2), (3, 4)", -1)
+		assert.Nil(t, err)
+
+		txSession := proxy.sessions.getSession(client.ConnectionID())
+		assert.Equal(t, "read committed", txSession.txnChars.Isolation)
+		assert.False(t, txSession.txnChars.ReadOnly)
+	}
+
+	// The invalid values.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		querys := []string{
+			"set tx_isolation = 'READ-SOMETHING'",
+			"set transaction_isolation = 1",
+			"set tx_read_only = 'maybe'",
+		}
+		for _, query := range querys {
 			_, err = client.FetchAll(query, -1)
 			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), "errno 1231")
 		}
 	}
 }
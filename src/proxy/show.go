@@ -544,6 +544,23 @@ func (spanner *Spanner) handleShowStatus(session *driver.Session, query string,
 		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(backendsJSON)),
 	})
 
+	// 6. radon_plancache row.
+	varname = "radon_plancache"
+	planCache := spanner.planCache
+	qr.Rows = append(qr.Rows, []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(varname)),
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf(`{"size":%d, "capacity":%d, "hits":%d, "misses":%d}`,
+			planCache.Len(), planCache.Capacity(), planCache.Hits(), planCache.Misses()))),
+	})
+
+	// 7. radon_stmtcache row.
+	varname = "radon_stmtcache"
+	stmtCache := spanner.stmtCache
+	qr.Rows = append(qr.Rows, []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(varname)),
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf(`{"size":%d, "capacity":%d, "hits":%d, "misses":%d}`,
+			stmtCache.Len(), stmtCache.Capacity(), stmtCache.Hits(), stmtCache.Misses()))),
+	})
 	return qr, nil
 }
 
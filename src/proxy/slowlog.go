@@ -0,0 +1,56 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"time"
+
+	"slowlog"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// slowQueryLog used to write the query to the slow log if it exceeds the long-query-time.
+// The profile has the stats of the shard querys, it's nil if the slow log is enabled after the query starts.
+func (spanner *Spanner) slowQueryLog(session *driver.Session, query string, profile *xcontext.Profile, timeStart time.Time, slowQueryTime time.Duration, qr *sqltypes.Result, err error) {
+	if !spanner.slowLog.Enabled() {
+		return
+	}
+
+	cost := time.Since(timeStart)
+	if cost <= slowQueryTime {
+		return
+	}
+	e := &slowlog.Event{
+		Start:    timeStart,
+		Cost:     cost,
+		User:     session.User(),
+		Host:     session.Addr(),
+		ThreadID: session.ID(),
+		DB:       session.Schema(),
+		Query:    query,
+	}
+	if qr != nil {
+		e.RowsSent = uint64(len(qr.Rows))
+		e.RowsAffected = qr.RowsAffected
+	}
+	if err != nil {
+		e.Errno = sqldb.ER_UNKNOWN_ERROR
+		if sqlErr, ok := err.(*sqldb.SQLError); ok {
+			e.Errno = sqlErr.Num
+		}
+	}
+	if profile != nil {
+		e.Shards = profile.Querys()
+	}
+	spanner.slowLog.LogEvent(e)
+}
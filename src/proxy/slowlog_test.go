@@ -0,0 +1,94 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"config"
+	"fakedb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxySlowLog(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+
+	conf := MockDefaultConfig()
+	conf.Proxy.LongQueryTime = 0
+	conf.SlowLog = &config.SlowLogConfig{
+		Enable:  true,
+		LogDir:  tmpDir,
+		MaxSize: 1024 * 1024,
+	}
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryErrorPattern("delete .*", errors.New("mock.delete.error"))
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"use test",
+		"insert into t1(id, b) values(1,2),(3,4)",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	_, err = client.FetchAll("delete from t1 where id=1", -1)
+	assert.NotNil(t, err)
+
+	got := string(proxy.SlowLog().Recent(0))
+	entries := strings.Split(got, "# Time: ")[1:]
+	assert.Equal(t, 5, len(entries))
+
+	// Insert.
+	{
+		entry := entries[3]
+		assert.True(t, strings.Contains(entry, "# User@Host: mock[mock] @  [127.0.0.1]"), entry)
+		assert.True(t, strings.Contains(entry, "Last_errno: 0\n"), entry)
+		assert.True(t, strings.Contains(entry, "Shard_query: insert into test.t1_"), entry)
+		assert.True(t, strings.Contains(entry, "use test;\nSET timestamp="), entry)
+		assert.True(t, strings.HasSuffix(entry, ";\ninsert into t1(id, b) values(1,2),(3,4);\n"), entry)
+	}
+
+	// Delete with error.
+	{
+		entry := entries[4]
+		assert.True(t, strings.Contains(entry, "Last_errno: 1105\n"), entry)
+		assert.True(t, strings.Contains(entry, "# Shard_error: mock.delete.error"), entry)
+	}
+
+	// Disabled.
+	{
+		conf.SlowLog.Enable = false
+		_, err = client.FetchAll("insert into t1(id, b) values(5,6)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, got, string(proxy.SlowLog().Recent(0)))
+	}
+}
@@ -14,10 +14,13 @@ import (
 	"config"
 	"monitor"
 	"plugins"
+	"quota"
 	"router"
+	"slowlog"
 	"sync"
 	"xbase"
 	"xbase/sync2"
+	"xtrace"
 
 	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -27,15 +30,23 @@ import (
 type Spanner struct {
 	log           *xlog.Log
 	audit         *audit.Audit
+	slowLog       *slowlog.SlowLog
+	tracer        *xtrace.Tracer
 	conf          *config.Config
 	router        *router.Router
 	scatter       *backend.Scatter
 	sessions      *Sessions
 	iptable       *IPTable
 	throttle      *xbase.Throttle
+	quota         *quota.Quota
 	plugins       *plugins.Plugin
 	diskChecker   *DiskCheck
 	manager       *Manager
+	planCache     *xbase.LRUCache
+	stmtCache     *xbase.LRUCache
+	digests       *Digests
+	onlineDDL     *OnlineDDL
+	ddlJobs       *DDLJobs
 	readonly      sync2.AtomicBool
 	mu            sync.RWMutex
 	serverVersion string
@@ -43,19 +54,28 @@ type Spanner struct {
 
 // NewSpanner creates a new spanner.
 func NewSpanner(log *xlog.Log, conf *config.Config,
-	iptable *IPTable, router *router.Router, scatter *backend.Scatter, sessions *Sessions, audit *audit.Audit, throttle *xbase.Throttle, plugins *plugins.Plugin, serverVersion string) *Spanner {
-	return &Spanner{
+	iptable *IPTable, router *router.Router, scatter *backend.Scatter, sessions *Sessions, audit *audit.Audit, slowLog *slowlog.SlowLog, tracer *xtrace.Tracer, throttle *xbase.Throttle, quota *quota.Quota, plugins *plugins.Plugin, serverVersion string) *Spanner {
+	spanner := &Spanner{
 		log:           log,
 		conf:          conf,
 		audit:         audit,
+		slowLog:       slowLog,
+		tracer:        tracer,
 		iptable:       iptable,
 		router:        router,
 		scatter:       scatter,
 		sessions:      sessions,
 		throttle:      throttle,
+		quota:         quota,
 		plugins:       plugins,
+		planCache:     xbase.NewLRUCache(conf.Proxy.PlanCacheSize),
+		stmtCache:     xbase.NewLRUCache(conf.Proxy.StmtCacheSize),
+		digests:       NewDigests(conf.Proxy.QueryDigestSize),
 		serverVersion: serverVersion,
 	}
+	spanner.onlineDDL = NewOnlineDDL(log, spanner)
+	spanner.ddlJobs = NewDDLJobs(log, spanner)
+	return spanner
 }
 
 // Init used to init the async worker.
@@ -74,11 +94,19 @@ func (spanner *Spanner) Init() error {
 		return err
 	}
 	spanner.manager = mgr
+
+	if err := spanner.ddlJobs.Init(); err != nil {
+		return err
+	}
+	if err := spanner.onlineDDL.Init(); err != nil {
+		return err
+	}
 	return nil
 }
 
 // Close used to close spanner.
 func (spanner *Spanner) Close() error {
+	spanner.onlineDDL.Close()
 	spanner.diskChecker.Close()
 	spanner.manager.Close()
 	spanner.log.Info("spanner.closed...")
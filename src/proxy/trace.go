@@ -0,0 +1,51 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"time"
+
+	"xtrace"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// startTrace starts the span of the query, the trace context is passed by the comment such as:
+// /*traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ select * from t1
+// Returns nil if the trace is disabled or the query isn't sampled.
+func (spanner *Spanner) startTrace(session *driver.Session, query string, start time.Time) *xtrace.Span {
+	if !spanner.tracer.Enabled() {
+		return nil
+	}
+	parent, _ := xtrace.ExtractTraceparent(query)
+	span := spanner.tracer.StartAt("query", parent, start)
+	if span == nil {
+		return nil
+	}
+	span.SetAttribute("db.system", "mysql")
+	span.SetAttribute("db.user", session.User())
+	span.SetAttribute("db.name", session.Schema())
+	span.SetAttribute("db.statement", query)
+	span.SetAttribute("radon.session_id", int64(session.ID()))
+	return span
+}
+
+// endTrace ends the span of the query with the result.
+func endTrace(span *xtrace.Span, qr *sqltypes.Result, err error) {
+	if span == nil {
+		return
+	}
+	if qr != nil {
+		span.SetAttribute("radon.rows", len(qr.Rows))
+		span.SetAttribute("radon.rows_affected", qr.RowsAffected)
+	}
+	span.SetError(err)
+	span.End()
+}
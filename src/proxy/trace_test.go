@@ -0,0 +1,152 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	"fakedb"
+	"xtrace"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+type traceSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Status       struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+// traceSpans returns the spans group by the trace id.
+func traceSpans(t *testing.T, file string) map[string][]traceSpan {
+	f, err := os.Open(file)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	traces := make(map[string][]traceSpan)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		req := struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []traceSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &req))
+		for _, span := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+			traces[span.TraceID] = append(traces[span.TraceID], span)
+		}
+	}
+	return traces
+}
+
+func spanNames(spans []traceSpan) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestProxyTrace(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_trace_", log)
+	defer os.RemoveAll(tmpDir)
+
+	conf := MockDefaultConfig()
+	conf.Proxy.TwopcEnable = true
+	conf.Trace.Enable = true
+	conf.Trace.Exporter = xtrace.ExporterFile
+	conf.Trace.Endpoint = path.Join(tmpDir, "spans.json")
+	fakedbs, proxy, cleanup := MockProxy1(log, conf)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("XA .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"/*traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ insert into test.t1(id, b) values(1,2)",
+		"/*traceparent=00-1af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ select * from test.t1 order by id limit 1",
+		"/*traceparent=00-2af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00*/ select * from test.t1",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	_, err = client.FetchAll("/*traceparent=00-3af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ selectx 1", -1)
+	assert.NotNil(t, err)
+	proxy.Tracer().Close()
+
+	traces := traceSpans(t, conf.Trace.Endpoint)
+	assert.Equal(t, 3, len(traces))
+
+	// Insert with 2pc.
+	{
+		spans := traces["0af7651916cd43dd8448eb211c80319c"]
+		want := []string{"backend.query", "parse", "plan", "query", "xa.commit", "xa.end", "xa.prepare", "xa.start"}
+		assert.Equal(t, want, spanNames(spans))
+		root := spans[len(spans)-1]
+		assert.Equal(t, "query", root.Name)
+		assert.Equal(t, "b7ad6b7169203331", root.ParentSpanID)
+		for _, span := range spans[:len(spans)-1] {
+			assert.Equal(t, root.SpanID, span.ParentSpanID, span.Name)
+		}
+	}
+
+	// Select with the operators.
+	{
+		spans := traces["1af7651916cd43dd8448eb211c80319c"]
+		names := spanNames(spans)
+		assert.Contains(t, names, "operator.merge")
+		assert.Contains(t, names, "operator.limit")
+		assert.Equal(t, "backend.query", names[0])
+	}
+
+	// Syntax error.
+	{
+		spans := traces["3af7651916cd43dd8448eb211c80319c"]
+		assert.Equal(t, []string{"parse", "query"}, spanNames(spans))
+		for _, span := range spans {
+			assert.Equal(t, 2, span.Status.Code)
+		}
+	}
+
+	// The traceparent comment isn't from the connector.
+	{
+		spanner := proxy.Spanner()
+		assert.True(t, spanner.isConnectorFilter("/* mysql-connector-java-5.1.40 */SELECT  @@session.auto_increment_increment"))
+		assert.False(t, spanner.isConnectorFilter("/*traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ select 1"))
+	}
+}
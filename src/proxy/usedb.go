@@ -25,6 +25,11 @@ func (spanner *Spanner) handleUseDB(session *driver.Session, query string, node
 		return nil, err
 	}
 
+	// Check the max connections quota of the database.
+	if err := spanner.databaseConnectionsCheck(session, db); err != nil {
+		return nil, err
+	}
+
 	if _, err := spanner.ExecuteSingle(query); err != nil {
 		return nil, err
 	}
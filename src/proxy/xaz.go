@@ -0,0 +1,98 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	xazSourceRetry   = "retry"
+	xazSourceTimeout = "timeout"
+	xazSourceRecover = "recover"
+)
+
+// handleShowXaTransactions used to handle the query "SHOW XA TRANSACTIONS".
+// It lists the xa commit errors pending to retry, the ones retried out of times,
+// and the prepared branches returned by the XA RECOVER on every backend.
+func (spanner *Spanner) handleShowXaTransactions(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	xaz, err := spanner.scatter.Xaz()
+	if err != nil {
+		return nil, err
+	}
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Source", Type: querypb.Type_VARCHAR},
+		{Name: "Backend", Type: querypb.Type_VARCHAR},
+		{Name: "Xid", Type: querypb.Type_VARCHAR},
+		{Name: "State", Type: querypb.Type_VARCHAR},
+		{Name: "Time", Type: querypb.Type_VARCHAR},
+		{Name: "Retrys", Type: querypb.Type_INT32},
+		{Name: "Error", Type: querypb.Type_VARCHAR},
+	}
+	makeRow := func(source, backend, xid, state, time string, retrys int, err string) []sqltypes.Value {
+		return []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(source)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(backend)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(xid)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(state)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(time)),
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(fmt.Sprintf("%d", retrys))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(err)),
+		}
+	}
+
+	for _, retry := range xaz.Retrys {
+		qr.Rows = append(qr.Rows, makeRow(xazSourceRetry, "", retry.Xaid, retry.State, retry.Time, retry.Times, ""))
+	}
+	for _, timeout := range xaz.TimesOut {
+		qr.Rows = append(qr.Rows, makeRow(xazSourceTimeout, "", timeout.Xaid, timeout.State, timeout.Time, timeout.Times, ""))
+	}
+	for _, recover := range xaz.Recovers {
+		// The branch with the commit decision logged will be committed, others are rolled back by the recovery.
+		state := "prepared"
+		if recover.Committed {
+			state = "prepared(commit)"
+		}
+		if recover.Error != "" {
+			state = ""
+		}
+		qr.Rows = append(qr.Rows, makeRow(xazSourceRecover, recover.Backend, recover.Xid, state, "", 0, recover.Error))
+	}
+	return qr, nil
+}
+
+// handleRadonXa used to handle the command: radon xa commit|rollback 'xid'.
+func (spanner *Spanner) handleRadonXa(session *driver.Session, query string, node *sqlparser.Radon) (*sqltypes.Result, error) {
+	log := spanner.log
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	commit := node.Action == sqlparser.XaCommitStr
+	log.Warning("proxy.radon.xa.resolve[%s].from.session[%v].user[%s]", query, session.ID(), session.User())
+	n, err := spanner.scatter.XaResolve(node.Xid, commit)
+	if err != nil {
+		return nil, err
+	}
+	return &sqltypes.Result{RowsAffected: uint64(n)}, nil
+}
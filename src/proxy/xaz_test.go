@@ -0,0 +1,141 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var xazRecoverResult = &sqltypes.Result{
+	RowsAffected: 1,
+	Fields: []*querypb.Field{
+		{Name: "formatID", Type: querypb.Type_INT64},
+		{Name: "gtrid_length", Type: querypb.Type_INT64},
+		{Name: "bqual_length", Type: querypb.Type_INT64},
+		{Name: "data", Type: querypb.Type_VARCHAR},
+	},
+	Rows: [][]sqltypes.Value{
+		{
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("21")),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("RXID-20190101000000-1")),
+		},
+	},
+}
+
+func TestProxyShowXaTransactions(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	backends := proxy.Scatter().AllBackends()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQuery("XA RECOVER", xazRecoverResult)
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	{
+		qr, err := client.FetchAll("show xa transactions", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 7, len(qr.Fields))
+		assert.Equal(t, len(backends), len(qr.Rows))
+		row := qr.Rows[0]
+		assert.Equal(t, "recover", row[0].String())
+		assert.Equal(t, "RXID-20190101000000-1", row[2].String())
+		assert.Equal(t, "prepared", row[3].String())
+	}
+
+	// Recover error.
+	{
+		fakedbs.AddQueryError("XA RECOVER", errors.New("mock.xa.recover.error"))
+		qr, err := client.FetchAll("show xa transactions", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, len(backends), len(qr.Rows))
+		assert.Equal(t, "mock.xa.recover.error (errno 1105) (sqlstate HY000)", qr.Rows[0][6].String())
+	}
+}
+
+func TestProxyRadonXa(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	backends := proxy.Scatter().AllBackends()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQuery("XA RECOVER", xazRecoverResult)
+		fakedbs.AddQuery("XA COMMIT 'RXID-20190101000000-1'", &sqltypes.Result{})
+		fakedbs.AddQuery("XA ROLLBACK 'RXID-20190101000000-1'", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	{
+		qr, err := client.FetchAll("radon xa commit 'RXID-20190101000000-1'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(len(backends)), qr.RowsAffected)
+		assert.Equal(t, len(backends), fakedbs.GetQueryCalledNum("XA COMMIT 'RXID-20190101000000-1'"))
+	}
+
+	{
+		_, err := client.FetchAll("radon xa rollback 'RXID-20190101000000-1'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, len(backends), fakedbs.GetQueryCalledNum("XA ROLLBACK 'RXID-20190101000000-1'"))
+	}
+
+	// Not found.
+	{
+		_, err := client.FetchAll("radon xa rollback 'RXID-20190101000000-2'", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyXaPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	querys := []string{
+		"show xa transactions",
+		"radon xa commit 'RXID-20190101000000-1'",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		want := "Access denied; lacking super privilege for the operation (errno 1227) (sqlstate 42000)"
+		assert.Equal(t, want, err.Error())
+	}
+}
@@ -0,0 +1,414 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package quota
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"config"
+	"xbase/sync2"
+
+	"github.com/beefsack/go-rate"
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	quotaJSONFile = "quota.json"
+)
+
+// limiter tuple, the runtime limiter of a user or a database.
+type limiter struct {
+	conf *config.QuotaConfig
+	rate *rate.RateLimiter
+	// running is the number of the queries in running, it's kept across the config reload.
+	running *sync2.AtomicInt32
+}
+
+func (l *limiter) setConfig(conf *config.QuotaConfig) {
+	l.conf = conf
+	l.rate = nil
+	if conf != nil && conf.MaxQPS > 0 {
+		l.rate = rate.New(conf.MaxQPS, time.Second)
+	}
+}
+
+// Quota tuple.
+// The quotas of the users and the databases are stored in the metadir/quota.json,
+// so they are synced to the peers by the syncer.
+type Quota struct {
+	log       *xlog.Log
+	metadir   string
+	mu        sync.RWMutex
+	conf      *config.QuotasConfig
+	users     map[string]*limiter
+	databases map[string]*limiter
+}
+
+// NewQuota creates the new quota.
+func NewQuota(log *xlog.Log, metadir string) *Quota {
+	return &Quota{
+		log:       log,
+		metadir:   metadir,
+		conf:      newQuotasConfig(),
+		users:     make(map[string]*limiter),
+		databases: make(map[string]*limiter),
+	}
+}
+
+func newQuotasConfig() *config.QuotasConfig {
+	return &config.QuotasConfig{
+		Users:     make(map[string]*config.QuotaConfig),
+		Databases: make(map[string]*config.QuotaConfig),
+	}
+}
+
+func checkQuotaConfig(conf *config.QuotaConfig) error {
+	if conf == nil {
+		return errors.New("quota.config.cant.be.nil")
+	}
+	if conf.MaxConcurrentQueries < 0 || conf.MaxQPS < 0 || conf.MaxConnections < 0 || conf.MaxResultSize < 0 || conf.QueryTimeout < 0 {
+		return errors.Errorf("quota.config[%+v].cant.be.negative", conf)
+	}
+	return nil
+}
+
+// rebuild used to apply the conf to the limiters, the mu must be held.
+func (q *Quota) rebuild(conf *config.QuotasConfig) {
+	if conf.Users == nil {
+		conf.Users = make(map[string]*config.QuotaConfig)
+	}
+	if conf.Databases == nil {
+		conf.Databases = make(map[string]*config.QuotaConfig)
+	}
+	q.conf = conf
+
+	apply := func(limiters map[string]*limiter, confs map[string]*config.QuotaConfig) {
+		for name, l := range limiters {
+			l.setConfig(confs[name])
+			// The idle limiter whose quota removed is evicted.
+			if l.conf == nil && l.running.Get() <= 0 {
+				delete(limiters, name)
+			}
+		}
+		for name, c := range confs {
+			if _, ok := limiters[name]; !ok {
+				l := &limiter{running: &sync2.AtomicInt32{}}
+				l.setConfig(c)
+				limiters[name] = l
+			}
+		}
+	}
+	apply(q.users, conf.Users)
+	apply(q.databases, conf.Databases)
+}
+
+// LoadConfig used to load the quotas from the metadir/quota.json file.
+func (q *Quota) LoadConfig() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	log := q.log
+	file := path.Join(q.metadir, quotaJSONFile)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		q.rebuild(newQuotasConfig())
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Error("quota.load.from.file[%v].error:%v", file, err)
+		return errors.WithStack(err)
+	}
+	conf, err := config.ReadQuotasConfig(string(data))
+	if err != nil {
+		log.Error("quota.parse.json.file[%v].error:%v", file, err)
+		return err
+	}
+	q.rebuild(conf)
+	log.Info("quota.load.config:%+v", conf)
+	return nil
+}
+
+// flushConfig used to write the quotas to the metadir/quota.json file, the mu must be held.
+func (q *Quota) flushConfig() error {
+	log := q.log
+	file := path.Join(q.metadir, quotaJSONFile)
+
+	log.Warning("quota.flush.to.file[%v].conf:%+v", file, q.conf)
+	if err := config.WriteConfig(file, q.conf); err != nil {
+		log.Error("quota.flush.config.to.file[%v].error:%v", file, err)
+		return err
+	}
+	if err := config.UpdateVersion(q.metadir); err != nil {
+		log.Error("quota.flush.config.update.version.error:%v", err)
+		return err
+	}
+	return nil
+}
+
+// update used to change the config and flush it to the file.
+func (q *Quota) update(fn func(conf *config.QuotasConfig)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	conf := newQuotasConfig()
+	for k, v := range q.conf.Users {
+		conf.Users[k] = v
+	}
+	for k, v := range q.conf.Databases {
+		conf.Databases[k] = v
+	}
+	fn(conf)
+	q.rebuild(conf)
+	return q.flushConfig()
+}
+
+// SetUser used to set the quota of the user.
+func (q *Quota) SetUser(user string, conf *config.QuotaConfig) error {
+	if err := checkQuotaConfig(conf); err != nil {
+		return err
+	}
+	return q.update(func(c *config.QuotasConfig) {
+		c.Users[user] = conf
+	})
+}
+
+// RemoveUser used to remove the quota of the user.
+func (q *Quota) RemoveUser(user string) error {
+	q.mu.RLock()
+	_, ok := q.conf.Users[user]
+	q.mu.RUnlock()
+	if !ok {
+		return errors.Errorf("quota.user[%s].not.found", user)
+	}
+	return q.update(func(c *config.QuotasConfig) {
+		delete(c.Users, user)
+	})
+}
+
+// SetDatabase used to set the quota of the database.
+func (q *Quota) SetDatabase(database string, conf *config.QuotaConfig) error {
+	if err := checkQuotaConfig(conf); err != nil {
+		return err
+	}
+	return q.update(func(c *config.QuotasConfig) {
+		c.Databases[database] = conf
+	})
+}
+
+// RemoveDatabase used to remove the quota of the database.
+func (q *Quota) RemoveDatabase(database string) error {
+	q.mu.RLock()
+	_, ok := q.conf.Databases[database]
+	q.mu.RUnlock()
+	if !ok {
+		return errors.Errorf("quota.database[%s].not.found", database)
+	}
+	return q.update(func(c *config.QuotasConfig) {
+		delete(c.Databases, database)
+	})
+}
+
+// Config returns the quotas config.
+func (q *Quota) Config() *config.QuotasConfig {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	conf := newQuotasConfig()
+	for k, v := range q.conf.Users {
+		c := *v
+		conf.Users[k] = &c
+	}
+	for k, v := range q.conf.Databases {
+		c := *v
+		conf.Databases[k] = &c
+	}
+	return conf
+}
+
+// acquireLimiter returns the limiter with the running added, creates it if not exists.
+// The running is added under the mu so that the limiter can't be evicted in the meantime.
+func (q *Quota) acquireLimiter(limiters map[string]*limiter, name string) *limiter {
+	q.mu.RLock()
+	l, ok := limiters[name]
+	if ok {
+		l.running.Add(1)
+	}
+	q.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if l, ok = limiters[name]; !ok {
+		l = &limiter{running: &sync2.AtomicInt32{}}
+		limiters[name] = l
+	}
+	l.running.Add(1)
+	return l
+}
+
+// releaseLimiter used to sub the running of the limiter, the idle limiter without quota
+// is evicted so that the limiters don't grow with every user and database seen.
+func (q *Quota) releaseLimiter(limiters map[string]*limiter, name string) {
+	q.mu.RLock()
+	l, ok := limiters[name]
+	q.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if l.running.Add(-1) > 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if limiters[name] == l && l.conf == nil && l.running.Get() <= 0 {
+		delete(limiters, name)
+	}
+}
+
+// check used to check the qps and the concurrent queries of the limiter, the running
+// has been added by the acquireLimiter.
+func (q *Quota) check(l *limiter, kind string, name string) error {
+	q.mu.RLock()
+	conf, rate := l.conf, l.rate
+	q.mu.RUnlock()
+
+	if conf == nil {
+		return nil
+	}
+	if conf.MaxConcurrentQueries > 0 && int(l.running.Get()) > conf.MaxConcurrentQueries {
+		return sqldb.NewSQLErrorf(sqldb.ER_USER_LIMIT_REACHED, "%s '%s' has exceeded the 'max_concurrent_queries' resource (current value: %d)", kind, name, conf.MaxConcurrentQueries)
+	}
+	if rate != nil {
+		if ok, _ := rate.Try(); !ok {
+			return sqldb.NewSQLErrorf(sqldb.ER_USER_LIMIT_REACHED, "%s '%s' has exceeded the 'max_qps' resource (current value: %d)", kind, name, conf.MaxQPS)
+		}
+	}
+	return nil
+}
+
+// distinct returns the distinct non-empty databases.
+func distinct(databases []string) []string {
+	var dbs []string
+	seen := make(map[string]bool)
+	for _, db := range databases {
+		if db != "" && !seen[db] {
+			seen[db] = true
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs
+}
+
+// Acquire used to acquire a query quota of the user and the databases the query references.
+// If nil error returns, the Release must be called with the same args after the query is done.
+func (q *Quota) Acquire(user string, databases ...string) error {
+	ul := q.acquireLimiter(q.users, user)
+	if err := q.check(ul, "User", user); err != nil {
+		q.releaseLimiter(q.users, user)
+		return err
+	}
+
+	dbs := distinct(databases)
+	for i, db := range dbs {
+		dl := q.acquireLimiter(q.databases, db)
+		if err := q.check(dl, "Database", db); err != nil {
+			for _, acquired := range dbs[:i+1] {
+				q.releaseLimiter(q.databases, acquired)
+			}
+			q.releaseLimiter(q.users, user)
+			return err
+		}
+	}
+	return nil
+}
+
+// Release used to release the query quota acquired by the Acquire.
+func (q *Quota) Release(user string, databases ...string) {
+	q.releaseLimiter(q.users, user)
+	for _, db := range distinct(databases) {
+		q.releaseLimiter(q.databases, db)
+	}
+}
+
+// Running returns the number of the running queries of the user and the database.
+func (q *Quota) Running(user string, database string) (int, int) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var u, d int
+	if l, ok := q.users[user]; ok {
+		u = int(l.running.Get())
+	}
+	if l, ok := q.databases[database]; ok {
+		d = int(l.running.Get())
+	}
+	return u, d
+}
+
+// limiters returns the number of the limiters of the users and the databases.
+func (q *Quota) limiters() (int, int) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.users), len(q.databases)
+}
+
+func (q *Quota) quotaConfig(user string, database string) (*config.QuotaConfig, *config.QuotaConfig) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.conf.Users[user], q.conf.Databases[database]
+}
+
+// minLimit returns the min non-zero limit, 0 means no limits.
+func minLimit(limits ...int) int {
+	min := 0
+	for _, limit := range limits {
+		if limit > 0 && (min == 0 || limit < min) {
+			min = limit
+		}
+	}
+	return min
+}
+
+// Limits returns the query timeout and the max result size for the user and the database,
+// the smaller one wins if the limits are set by the proxy, the user and the database.
+func (q *Quota) Limits(user string, database string, timeout int, maxResultSize int) (int, int) {
+	uc, dc := q.quotaConfig(user, database)
+	if uc != nil {
+		timeout = minLimit(timeout, uc.QueryTimeout)
+		maxResultSize = minLimit(maxResultSize, uc.MaxResultSize)
+	}
+	if dc != nil {
+		timeout = minLimit(timeout, dc.QueryTimeout)
+		maxResultSize = minLimit(maxResultSize, dc.MaxResultSize)
+	}
+	return timeout, maxResultSize
+}
+
+// MaxConnections returns the max connections of the user and the database, 0 means no limits.
+func (q *Quota) MaxConnections(user string, database string) (int, int) {
+	var userMax, dbMax int
+	uc, dc := q.quotaConfig(user, database)
+	if uc != nil {
+		userMax = uc.MaxConnections
+	}
+	if dc != nil {
+		dbMax = dc.MaxConnections
+	}
+	return userMax, dbMax
+}
@@ -0,0 +1,266 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package quota
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	testMetadir = "_test_quota"
+)
+
+func testRemoveMetadir() {
+	os.RemoveAll(testMetadir)
+}
+
+func mockQuota(log *xlog.Log) *Quota {
+	os.MkdirAll(testMetadir, os.ModePerm)
+	return NewQuota(log, testMetadir)
+}
+
+func TestQuotaSetRemove(t *testing.T) {
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	quota := mockQuota(log)
+
+	// Set.
+	{
+		err := quota.SetUser("u1", &config.QuotaConfig{MaxQPS: 10, MaxConnections: 2})
+		assert.Nil(t, err)
+		err = quota.SetDatabase("db1", &config.QuotaConfig{MaxConcurrentQueries: 5, QueryTimeout: 100})
+		assert.Nil(t, err)
+
+		conf := quota.Config()
+		assert.Equal(t, 10, conf.Users["u1"].MaxQPS)
+		assert.Equal(t, 5, conf.Databases["db1"].MaxConcurrentQueries)
+		assert.True(t, config.ReadVersion(testMetadir) > 0)
+	}
+
+	// Load from the file.
+	{
+		quota1 := NewQuota(log, testMetadir)
+		err := quota1.LoadConfig()
+		assert.Nil(t, err)
+		assert.Equal(t, quota.Config(), quota1.Config())
+	}
+
+	// Remove.
+	{
+		err := quota.RemoveUser("u1")
+		assert.Nil(t, err)
+		err = quota.RemoveDatabase("db1")
+		assert.Nil(t, err)
+		conf := quota.Config()
+		assert.Equal(t, 0, len(conf.Users))
+		assert.Equal(t, 0, len(conf.Databases))
+	}
+}
+
+func TestQuotaError(t *testing.T) {
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	quota := mockQuota(log)
+
+	// Negative.
+	{
+		err := quota.SetUser("u1", &config.QuotaConfig{MaxQPS: -1})
+		assert.NotNil(t, err)
+		err = quota.SetDatabase("db1", nil)
+		assert.NotNil(t, err)
+	}
+
+	// Not found.
+	{
+		err := quota.RemoveUser("u1")
+		assert.NotNil(t, err)
+		err = quota.RemoveDatabase("db1")
+		assert.NotNil(t, err)
+	}
+
+	// Load invalid file.
+	{
+		err := ioutil.WriteFile(path.Join(testMetadir, quotaJSONFile), []byte("xx"), 0644)
+		assert.Nil(t, err)
+		err = quota.LoadConfig()
+		assert.NotNil(t, err)
+	}
+
+	// Load not exists.
+	{
+		quota1 := NewQuota(log, "/tmp/_radon_quota_not_exists")
+		err := quota1.LoadConfig()
+		assert.Nil(t, err)
+	}
+}
+
+func TestQuotaConcurrentQueries(t *testing.T) {
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	quota := mockQuota(log)
+
+	// Running queries are kept before the quota set.
+	err := quota.Acquire("u1", "db1")
+	assert.Nil(t, err)
+
+	err = quota.SetUser("u1", &config.QuotaConfig{MaxConcurrentQueries: 2})
+	assert.Nil(t, err)
+	err = quota.SetDatabase("db1", &config.QuotaConfig{MaxConcurrentQueries: 1})
+	assert.Nil(t, err)
+
+	// Database limit.
+	{
+		err := quota.Acquire("u1", "db1")
+		assert.NotNil(t, err)
+		assert.Equal(t, sqldb.ER_USER_LIMIT_REACHED, int(err.(*sqldb.SQLError).Num))
+		u, d := quota.Running("u1", "db1")
+		assert.Equal(t, 1, u)
+		assert.Equal(t, 1, d)
+	}
+
+	// User limit.
+	{
+		err := quota.Acquire("u1", "db2")
+		assert.Nil(t, err)
+		err = quota.Acquire("u1", "")
+		assert.NotNil(t, err)
+		quota.Release("u1", "db2")
+	}
+
+	quota.Release("u1", "db1")
+	u, d := quota.Running("u1", "db1")
+	assert.Equal(t, 0, u)
+	assert.Equal(t, 0, d)
+
+	// Unlimited user.
+	for i := 0; i < 10; i++ {
+		err := quota.Acquire("u2", "")
+		assert.Nil(t, err)
+	}
+}
+
+func TestQuotaQPS(t *testing.T) {
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	quota := mockQuota(log)
+
+	err := quota.SetUser("u1", &config.QuotaConfig{MaxQPS: 2})
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		err := quota.Acquire("u1", "db1")
+		assert.Nil(t, err)
+		quota.Release("u1", "db1")
+	}
+	err = quota.Acquire("u1", "db1")
+	assert.NotNil(t, err)
+	u, d := quota.Running("u1", "db1")
+	assert.Equal(t, 0, u)
+	assert.Equal(t, 0, d)
+}
+
+func TestQuotaLimits(t *testing.T) {
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	quota := mockQuota(log)
+
+	err := quota.SetUser("u1", &config.QuotaConfig{QueryTimeout: 1000, MaxConnections: 3})
+	assert.Nil(t, err)
+	err = quota.SetDatabase("db1", &config.QuotaConfig{QueryTimeout: 2000, MaxResultSize: 1024, MaxConnections: 5})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		user      string
+		db        string
+		timeout   int
+		maxResult int
+		want      [2]int
+	}{
+		{"u1", "db1", 0, 0, [2]int{1000, 1024}},
+		{"u1", "db1", 500, 4096, [2]int{500, 1024}},
+		{"u2", "db1", 5000, 0, [2]int{2000, 1024}},
+		{"u2", "db2", 5000, 10, [2]int{5000, 10}},
+	}
+	for _, test := range tests {
+		timeout, maxResult := quota.Limits(test.user, test.db, test.timeout, test.maxResult)
+		assert.Equal(t, test.want, [2]int{timeout, maxResult})
+	}
+
+	u, d := quota.MaxConnections("u1", "db1")
+	assert.Equal(t, 3, u)
+	assert.Equal(t, 5, d)
+	u, d = quota.MaxConnections("u2", "db2")
+	assert.Equal(t, 0, u)
+	assert.Equal(t, 0, d)
+}
+
+func TestQuotaEvict(t *testing.T) {
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	quota := mockQuota(log)
+
+	err := quota.SetUser("u1", &config.QuotaConfig{MaxConcurrentQueries: 2})
+	assert.Nil(t, err)
+
+	// The limiters without quota are evicted once idle.
+	for i := 0; i < 10; i++ {
+		user := fmt.Sprintf("user%d", i)
+		err := quota.Acquire(user, "db1", "db2", "db1")
+		assert.Nil(t, err)
+		u, d := quota.Running(user, "db1")
+		assert.Equal(t, 1, u)
+		assert.Equal(t, 1, d)
+		quota.Release(user, "db1", "db2", "db1")
+	}
+	users, dbs := quota.limiters()
+	assert.Equal(t, 1, users)
+	assert.Equal(t, 0, dbs)
+
+	// The limiter with quota is kept.
+	err = quota.Acquire("u1", "db1")
+	assert.Nil(t, err)
+	quota.Release("u1", "db1")
+	users, dbs = quota.limiters()
+	assert.Equal(t, 1, users)
+	assert.Equal(t, 0, dbs)
+
+	// Running limiter isn't evicted by the quota removed.
+	err = quota.Acquire("u1", "db1")
+	assert.Nil(t, err)
+	err = quota.RemoveUser("u1")
+	assert.Nil(t, err)
+	u, _ := quota.Running("u1", "db1")
+	assert.Equal(t, 1, u)
+	quota.Release("u1", "db1")
+	users, dbs = quota.limiters()
+	assert.Equal(t, 0, users)
+	assert.Equal(t, 0, dbs)
+
+	// Failed acquire releases the acquired.
+	err = quota.SetDatabase("db2", &config.QuotaConfig{MaxQPS: 1})
+	assert.Nil(t, err)
+	err = quota.Acquire("u2", "db1", "db2")
+	assert.Nil(t, err)
+	quota.Release("u2", "db1", "db2")
+	err = quota.Acquire("u2", "db1", "db2")
+	assert.NotNil(t, err)
+	users, dbs = quota.limiters()
+	assert.Equal(t, 0, users)
+	assert.Equal(t, 1, dbs)
+}
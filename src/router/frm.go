@@ -9,10 +9,12 @@
 package router
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
 	"config"
 
@@ -202,6 +204,55 @@ func (r *Router) CheckTable(database string, tableName string) (isExist bool, er
 	return true, nil
 }
 
+// CheckFrm used to compare the table json files of the database with the router,
+// returns the mismatched tables and the reasons.
+func (r *Router) CheckFrm(db string) (map[string]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return nil, errors.Errorf("router.can.not.find.db[%v]", db)
+	}
+
+	files := make(map[string]string)
+	dir := path.Join(r.metadir, db)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+			files[strings.TrimSuffix(info.Name(), ".json")] = path.Join(dir, info.Name())
+		}
+	}
+
+	mismatches := make(map[string]string)
+	for name, table := range schema.Tables {
+		file, ok := files[name]
+		if !ok {
+			mismatches[name] = "frm.file.missing"
+			continue
+		}
+		conf, err := r.readTableFrmData(file)
+		if err != nil {
+			mismatches[name] = fmt.Sprintf("frm.file.unreadable:%v", err)
+			continue
+		}
+		want, _ := json.Marshal(table.TableConfig)
+		got, _ := json.Marshal(conf)
+		if string(want) != string(got) {
+			mismatches[name] = "frm.file.differs.from.router"
+		}
+	}
+	for name := range files {
+		if _, ok := schema.Tables[name]; !ok {
+			mismatches[name] = "frm.file.not.in.router"
+		}
+	}
+	return mismatches, nil
+}
+
 // CreateTable used to add a table to router and flush the schema to disk.
 // Lock.
 func (r *Router) CreateTable(db, table, shardKey string, tableType string, backends []string, extra *Extra) error {
@@ -222,17 +273,20 @@ func (r *Router) CreateTable(db, table, shardKey string, tableType string, backe
 			return err
 		}
 	case TableTypePartitionHash:
-		if tableConf, err = r.HashUniform(table, shardKey, backends); err != nil {
+		if tableConf, err = r.hashOrGroupUniform(db, table, shardKey, backends, extra); err != nil {
 			return err
 		}
 	default:
-		if tableConf, err = r.HashUniform(table, shardKey, backends); err != nil {
+		if tableConf, err = r.hashOrGroupUniform(db, table, shardKey, backends, extra); err != nil {
 			return err
 		}
 	}
 
 	if extra != nil {
 		tableConf.AutoIncrement = extra.AutoIncrement
+		if extra.TableGroup != "" && tableConf.TableGroup == "" {
+			return errors.Errorf("router.tablegroup[%s].unsupport.table.type:[%v]", extra.TableGroup, tableType)
+		}
 	}
 
 	// add config to router.
@@ -252,6 +306,24 @@ func (r *Router) CreateTable(db, table, shardKey string, tableType string, backe
 	return nil
 }
 
+// hashOrGroupUniform used to uniform the hash table, if the table group is specified and
+// not empty, the table will use the same partition map as the group members.
+func (r *Router) hashOrGroupUniform(db, table, shardKey string, backends []string, extra *Extra) (*config.TableConfig, error) {
+	if extra == nil || extra.TableGroup == "" {
+		return r.HashUniform(table, shardKey, backends)
+	}
+
+	if member := r.groupMember(db, extra.TableGroup); member != nil {
+		return r.GroupUniform(table, shardKey, member)
+	}
+	tableConf, err := r.HashUniform(table, shardKey, backends)
+	if err != nil {
+		return nil, err
+	}
+	tableConf.TableGroup = extra.TableGroup
+	return tableConf, nil
+}
+
 // CreateListTable used to add a list table to router and flush the schema to disk.
 func (r *Router) CreateListTable(db, table, shardKey string, tableType string,
 	partitionDef sqlparser.PartitionOptions, extra *Extra) error {
@@ -275,6 +347,9 @@ func (r *Router) CreateListTable(db, table, shardKey string, tableType string,
 
 	if extra != nil {
 		tableConf.AutoIncrement = extra.AutoIncrement
+		if extra.TableGroup != "" {
+			return errors.Errorf("router.tablegroup[%s].unsupport.table.type:[%v]", extra.TableGroup, tableType)
+		}
 	}
 
 	// add config to router.
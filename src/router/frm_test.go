@@ -254,12 +254,12 @@ func TestFrmLoad(t *testing.T) {
 		// load.
 		err := router1.LoadConfig()
 		assert.Nil(t, err)
-		assert.Equal(t, router, router1)
+		assert.Equal(t, router.Schemas, router1.Schemas)
 
 		// load again.
 		err = router1.LoadConfig()
 		assert.Nil(t, err)
-		assert.Equal(t, router, router1)
+		assert.Equal(t, router.Schemas, router1.Schemas)
 	}
 }
 
@@ -352,7 +352,7 @@ func TestFrmDatabaseNoTables(t *testing.T) {
 		// load.
 		err := router1.LoadConfig()
 		assert.Nil(t, err)
-		assert.Equal(t, router, router1)
+		assert.Equal(t, router.Schemas, router1.Schemas)
 	}
 
 	err := router.CreateDatabase("test2")
@@ -494,6 +494,54 @@ func TestFrmCheckTable(t *testing.T) {
 	router.CheckTable("test", "t3")
 }
 
+func TestFrmCheckFrm(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+	backends := []string{"backend1", "backend2", "backend3"}
+	for _, table := range []string{"t1", "t2", "t3"} {
+		err := router.CreateTable("test", table, "id", "", backends, nil)
+		assert.Nil(t, err)
+	}
+
+	// Consistent.
+	{
+		mismatches, err := router.CheckFrm("test")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(mismatches))
+	}
+
+	// Mismatched.
+	{
+		err := os.Remove(path.Join(router.metadir, "test", "t1.json"))
+		assert.Nil(t, err)
+		makeFileBrokenForTest(router, "test", "t2")
+		conf, err := router.TableConfig("test", "t3")
+		assert.Nil(t, err)
+		frm := *conf
+		frm.Partitions = conf.Partitions[1:]
+		err = config.WriteConfig(path.Join(router.metadir, "test", "t3.json"), &frm)
+		assert.Nil(t, err)
+		err = config.WriteConfig(path.Join(router.metadir, "test", "t4.json"), &frm)
+		assert.Nil(t, err)
+
+		mismatches, err := router.CheckFrm("test")
+		assert.Nil(t, err)
+		assert.Equal(t, "frm.file.missing", mismatches["t1"])
+		assert.Contains(t, mismatches["t2"], "frm.file.unreadable")
+		assert.Equal(t, "frm.file.differs.from.router", mismatches["t3"])
+		assert.Equal(t, "frm.file.not.in.router", mismatches["t4"])
+	}
+
+	// Database not exists.
+	{
+		_, err := router.CheckFrm("test1")
+		assert.Equal(t, "router.can.not.find.db[test1]", err.Error())
+	}
+}
+
 func TestFrmTableCreateListTable(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	router, cleanup := MockNewRouter(log)
@@ -535,7 +583,7 @@ func TestFrmTableCreateListTable(t *testing.T) {
 		err = router.CreateListTable("test", "l", "id", TableTypePartitionList, sqlparser.PartitionOptions{}, nil)
 		assert.NotNil(t, err)
 
-		err = router.CreateListTable("test", "l", "id", TableTypePartitionList, partitionDef, &Extra{&config.AutoIncrement{"id"}})
+		err = router.CreateListTable("test", "l", "id", TableTypePartitionList, partitionDef, &Extra{AutoIncrement: &config.AutoIncrement{"id"}})
 		assert.NotNil(t, err)
 	}
 }
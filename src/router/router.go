@@ -14,6 +14,7 @@ import (
 	"sync"
 
 	"config"
+	"xbase/sync2"
 
 	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/sqldb"
@@ -24,6 +25,7 @@ import (
 // Extra -- router extra params.
 type Extra struct {
 	AutoIncrement *config.AutoIncrement
+	TableGroup    string
 }
 
 // Table tuple.
@@ -44,6 +46,8 @@ type Schema struct {
 	DB string `json:",omitempty"`
 	// tables map, key is table name
 	Tables map[string]*Table `json:",omitempty"`
+	// table groups map, key is group name
+	TableGroups map[string]*TableGroup `json:",omitempty"`
 }
 
 // Router tuple.
@@ -53,6 +57,8 @@ type Router struct {
 	metadir string
 	dbACL   *DatabaseACL
 	conf    *config.RouterConfig
+	// version is increased when the schemas changed.
+	version sync2.AtomicInt64
 
 	// schemas map, key is database name
 	Schemas map[string]*Schema `json:",omitempty"`
@@ -85,7 +91,7 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 
 	// schema
 	if schema, ok = r.Schemas[db]; !ok {
-		schema = &Schema{DB: db, Tables: make(map[string]*Table)}
+		schema = &Schema{DB: db, Tables: make(map[string]*Table), TableGroups: make(map[string]*TableGroup)}
 		r.Schemas[db] = schema
 	}
 
@@ -134,6 +140,8 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 	default:
 		return errors.Errorf("router.unsupport.shardtype:[%v]", tbl.ShardType)
 	}
+	schema.addToTableGroup(tbl)
+	r.version.Add(1)
 	return nil
 }
 
@@ -141,17 +149,20 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 func (r *Router) removeTable(db string, table string) error {
 	var ok bool
 	var schema *Schema
+	var tbl *Table
 
 	// schema
 	if schema, ok = r.Schemas[db]; !ok {
 		return errors.Errorf("router.can.not.find.db[%v]", db)
 	}
 	// table
-	if _, ok = schema.Tables[table]; !ok {
+	if tbl, ok = schema.Tables[table]; !ok {
 		return errors.Errorf("router.can.not.find.table[%v]", table)
 	}
 	// remove
 	delete(schema.Tables, table)
+	schema.removeFromTableGroup(tbl.TableConfig.TableGroup, table)
+	r.version.Add(1)
 	return nil
 }
 
@@ -181,8 +192,9 @@ func (r *Router) getRenameTableConfig(db, fromTable, toTable string) (*config.Ta
 
 func (r *Router) addDatabase(db string) error {
 	if _, ok := r.Schemas[db]; !ok {
-		schema := &Schema{DB: db, Tables: make(map[string]*Table)}
+		schema := &Schema{DB: db, Tables: make(map[string]*Table), TableGroups: make(map[string]*TableGroup)}
 		r.Schemas[db] = schema
+		r.version.Add(1)
 		return nil
 	}
 	return errors.Errorf("router.database.exists")
@@ -193,12 +205,19 @@ func (r *Router) dropDatabase(db string) error {
 		return errors.Errorf("router.can.not.find.db[%v]", db)
 	}
 	delete(r.Schemas, db)
+	r.version.Add(1)
 	return nil
 }
 
 // clear used to reset Schemas to new.
 func (r *Router) clear() {
 	r.Schemas = make(map[string]*Schema)
+	r.version.Add(1)
+}
+
+// Version returns the in-memory version of the router, it changes every time the schemas changed.
+func (r *Router) Version() int64 {
+	return r.version.Get()
 }
 
 // DatabaseACL used to check whether the database is a system database.
@@ -670,3 +670,24 @@ func TestRouterIsPartitionHash(t *testing.T) {
 	isHash := router.IsPartitionHash(methodTypeHash)
 	assert.Equal(t, true, isHash)
 }
+
+func TestRouterVersion(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	version := router.Version()
+	err := router.CreateDatabase("test")
+	assert.Nil(t, err)
+	assert.True(t, router.Version() > version)
+
+	version = router.Version()
+	err = router.AddForTest("test", MockTableMConfig())
+	assert.Nil(t, err)
+	assert.True(t, router.Version() > version)
+
+	version = router.Version()
+	err = router.ReLoad()
+	assert.Nil(t, err)
+	assert.True(t, router.Version() > version)
+}
@@ -0,0 +1,155 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"fmt"
+	"sort"
+
+	"config"
+
+	"github.com/pkg/errors"
+)
+
+// TableGroup tuple.
+// The tables in one group share the same partition map, so the sub-tables
+// with the same segment are always co-located on the same backend.
+type TableGroup struct {
+	// Group name.
+	Name string `json:",omitempty"`
+	// Tables in this group.
+	Tables []string `json:",omitempty"`
+}
+
+// groupMember returns one table config from the group, nil if the group is empty.
+func (r *Router) groupMember(db, group string) *config.TableConfig {
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return nil
+	}
+	tg, ok := schema.TableGroups[group]
+	if !ok || len(tg.Tables) == 0 {
+		return nil
+	}
+	return schema.Tables[tg.Tables[0]].TableConfig
+}
+
+// GroupUniform used to uniform the table with the partition map of the group member.
+func (r *Router) GroupUniform(table, shardkey string, member *config.TableConfig) (*config.TableConfig, error) {
+	if table == "" {
+		return nil, errors.New("table.cant.be.null")
+	}
+	if shardkey == "" {
+		return nil, errors.New("shard.key.cant.be.null")
+	}
+	if member.ShardType != methodTypeHash {
+		return nil, errors.Errorf("router.tablegroup[%s].unsupport.shardtype:[%v]", member.TableGroup, member.ShardType)
+	}
+
+	tableConf := &config.TableConfig{
+		Name:       table,
+		Slots:      member.Slots,
+		Blocks:     member.Blocks,
+		ShardKey:   shardkey,
+		ShardType:  methodTypeHash,
+		Partitions: make([]*config.PartitionConfig, 0, len(member.Partitions)),
+		TableGroup: member.TableGroup,
+	}
+	for i, part := range member.Partitions {
+		partConf := &config.PartitionConfig{
+			Table:   fmt.Sprintf("%s_%04d", table, i),
+			Segment: part.Segment,
+			Backend: part.Backend,
+		}
+		tableConf.Partitions = append(tableConf.Partitions, partConf)
+	}
+	return tableConf, nil
+}
+
+// addToTableGroup used to add the table to its group.
+func (schema *Schema) addToTableGroup(tbl *config.TableConfig) {
+	group := tbl.TableGroup
+	if group == "" {
+		return
+	}
+
+	tg, ok := schema.TableGroups[group]
+	if !ok {
+		tg = &TableGroup{Name: group}
+		schema.TableGroups[group] = tg
+	}
+	tg.Tables = append(tg.Tables, tbl.Name)
+	sort.Strings(tg.Tables)
+}
+
+// removeFromTableGroup used to remove the table from the group.
+func (schema *Schema) removeFromTableGroup(group string, table string) {
+	tg, ok := schema.TableGroups[group]
+	if !ok {
+		return
+	}
+
+	for i, name := range tg.Tables {
+		if name == table {
+			tg.Tables = append(tg.Tables[:i], tg.Tables[i+1:]...)
+			break
+		}
+	}
+	if len(tg.Tables) == 0 {
+		delete(schema.TableGroups, group)
+	}
+}
+
+// TableGroupSiblings returns the partition tables of the other tables in the same group,
+// which have the same segment as the partitionTable.
+func (r *Router) TableGroupSiblings(database, partitionTable string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, ok := r.Schemas[database]
+	if !ok {
+		return nil, errors.Errorf("router.can.not.find.db[%v]", database)
+	}
+
+	var table *config.TableConfig
+	var segment string
+	for _, v := range schema.Tables {
+		for _, partition := range v.TableConfig.Partitions {
+			if partition.Table == partitionTable {
+				table = v.TableConfig
+				segment = partition.Segment
+				break
+			}
+		}
+		if table != nil {
+			break
+		}
+	}
+	if table == nil {
+		return nil, errors.Errorf("router.can.not.find.partition.table[%v]", partitionTable)
+	}
+
+	var siblings []string
+	tg, ok := schema.TableGroups[table.TableGroup]
+	if !ok {
+		return siblings, nil
+	}
+	for _, name := range tg.Tables {
+		if name == table.Name {
+			continue
+		}
+		for _, partition := range schema.Tables[name].TableConfig.Partitions {
+			if partition.Segment == segment {
+				siblings = append(siblings, partition.Table)
+				break
+			}
+		}
+	}
+	return siblings, nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestTableGroupCreate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	err := router.CreateDatabase("test")
+	assert.Nil(t, err)
+
+	// The first member uses the backends.
+	{
+		backends := []string{"backend1", "backend2"}
+		err := router.CreateTable("test", "t1", "id", TableTypePartitionHash, backends, &Extra{TableGroup: "g1"})
+		assert.Nil(t, err)
+	}
+
+	// The other members use the partition map of the group, even the backends changed.
+	{
+		backends := []string{"backend1", "backend2", "backend3"}
+		err := router.CreateTable("test", "t2", "id", TableTypePartitionHash, backends, &Extra{TableGroup: "g1"})
+		assert.Nil(t, err)
+		err = router.CreateTable("test", "t3", "id", TableTypePartitionHash, backends, nil)
+		assert.Nil(t, err)
+	}
+
+	t1, err := router.TableConfig("test", "t1")
+	assert.Nil(t, err)
+	t2, err := router.TableConfig("test", "t2")
+	assert.Nil(t, err)
+	assert.Equal(t, "g1", t2.TableGroup)
+	assert.Equal(t, len(t1.Partitions), len(t2.Partitions))
+	for i, part := range t1.Partitions {
+		assert.Equal(t, part.Segment, t2.Partitions[i].Segment)
+		assert.Equal(t, part.Backend, t2.Partitions[i].Backend)
+	}
+	assert.Equal(t, "t2_0000", t2.Partitions[0].Table)
+
+	// Reload from the disk.
+	{
+		err := router.ReLoad()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"t1", "t2"}, router.Schemas["test"].TableGroups["g1"].Tables)
+	}
+
+	// Rename.
+	{
+		err := router.RenameTable("test", "t2", "t5")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"t1", "t5"}, router.Schemas["test"].TableGroups["g1"].Tables)
+	}
+
+	// Drop.
+	{
+		err := router.DropTable("test", "t5")
+		assert.Nil(t, err)
+		err = router.DropTable("test", "t1")
+		assert.Nil(t, err)
+		_, ok := router.Schemas["test"].TableGroups["g1"]
+		assert.False(t, ok)
+	}
+}
+
+func TestTableGroupCreateError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	err := router.CreateDatabase("test")
+	assert.Nil(t, err)
+
+	backends := []string{"backend1", "backend2"}
+	err = router.CreateTable("test", "t1", "", TableTypeGlobal, backends, &Extra{TableGroup: "g1"})
+	assert.NotNil(t, err)
+	err = router.CreateTable("test", "t1", "", TableTypeSingle, backends, &Extra{TableGroup: "g1"})
+	assert.NotNil(t, err)
+	err = router.CreateTable("test", "t1", "", TableTypePartitionHash, backends, &Extra{TableGroup: "g1"})
+	assert.NotNil(t, err)
+}
+
+func TestTableGroupShift(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	err := router.CreateDatabase("test")
+	assert.Nil(t, err)
+
+	backends := []string{"backend1", "backend2"}
+	err = router.CreateTable("test", "t1", "id", TableTypePartitionHash, backends, &Extra{TableGroup: "g1"})
+	assert.Nil(t, err)
+	err = router.CreateTable("test", "t2", "id", TableTypePartitionHash, backends, &Extra{TableGroup: "g1"})
+	assert.Nil(t, err)
+
+	siblings, err := router.TableGroupSiblings("test", "t1_0001")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"t2_0001"}, siblings)
+
+	_, err = router.TableGroupSiblings("test", "t1_xxxx")
+	assert.NotNil(t, err)
+	_, err = router.TableGroupSiblings("xx", "t1_0001")
+	assert.NotNil(t, err)
+
+	// Shift the first member, the group diverged.
+	err = router.PartitionRuleShift("backend1", "backend2", "test", "t1_0001")
+	assert.Nil(t, err)
+	t1, err := router.TableConfig("test", "t1")
+	assert.Nil(t, err)
+	t2, err := router.TableConfig("test", "t2")
+	assert.Nil(t, err)
+	assert.Equal(t, "backend2", t1.Partitions[1].Backend)
+	assert.Equal(t, "backend1", t2.Partitions[1].Backend)
+
+	// Shift the sibling, the group colocated again.
+	err = router.PartitionRuleShift("backend1", "backend2", "test", "t2_0001")
+	assert.Nil(t, err)
+	t2, err = router.TableConfig("test", "t2")
+	assert.Nil(t, err)
+	assert.Equal(t, t1.Partitions[1].Backend, t2.Partitions[1].Backend)
+}
@@ -0,0 +1,204 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package slowlog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"config"
+	"xbase"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	prefix    = "slow-"
+	extension = ".log"
+
+	// maxRecent is the number of the recent entries kept in memory for the debug api.
+	maxRecent = 256
+)
+
+// Event tuple, the slow query.
+type Event struct {
+	Start        time.Time
+	Cost         time.Duration
+	User         string
+	Host         string
+	ThreadID     uint32
+	DB           string
+	Query        string
+	RowsSent     uint64
+	RowsAffected uint64
+	Errno        uint16
+	// Shards are the rewritten querys executed on the backends.
+	Shards []xcontext.QueryProfile
+}
+
+// SlowLog tuple.
+type SlowLog struct {
+	log    *xlog.Log
+	conf   *config.SlowLogConfig
+	mu     sync.Mutex
+	ticker *time.Ticker
+	done   chan bool
+	rfile  xbase.RotateFile
+	recent [][]byte
+	wg     sync.WaitGroup
+}
+
+// NewSlowLog creates the new slow log.
+func NewSlowLog(log *xlog.Log, conf *config.SlowLogConfig) *SlowLog {
+	return &SlowLog{
+		log:    log,
+		conf:   conf,
+		done:   make(chan bool),
+		ticker: time.NewTicker(time.Duration(time.Second * 300)), // 5 minutes
+		rfile:  xbase.NewRotateFile(conf.LogDir, prefix, extension, conf.MaxSize),
+	}
+}
+
+// Init used to create the log dir and start the purge worker.
+func (s *SlowLog) Init() error {
+	log := s.log
+
+	log.Info("slowlog.init.conf:%+v", s.conf)
+	if err := os.MkdirAll(s.conf.LogDir, 0744); err != nil {
+		return err
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.purge()
+	}()
+	log.Info("slowlog.init.done")
+	return nil
+}
+
+// Close used to close the slow log.
+func (s *SlowLog) Close() {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rfile.Close()
+	s.log.Info("slowlog.closed")
+}
+
+// Enabled returns true if the slow log is enabled.
+func (s *SlowLog) Enabled() bool {
+	return s.conf.Enable
+}
+
+// LogEvent used to write the event to the slow log.
+func (s *SlowLog) LogEvent(e *Event) {
+	entry := format(e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.rfile.Write(entry); err != nil {
+		s.log.Error("slowlog.write.error:%v", err)
+	}
+	if len(s.recent) >= maxRecent {
+		s.recent = s.recent[1:]
+	}
+	s.recent = append(s.recent, entry)
+}
+
+// Recent returns the recent entries in the slow log format, at most limit entries, the oldest first.
+func (s *SlowLog) Recent(limit int) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := s.recent
+	if limit > 0 && limit < len(recent) {
+		recent = recent[len(recent)-limit:]
+	}
+	return bytes.Join(recent, nil)
+}
+
+// format used to format the event as the mysql slow log, which can be consumed by the pt-query-digest.
+// The shard lines use the 'Shard_' attributes to avoid overwriting the attributes of the query.
+func format(e *Event) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 512))
+	ip := e.Host
+	if host, _, err := net.SplitHostPort(e.Host); err == nil {
+		ip = host
+	}
+
+	examined := 0
+	for _, shard := range e.Shards {
+		examined += shard.Rows
+	}
+	fmt.Fprintf(buf, "# Time: %s\n", e.Start.UTC().Format("2006-01-02T15:04:05.000000Z"))
+	fmt.Fprintf(buf, "# User@Host: %s[%s] @  [%s]  Id: %5d\n", e.User, e.User, ip, e.ThreadID)
+	fmt.Fprintf(buf, "# Query_time: %.6f  Lock_time: 0.000000 Rows_sent: %d  Rows_examined: %d  Rows_affected: %d  Last_errno: %d\n",
+		e.Cost.Seconds(), e.RowsSent, examined, e.RowsAffected, e.Errno)
+	for _, shard := range e.Shards {
+		fmt.Fprintf(buf, "# Shard: %s  Shard_time: %.6f  Shard_rows: %d  Shard_query: %s\n",
+			shard.Backend, shard.Duration.Seconds(), shard.Rows, oneLine(shard.Query))
+		if shard.Error != "" {
+			fmt.Fprintf(buf, "# Shard_error: %s\n", oneLine(shard.Error))
+		}
+	}
+	if e.DB != "" {
+		fmt.Fprintf(buf, "use %s;\n", e.DB)
+	}
+	fmt.Fprintf(buf, "SET timestamp=%d;\n", e.Start.Unix())
+	buf.WriteString(e.Query)
+	buf.WriteString(";\n")
+	return buf.Bytes()
+}
+
+// oneLine replaces the line breaks, the shard lines must be in one line.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+func (s *SlowLog) purge() {
+	defer s.ticker.Stop()
+	for {
+		select {
+		case <-s.ticker.C:
+			s.doPurge()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *SlowLog) doPurge() {
+	log := s.log
+	if s.conf.ExpireHours == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	oldLogs, err := s.rfile.GetOldLogInfos()
+	s.mu.Unlock()
+	if err != nil {
+		log.Error("slowlog.get.old.loginfos.error:%v", err)
+		return
+	}
+
+	for _, old := range oldLogs {
+		diff := time.Now().UTC().Sub(time.Unix(0, old.Ts))
+		if int(diff.Hours()) > s.conf.ExpireHours {
+			os.Remove(filepath.Join(s.conf.LogDir, old.Name))
+		}
+	}
+}
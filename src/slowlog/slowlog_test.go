@@ -0,0 +1,119 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package slowlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+	"fakedb"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestSlowLogFormat(t *testing.T) {
+	e := &Event{
+		Start:        time.Date(2019, 10, 21, 3, 11, 1, 123456000, time.UTC),
+		Cost:         time.Millisecond * 2500,
+		User:         "root",
+		Host:         "127.0.0.1:38440",
+		ThreadID:     3,
+		DB:           "db1",
+		Query:        "select * from t1",
+		RowsSent:     2,
+		RowsAffected: 0,
+		Shards: []xcontext.QueryProfile{
+			{Backend: "backend1", Query: "select * from db1.t1_0000\nas t1", Duration: time.Millisecond * 1500, Rows: 10},
+			{Backend: "backend2", Query: "select * from db1.t1_0001 as t1", Duration: time.Millisecond, Error: "mock.error"},
+		},
+	}
+	want := "# Time: 2019-10-21T03:11:01.123456Z\n" +
+		"# User@Host: root[root] @  [127.0.0.1]  Id:     3\n" +
+		"# Query_time: 2.500000  Lock_time: 0.000000 Rows_sent: 2  Rows_examined: 10  Rows_affected: 0  Last_errno: 0\n" +
+		"# Shard: backend1  Shard_time: 1.500000  Shard_rows: 10  Shard_query: select * from db1.t1_0000 as t1\n" +
+		"# Shard: backend2  Shard_time: 0.001000  Shard_rows: 0  Shard_query: select * from db1.t1_0001 as t1\n" +
+		"# Shard_error: mock.error\n" +
+		"use db1;\n" +
+		"SET timestamp=1571627461;\n" +
+		"select * from t1;\n"
+	assert.Equal(t, want, string(format(e)))
+}
+
+func TestSlowLog(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+
+	conf := &config.SlowLogConfig{
+		Enable:      true,
+		LogDir:      tmpDir,
+		MaxSize:     1024 * 1024,
+		ExpireHours: 1,
+	}
+	slowLog := NewSlowLog(log, conf)
+	err := slowLog.Init()
+	assert.Nil(t, err)
+	assert.True(t, slowLog.Enabled())
+
+	for i := 0; i < maxRecent+10; i++ {
+		slowLog.LogEvent(&Event{Start: time.Now(), User: "root", Query: "select 1"})
+	}
+	assert.Equal(t, maxRecent, len(slowLog.recent))
+	assert.Equal(t, 2, strings.Count(string(slowLog.Recent(2)), "select 1;\n"))
+	assert.Equal(t, maxRecent, strings.Count(string(slowLog.Recent(0)), "select 1;\n"))
+	slowLog.Close()
+
+	files, err := filepath.Glob(filepath.Join(tmpDir, prefix+"*"+extension))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	data, err := ioutil.ReadFile(files[0])
+	assert.Nil(t, err)
+	assert.Equal(t, maxRecent+10, strings.Count(string(data), "select 1;\n"))
+}
+
+func TestSlowLogPurge(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir := fakedb.GetTmpDir("", "radon_slowlog_", log)
+	defer os.RemoveAll(tmpDir)
+
+	conf := &config.SlowLogConfig{
+		Enable:      true,
+		LogDir:      tmpDir,
+		MaxSize:     100,
+		ExpireHours: 1,
+	}
+	slowLog := NewSlowLog(log, conf)
+	err := slowLog.Init()
+	assert.Nil(t, err)
+	defer slowLog.Close()
+
+	// Rotated for every event.
+	for i := 0; i < 3; i++ {
+		slowLog.LogEvent(&Event{Start: time.Now(), User: "root", Query: "select 1"})
+		time.Sleep(time.Millisecond * 10)
+	}
+	files, err := filepath.Glob(filepath.Join(tmpDir, prefix+"*"+extension))
+	assert.Nil(t, err)
+	assert.True(t, len(files) > 1)
+
+	// Expire all the old logs, the current is kept.
+	slowLog.conf.ExpireHours = -1
+	slowLog.doPurge()
+	slowLog.conf.ExpireHours = 1
+	files, err = filepath.Glob(filepath.Join(tmpDir, prefix+"*"+extension))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+}
@@ -183,6 +183,9 @@ func (s *Syncer) MetaReload() error {
 	if err := s.peer.LoadConfig(); err != nil {
 		log.Panicf("syncer.meta.peer.load.config.error:%+v", err)
 	}
+	if err := s.quota.LoadConfig(); err != nil {
+		log.Panicf("syncer.meta.quota.load.config.error:%+v", err)
+	}
 	log.Warning("syncer.meta.reload.done...")
 	return nil
 }
@@ -35,7 +35,7 @@ func TestMeta(t *testing.T) {
 	defer testRemoveMetadir()
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	syncer := NewSyncer(log, testMetadir, "", nil, nil)
+	syncer := NewSyncer(log, testMetadir, "", nil, nil, nil)
 	assert.NotNil(t, syncer)
 
 	err := syncer.Init()
@@ -83,7 +83,7 @@ func TestMeta(t *testing.T) {
 func TestMetaError(t *testing.T) {
 	defer testRemoveMetadir()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	syncer := NewSyncer(log, testMetadir, "", nil, nil)
+	syncer := NewSyncer(log, testMetadir, "", nil, nil, nil)
 	assert.NotNil(t, syncer)
 
 	// MetaJson.
@@ -12,6 +12,7 @@ import (
 
 	"backend"
 	"config"
+	"quota"
 	"router"
 
 	"github.com/ant0ine/go-json-rest/rest"
@@ -56,7 +57,7 @@ func mockSyncer(log *xlog.Log, n int) ([]*Syncer, func()) {
 			log.Panicf("mock.syncer.error:%+v", err)
 		}
 
-		syncer := NewSyncer(log, metadir, peerAddr, router, scatter)
+		syncer := NewSyncer(log, metadir, peerAddr, router, scatter, quota.NewQuota(log, metadir))
 		syncer.Init()
 		syncers = append(syncers, syncer)
 		peers = append(peers, peerAddr)
@@ -86,6 +87,7 @@ func mockSyncer(log *xlog.Log, n int) ([]*Syncer, func()) {
 			}
 			oldSha1 = sha1
 			syncer.Close()
+			syncer.scatter.Close()
 			os.RemoveAll(syncer.metadir + "/")
 
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
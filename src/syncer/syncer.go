@@ -17,6 +17,7 @@ import (
 
 	"backend"
 	"config"
+	"quota"
 	"router"
 	"xbase"
 
@@ -34,15 +35,17 @@ type Syncer struct {
 	ticker  *time.Ticker
 	router  *router.Router
 	scatter *backend.Scatter
+	quota   *quota.Quota
 }
 
 // NewSyncer creates the new syncer.
-func NewSyncer(log *xlog.Log, metadir string, peerAddr string, router *router.Router, scatter *backend.Scatter) *Syncer {
+func NewSyncer(log *xlog.Log, metadir string, peerAddr string, router *router.Router, scatter *backend.Scatter, quota *quota.Quota) *Syncer {
 	return &Syncer{
 		log:     log,
 		metadir: metadir,
 		router:  router,
 		scatter: scatter,
+		quota:   quota,
 		done:    make(chan bool),
 		peer:    NewPeer(log, metadir, peerAddr),
 		ticker:  time.NewTicker(time.Duration(time.Millisecond * 500)), // 0.5s
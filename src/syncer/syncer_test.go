@@ -12,6 +12,8 @@ import (
 	"testing"
 	"time"
 
+	"config"
+
 	"github.com/fortytw2/leaktest"
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -75,3 +77,22 @@ func TestSyncerAddRemovePeers(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestSyncerQuota(t *testing.T) {
+	defer leaktest.Check(t)()
+	defer testRemoveMetadir()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	syncers, cleanup := mockSyncer(log, 3)
+	assert.NotNil(t, syncers)
+	defer cleanup()
+
+	err := syncers[0].quota.SetUser("u1", &config.QuotaConfig{MaxQPS: 10})
+	assert.Nil(t, err)
+	time.Sleep(time.Second * 2)
+
+	// The quotas are reloaded by the peers.
+	for _, syncer := range syncers[1:] {
+		conf := syncer.quota.Config()
+		assert.Equal(t, 10, conf.Users["u1"].MaxQPS)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"sync"
+
+	"xbase/sync2"
+
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// batchSize is the max number of the items handled in one batch.
+	batchSize = 256
+)
+
+// Batcher used to handle the items asynchronously in batches.
+// The items are buffered in the bounded queue, they are dropped if the queue is full,
+// so a slow handler never blocks the callers.
+type Batcher struct {
+	log     *xlog.Log
+	name    string
+	queue   chan interface{}
+	handle  func(items []interface{})
+	dropped sync2.AtomicInt64
+	wg      sync.WaitGroup
+}
+
+// NewBatcher creates the new Batcher, the name prefixes the logs.
+func NewBatcher(log *xlog.Log, name string, bufferSize int, handle func(items []interface{})) *Batcher {
+	return &Batcher{
+		log:    log,
+		name:   name,
+		queue:  make(chan interface{}, bufferSize),
+		handle: handle,
+	}
+}
+
+// Start used to start the worker goroutine.
+func (b *Batcher) Start() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.consume()
+	}()
+}
+
+// Push used to buffer the item without blocking, it must not be called after the Close.
+func (b *Batcher) Push(item interface{}) {
+	select {
+	case b.queue <- item:
+	default:
+		if n := b.dropped.Add(1); n%1000 == 1 {
+			b.log.Warning("%s.queue.full.dropped[%d]", b.name, n)
+		}
+	}
+}
+
+// Dropped returns the number of the items dropped since the queue is full.
+func (b *Batcher) Dropped() int64 {
+	return b.dropped.Get()
+}
+
+// Close used to handle the buffered items and stop the worker.
+func (b *Batcher) Close() {
+	close(b.queue)
+	b.wg.Wait()
+}
+
+func (b *Batcher) consume() {
+	items := make([]interface{}, 0, batchSize)
+	for item := range b.queue {
+		items = append(items[:0], item)
+
+		// Batch the buffered items.
+	batch:
+		for i := 1; i < batchSize; i++ {
+			select {
+			case item, ok := <-b.queue:
+				if !ok {
+					break batch
+				}
+				items = append(items, item)
+			default:
+				break batch
+			}
+		}
+		b.handle(items)
+	}
+}
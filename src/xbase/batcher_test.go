@@ -0,0 +1,56 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestBatcher(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	var got []interface{}
+	batches := 0
+	batcher := NewBatcher(log, "test", 1024, func(items []interface{}) {
+		batches++
+		got = append(got, items...)
+	})
+	for i := 0; i < 600; i++ {
+		batcher.Push(i)
+	}
+	batcher.Start()
+	batcher.Close()
+
+	// The buffered items are handled in the batches by the close.
+	assert.Equal(t, 600, len(got))
+	assert.Equal(t, 0, got[0])
+	assert.Equal(t, 599, got[599])
+	assert.Equal(t, 3, batches)
+	assert.Equal(t, int64(0), batcher.Dropped())
+}
+
+func TestBatcherDropped(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	handled := 0
+	batcher := NewBatcher(log, "test", 2, func(items []interface{}) {
+		handled += len(items)
+	})
+	for i := 0; i < 10; i++ {
+		batcher.Push(i)
+	}
+	assert.Equal(t, int64(8), batcher.Dropped())
+
+	batcher.Start()
+	batcher.Close()
+	assert.Equal(t, 2, handled)
+}
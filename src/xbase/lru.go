@@ -0,0 +1,124 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"container/list"
+	"sync"
+
+	"xbase/sync2"
+)
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// LRUCache is a fixed capacity cache which evicts the least recently used entry.
+// If the capacity <= 0, the cache is disabled.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	list     *list.List
+	entries  map[string]*list.Element
+	hits     sync2.AtomicInt64
+	misses   sync2.AtomicInt64
+}
+
+// NewLRUCache creates the new LRUCache.
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		list:     list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value of the key and moves it to the front.
+func (lru *LRUCache) Get(key string) (interface{}, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	elem, ok := lru.entries[key]
+	if !ok {
+		lru.misses.Add(1)
+		return nil, false
+	}
+	lru.hits.Add(1)
+	lru.list.MoveToFront(elem)
+	return elem.Value.(*lruEntry).value, true
+}
+
+// Set used to add or update the key, the oldest entry will be evicted if the cache is full.
+func (lru *LRUCache) Set(key string, value interface{}) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	if lru.capacity <= 0 {
+		return
+	}
+
+	if elem, ok := lru.entries[key]; ok {
+		elem.Value.(*lruEntry).value = value
+		lru.list.MoveToFront(elem)
+		return
+	}
+	lru.entries[key] = lru.list.PushFront(&lruEntry{key: key, value: value})
+	lru.evict()
+}
+
+// SetCapacity used to resize the cache, the oldest entries are evicted if the cache is over the capacity.
+func (lru *LRUCache) SetCapacity(capacity int) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	lru.capacity = capacity
+	lru.evict()
+}
+
+func (lru *LRUCache) evict() {
+	for lru.list.Len() > 0 && lru.list.Len() > lru.capacity {
+		oldest := lru.list.Back()
+		lru.list.Remove(oldest)
+		delete(lru.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Clear used to remove all the entries.
+func (lru *LRUCache) Clear() {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	lru.list.Init()
+	lru.entries = make(map[string]*list.Element)
+}
+
+// Len returns the number of the entries.
+func (lru *LRUCache) Len() int {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.list.Len()
+}
+
+// Capacity returns the capacity of the cache.
+func (lru *LRUCache) Capacity() int {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.capacity
+}
+
+// Hits returns the hit count.
+func (lru *LRUCache) Hits() int64 {
+	return lru.hits.Get()
+}
+
+// Misses returns the miss count.
+func (lru *LRUCache) Misses() int64 {
+	return lru.misses.Get()
+}
@@ -0,0 +1,71 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache(t *testing.T) {
+	lru := NewLRUCache(2)
+	assert.Equal(t, 2, lru.Capacity())
+
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+	// Touch a, b is the oldest now.
+	v, ok := lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	lru.Set("c", 3)
+	assert.Equal(t, 2, lru.Len())
+	_, ok = lru.Get("b")
+	assert.False(t, ok)
+
+	// Update.
+	lru.Set("c", 4)
+	v, ok = lru.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+	assert.Equal(t, int64(2), lru.Hits())
+	assert.Equal(t, int64(1), lru.Misses())
+
+	lru.Clear()
+	assert.Equal(t, 0, lru.Len())
+	_, ok = lru.Get("a")
+	assert.False(t, ok)
+}
+
+func TestLRUCacheDisabled(t *testing.T) {
+	lru := NewLRUCache(0)
+	lru.Set("a", 1)
+	_, ok := lru.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, lru.Len())
+}
+
+func TestLRUCacheSetCapacity(t *testing.T) {
+	lru := NewLRUCache(3)
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+	lru.Set("c", 3)
+
+	lru.SetCapacity(1)
+	assert.Equal(t, 1, lru.Capacity())
+	assert.Equal(t, 1, lru.Len())
+	_, ok := lru.Get("c")
+	assert.True(t, ok)
+
+	lru.SetCapacity(0)
+	assert.Equal(t, 0, lru.Len())
+	lru.Set("a", 1)
+	assert.Equal(t, 0, lru.Len())
+}
@@ -43,6 +43,9 @@ const (
 	// CHECKSUM type.
 	CHECKSUM = "CHECKSUM"
 
+	// LOAD type.
+	LOAD = "LOAD"
+
 	// RADON type
 	RADON = "RADON"
 )
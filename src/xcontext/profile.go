@@ -0,0 +1,160 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xcontext
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"xtrace"
+)
+
+// QueryProfile tuple, the execution stats of one query on the backend.
+type QueryProfile struct {
+	Query    string
+	Backend  string
+	Duration time.Duration
+	Rows     int
+	Bytes    int
+	Error    string
+}
+
+// OperatorProfile tuple, the execution stats of one plan node or operator in proxy.
+type OperatorProfile struct {
+	Duration time.Duration
+	Rows     int
+	// Loops is the executed times, the right node of the nest loop join will be executed many times.
+	Loops int
+}
+
+// Profile used to collect the execution stats, used by the EXPLAIN ANALYZE.
+// All the methods are safe for the nil Profile, which means the profiling is disabled.
+// If the span is set, the backend querys and the operators are traced as its children too.
+type Profile struct {
+	mu        sync.Mutex
+	querys    []QueryProfile
+	operators map[interface{}]*OperatorProfile
+	span      *xtrace.Span
+	// analyze is true if the profile is for the EXPLAIN ANALYZE, the bytes of the backend results are counted.
+	analyze bool
+}
+
+// NewProfile creates the new Profile.
+func NewProfile() *Profile {
+	return &Profile{
+		operators: make(map[interface{}]*OperatorProfile),
+	}
+}
+
+// NewAnalyzeProfile creates the Profile of the EXPLAIN ANALYZE.
+func NewAnalyzeProfile() *Profile {
+	p := NewProfile()
+	p.analyze = true
+	return p
+}
+
+// Analyze returns true if the profile is for the EXPLAIN ANALYZE.
+func (p *Profile) Analyze() bool {
+	if p == nil {
+		return false
+	}
+	return p.analyze
+}
+
+// SetSpan used to set the span of the statement.
+func (p *Profile) SetSpan(span *xtrace.Span) {
+	if p == nil {
+		return
+	}
+	p.span = span
+}
+
+// Span returns the span of the statement, nil if the statement isn't traced.
+func (p *Profile) Span() *xtrace.Span {
+	if p == nil {
+		return nil
+	}
+	return p.span
+}
+
+// AddQuery used to record the stats of the backend query.
+func (p *Profile) AddQuery(qp QueryProfile) {
+	if p == nil {
+		return
+	}
+	if p.span != nil {
+		end := time.Now()
+		span := p.span.StartChildAt("backend.query", xtrace.SpanKindClient, end.Add(-qp.Duration))
+		span.SetAttribute("db.statement", qp.Query)
+		span.SetAttribute("radon.backend", qp.Backend)
+		span.SetAttribute("radon.rows", qp.Rows)
+		if qp.Error != "" {
+			span.SetError(errors.New(qp.Error))
+		}
+		span.EndAt(end)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.querys = append(p.querys, qp)
+}
+
+// operatorName returns the name of the plan node or the child plan, such as: MergeNode to merge.
+func operatorName(key interface{}) string {
+	name := fmt.Sprintf("%T", key)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "Node"), "Plan")
+	return strings.ToLower(name)
+}
+
+// AddOperator used to record the stats of the operator, the key is the plan node or the child plan.
+func (p *Profile) AddOperator(key interface{}, start time.Time, rows int) {
+	if p == nil {
+		return
+	}
+	if p.span != nil {
+		span := p.span.StartChildAt("operator."+operatorName(key), xtrace.SpanKindInternal, start)
+		span.SetAttribute("radon.rows", rows)
+		span.End()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	op, ok := p.operators[key]
+	if !ok {
+		op = &OperatorProfile{}
+		p.operators[key] = op
+	}
+	op.Duration += time.Since(start)
+	op.Rows += rows
+	op.Loops++
+}
+
+// Querys returns the stats of the backend querys in the execution order.
+func (p *Profile) Querys() []QueryProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	querys := make([]QueryProfile, len(p.querys))
+	copy(querys, p.querys)
+	return querys
+}
+
+// Operator returns the stats of the operator, nil if it's never executed.
+func (p *Profile) Operator(key interface{}) *OperatorProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if op, ok := p.operators[key]; ok {
+		clone := *op
+		return &clone
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xcontext
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile(t *testing.T) {
+	key := &QueryTuple{}
+	profile := NewProfile()
+	profile.AddQuery(QueryProfile{Query: "select 1", Backend: "b1", Rows: 1})
+	profile.AddOperator(key, time.Now(), 2)
+	profile.AddOperator(key, time.Now(), 3)
+
+	querys := profile.Querys()
+	assert.Equal(t, 1, len(querys))
+	assert.Equal(t, "b1", querys[0].Backend)
+
+	op := profile.Operator(key)
+	assert.Equal(t, 5, op.Rows)
+	assert.Equal(t, 2, op.Loops)
+	assert.Nil(t, profile.Operator(&QueryTuple{}))
+
+	// Nil profile.
+	var nilProfile *Profile
+	nilProfile.AddQuery(QueryProfile{})
+	nilProfile.AddOperator(key, time.Now(), 1)
+	nilProfile.SetSpan(nil)
+	assert.Nil(t, nilProfile.Span())
+	child := (&ResultContext{Profile: profile}).Child()
+	assert.Equal(t, profile, child.Profile)
+}
+
+func TestProfileOperatorName(t *testing.T) {
+	assert.Equal(t, "querytuple", operatorName(&QueryTuple{}))
+	assert.Equal(t, "resultcontext", operatorName(ResultContext{}))
+	assert.Equal(t, "int", operatorName(1))
+}
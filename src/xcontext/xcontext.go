@@ -9,6 +9,7 @@
 package xcontext
 
 import (
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
@@ -43,6 +44,10 @@ const (
 // ResultContext tuple.
 type ResultContext struct {
 	Results *sqltypes.Result
+	// Profile collects the execution stats if not nil.
+	Profile *Profile
+	// BindVars are the literals of the statement if the plan is parameterized.
+	BindVars map[string]*querypb.BindVariable
 }
 
 // NewResultContext returns the result context.
@@ -50,12 +55,19 @@ func NewResultContext() *ResultContext {
 	return &ResultContext{}
 }
 
+// Child returns a new result context which shares the profile and the bind variables with the ctx.
+func (ctx *ResultContext) Child() *ResultContext {
+	return &ResultContext{Profile: ctx.Profile, BindVars: ctx.BindVars}
+}
+
 // RequestContext tuple.
 type RequestContext struct {
 	RawQuery string
 	Mode     RequestMode
 	TxnMode  TxnMode
 	Querys   []QueryTuple
+	// Profile collects the stats of the backend querys if not nil.
+	Profile *Profile
 }
 
 // NewRequestContext creates RequestContext
@@ -74,6 +86,9 @@ type QueryTuple struct {
 
 	// Range info.
 	Range string
+
+	// Tables are the 'db.subtable' the query executes on, used by the audit.
+	Tables []string `json:"-"`
 }
 
 // QueryTuples represents the query tuple slice.
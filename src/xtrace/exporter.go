@@ -0,0 +1,238 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"config"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExporterOTLP enum, the spans are posted to the OTLP/HTTP collector in json.
+	ExporterOTLP = "otlp"
+
+	// ExporterFile enum, the spans are appended to the file in OTLP json lines, used for testing.
+	ExporterFile = "file"
+)
+
+const (
+	exportTimeout = 5 * time.Second
+	scopeName     = "radon"
+)
+
+// Exporter is the destination of the ended spans.
+type Exporter interface {
+	Export(spans []*Span) error
+	Close() error
+}
+
+// The OTLP json encoding of the ExportTraceServiceRequest, see:
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/docs/specification.md#json-protobuf-encoding
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpStatus code: 0 unset, 1 ok, 2 error.
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func otlpValue(v interface{}) otlpAnyValue {
+	var av otlpAnyValue
+	switch v := v.(type) {
+	case string:
+		av.StringValue = &v
+	case bool:
+		av.BoolValue = &v
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		av.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		av.IntValue = &s
+	case uint64:
+		s := strconv.FormatUint(v, 10)
+		av.IntValue = &s
+	case float64:
+		av.DoubleValue = &v
+	default:
+		s := fmt.Sprintf("%v", v)
+		av.StringValue = &s
+	}
+	return av
+}
+
+func otlpKeyValues(attrs []Attribute) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, otlpKeyValue{Key: attr.Key, Value: otlpValue(attr.Value)})
+	}
+	return kvs
+}
+
+// encodeOTLP encodes the spans to the OTLP json.
+func encodeOTLP(service string, spans []*Span) ([]byte, error) {
+	scope := otlpScopeSpans{
+		Scope: otlpScope{Name: scopeName},
+		Spans: make([]otlpSpan, 0, len(spans)),
+	}
+	for _, span := range spans {
+		span.mu.Lock()
+		s := otlpSpan{
+			TraceID:           span.ctx.TraceID.String(),
+			SpanID:            span.ctx.SpanID.String(),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpKeyValues(span.attrs),
+		}
+		if span.parentID != (SpanID{}) {
+			s.ParentSpanID = span.parentID.String()
+		}
+		if span.err != "" {
+			s.Status = otlpStatus{Code: 2, Message: span.err}
+		}
+		span.mu.Unlock()
+		scope.Spans = append(scope.Spans, s)
+	}
+	req := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpKeyValues([]Attribute{{Key: "service.name", Value: service}}),
+				},
+				ScopeSpans: []otlpScopeSpans{scope},
+			},
+		},
+	}
+	return json.Marshal(req)
+}
+
+// otlpExporter posts the spans to the OTLP/HTTP collector, such as: http://127.0.0.1:4318/v1/traces.
+type otlpExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+func (e *otlpExporter) Export(spans []*Span) error {
+	data, err := encodeOTLP(e.service, spans)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("xtrace.otlp.exporter[%s].status.code[%d]", e.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpExporter) Close() error {
+	return nil
+}
+
+// fileExporter appends the spans to the file, one OTLP json request per line.
+type fileExporter struct {
+	file    *os.File
+	service string
+}
+
+func (e *fileExporter) Export(spans []*Span) error {
+	data, err := encodeOTLP(e.service, spans)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	data = append(data, '\n')
+	if _, err := e.file.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (e *fileExporter) Close() error {
+	return e.file.Close()
+}
+
+// newExporter creates the exporter by the config.
+func newExporter(conf *config.TraceConfig) (Exporter, error) {
+	switch conf.Exporter {
+	case ExporterOTLP:
+		if conf.Endpoint == "" {
+			return nil, errors.New("xtrace.otlp.exporter.endpoint.cant.be.empty")
+		}
+		return &otlpExporter{url: conf.Endpoint, service: conf.ServiceName, client: &http.Client{Timeout: exportTimeout}}, nil
+	case ExporterFile:
+		if conf.Endpoint == "" {
+			return nil, errors.New("xtrace.file.exporter.endpoint.cant.be.empty")
+		}
+		file, err := os.OpenFile(conf.Endpoint, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &fileExporter{file: file, service: conf.ServiceName}, nil
+	}
+	return nil, errors.Errorf("xtrace.unsupported.exporter[%s]", conf.Exporter)
+}
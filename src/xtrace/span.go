@@ -0,0 +1,236 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SpanKind is the kind of the span, the values are same as the OTLP.
+type SpanKind int
+
+const (
+	// SpanKindInternal enum, the operation inside the proxy.
+	SpanKindInternal SpanKind = 1
+
+	// SpanKindServer enum, the query from the client.
+	SpanKindServer SpanKind = 2
+
+	// SpanKindClient enum, the query sent to the backend.
+	SpanKindClient SpanKind = 3
+)
+
+const (
+	traceparentKey = "traceparent="
+)
+
+// TraceID is the W3C trace id.
+type TraceID [16]byte
+
+// String returns the id in hex.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID is the W3C span id.
+type SpanID [8]byte
+
+// String returns the id in hex.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+// SpanContext tuple, the W3C trace context.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true if both the trace id and the span id are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent returns the context in the W3C traceparent format.
+func (sc SpanContext) Traceparent() string {
+	flags := 0
+	if sc.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses the W3C traceparent: version-traceid-parentid-flags,
+// such as: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errors.Errorf("xtrace.invalid.traceparent[%s]", s)
+	}
+	// Only the version 00 has exactly four parts.
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, errors.Errorf("xtrace.invalid.traceparent[%s]", s)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, errors.Errorf("xtrace.invalid.traceparent[%s].trace.id", s)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, errors.Errorf("xtrace.invalid.traceparent[%s].parent.id", s)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, errors.Errorf("xtrace.invalid.traceparent[%s].flags", s)
+	}
+	if !sc.IsValid() {
+		return sc, errors.Errorf("xtrace.invalid.traceparent[%s].zero.id", s)
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, nil
+}
+
+// ExtractTraceparent returns the trace context from the comments of the query, such as:
+// /*traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ select * from t1
+func ExtractTraceparent(query string) (SpanContext, bool) {
+	for {
+		i := strings.Index(query, "/*")
+		if i < 0 {
+			return SpanContext{}, false
+		}
+		query = query[i+2:]
+		j := strings.Index(query, "*/")
+		if j < 0 {
+			return SpanContext{}, false
+		}
+		comment := query[:j]
+		query = query[j+2:]
+
+		if k := strings.Index(comment, traceparentKey); k >= 0 {
+			value := strings.Fields(comment[k+len(traceparentKey):])
+			if len(value) == 0 {
+				continue
+			}
+			if sc, err := ParseTraceparent(strings.Trim(value[0], `'",;`)); err == nil {
+				return sc, true
+			}
+		}
+	}
+}
+
+// Attribute tuple, the value is string, bool, int, int64, uint64 or float64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span tuple, one traced operation.
+// All the methods are safe for the nil Span, which means the statement isn't traced.
+type Span struct {
+	mu       sync.Mutex
+	tracer   *Tracer
+	name     string
+	kind     SpanKind
+	ctx      SpanContext
+	parentID SpanID
+	start    time.Time
+	end      time.Time
+	attrs    []Attribute
+	err      string
+	ended    bool
+}
+
+// StartChild starts the child span now.
+func (s *Span) StartChild(name string, kind SpanKind) *Span {
+	return s.StartChildAt(name, kind, time.Now())
+}
+
+// StartChildAt starts the child span at the start time, used for the operations which are timed already.
+func (s *Span) StartChildAt(name string, kind SpanKind, start time.Time) *Span {
+	if s == nil {
+		return nil
+	}
+	return &Span{
+		tracer:   s.tracer,
+		name:     name,
+		kind:     kind,
+		ctx:      SpanContext{TraceID: s.ctx.TraceID, SpanID: newSpanID(), Sampled: true},
+		parentID: s.ctx.SpanID,
+		start:    start,
+	}
+}
+
+// Context returns the span context.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// SetAttribute used to set the attribute of the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, Attribute{Key: key, Value: value})
+}
+
+// SetError used to mark the span failed, nil error is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End used to end the span now.
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt used to end the span at the end time, the span is exported once it's ended.
+func (s *Span) EndAt(end time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = end
+	s.mu.Unlock()
+	s.tracer.export(s)
+}
@@ -0,0 +1,97 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXTraceParseTraceparent(t *testing.T) {
+	{
+		traceparent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+		sc, err := ParseTraceparent(traceparent)
+		assert.Nil(t, err)
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", sc.TraceID.String())
+		assert.Equal(t, "b7ad6b7169203331", sc.SpanID.String())
+		assert.True(t, sc.Sampled)
+		assert.True(t, sc.IsValid())
+		assert.Equal(t, traceparent, sc.Traceparent())
+	}
+
+	// Not sampled.
+	{
+		sc, err := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+		assert.Nil(t, err)
+		assert.False(t, sc.Sampled)
+	}
+
+	// Future version with more parts.
+	{
+		_, err := ParseTraceparent("01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-xx")
+		assert.Nil(t, err)
+	}
+
+	// Errors.
+	{
+		traceparents := []string{
+			"",
+			"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+			"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-xx",
+			"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			"00-0af7651916cd43dd8448eb211c8031-b7ad6b7169203331-01",
+			"00-0af7651916cd43dd8448eb211c80319x-b7ad6b7169203331-01",
+			"00-0af7651916cd43dd8448eb211c80319c-b7ad6b716920333x-01",
+			"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-0x",
+			"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+			"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		}
+		for _, traceparent := range traceparents {
+			_, err := ParseTraceparent(traceparent)
+			assert.NotNil(t, err, traceparent)
+		}
+	}
+}
+
+func TestXTraceExtractTraceparent(t *testing.T) {
+	querys := []struct {
+		query string
+		ok    bool
+	}{
+		{"/*traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ select 1", true},
+		{"select /* traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01' */ 1", true},
+		{"/* app=a */ /* traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01, x=1 */ select 1", true},
+		{"/*traceparent=00-xx*/ /*traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01*/ select 1", true},
+		{"select 1", false},
+		{"select 'traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'", false},
+		{"/*traceparent=*/ select 1", false},
+		{"/*traceparent=00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01 select 1", false},
+	}
+	for _, q := range querys {
+		sc, ok := ExtractTraceparent(q.query)
+		assert.Equal(t, q.ok, ok, q.query)
+		if ok {
+			assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", sc.TraceID.String())
+		}
+	}
+}
+
+func TestXTraceNilSpan(t *testing.T) {
+	var span *Span
+	child := span.StartChild("child", SpanKindInternal)
+	assert.Nil(t, child)
+	child.SetAttribute("k", "v")
+	child.SetError(errors.New("mock"))
+	child.EndAt(time.Now())
+	child.End()
+	assert.False(t, child.Context().IsValid())
+}
@@ -0,0 +1,174 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"config"
+	"xbase"
+
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	defaultBufferSize = 4096
+)
+
+// Tracer tuple, the spans are exported asynchronously.
+// The ended spans are buffered in the bounded queue, they are dropped if the queue is full,
+// so a slow collector never blocks the queries.
+type Tracer struct {
+	log      *xlog.Log
+	conf     *config.TraceConfig
+	mu       sync.RWMutex
+	randMu   sync.Mutex
+	rand     *rand.Rand
+	running  bool
+	exporter Exporter
+	batcher  *xbase.Batcher
+}
+
+// NewTracer creates the new tracer.
+func NewTracer(log *xlog.Log, conf *config.TraceConfig) *Tracer {
+	return &Tracer{
+		log:  log,
+		conf: conf,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Init used to create the exporter and start the export worker if the trace is enabled.
+func (t *Tracer) Init() error {
+	log := t.log
+
+	log.Info("xtrace.init.conf:%+v", t.conf)
+	if !t.conf.Enable {
+		return nil
+	}
+	exporter, err := newExporter(t.conf)
+	if err != nil {
+		return err
+	}
+	bufferSize := t.conf.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	t.mu.Lock()
+	t.exporter = exporter
+	t.batcher = xbase.NewBatcher(log, "xtrace", bufferSize, t.write)
+	t.batcher.Start()
+	t.running = true
+	t.mu.Unlock()
+	log.Info("xtrace.init.done")
+	return nil
+}
+
+// Close used to flush the buffered spans and close the exporter.
+func (t *Tracer) Close() {
+	t.mu.Lock()
+	if !t.running {
+		t.mu.Unlock()
+		return
+	}
+	t.running = false
+	t.mu.Unlock()
+
+	t.batcher.Close()
+	if err := t.exporter.Close(); err != nil {
+		t.log.Error("xtrace.exporter.close.error:%v", err)
+	}
+	t.log.Info("xtrace.closed")
+}
+
+// Enabled returns true if the trace is enabled.
+func (t *Tracer) Enabled() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.running
+}
+
+// Dropped returns the number of the spans dropped since the queue is full.
+func (t *Tracer) Dropped() int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.batcher == nil {
+		return 0
+	}
+	return t.batcher.Dropped()
+}
+
+// StartAt starts the root span of the statement, the parent is the trace context from the client.
+// Returns nil if the trace is disabled or the statement isn't sampled.
+func (t *Tracer) StartAt(name string, parent SpanContext, start time.Time) *Span {
+	if !t.Enabled() {
+		return nil
+	}
+
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   SpanKindServer,
+		start:  start,
+	}
+	if parent.IsValid() {
+		if !parent.Sampled {
+			return nil
+		}
+		span.ctx.TraceID = parent.TraceID
+		span.parentID = parent.SpanID
+	} else {
+		if !t.sample() {
+			return nil
+		}
+		span.ctx.TraceID = newTraceID()
+	}
+	span.ctx.SpanID = newSpanID()
+	span.ctx.Sampled = true
+	return span
+}
+
+func (t *Tracer) sample() bool {
+	ratio := t.conf.SampleRatio
+	if ratio <= 0 {
+		return false
+	}
+	if ratio >= 1 {
+		return true
+	}
+	t.randMu.Lock()
+	defer t.randMu.Unlock()
+	return t.rand.Float64() < ratio
+}
+
+// export used to buffer the ended span without blocking.
+func (t *Tracer) export(span *Span) {
+	if t == nil {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.running {
+		return
+	}
+	t.batcher.Push(span)
+}
+
+// write used to export the batch of the ended spans.
+func (t *Tracer) write(items []interface{}) {
+	spans := make([]*Span, len(items))
+	for i, item := range items {
+		spans[i] = item.(*Span)
+	}
+	if err := t.exporter.Export(spans); err != nil {
+		t.log.Error("xtrace.export[%d].spans.error:%v", len(spans), err)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xtrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func readSpans(t *testing.T, file string) []otlpSpan {
+	f, err := os.Open(file)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	var spans []otlpSpan
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		req := &otlpRequest{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), req))
+		assert.Equal(t, "service.name", req.ResourceSpans[0].Resource.Attributes[0].Key)
+		spans = append(spans, req.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}
+	return spans
+}
+
+func TestXTraceFileExporter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir, err := ioutil.TempDir("", "radon_xtrace_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.Exporter = ExporterFile
+	conf.Endpoint = path.Join(tmpDir, "spans.json")
+	tracer := NewTracer(log, conf)
+	assert.Nil(t, tracer.Init())
+	assert.True(t, tracer.Enabled())
+
+	parent, err := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Nil(t, err)
+	start := time.Now()
+	root := tracer.StartAt("query", parent, start)
+	root.SetAttribute("db.statement", "select 1")
+	root.SetAttribute("radon.rows", 1)
+	root.SetAttribute("radon.rows_affected", uint64(2))
+	root.SetAttribute("radon.cache_hit", true)
+	root.SetAttribute("radon.ratio", 0.5)
+	root.SetAttribute("radon.duration", time.Second)
+	child := root.StartChild("backend.query", SpanKindClient)
+	child.SetError(errors.New("mock.error"))
+	child.End()
+	root.End()
+	// Ended twice.
+	root.End()
+	tracer.Close()
+	assert.False(t, tracer.Enabled())
+
+	spans := readSpans(t, conf.Endpoint)
+	assert.Equal(t, 2, len(spans))
+	{
+		span := spans[0]
+		assert.Equal(t, "backend.query", span.Name)
+		assert.Equal(t, SpanKindClient, span.Kind)
+		assert.Equal(t, root.Context().SpanID.String(), span.ParentSpanID)
+		assert.Equal(t, 2, span.Status.Code)
+		assert.Equal(t, "mock.error", span.Status.Message)
+	}
+	{
+		span := spans[1]
+		assert.Equal(t, "query", span.Name)
+		assert.Equal(t, SpanKindServer, span.Kind)
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.TraceID)
+		assert.Equal(t, "b7ad6b7169203331", span.ParentSpanID)
+		assert.Equal(t, 0, span.Status.Code)
+		assert.Equal(t, 6, len(span.Attributes))
+		assert.Equal(t, "select 1", *span.Attributes[0].Value.StringValue)
+		assert.Equal(t, "1", *span.Attributes[1].Value.IntValue)
+		assert.Equal(t, "2", *span.Attributes[2].Value.IntValue)
+		assert.Equal(t, true, *span.Attributes[3].Value.BoolValue)
+		assert.Equal(t, 0.5, *span.Attributes[4].Value.DoubleValue)
+		assert.Equal(t, "1s", *span.Attributes[5].Value.StringValue)
+	}
+
+	// Spans after closed are ignored.
+	{
+		span := root.StartChild("child", SpanKindInternal)
+		span.End()
+		assert.Nil(t, tracer.StartAt("query", SpanContext{}, time.Now()))
+	}
+}
+
+func TestXTraceOTLPExporter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	var mu sync.Mutex
+	var spans []otlpSpan
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		req := &otlpRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		mu.Lock()
+		spans = append(spans, req.ResourceSpans[0].ScopeSpans[0].Spans...)
+		mu.Unlock()
+	}))
+	defer svr.Close()
+
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.Endpoint = svr.URL + "/v1/traces"
+	conf.SampleRatio = 1
+	tracer := NewTracer(log, conf)
+	assert.Nil(t, tracer.Init())
+
+	for i := 0; i < 10; i++ {
+		span := tracer.StartAt("query", SpanContext{}, time.Now())
+		assert.NotNil(t, span)
+		assert.True(t, span.Context().IsValid())
+		span.End()
+	}
+	tracer.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 10, len(spans))
+	assert.Equal(t, "", spans[0].ParentSpanID)
+	assert.NotEqual(t, spans[0].TraceID, spans[1].TraceID)
+}
+
+func TestXTraceSample(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmpDir, err := ioutil.TempDir("", "radon_xtrace_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	// Disabled.
+	{
+		tracer := NewTracer(log, config.DefaultTraceConfig())
+		assert.Nil(t, tracer.Init())
+		assert.False(t, tracer.Enabled())
+		assert.Nil(t, tracer.StartAt("query", SpanContext{}, time.Now()))
+		tracer.Close()
+	}
+
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.Exporter = ExporterFile
+	conf.Endpoint = path.Join(tmpDir, "spans.json")
+	tracer := NewTracer(log, conf)
+	assert.Nil(t, tracer.Init())
+	defer tracer.Close()
+
+	sampled, _ := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	unsampled, _ := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+
+	// Only the sampled trace context.
+	conf.SampleRatio = 0
+	assert.NotNil(t, tracer.StartAt("query", sampled, time.Now()))
+	assert.Nil(t, tracer.StartAt("query", unsampled, time.Now()))
+	assert.Nil(t, tracer.StartAt("query", SpanContext{}, time.Now()))
+
+	// The parent decides.
+	conf.SampleRatio = 1
+	assert.Nil(t, tracer.StartAt("query", unsampled, time.Now()))
+	assert.NotNil(t, tracer.StartAt("query", SpanContext{}, time.Now()))
+
+	conf.SampleRatio = 0.5
+	n := 0
+	for i := 0; i < 1000; i++ {
+		if tracer.StartAt("query", SpanContext{}, time.Now()) != nil {
+			n++
+		}
+	}
+	assert.True(t, n > 300 && n < 700, n)
+}
+
+// blockExporter blocks the export until the done is closed.
+type blockExporter struct {
+	done chan struct{}
+}
+
+func (e *blockExporter) Export(spans []*Span) error {
+	<-e.done
+	return nil
+}
+
+func (e *blockExporter) Close() error {
+	return nil
+}
+
+func TestXTraceDropped(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultTraceConfig()
+	conf.Enable = true
+	conf.SampleRatio = 1
+	conf.BufferSize = 2
+	tracer := NewTracer(log, conf)
+	assert.Nil(t, tracer.Init())
+
+	exporter := &blockExporter{done: make(chan struct{})}
+	tracer.exporter = exporter
+	for i := 0; i < 10; i++ {
+		tracer.StartAt("query", SpanContext{}, time.Now()).End()
+	}
+	assert.True(t, tracer.Dropped() >= 7, tracer.Dropped())
+	close(exporter.done)
+	tracer.Close()
+}
+
+func TestXTraceExporterError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	confs := []*config.TraceConfig{
+		{Enable: true, Exporter: ExporterOTLP},
+		{Enable: true, Exporter: ExporterFile},
+		{Enable: true, Exporter: ExporterFile, Endpoint: "/proc/radon/spans.json"},
+		{Enable: true, Exporter: "jaeger", Endpoint: "http://127.0.0.1"},
+	}
+	for _, conf := range confs {
+		tracer := NewTracer(log, conf)
+		assert.NotNil(t, tracer.Init())
+		assert.False(t, tracer.Enabled())
+	}
+
+	// Status code error.
+	{
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer svr.Close()
+		exporter, err := newExporter(&config.TraceConfig{Exporter: ExporterOTLP, Endpoint: svr.URL})
+		assert.Nil(t, err)
+		err = exporter.Export(nil)
+		assert.NotNil(t, err)
+	}
+}